@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/oauth2"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// personal access token scopes
+const (
+	ReadFeedScope       = "feed:read"
+	MarkViewedScope     = "feed:mark_viewed"
+	SyncFeedScope       = "feed:sync"
+	ManageSettingsScope = "settings:manage"
+)
+
+// AccessTokenScopes are the scopes a personal access token can be granted
+var AccessTokenScopes = []string{ReadFeedScope, MarkViewedScope, SyncFeedScope, ManageSettingsScope}
+
+const accessTokenPrefix = "cyt_"
+
+// GenerateAccessToken returns a new random personal access token along with the hash to store
+func GenerateAccessToken() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	accessToken := accessTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return accessToken, HashAccessToken(accessToken), nil
+}
+
+// HashAccessToken returns the hash a personal access token is stored with
+func HashAccessToken(accessToken string) string {
+	digest := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(digest[:])
+}
+
+// CheckAccessTokenMiddleware authenticates the request by the personal access token sent as bearer token,
+// checking it grants the given scope. The Google accounts linked to the token owner are stored in the context,
+// the same way CheckTokenMiddleware does, built from their stored refresh tokens
+func CheckAccessTokenMiddleware(next http.Handler, storage database.StorageInterface, scope string) http.HandlerFunc {
+	const funcName = "CheckAccessTokenMiddleware"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get the access token from the Authorization header
+		bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || bearer == "" {
+			unauthorized(w, "missing bearer token", funcName)
+			return
+		}
+
+		accessToken, err := storage.GetAccessTokenByHash(r.Context(), HashAccessToken(bearer))
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve access token: %s", err.Error()), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if accessToken == nil {
+			unauthorized(w, "invalid access token", funcName)
+			return
+		}
+		if accessToken.ExpiresAt != nil && time.Now().After(*accessToken.ExpiresAt) {
+			unauthorized(w, fmt.Sprintf("access token %s is expired", accessToken.Name), funcName)
+			return
+		}
+		if !slices.Contains(accessToken.Scopes, scope) {
+			err = fmt.Errorf("access token %s does not grant the %s scope", accessToken.Name, scope)
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err = storage.UpdateAccessTokenLastUsed(r.Context(), accessToken.Id); err != nil {
+			slog.Warn(fmt.Sprintf("failed to update access token last use: %s", err.Error()),
+				logging.FuncNameAttr(funcName))
+		}
+
+		// load the Google accounts of the token owner from their stored refresh tokens
+		accounts, err := accessTokenAccounts(r.Context(), storage, accessToken)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve linked accounts: %s", err.Error()),
+				logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tokenInfo := &TokenInfo{Username: accessToken.Name, AppUserId: accessToken.UserId, LoginOnly: true}
+		if len(accounts) > 0 {
+			tokenInfo = accounts[0]
+		}
+
+		// add token and linked accounts to context
+		ctx := r.Context()
+		ctx = context.WithValue(ctx, TokenCtxKey{}, tokenInfo)
+		ctx = context.WithValue(ctx, AccountsCtxKey{}, accounts)
+		r = r.WithContext(ctx)
+
+		// serve next handler in the chain
+		next.ServeHTTP(w, r)
+	}
+}
+
+// return the token info of every Google account linked to the owner of the access token having a refresh token
+func accessTokenAccounts(ctx context.Context, storage database.StorageInterface,
+	accessToken *database.AccessToken) ([]*TokenInfo, error) {
+	linkedAccounts, err := storage.GetLinkedAccounts(ctx, accessToken.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*TokenInfo, 0, len(linkedAccounts))
+	for _, account := range linkedAccounts {
+		if account.RefreshToken == "" {
+			continue
+		}
+		accounts = append(accounts, &TokenInfo{
+			Token:     &oauth2.Token{RefreshToken: account.RefreshToken},
+			Username:  account.DisplayName,
+			UserId:    account.AccountId,
+			AppUserId: accessToken.UserId,
+		})
+	}
+
+	return accounts, nil
+}
+
+func unauthorized(w http.ResponseWriter, message, funcName string) {
+	slog.Warn(message, logging.FuncNameAttr(funcName))
+	w.Header().Set("WWW-Authenticate", `Bearer realm="check-youtube"`)
+	http.Error(w, message, http.StatusUnauthorized)
+}
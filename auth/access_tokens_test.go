@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateAccessToken(t *testing.T) {
+	accessToken, tokenHash, err := GenerateAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(accessToken, accessTokenPrefix) {
+		t.Errorf("GenerateAccessToken() token = %v, want prefix %v", accessToken, accessTokenPrefix)
+	}
+	if tokenHash != HashAccessToken(accessToken) || tokenHash == accessToken {
+		t.Errorf("GenerateAccessToken() hash = %v does not match the token", tokenHash)
+	}
+	if other, _, _ := GenerateAccessToken(); other == accessToken {
+		t.Errorf("GenerateAccessToken() generated the same token twice")
+	}
+}
+
+func TestCheckAccessTokenMiddleware(t *testing.T) {
+	// mocks
+	const validToken = "cyt_valid"
+	expired := time.Now().Add(-time.Hour)
+	storedTokens := map[string]*database.AccessToken{
+		HashAccessToken(validToken): {Id: 1, UserId: 1, Name: "cron", Scopes: []string{ReadFeedScope}},
+		HashAccessToken("cyt_expired"): {Id: 2, UserId: 1, Name: "old", Scopes: []string{ReadFeedScope},
+			ExpiresAt: &expired},
+		HashAccessToken("cyt_failing"): {Id: 3, UserId: 2, Name: "failing", Scopes: []string{ReadFeedScope}},
+	}
+	storage := &test.StorageMock{
+		GetAccessTokenByHashStub: func(_ context.Context, tokenHash string) (*database.AccessToken, error) {
+			return storedTokens[tokenHash], nil
+		},
+		UpdateAccessTokenLastUsedStub: func(_ context.Context, id int64) error {
+			return nil
+		},
+		GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+			if userId == 2 {
+				return nil, fmt.Errorf("test error")
+			}
+			return []database.LinkedAccount{
+				{AccountId: "1", DisplayName: "usertest", RefreshToken: "refreshToken1"},
+				{AccountId: "2", DisplayName: "noRefreshToken"},
+			}, nil
+		},
+	}
+	var gotTokenInfo *TokenInfo
+	var gotAccounts []*TokenInfo
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTokenInfo, _ = r.Context().Value(TokenCtxKey{}).(*TokenInfo)
+		gotAccounts, _ = r.Context().Value(AccountsCtxKey{}).([]*TokenInfo)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		scope         string
+		want          int
+	}{
+		{
+			name:          "success case",
+			authorization: "Bearer " + validToken,
+			scope:         ReadFeedScope,
+			want:          http.StatusOK,
+		},
+		{
+			name:          "error case - missing bearer token",
+			authorization: "",
+			scope:         ReadFeedScope,
+			want:          http.StatusUnauthorized,
+		},
+		{
+			name:          "error case - unknown token",
+			authorization: "Bearer cyt_unknown",
+			scope:         ReadFeedScope,
+			want:          http.StatusUnauthorized,
+		},
+		{
+			name:          "error case - expired token",
+			authorization: "Bearer cyt_expired",
+			scope:         ReadFeedScope,
+			want:          http.StatusUnauthorized,
+		},
+		{
+			name:          "error case - scope not granted",
+			authorization: "Bearer " + validToken,
+			scope:         ManageSettingsScope,
+			want:          http.StatusForbidden,
+		},
+		{
+			name:          "error case - storage error",
+			authorization: "Bearer cyt_failing",
+			scope:         ReadFeedScope,
+			want:          http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTokenInfo, gotAccounts = nil, nil
+			req, err := http.NewRequest(http.MethodGet, "/api/v1/feed", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := CheckAccessTokenMiddleware(next, storage, tt.scope)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("CheckAccessTokenMiddleware() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if gotTokenInfo == nil || gotTokenInfo.UserId != "1" || gotTokenInfo.Token.RefreshToken != "refreshToken1" {
+				t.Errorf("CheckAccessTokenMiddleware() token info = %v", gotTokenInfo)
+			}
+			if len(gotAccounts) != 1 {
+				t.Errorf("CheckAccessTokenMiddleware() accounts = %v, want 1 account", len(gotAccounts))
+			}
+		})
+	}
+}
@@ -7,16 +7,18 @@ import (
 	"checkYoutube/logging"
 	sessionsutils "checkYoutube/sessions"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	errors2 "errors"
 	"fmt"
 	"github.com/gorilla/sessions"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/people/v1"
-	"google.golang.org/api/youtube/v3"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"unicode/utf8"
 )
 
 // Oauth2Config embeds the interface that wraps an oauth2.Config
@@ -26,19 +28,33 @@ type Oauth2Config struct {
 
 // TokenInfo contains the oauth2 token and additional user information
 type TokenInfo struct {
-	Token    *oauth2.Token
-	Username string
-	UserId   string
+	Token     *oauth2.Token
+	Username  string
+	UserId    string
+	AppUserId int64
+	// LoginOnly is set when the user logged in with a login-only provider, whose token can't access YouTube
+	LoginOnly bool
+	// WriteAccess is set when the token grants the write scopes, allowing the user to change its YouTube data
+	WriteAccess bool
 }
 
 type verifierCtxKey struct{}
 
 func (verifierCtxKey) String() string { return "verifier" }
 
+type nonceCtxKey struct{}
+
+func (nonceCtxKey) String() string { return "nonce" }
+
 type TokenCtxKey struct{}
 
 func (TokenCtxKey) String() string { return "tokenInfo" }
 
+// AccountsCtxKey is the context key of the Google accounts linked to the logged user, the session one first
+type AccountsCtxKey struct{}
+
+func (AccountsCtxKey) String() string { return "linkedAccounts" }
+
 const (
 	selectAccountPrompt = "select_account"
 	consentPrompt       = "consent"
@@ -46,17 +62,29 @@ const (
 	falseStr            = "false"
 )
 
-// CreateOauth2Config creates a new Oauth2Config instance
-func CreateOauth2Config(clientID, clientSecret, redirectURL string) Oauth2Config {
+// max lengths of the login history columns
+const (
+	maxUserAgentLength   = 512
+	maxLoginReasonLength = 255
+)
+
+// CreateOauth2Config creates a new Oauth2Config instance from the given provider config
+func CreateOauth2Config(providerConfig ProviderConfig) Oauth2Config {
 	return Oauth2Config{
 		&oauth2ConfigInstance{
 			oauth2Config: oauth2.Config{
-				ClientID:     clientID,
-				ClientSecret: clientSecret,
-				Endpoint:     google.Endpoint,
-				RedirectURL:  redirectURL,
-				Scopes:       []string{youtube.YoutubeScope, people.UserinfoProfileScope},
+				ClientID:     providerConfig.ClientID,
+				ClientSecret: providerConfig.ClientSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  providerConfig.AuthURL,
+					TokenURL: providerConfig.TokenURL,
+				},
+				RedirectURL: providerConfig.RedirectURL,
+				Scopes:      providerConfig.Scopes,
 			},
+			writeScopes: providerConfig.WriteScopes,
+			prompts:     providerConfig.Prompts,
+			authParams:  providerConfig.AuthParams,
 		},
 	}
 }
@@ -69,41 +97,104 @@ func Login(oauth2C Oauth2Config, sessionStore *sessions.CookieStore) http.Handle
 		promptAccountSelect := selectAccountParam == trueStr || selectAccountParam == ""
 		promptConsent := r.URL.Query().Get(consentPrompt) == trueStr
 
-		// add and retrieve session
-		session, err := sessionStore.Get(r, sessionsutils.Oauth2SessionName)
-		if err != nil {
-			err = errors.GetSessionErr{Err: err}
-			slog.Error(err.Error(), logging.FuncNameAttr(funcName))
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		startAuthFlow(w, r, oauth2C, sessionStore, false, "", func(verifier, nonce string) string {
+			return oauth2C.GenerateAuthURL("state", verifier, nonce, promptAccountSelect, promptConsent)
+		}, funcName)
+	}
+}
 
-		// generate and store oauth code verifier
-		verifier := oauth2C.GenerateVerifier()
-		session.Values[sessionsutils.VerifierKey] = verifier
+// LinkAccount redirects the logged user to select a Google account to link to its app user
+func LinkAccount(oauth2C Oauth2Config, sessionStore *sessions.CookieStore) http.HandlerFunc {
+	const funcName = "LinkAccount"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// always prompt for consent, in order to get back a refresh token for the linked account
+		startAuthFlow(w, r, oauth2C, sessionStore, true, "", func(verifier, nonce string) string {
+			return oauth2C.GenerateAuthURL("state", verifier, nonce, true, true)
+		}, funcName)
+	}
+}
 
-		// set session cookie in the response
-		session.Options.HttpOnly = true
-		err = session.Save(r, w)
-		if err != nil {
-			err = errors.SaveSessionErr{Err: err}
+// GrantWriteAccess asks the logged user for consent to change its YouTube data, e.g. its subscriptions, sending it
+// back to the path given by the return_to query parameter once granted
+func GrantWriteAccess(oauth2C Oauth2Config, sessionStore *sessions.CookieStore) http.HandlerFunc {
+	const funcName = "GrantWriteAccess"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(TokenCtxKey{}).(*TokenInfo)
+		if !tokenOk {
+			err := errors.ValueNotFoundInCtx{Key: TokenCtxKey{}}
 			slog.Error(err.Error(), logging.FuncNameAttr(funcName))
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if tokenInfo.LoginOnly {
+			err := fmt.Errorf("users logged in with a login-only provider can't change their YouTube data")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		// redirect to the Google's auth url
-		authUrl := oauth2C.GenerateAuthURL("state", verifier, promptAccountSelect, promptConsent)
-		http.Redirect(w, r, authUrl, http.StatusTemporaryRedirect)
+		returnTo := r.URL.Query().Get(sessionsutils.ReturnToKey)
+		if !LocalPath(returnTo) {
+			returnTo = ""
+		}
+		startAuthFlow(w, r, oauth2C, sessionStore, false, returnTo, func(verifier, nonce string) string {
+			return oauth2C.GenerateWriteAuthURL("state", verifier, nonce)
+		}, funcName)
 	}
 }
 
-// Oauth2Redirect oauth2 redirect landing endpoint
-func Oauth2Redirect(oauth2C Oauth2Config, sessionStore *sessions.CookieStore, storage database.StorageInterface,
-	pcf clients.PeopleClientFactoryInterface, serverBasepath string) http.HandlerFunc {
+// store a new oauth code verifier in the session, along with the path to go back to after the login if any, and
+// redirect the user to the auth url built from the verifier and the ID token nonce
+func startAuthFlow(w http.ResponseWriter, r *http.Request, oauth2C Oauth2Config, sessionStore *sessions.CookieStore,
+	linkAccount bool, returnTo string, authURL func(verifier, nonce string) string, funcName string) {
+	// add and retrieve session
+	session, err := sessionStore.Get(r, sessionsutils.Oauth2SessionName)
+	if err != nil {
+		err = errors.GetSessionErr{Err: err}
+		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// generate and store oauth code verifier and ID token nonce
+	verifier := oauth2C.GenerateVerifier()
+	nonce, err := generateNonce()
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to generate nonce: %s", err.Error()), logging.FuncNameAttr(funcName))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	session.Values[sessionsutils.VerifierKey] = verifier
+	session.Values[sessionsutils.NonceKey] = nonce
+	session.Values[sessionsutils.LinkAccountKey] = linkAccount
+	if returnTo != "" {
+		session.Values[sessionsutils.ReturnToKey] = returnTo
+	} else {
+		delete(session.Values, sessionsutils.ReturnToKey)
+	}
+
+	// set session cookie in the response
+	session.Options.HttpOnly = true
+	err = session.Save(r, w)
+	if err != nil {
+		err = errors.SaveSessionErr{Err: err}
+		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// redirect to the Google's auth url
+	http.Redirect(w, r, authURL(verifier, nonce), http.StatusTemporaryRedirect)
+}
+
+// Oauth2Redirect oauth2 redirect landing endpoint. Users log in with the login provider, while the accounts
+// linked to them are authorized with the data provider: the two are the same unless a company SSO is configured
+func Oauth2Redirect(loginProvider, dataProvider *Provider, sessionStore *sessions.CookieStore,
+	storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
 	const funcName = "Oauth2Redirect"
 	return func(w http.ResponseWriter, r *http.Request) {
-		// retrieve verifier from context
+		// retrieve verifier and nonce from context
 		verifier, verifierOk := r.Context().Value(verifierCtxKey{}).(string)
 		if !verifierOk {
 			err := errors.ValueNotFoundInCtx{Key: verifierCtxKey{}}
@@ -111,6 +202,13 @@ func Oauth2Redirect(oauth2C Oauth2Config, sessionStore *sessions.CookieStore, st
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		nonce, nonceOk := r.Context().Value(nonceCtxKey{}).(string)
+		if !nonceOk {
+			err := errors.ValueNotFoundInCtx{Key: nonceCtxKey{}}
+			slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		// get code from request URL
 		code := r.URL.Query().Get("code")
@@ -123,30 +221,94 @@ func Oauth2Redirect(oauth2C Oauth2Config, sessionStore *sessions.CookieStore, st
 			return
 		}
 
+		// the verifier and the nonce are used once, so that the callback can't be replayed whatever its outcome
+		delete(session.Values, sessionsutils.VerifierKey)
+		delete(session.Values, sessionsutils.NonceKey)
+		err = session.Save(r, w)
+		if err != nil {
+			err = errors.SaveSessionErr{Err: err}
+			slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// an account is being linked to the app user already logged in
+		linkAccount, _ := session.Values[sessionsutils.LinkAccountKey].(bool)
+		sessionTokenInfo, _ := session.Values[sessionsutils.TokenKey].(*TokenInfo)
+		linking := linkAccount && sessionTokenInfo != nil && sessionTokenInfo.AppUserId != 0
+		provider := loginProvider
+		if linking {
+			provider = dataProvider
+		}
+
+		// failed logins are recorded in the login history, while failures linking an account are not logins
+		recordFailure := func(subject string, err error) {
+			if !linking {
+				recordLoginEvent(r, storage, database.LoginEvent{Provider: provider.Name, Subject: subject,
+					Outcome: database.LoginFailed, Reason: err.Error()})
+			}
+		}
+
 		// exchange code with token
-		token, err := oauth2C.ExchangeCodeWithToken(r.Context(), code, oauth2.VerifierOption(verifier))
+		token, err := provider.Oauth2C.ExchangeCodeWithToken(r.Context(), code, oauth2.VerifierOption(verifier))
 		if err != nil {
 			slog.Error(fmt.Sprintf("failed to retrieve auth token, error: %s", err.Error()),
 				logging.FuncNameAttr(funcName))
+			recordFailure("", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// use people service to get username of the logged user
-		peopleSvc, err := pcf.NewClient(oauth2C.CreateTokenSource(r.Context(), token))
+		// verify the ID token, its subject is the stable identifier of the user
+		var claims *IDTokenClaims
+		if provider.IDTokenVerifier != nil {
+			rawIDToken, idTokenOk := token.Extra(idTokenExtraKey).(string)
+			if !idTokenOk || rawIDToken == "" {
+				err = fmt.Errorf("ID token not found in the token response")
+				slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+				recordFailure("", err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			claims, err = provider.IDTokenVerifier.Verify(r.Context(), rawIDToken, nonce)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to verify ID token: %s", err.Error()), logging.FuncNameAttr(funcName))
+				recordFailure("", err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		// get the info of the logged user
+		userinfo, err := provider.Userinfo.GetUserinfo(r.Context(), token, claims)
 		if err != nil {
-			slog.Error(fmt.Sprintf("unable to create people service: %s",
-				err.Error()), logging.FuncNameAttr(funcName))
+			slog.Error(fmt.Sprintf("unable to retrieve logged user info: %s", err.Error()),
+				logging.FuncNameAttr(funcName))
+			recordFailure(claimsSubject(claims), err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		userinfo := peopleSvc.GetLoggedUserinfo()
-		userId := userinfo.Id
 		username := userinfo.DisplayName
+		subject := userinfo.Id
+		if claims != nil {
+			subject = claims.Subject
+		}
+		if subject == "" {
+			err = fmt.Errorf("unable to identify the logged user")
+			slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+			recordFailure("", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		userId := subject
+		if provider.LoginOnly {
+			// login identities must not collide with the ids of the linked accounts
+			userId = loginIdentityUserId(provider.Name, subject)
+		}
 
 		// store refresh token into the database
 		if token.RefreshToken != "" {
-			err = storage.UpsertRefreshToken(userId, token.RefreshToken)
+			err = storage.UpsertRefreshToken(r.Context(), userId, token.RefreshToken)
 			if err != nil {
 				slog.Error(fmt.Sprintf("failed to store refresh token into db: %s", err.Error()),
 					logging.FuncNameAttr(funcName), logging.UserAttr(username))
@@ -156,11 +318,59 @@ func Oauth2Redirect(oauth2C Oauth2Config, sessionStore *sessions.CookieStore, st
 			slog.Info("user's refresh token updated", logging.FuncNameAttr(funcName), logging.UserAttr(username))
 		}
 
+		// link the account to the app user already logged in, keeping the session token untouched
+		if linking {
+			err = storage.LinkAccount(r.Context(), sessionTokenInfo.AppUserId, userId, username)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to link account: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(sessionTokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			delete(session.Values, sessionsutils.LinkAccountKey)
+			err = session.Save(r, w)
+			if err != nil {
+				err = errors.SaveSessionErr{Err: err}
+				slog.Error(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(sessionTokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("account %s linked", username), logging.FuncNameAttr(funcName),
+				logging.UserAttr(sessionTokenInfo.Username))
+			http.Redirect(w, r, fmt.Sprintf("%s/settings", serverBasepath), http.StatusSeeOther)
+			return
+		}
+
+		// retrieve the app user the account or the login identity belongs to, creating it on first login
+		var appUserId int64
+		if provider.LoginOnly {
+			appUserId, err = resolveLoginIdentityUserId(r.Context(), storage, provider.Name, subject, username)
+		} else {
+			appUserId, err = resolveAppUserId(r.Context(), storage, userId, username)
+		}
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve app user: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(username))
+			recordFailure(subject, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// store the profile of the user, along with the login in the login history
+		recordLogin(r, storage, provider.Name, subject, appUserId, userinfo)
+
 		// store token and user info in session
+		returnTo, _ := session.Values[sessionsutils.ReturnToKey].(string)
+		delete(session.Values, sessionsutils.LinkAccountKey)
+		delete(session.Values, sessionsutils.ReturnToKey)
 		session.Values[sessionsutils.TokenKey] = &TokenInfo{
-			Token:    token,
-			Username: username,
-			UserId:   userId,
+			Token:       token,
+			Username:    username,
+			UserId:      userId,
+			AppUserId:   appUserId,
+			LoginOnly:   provider.LoginOnly,
+			WriteAccess: provider.grantsWriteAccess(token),
 		}
 
 		// save session
@@ -172,9 +382,12 @@ func Oauth2Redirect(oauth2C Oauth2Config, sessionStore *sessions.CookieStore, st
 			return
 		}
 
-		// redirect to YouTube check endpoint
+		// redirect to YouTube check endpoint, or back to the page that asked for more scopes
 		slog.Info("user successfully authenticated", logging.FuncNameAttr(funcName), logging.UserAttr(username))
-		http.Redirect(w, r, fmt.Sprintf("%s/check-youtube?filtered=true", serverBasepath), http.StatusSeeOther)
+		if !LocalPath(returnTo) {
+			returnTo = "/check-youtube"
+		}
+		http.Redirect(w, r, serverBasepath+returnTo, http.StatusSeeOther)
 	}
 }
 
@@ -182,7 +395,7 @@ func Oauth2Redirect(oauth2C Oauth2Config, sessionStore *sessions.CookieStore, st
 func SwitchAccount(oauth2C Oauth2Config) http.HandlerFunc {
 	const funcName = "SwitchAccount"
 	return func(w http.ResponseWriter, r *http.Request) {
-		// retrieve verifier from context
+		// retrieve verifier and nonce from context
 		verifier, verifierOk := r.Context().Value(verifierCtxKey{}).(string)
 		if !verifierOk {
 			err := errors.ValueNotFoundInCtx{Key: verifierCtxKey{}}
@@ -190,20 +403,32 @@ func SwitchAccount(oauth2C Oauth2Config) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		nonce, nonceOk := r.Context().Value(nonceCtxKey{}).(string)
+		if !nonceOk {
+			err := errors.ValueNotFoundInCtx{Key: nonceCtxKey{}}
+			slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		// redirect to the Google's auth url
-		authUrl := oauth2C.GenerateAuthURL("state", verifier, true, false)
+		authUrl := oauth2C.GenerateAuthURL("state", verifier, nonce, true, false)
 		http.Redirect(w, r, authUrl, http.StatusTemporaryRedirect)
 	}
 }
 
-// CheckVerifierMiddleware redirects the user if the oauth2 verifier is not found in the session
+// CheckVerifierMiddleware redirects the user if the oauth2 verifier or the ID token nonce is not found in the session
 func CheckVerifierMiddleware(next http.Handler, sessionStore *sessions.CookieStore, serverBasepath string) http.HandlerFunc {
 	const funcName = "CheckVerifierMiddleware"
 	return func(w http.ResponseWriter, r *http.Request) {
-		// get verifier from session
+		// get verifier and nonce from session
 		verifier, err := sessionsutils.GetValueFromSession[string](sessionStore, r,
 			sessionsutils.Oauth2SessionName, sessionsutils.VerifierKey)
+		var nonce string
+		if err == nil {
+			nonce, err = sessionsutils.GetValueFromSession[string](sessionStore, r,
+				sessionsutils.Oauth2SessionName, sessionsutils.NonceKey)
+		}
 		if err != nil {
 			if errors2.As(err, &errors.GetSessionErr{}) {
 				slog.Error(err.Error(), logging.FuncNameAttr(funcName))
@@ -216,9 +441,10 @@ func CheckVerifierMiddleware(next http.Handler, sessionStore *sessions.CookieSto
 			return
 		}
 
-		// add verifier to context
+		// add verifier and nonce to context
 		ctx := r.Context()
 		ctx = context.WithValue(ctx, verifierCtxKey{}, verifier)
+		ctx = context.WithValue(ctx, nonceCtxKey{}, nonce)
 		r = r.WithContext(ctx)
 
 		// serve next handler in the chain
@@ -257,7 +483,7 @@ func CheckTokenMiddleware(next http.Handler, oauth2C Oauth2Config, storage datab
 				// retrieve refresh token from db for session user
 				slog.Info("trying to retrieve refresh token from db",
 					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
-				refreshToken, err = storage.GetRefreshTokenByUserId(tokenInfo.UserId)
+				refreshToken, err = storage.GetRefreshTokenByUserId(r.Context(), tokenInfo.UserId)
 				if err != nil {
 					slog.Error(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
 					http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -278,6 +504,13 @@ func CheckTokenMiddleware(next http.Handler, oauth2C Oauth2Config, storage datab
 			} else {
 				err = fmt.Errorf("token not found in session")
 			}
+			// the token refresh is canceled along with the request, the user is not asked to log in again
+			if ctxErr := r.Context().Err(); err != nil && ctxErr != nil {
+				slog.Warn(fmt.Sprintf("token refresh canceled: %s", ctxErr.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, ctxErr.Error(), http.StatusServiceUnavailable)
+				return
+			}
 			if err != nil {
 				slog.Error(fmt.Sprintf("unable to refresh token, redirect to login: %s", err.Error()),
 					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
@@ -301,7 +534,7 @@ func CheckTokenMiddleware(next http.Handler, oauth2C Oauth2Config, storage datab
 				logging.UserAttr(tokenInfo.Username))
 
 			// update refresh token into db
-			err = storage.UpsertRefreshToken(tokenInfo.UserId, tokenInfo.Token.RefreshToken)
+			err = storage.UpsertRefreshToken(r.Context(), tokenInfo.UserId, tokenInfo.Token.RefreshToken)
 			if err != nil {
 				slog.Error(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
 				http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -309,29 +542,202 @@ func CheckTokenMiddleware(next http.Handler, oauth2C Oauth2Config, storage datab
 			}
 
 			// update tokenInfo in session with refreshed token
-			session, err := sessionStore.Get(r, sessionsutils.Oauth2SessionName)
+			err = saveTokenInfo(w, r, sessionStore, tokenInfo)
 			if err != nil {
-				err = errors.GetSessionErr{Err: err}
 				slog.Error(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			session.Values[sessionsutils.TokenKey] = tokenInfo
-			err = session.Save(r, w)
+		}
+
+		// resolve the app user of sessions created before accounts linking was available
+		if tokenInfo.AppUserId == 0 && !tokenInfo.LoginOnly {
+			tokenInfo.AppUserId, err = resolveAppUserId(r.Context(), storage, tokenInfo.UserId, tokenInfo.Username)
+			if err == nil {
+				err = saveTokenInfo(w, r, sessionStore, tokenInfo)
+			}
 			if err != nil {
-				err = errors.SaveSessionErr{Err: err}
 				slog.Error(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
 
-		// add token to context
+		// retrieve the other Google accounts linked to the user
+		accounts, err := linkedAccountsTokenInfo(r.Context(), storage, tokenInfo)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve linked accounts: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// add token and linked accounts to context
 		ctx := r.Context()
 		ctx = context.WithValue(ctx, TokenCtxKey{}, tokenInfo)
+		ctx = context.WithValue(ctx, AccountsCtxKey{}, accounts)
 		r = r.WithContext(ctx)
 
 		// serve next handler in the chain
 		next.ServeHTTP(w, r)
 	}
 }
+
+// return the id of the app user the Google account is linked to, creating a new user if the account is not linked
+func resolveAppUserId(ctx context.Context, storage database.StorageInterface, accountId,
+	displayName string) (int64, error) {
+	var appUserId int64
+	err := storage.WithTx(ctx, func(tx database.StorageInterface) error {
+		var err error
+		if appUserId, err = tx.GetUserIdByAccountId(ctx, accountId); err != nil || appUserId != 0 {
+			return err
+		}
+		appUserId, err = tx.CreateUserWithAccount(ctx, accountId, displayName)
+		return err
+	})
+	return appUserId, err
+}
+
+// return the id of the app user having the given login identity, creating a new user on first login
+func resolveLoginIdentityUserId(ctx context.Context, storage database.StorageInterface, provider, subject,
+	displayName string) (int64, error) {
+	var appUserId int64
+	err := storage.WithTx(ctx, func(tx database.StorageInterface) error {
+		var err error
+		if appUserId, err = tx.GetUserIdByLoginIdentity(ctx, provider, subject); err != nil || appUserId != 0 {
+			return err
+		}
+		appUserId, err = tx.CreateUserWithLoginIdentity(ctx, provider, subject, displayName)
+		return err
+	})
+	return appUserId, err
+}
+
+// return the subject of the ID token claims, empty if the provider does not issue ID tokens
+func claimsSubject(claims *IDTokenClaims) string {
+	if claims == nil {
+		return ""
+	}
+	return claims.Subject
+}
+
+// store the profile of the logged user and record the successful login. Failing to do so is only logged,
+// not to prevent the user from logging in
+func recordLogin(r *http.Request, storage database.StorageInterface, provider, subject string, appUserId int64,
+	userinfo clients.Userinfo) {
+	const funcName = "recordLogin"
+
+	err := storage.WithTx(r.Context(), func(tx database.StorageInterface) error {
+		err := tx.RecordUserLogin(r.Context(), database.UserProfile{
+			UserId:      appUserId,
+			DisplayName: userinfo.DisplayName,
+			AvatarURL:   userinfo.AvatarURL,
+			Locale:      userinfo.Locale,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.AddLoginEvent(r.Context(), loginEvent(r, database.LoginEvent{UserId: appUserId, Provider: provider,
+			Subject: subject, Outcome: database.LoginSucceeded}))
+	})
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to record login: %s", err.Error()), logging.FuncNameAttr(funcName),
+			logging.UserAttr(userinfo.DisplayName))
+	}
+}
+
+// record a login attempt in the login history. Failing to do so is only logged
+func recordLoginEvent(r *http.Request, storage database.StorageInterface, event database.LoginEvent) {
+	const funcName = "recordLoginEvent"
+
+	if err := storage.AddLoginEvent(r.Context(), loginEvent(r, event)); err != nil {
+		slog.Warn(fmt.Sprintf("failed to record login attempt: %s", err.Error()), logging.FuncNameAttr(funcName))
+	}
+}
+
+// return the login event completed with the client address and user agent of the request
+func loginEvent(r *http.Request, event database.LoginEvent) database.LoginEvent {
+	event.IPAddress = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		event.IPAddress = host
+	}
+	event.UserAgent = truncate(r.UserAgent(), maxUserAgentLength)
+	event.Reason = truncate(event.Reason, maxLoginReasonLength)
+	return event
+}
+
+// return the string cut to the given number of bytes, not splitting multibyte characters
+func truncate(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	for maxLength > 0 && !utf8.RuneStart(s[maxLength]) {
+		maxLength--
+	}
+	return s[:maxLength]
+}
+
+// return the id the refresh token of a login identity is stored with
+func loginIdentityUserId(provider, subject string) string {
+	return fmt.Sprintf("%s:%s", provider, subject)
+}
+
+// return the token info of every Google account linked to the user of the session, the session one first.
+// Linked accounts only carry their stored refresh token, that is exchanged for a new token when first used.
+// The session token is left out when it was issued by a login-only provider
+func linkedAccountsTokenInfo(ctx context.Context, storage database.StorageInterface,
+	tokenInfo *TokenInfo) ([]*TokenInfo, error) {
+	linkedAccounts, err := storage.GetLinkedAccounts(ctx, tokenInfo.AppUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*TokenInfo, 0, len(linkedAccounts)+1)
+	if !tokenInfo.LoginOnly {
+		accounts = append(accounts, tokenInfo)
+	}
+	for _, account := range linkedAccounts {
+		if account.AccountId == tokenInfo.UserId || account.RefreshToken == "" {
+			continue
+		}
+		accounts = append(accounts, &TokenInfo{
+			Token:     &oauth2.Token{RefreshToken: account.RefreshToken},
+			Username:  account.DisplayName,
+			UserId:    account.AccountId,
+			AppUserId: tokenInfo.AppUserId,
+		})
+	}
+
+	return accounts, nil
+}
+
+// LocalPath tells whether the path is one of this server, the only ones users are sent back to. Paths starting
+// with // or /\ are left out, browsers taking them as URLs of another host
+func LocalPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.HasPrefix(path, "/\\")
+}
+
+// generate a random value binding the ID token to the login attempt
+func generateNonce() (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce), nil
+}
+
+// store the token info in the oauth2 session
+func saveTokenInfo(w http.ResponseWriter, r *http.Request, sessionStore *sessions.CookieStore,
+	tokenInfo *TokenInfo) error {
+	session, err := sessionStore.Get(r, sessionsutils.Oauth2SessionName)
+	if err != nil {
+		return errors.GetSessionErr{Err: err}
+	}
+
+	session.Values[sessionsutils.TokenKey] = tokenInfo
+	if err = session.Save(r, w); err != nil {
+		return errors.SaveSessionErr{Err: err}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"checkYoutube/clients"
+	"checkYoutube/database"
 	sessionsutils "checkYoutube/sessions"
 	"checkYoutube/test"
 	"context"
@@ -12,42 +13,32 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
 
 type peopleClientMock struct {
-	getLoggedUserinfoStub func() clients.Userinfo
+	getLoggedUserinfoStub func() (clients.Userinfo, error)
 }
 type peopleClientFactoryMock struct {
 	newClientStub func(oauth2.TokenSource) (clients.PeopleClientInterface, error)
 }
 
-func (p peopleClientMock) GetLoggedUserinfo() clients.Userinfo {
+func (p peopleClientMock) GetLoggedUserinfo() (clients.Userinfo, error) {
 	return p.getLoggedUserinfoStub()
 }
 func (pf *peopleClientFactoryMock) NewClient(ts oauth2.TokenSource) (clients.PeopleClientInterface, error) {
 	return pf.newClientStub(ts)
 }
 
-type storageMock struct {
-	initStub                    func() error
-	runMigrationsStub           func() error
-	getRefreshTokenByUserIdStub func(userId string) (string, error)
-	upsertRefreshTokenStub      func(userId, refreshToken string) error
+type idTokenVerifierMock struct {
+	verifyStub func(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error)
 }
 
-func (s *storageMock) Init() error {
-	return s.initStub()
-}
-func (s *storageMock) RunMigrations() error {
-	return s.runMigrationsStub()
-}
-func (s *storageMock) GetRefreshTokenByUserId(userId string) (string, error) {
-	return s.getRefreshTokenByUserIdStub(userId)
-}
-func (s *storageMock) UpsertRefreshToken(userId, refreshToken string) error {
-	return s.upsertRefreshTokenStub(userId, refreshToken)
+func (v *idTokenVerifierMock) Verify(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	return v.verifyStub(ctx, rawIDToken, nonce)
 }
 
 func TestMain(m *testing.M) {
@@ -57,9 +48,7 @@ func TestMain(m *testing.M) {
 
 func TestCreateOauth2Config(t *testing.T) {
 	type args struct {
-		clientID     string
-		clientSecret string
-		redirectURL  string
+		providerConfig ProviderConfig
 	}
 	tests := []struct {
 		name string
@@ -68,15 +57,14 @@ func TestCreateOauth2Config(t *testing.T) {
 		{
 			name: "success case",
 			args: args{
-				clientID:     "clientIDTest",
-				clientSecret: "clientSecretTest",
-				redirectURL:  "redirectURLTest",
+				providerConfig: GoogleProviderConfig("clientIDTest", "clientSecretTest", "redirectURLTest",
+					"issuerURLTest", "jwksURLTest"),
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := CreateOauth2Config(tt.args.clientID, tt.args.clientSecret, tt.args.redirectURL)
+			got := CreateOauth2Config(tt.args.providerConfig)
 			if got.Oauth2ConfigProvider == nil {
 				t.Errorf("CreateOauth2Config() - config is nil")
 			}
@@ -123,6 +111,113 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+func TestLinkAccount(t *testing.T) {
+	// mocks
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oauth2C := Oauth2Config{&test.Oauth2Mock{}}
+	sessionStore := sessions.NewCookieStore([]byte(("test")))
+
+	type args struct {
+		oauth2C      Oauth2Config
+		sessionStore *sessions.CookieStore
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "success case",
+			args: args{
+				oauth2C:      oauth2C,
+				sessionStore: sessionStore,
+			},
+			want: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handlerFunction := LinkAccount(tt.args.oauth2C, tt.args.sessionStore)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("LinkAccount() = %v, want %v", recorder.Code, tt.want)
+			}
+			linkAccount, err := sessionsutils.GetValueFromSession[bool](tt.args.sessionStore, req,
+				sessionsutils.Oauth2SessionName, sessionsutils.LinkAccountKey)
+			if err != nil || !linkAccount {
+				t.Errorf("LinkAccount() - link account flag not set in session")
+			}
+		})
+	}
+}
+
+func TestGrantWriteAccess(t *testing.T) {
+	// mocks
+	oauth2C := Oauth2Config{&test.Oauth2Mock{}}
+	sessionStore := sessions.NewCookieStore([]byte(("test")))
+
+	tests := []struct {
+		name         string
+		target       string
+		tokenInfo    *TokenInfo
+		want         int
+		wantReturnTo string
+	}{
+		{
+			name:         "success case",
+			target:       "/grant-write-access?return_to=/subscriptions",
+			tokenInfo:    &TokenInfo{Username: "usertest"},
+			want:         http.StatusTemporaryRedirect,
+			wantReturnTo: "/subscriptions",
+		},
+		{
+			name:      "success case - return path of another server ignored",
+			target:    "/grant-write-access?return_to=//example.com",
+			tokenInfo: &TokenInfo{Username: "usertest"},
+			want:      http.StatusTemporaryRedirect,
+		},
+		{
+			name:      "error case - login-only user",
+			target:    "/grant-write-access",
+			tokenInfo: &TokenInfo{Username: "usertest", LoginOnly: true},
+			want:      http.StatusBadRequest,
+		},
+		{
+			name:   "error case - token not found in context",
+			target: "/grant-write-access",
+			want:   http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.tokenInfo != nil {
+				req = req.WithContext(context.WithValue(req.Context(), TokenCtxKey{}, tt.tokenInfo))
+			}
+			recorder := httptest.NewRecorder()
+			GrantWriteAccess(oauth2C, sessionStore)(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("GrantWriteAccess() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want != http.StatusTemporaryRedirect {
+				return
+			}
+			if location := recorder.Header().Get("Location"); !strings.HasSuffix(location, "mockWriteURL") {
+				t.Errorf("GrantWriteAccess() location = %v, want mockWriteURL", location)
+			}
+			returnTo, _ := sessionsutils.GetValueFromSession[string](sessionStore, req,
+				sessionsutils.Oauth2SessionName, sessionsutils.ReturnToKey)
+			if returnTo != tt.wantReturnTo {
+				t.Errorf("GrantWriteAccess() return path = %v, want %v", returnTo, tt.wantReturnTo)
+			}
+		})
+	}
+}
+
 func TestOauth2Redirect(t *testing.T) {
 	// mocks
 	req, err := http.NewRequest(http.MethodGet, "/", nil)
@@ -131,82 +226,246 @@ func TestOauth2Redirect(t *testing.T) {
 	}
 	oauth2C := Oauth2Config{&test.Oauth2Mock{}}
 	sessionStore := sessions.NewCookieStore([]byte(("test")))
-	const errorCase = "error case - verifier not found"
+	const (
+		errorCase       = "error case - verifier not found"
+		linkAccountCase = "success case - link account"
+	)
 	pcf := &peopleClientFactoryMock{
 		newClientStub: func(ts oauth2.TokenSource) (clients.PeopleClientInterface, error) {
-			return &peopleClientMock{getLoggedUserinfoStub: func() clients.Userinfo {
+			return &peopleClientMock{getLoggedUserinfoStub: func() (clients.Userinfo, error) {
 				return clients.Userinfo{
 					Id:          "1",
 					DisplayName: "usertest",
-				}
+				}, nil
 			}}, nil
 		},
 	}
+	idTokenVerifier := &idTokenVerifierMock{
+		verifyStub: func(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+			return &IDTokenClaims{Subject: "1"}, nil
+		},
+	}
 
 	type args struct {
-		oauth2C        Oauth2Config
-		sessionStore   *sessions.CookieStore
-		pcf            clients.PeopleClientFactoryInterface
-		serverBasepath string
+		oauth2C         Oauth2Config
+		sessionStore    *sessions.CookieStore
+		pcf             clients.PeopleClientFactoryInterface
+		idTokenVerifier IDTokenVerifierInterface
+		loginProvider   *Provider
+		serverBasepath  string
 	}
 	tests := []struct {
-		name string
-		args args
-		want int
+		name         string
+		args         args
+		want         int
+		wantLocation string
+		// wantOutcome is the outcome of the login recorded in the login history, empty if none is recorded
+		wantOutcome string
 	}{
+		{
+			name: "success case - login-only provider",
+			args: args{
+				serverBasepath:  "http://localhost:8900",
+				oauth2C:         oauth2C,
+				sessionStore:    sessionStore,
+				pcf:             pcf,
+				idTokenVerifier: idTokenVerifier,
+				loginProvider: &Provider{
+					Name:            "sso",
+					Oauth2C:         oauth2C,
+					IDTokenVerifier: idTokenVerifier,
+					Userinfo:        &idTokenUserinfoSource{},
+					LoginOnly:       true,
+				},
+			},
+			want:         http.StatusSeeOther,
+			wantLocation: "http://localhost:8900/check-youtube",
+			wantOutcome:  database.LoginSucceeded,
+		},
+		{
+			name: "error case - userinfo endpoint unreachable",
+			args: args{
+				serverBasepath:  "http://localhost:8900",
+				oauth2C:         oauth2C,
+				sessionStore:    sessionStore,
+				pcf:             pcf,
+				idTokenVerifier: idTokenVerifier,
+				loginProvider: &Provider{
+					Name:    "sso",
+					Oauth2C: oauth2C,
+					Userinfo: &endpointUserinfoSource{
+						oauth2C:     oauth2C,
+						userinfoURL: "http://localhost:0/userinfo",
+					},
+					LoginOnly: true,
+				},
+			},
+			want:        http.StatusInternalServerError,
+			wantOutcome: database.LoginFailed,
+		},
 		{
 			name: "success case",
+			args: args{
+				serverBasepath:  "http://localhost:8900",
+				oauth2C:         oauth2C,
+				sessionStore:    sessionStore,
+				pcf:             pcf,
+				idTokenVerifier: idTokenVerifier,
+			},
+			want:         http.StatusSeeOther,
+			wantLocation: "http://localhost:8900/check-youtube",
+			wantOutcome:  database.LoginSucceeded,
+		},
+		{
+			name: "error case - error on creating people client",
 			args: args{
 				serverBasepath: "http://localhost:8900",
 				oauth2C:        oauth2C,
 				sessionStore:   sessionStore,
-				pcf:            pcf,
+				pcf: &peopleClientFactoryMock{
+					newClientStub: func(ts oauth2.TokenSource) (clients.PeopleClientInterface, error) {
+						return nil, fmt.Errorf("testerror")
+					},
+				},
+				idTokenVerifier: idTokenVerifier,
 			},
-			want: http.StatusSeeOther,
+			want:        http.StatusInternalServerError,
+			wantOutcome: database.LoginFailed,
 		},
 		{
-			name: "error case - error on creating people client",
+			name: "error case - error on retrieving user info",
 			args: args{
 				serverBasepath: "http://localhost:8900",
 				oauth2C:        oauth2C,
 				sessionStore:   sessionStore,
 				pcf: &peopleClientFactoryMock{
 					newClientStub: func(ts oauth2.TokenSource) (clients.PeopleClientInterface, error) {
-						return nil, fmt.Errorf("testerror")
+						return &peopleClientMock{getLoggedUserinfoStub: func() (clients.Userinfo, error) {
+							return clients.Userinfo{}, fmt.Errorf("testerror")
+						}}, nil
 					},
 				},
+				idTokenVerifier: idTokenVerifier,
 			},
-			want: http.StatusInternalServerError,
+			want:        http.StatusInternalServerError,
+			wantOutcome: database.LoginFailed,
 		},
 		{
-			name: errorCase,
+			name: "error case - invalid ID token",
 			args: args{
 				serverBasepath: "http://localhost:8900",
 				oauth2C:        oauth2C,
 				sessionStore:   sessionStore,
 				pcf:            pcf,
+				idTokenVerifier: &idTokenVerifierMock{
+					verifyStub: func(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+						return nil, fmt.Errorf("testerror")
+					},
+				},
+			},
+			want:        http.StatusUnauthorized,
+			wantOutcome: database.LoginFailed,
+		},
+		{
+			name: errorCase,
+			args: args{
+				serverBasepath:  "http://localhost:8900",
+				oauth2C:         oauth2C,
+				sessionStore:    sessionStore,
+				pcf:             pcf,
+				idTokenVerifier: idTokenVerifier,
 			},
 			want: http.StatusInternalServerError,
 		},
+		{
+			name: linkAccountCase,
+			args: args{
+				serverBasepath:  "http://localhost:8900",
+				oauth2C:         oauth2C,
+				sessionStore:    sessionStore,
+				pcf:             pcf,
+				idTokenVerifier: idTokenVerifier,
+			},
+			want:         http.StatusSeeOther,
+			wantLocation: "http://localhost:8900/settings",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.name == errorCase {
 				req = req.WithContext(context.Background())
 			} else {
-				req = req.WithContext(addVerifierToContext(req.Context(), "verifier"))
+				req = req.WithContext(addVerifierToContext(req.Context(), "verifier", "nonce"))
+				test.SetOauth2SessionValue(t, sessionStore, req, sessionsutils.Oauth2SessionName,
+					sessionsutils.VerifierKey, "verifier")
+				test.SetOauth2SessionValue(t, sessionStore, req, sessionsutils.Oauth2SessionName,
+					sessionsutils.NonceKey, "nonce")
+			}
+			if tt.name == linkAccountCase {
+				test.SetOauth2SessionValue(t, sessionStore, req, sessionsutils.Oauth2SessionName,
+					sessionsutils.LinkAccountKey, true)
+				test.SetOauth2SessionValue(t, sessionStore, req, sessionsutils.Oauth2SessionName,
+					sessionsutils.TokenKey, &TokenInfo{Token: &oauth2.Token{}, UserId: "2", AppUserId: 1})
 			}
 			recorder := httptest.NewRecorder()
-			storage := &storageMock{
-				upsertRefreshTokenStub: func(userId, refreshToken string) error {
+			var recordedOutcome string
+			storage := &test.StorageMock{
+				UpsertRefreshTokenStub: func(_ context.Context, userId, refreshToken string) error {
+					return nil
+				},
+				GetUserIdByAccountIdStub: func(_ context.Context, accountId string) (int64, error) {
+					return 1, nil
+				},
+				LinkAccountStub: func(_ context.Context, userId int64, accountId, displayName string) error {
+					return nil
+				},
+				GetUserIdByLoginIdentityStub: func(_ context.Context, provider, subject string) (int64, error) {
+					return 0, nil
+				},
+				CreateUserWithLoginIdentityStub: func(_ context.Context, provider, subject, displayName string) (int64, error) {
+					return 1, nil
+				},
+				RecordUserLoginStub: func(_ context.Context, profile database.UserProfile) error {
+					return nil
+				},
+				AddLoginEventStub: func(_ context.Context, event database.LoginEvent) error {
+					recordedOutcome = event.Outcome
 					return nil
 				},
 			}
-			handlerFunction := Oauth2Redirect(oauth2C, tt.args.sessionStore, storage, tt.args.pcf, tt.args.serverBasepath)
+			dataProvider := &Provider{
+				Name:            "google",
+				Oauth2C:         tt.args.oauth2C,
+				IDTokenVerifier: tt.args.idTokenVerifier,
+				Userinfo:        &peopleUserinfoSource{oauth2C: tt.args.oauth2C, pcf: tt.args.pcf},
+			}
+			loginProvider := dataProvider
+			if tt.args.loginProvider != nil {
+				loginProvider = tt.args.loginProvider
+			}
+			handlerFunction := Oauth2Redirect(loginProvider, dataProvider, tt.args.sessionStore, storage,
+				tt.args.serverBasepath)
 			handlerFunction(recorder, req)
 			if recorder.Code != tt.want {
 				t.Errorf("Oauth2Redirect() = %v, want %v", recorder.Code, tt.want)
 			}
+			if location := recorder.Header().Get("Location"); tt.wantLocation != "" && location != tt.wantLocation {
+				t.Errorf("Oauth2Redirect() location = %v, want %v", location, tt.wantLocation)
+			}
+			if recordedOutcome != tt.wantOutcome {
+				t.Errorf("Oauth2Redirect() recorded login outcome = %v, want %v", recordedOutcome, tt.wantOutcome)
+			}
+			if tt.name != errorCase {
+				session, err := sessionStore.Get(req, sessionsutils.Oauth2SessionName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, verifierOk := session.Values[sessionsutils.VerifierKey]
+				_, nonceOk := session.Values[sessionsutils.NonceKey]
+				if verifierOk || nonceOk {
+					t.Errorf("Oauth2Redirect() left the verifier or the nonce in the session: %v", session.Values)
+				}
+			}
 		})
 	}
 }
@@ -244,7 +503,7 @@ func TestSwitchAccount(t *testing.T) { // mocks
 			if tt.name == errorCase {
 				req = req.WithContext(context.Background())
 			} else {
-				req = req.WithContext(addVerifierToContext(req.Context(), "verifier"))
+				req = req.WithContext(addVerifierToContext(req.Context(), "verifier", "nonce"))
 			}
 			recorder := httptest.NewRecorder()
 			handlerFunction := SwitchAccount(tt.args.oauth2C)
@@ -303,6 +562,8 @@ func TestCheckVerifierMiddleware(t *testing.T) {
 			if tt.name == successCase {
 				test.SetOauth2SessionValue(t, tt.args.sessionStore, req,
 					sessionsutils.Oauth2SessionName, sessionsutils.VerifierKey, "verifier")
+				test.SetOauth2SessionValue(t, tt.args.sessionStore, req,
+					sessionsutils.Oauth2SessionName, sessionsutils.NonceKey, "nonce")
 			}
 			handlerFunction := CheckVerifierMiddleware(tt.args.next, tt.args.sessionStore, tt.args.serverBasepath)
 			handlerFunction(recorder, req)
@@ -323,13 +584,19 @@ func TestCheckTokenMiddleware(t *testing.T) {
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 	sessionStore := sessions.NewCookieStore([]byte(("test")))
 	oauth2C := Oauth2Config{&test.Oauth2Mock{}}
-	storage := &storageMock{
-		getRefreshTokenByUserIdStub: func(userId string) (string, error) {
+	storage := &test.StorageMock{
+		GetRefreshTokenByUserIdStub: func(_ context.Context, userId string) (string, error) {
 			return "refreshToken", nil
 		},
-		upsertRefreshTokenStub: func(userId, refreshToken string) error {
+		UpsertRefreshTokenStub: func(_ context.Context, userId, refreshToken string) error {
 			return nil
 		},
+		GetUserIdByAccountIdStub: func(_ context.Context, accountId string) (int64, error) {
+			return 1, nil
+		},
+		GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+			return []database.LinkedAccount{{AccountId: "2", DisplayName: "linked", RefreshToken: "refreshToken"}}, nil
+		},
 	}
 
 	type args struct {
@@ -431,6 +698,167 @@ func TestCheckTokenMiddleware(t *testing.T) {
 	}
 }
 
-func addVerifierToContext(ctx context.Context, value string) context.Context {
-	return context.WithValue(ctx, verifierCtxKey{}, value)
+func TestCheckTokenMiddleware_canceledRefresh(t *testing.T) {
+	// the token endpoint would grant a new token, but the request is canceled first
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"new","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+	oauth2C := CreateOauth2Config(ProviderConfig{ClientID: "clientID", TokenURL: tokenServer.URL})
+	storage := &test.StorageMock{
+		GetRefreshTokenByUserIdStub: func(_ context.Context, userId string) (string, error) {
+			return "refreshToken", nil
+		},
+	}
+	sessionStore := sessions.NewCookieStore([]byte(("test")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.SetOauth2SessionValue[*TokenInfo](t, sessionStore, req, sessionsutils.Oauth2SessionName,
+		sessionsutils.TokenKey, &TokenInfo{Token: &oauth2.Token{AccessToken: "expired",
+			Expiry: time.Now().Add(-time.Hour)}, Username: "usertest", UserId: "1"})
+
+	recorder := httptest.NewRecorder()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("CheckTokenMiddleware() served the next handler of a canceled request")
+	})
+	CheckTokenMiddleware(next, oauth2C, storage, sessionStore, "http://localhost:8900")(recorder, req)
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("CheckTokenMiddleware() = %v, want %v", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func Test_linkedAccountsTokenInfo(t *testing.T) {
+	tokenInfo := &TokenInfo{Token: &oauth2.Token{AccessToken: "test"}, Username: "usertest", UserId: "1", AppUserId: 1}
+
+	type args struct {
+		storage   *test.StorageMock
+		tokenInfo *TokenInfo
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "success case - session account first, accounts without refresh token skipped",
+			args: args{
+				storage: &test.StorageMock{
+					GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+						return []database.LinkedAccount{
+							{AccountId: "1", DisplayName: "usertest", RefreshToken: "refreshToken1"},
+							{AccountId: "2", DisplayName: "linked", RefreshToken: "refreshToken2"},
+							{AccountId: "3", DisplayName: "noRefreshToken"},
+						}, nil
+					},
+				},
+				tokenInfo: tokenInfo,
+			},
+			want:    []string{"1", "2"},
+			wantErr: false,
+		},
+		{
+			name: "success case - login-only session account left out",
+			args: args{
+				storage: &test.StorageMock{
+					GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+						return []database.LinkedAccount{
+							{AccountId: "2", DisplayName: "linked", RefreshToken: "refreshToken2"},
+						}, nil
+					},
+				},
+				tokenInfo: &TokenInfo{Token: &oauth2.Token{AccessToken: "test"}, Username: "usertest",
+					UserId: "sso:1", AppUserId: 1, LoginOnly: true},
+			},
+			want:    []string{"2"},
+			wantErr: false,
+		},
+		{
+			name: "error case",
+			args: args{
+				storage: &test.StorageMock{
+					GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+						return nil, fmt.Errorf("test error")
+					},
+				},
+				tokenInfo: tokenInfo,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := linkedAccountsTokenInfo(context.Background(), tt.args.storage, tt.args.tokenInfo)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("linkedAccountsTokenInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var gotIds []string
+			for _, account := range got {
+				gotIds = append(gotIds, account.UserId)
+			}
+			if !slices.Equal(gotIds, tt.want) {
+				t.Errorf("linkedAccountsTokenInfo() got = %v, want %v", gotIds, tt.want)
+			}
+		})
+	}
+}
+
+func addVerifierToContext(ctx context.Context, verifier, nonce string) context.Context {
+	ctx = context.WithValue(ctx, verifierCtxKey{}, verifier)
+	return context.WithValue(ctx, nonceCtxKey{}, nonce)
+}
+
+func Test_loginEvent(t *testing.T) {
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		userAgent     string
+		reason        string
+		wantIPAddress string
+		wantUserAgent string
+		wantReason    string
+	}{
+		{
+			name:          "success case",
+			remoteAddr:    "192.0.2.1:1234",
+			userAgent:     "test",
+			reason:        "test error",
+			wantIPAddress: "192.0.2.1",
+			wantUserAgent: "test",
+			wantReason:    "test error",
+		},
+		{
+			name:          "success case - IPv6 address, long values truncated",
+			remoteAddr:    "[2001:db8::1]:1234",
+			userAgent:     strings.Repeat("a", maxUserAgentLength+1),
+			reason:        strings.Repeat("è", maxLoginReasonLength),
+			wantIPAddress: "2001:db8::1",
+			wantUserAgent: strings.Repeat("a", maxUserAgentLength),
+			wantReason:    strings.Repeat("è", maxLoginReasonLength/2),
+		},
+		{
+			name:          "success case - address without port",
+			remoteAddr:    "192.0.2.1",
+			wantIPAddress: "192.0.2.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/landing", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("User-Agent", tt.userAgent)
+			got := loginEvent(req, database.LoginEvent{Outcome: database.LoginFailed, Reason: tt.reason})
+			if got.IPAddress != tt.wantIPAddress || got.UserAgent != tt.wantUserAgent || got.Reason != tt.wantReason {
+				t.Errorf("loginEvent() got = %+v", got)
+			}
+		})
+	}
 }
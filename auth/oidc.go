@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"net/http"
+	"time"
+)
+
+const (
+	// OpenIDScope is the scope requesting an OpenID Connect ID token along with the oauth2 token
+	OpenIDScope = "openid"
+
+	idTokenExtraKey = "id_token"
+	scopeExtraKey   = "scope"
+	nonceParam      = "nonce"
+	jwksTimeout     = 10 * time.Second
+)
+
+// signing algorithms accepted for the ID tokens, the asymmetric ones of the OpenID Connect specs
+var idTokenSigningAlgs = []string{oidc.RS256, oidc.RS384, oidc.RS512, oidc.ES256, oidc.ES384, oidc.ES512,
+	oidc.PS256, oidc.PS384, oidc.PS512, oidc.EdDSA}
+
+// IDTokenVerifierInterface verifies an OpenID Connect ID token and returns its claims
+type IDTokenVerifierInterface interface {
+	Verify(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error)
+}
+
+// IDTokenClaims contains the claims of an ID token used by the application
+type IDTokenClaims struct {
+	Issuer          string `json:"iss"`
+	Subject         string `json:"sub"`
+	AuthorizedParty string `json:"azp"`
+	Nonce           string `json:"nonce"`
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	Picture         string `json:"picture"`
+	Locale          string `json:"locale"`
+}
+
+// IDTokenVerifier verifies the ID tokens against the keys published by the issuer JWKS endpoint, the signature,
+// issuer, audience and expiry being checked by go-oidc
+type IDTokenVerifier struct {
+	clientID string
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewIDTokenVerifier creates a new IDTokenVerifier for the tokens issued by the given issuer to the given client
+func NewIDTokenVerifier(issuer, jwksURL, clientID string) *IDTokenVerifier {
+	// the keys are fetched in the background of the verifications, with a client of their own
+	ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: jwksTimeout})
+	return &IDTokenVerifier{
+		clientID: clientID,
+		verifier: oidc.NewVerifier(issuer, oidc.NewRemoteKeySet(ctx, jwksURL), &oidc.Config{
+			ClientID:             clientID,
+			SupportedSigningAlgs: idTokenSigningAlgs,
+		}),
+	}
+}
+
+// Verify checks the ID token signature, issuer, audience, authorized party, expiry and nonce, returning its claims
+func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	idToken, err := v.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	var claims IDTokenClaims
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+	// a token issued to several audiences must name the client it was requested by
+	if (len(idToken.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != v.clientID {
+		return nil, fmt.Errorf("ID token not authorized for this client, authorized party: %q",
+			claims.AuthorizedParty)
+	}
+	if nonce == "" || idToken.Nonce != nonce {
+		return nil, fmt.Errorf("ID token nonce mismatch")
+	}
+	if idToken.Subject == "" {
+		return nil, fmt.Errorf("ID token has no subject")
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"checkYoutube/test"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIDTokenVerifier_Verify(t *testing.T) {
+	// mocks
+	const (
+		clientID = "clientIDTest"
+		nonce    = "nonceTest"
+	)
+	provider := test.NewOIDCProviderMock(t)
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":   provider.Issuer,
+			"sub":   "1",
+			"aud":   clientID,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": nonce,
+			"name":  "usertest",
+		}
+	}
+	withClaim := func(key string, value any) map[string]any {
+		claims := validClaims()
+		claims[key] = value
+		return claims
+	}
+	withAudiences := func(authorizedParty string) map[string]any {
+		claims := withClaim("aud", []string{"otherClient", clientID})
+		if authorizedParty != "" {
+			claims["azp"] = authorizedParty
+		}
+		return claims
+	}
+
+	type args struct {
+		rawIDToken string
+		nonce      string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "success case",
+			args:    args{rawIDToken: provider.SignIDToken(t, validClaims()), nonce: nonce},
+			want:    "1",
+			wantErr: false,
+		},
+		{
+			name:    "success case - multiple audiences authorizing the client",
+			args:    args{rawIDToken: provider.SignIDToken(t, withAudiences(clientID)), nonce: nonce},
+			want:    "1",
+			wantErr: false,
+		},
+		{
+			name:    "error case - multiple audiences without authorized party",
+			args:    args{rawIDToken: provider.SignIDToken(t, withAudiences("")), nonce: nonce},
+			wantErr: true,
+		},
+		{
+			name:    "error case - multiple audiences authorizing another client",
+			args:    args{rawIDToken: provider.SignIDToken(t, withAudiences("otherClient")), nonce: nonce},
+			wantErr: true,
+		},
+		{
+			name:    "error case - authorized party of another client",
+			args:    args{rawIDToken: provider.SignIDToken(t, withClaim("azp", "otherClient")), nonce: nonce},
+			wantErr: true,
+		},
+		{
+			name:    "error case - wrong audience",
+			args:    args{rawIDToken: provider.SignIDToken(t, withClaim("aud", "otherClient")), nonce: nonce},
+			wantErr: true,
+		},
+		{
+			name:    "error case - wrong issuer",
+			args:    args{rawIDToken: provider.SignIDToken(t, withClaim("iss", "https://issuer.test")), nonce: nonce},
+			wantErr: true,
+		},
+		{
+			name: "error case - expired",
+			args: args{
+				rawIDToken: provider.SignIDToken(t, withClaim("exp", time.Now().Add(-time.Hour).Unix())),
+				nonce:      nonce,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "error case - nonce mismatch",
+			args:    args{rawIDToken: provider.SignIDToken(t, validClaims()), nonce: "otherNonce"},
+			wantErr: true,
+		},
+		{
+			name:    "error case - missing subject",
+			args:    args{rawIDToken: provider.SignIDToken(t, withClaim("sub", "")), nonce: nonce},
+			wantErr: true,
+		},
+		{
+			name: "error case - tampered claims",
+			args: args{
+				rawIDToken: func() string {
+					parts := strings.Split(provider.SignIDToken(t, validClaims()), ".")
+					otherParts := strings.Split(provider.SignIDToken(t, withClaim("sub", "2")), ".")
+					return parts[0] + "." + otherParts[1] + "." + parts[2]
+				}(),
+				nonce: nonce,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "error case - malformed token",
+			args:    args{rawIDToken: "malformed", nonce: nonce},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewIDTokenVerifier(provider.Issuer, provider.JWKSURL(), clientID)
+			got, err := v.Verify(context.Background(), tt.args.rawIDToken, tt.args.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.Subject != tt.want {
+				t.Errorf("Verify() got = %v, want %v", got.Subject, tt.want)
+			}
+		})
+	}
+}
@@ -3,16 +3,125 @@ package auth
 import (
 	"checkYoutube/logging"
 	"context"
+	"encoding/json"
 	"fmt"
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/people/v1"
+	"google.golang.org/api/youtube/v3"
 	"log/slog"
 	"net/http"
+	"os"
+	"slices"
 	"strings"
 )
 
+// userinfo sources, see ProviderConfig.UserinfoSource
+const (
+	PeopleUserinfoSource   = "people"
+	IDTokenUserinfoSource  = "id_token"
+	EndpointUserinfoSource = "userinfo_endpoint"
+)
+
+// ProviderConfig describes an oauth2 provider: its endpoints, the requested scopes, how the logged user
+// is identified and which prompts it supports
+type ProviderConfig struct {
+	Name         string   `json:"name"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	AuthURL      string   `json:"auth_url"`
+	TokenURL     string   `json:"token_url"`
+	Scopes       []string `json:"scopes"`
+	// WriteScopes are requested on top of Scopes only when the user first changes its YouTube data, e.g. its
+	// subscriptions. Users can't change their YouTube data when empty
+	WriteScopes []string `json:"write_scopes"`
+	// UserinfoSource is where the logged user display name comes from: "people", "id_token" or "userinfo_endpoint"
+	UserinfoSource string `json:"userinfo_source"`
+	UserinfoURL    string `json:"userinfo_url"`
+	// IssuerURL and JWKSURL are used to verify the ID tokens, that are not requested when JWKSURL is empty
+	IssuerURL string  `json:"issuer_url"`
+	JWKSURL   string  `json:"jwks_url"`
+	Prompts   Prompts `json:"prompts"`
+	// AuthParams are additional parameters sent to the auth url, e.g. access_type
+	AuthParams map[string]string `json:"auth_params"`
+}
+
+// Prompts are the values of the prompt parameter asking the user to select an account or to grant consent,
+// an empty value means the provider does not support the prompt
+type Prompts struct {
+	SelectAccount string `json:"select_account"`
+	Consent       string `json:"consent"`
+}
+
+// GoogleProviderConfig returns the config of the Google provider, granting read-only access to the YouTube data.
+// Write access is granted incrementally, keeping the scopes already granted
+func GoogleProviderConfig(clientID, clientSecret, redirectURL, issuerURL, jwksURL string) ProviderConfig {
+	return ProviderConfig{
+		Name:           "google",
+		ClientID:       clientID,
+		ClientSecret:   clientSecret,
+		RedirectURL:    redirectURL,
+		AuthURL:        google.Endpoint.AuthURL,
+		TokenURL:       google.Endpoint.TokenURL,
+		Scopes:         []string{OpenIDScope, youtube.YoutubeReadonlyScope, people.UserinfoProfileScope},
+		WriteScopes:    []string{youtube.YoutubeForceSslScope},
+		UserinfoSource: PeopleUserinfoSource,
+		IssuerURL:      issuerURL,
+		JWKSURL:        jwksURL,
+		Prompts: Prompts{
+			SelectAccount: selectAccountPrompt,
+			Consent:       consentPrompt,
+		},
+		AuthParams: map[string]string{"access_type": "offline", "include_granted_scopes": "true"},
+	}
+}
+
+// LoadProviderConfig reads a provider config from a JSON file, expanding the env variables it references
+func LoadProviderConfig(path string) (ProviderConfig, error) {
+	const funcName = "LoadProviderConfig"
+	var config ProviderConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to read provider config: %s", err.Error()), logging.FuncNameAttr(funcName))
+		return config, err
+	}
+
+	if err = json.Unmarshal([]byte(os.ExpandEnv(string(data))), &config); err != nil {
+		slog.Error(fmt.Sprintf("failed to parse provider config %s: %s", path, err.Error()),
+			logging.FuncNameAttr(funcName))
+		return config, err
+	}
+
+	if config.Name == "" || config.ClientID == "" || config.AuthURL == "" || config.TokenURL == "" {
+		err = fmt.Errorf("provider config %s must set name, client_id, auth_url and token_url", path)
+		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+		return config, err
+	}
+	switch config.UserinfoSource {
+	case PeopleUserinfoSource, IDTokenUserinfoSource:
+	case EndpointUserinfoSource:
+		if config.UserinfoURL == "" {
+			err = fmt.Errorf("provider config %s must set userinfo_url", path)
+		}
+	default:
+		err = fmt.Errorf("provider config %s has unknown userinfo_source: %s", path, config.UserinfoSource)
+	}
+	if err == nil && config.UserinfoSource == IDTokenUserinfoSource && config.JWKSURL == "" {
+		err = fmt.Errorf("provider config %s must set jwks_url to get userinfo from the ID token", path)
+	}
+	if err != nil {
+		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+	}
+
+	return config, err
+}
+
 type Oauth2ConfigProvider interface {
 	GenerateVerifier() string
-	GenerateAuthURL(state, verifier string, promptAccountSelect, promptConsent bool) string
+	GenerateAuthURL(state, verifier, nonce string, promptAccountSelect, promptConsent bool) string
+	GenerateWriteAuthURL(state, verifier, nonce string) string
 	ExchangeCodeWithToken(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
 	CreateHTTPClient(ctx context.Context, token *oauth2.Token) *http.Client
 	CreateTokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource
@@ -20,27 +129,54 @@ type Oauth2ConfigProvider interface {
 
 type oauth2ConfigInstance struct {
 	oauth2Config oauth2.Config
+	writeScopes  []string
+	prompts      Prompts
+	authParams   map[string]string
 }
 
 func (o *oauth2ConfigInstance) GenerateVerifier() string {
 	return oauth2.GenerateVerifier()
 }
 
-func (o *oauth2ConfigInstance) GenerateAuthURL(state, verifier string, promptAccountSelect, promptConsent bool) string {
+func (o *oauth2ConfigInstance) GenerateAuthURL(state, verifier, nonce string,
+	promptAccountSelect, promptConsent bool) string {
+	return o.authURL(state, verifier, nonce, promptAccountSelect, promptConsent, nil)
+}
+
+// GenerateWriteAuthURL returns the auth url asking the user for consent to the write scopes along with the other
+// ones, in order to get back a refresh token granting them all
+func (o *oauth2ConfigInstance) GenerateWriteAuthURL(state, verifier, nonce string) string {
+	return o.authURL(state, verifier, nonce, false, true, o.writeScopes)
+}
+
+// return the auth url requesting the scopes of the config along with the given additional ones
+func (o *oauth2ConfigInstance) authURL(state, verifier, nonce string, promptAccountSelect, promptConsent bool,
+	additionalScopes []string) string {
 	opts := []oauth2.AuthCodeOption{
-		oauth2.AccessTypeOffline,
 		oauth2.S256ChallengeOption(verifier),
 	}
+	if len(additionalScopes) > 0 {
+		scopes := append(slices.Clone(o.oauth2Config.Scopes), additionalScopes...)
+		opts = append(opts, oauth2.SetAuthURLParam("scope", strings.Join(scopes, " ")))
+	}
+	for key, value := range o.authParams {
+		opts = append(opts, oauth2.SetAuthURLParam(key, value))
+	}
+
+	// bind the ID token to the login attempt
+	if nonce != "" {
+		opts = append(opts, oauth2.SetAuthURLParam(nonceParam, nonce))
+	}
 	prompts := make([]string, 0)
 
 	// prompt user to select an account
-	if promptAccountSelect {
-		prompts = append(prompts, selectAccountPrompt)
+	if promptAccountSelect && o.prompts.SelectAccount != "" {
+		prompts = append(prompts, o.prompts.SelectAccount)
 	}
 
 	// prompt user for App consent
-	if promptConsent {
-		prompts = append(prompts, consentPrompt)
+	if promptConsent && o.prompts.Consent != "" {
+		prompts = append(prompts, o.prompts.Consent)
 	}
 
 	if len(prompts) > 0 {
@@ -71,3 +207,18 @@ func (o *oauth2ConfigInstance) CreateHTTPClient(ctx context.Context, token *oaut
 func (o *oauth2ConfigInstance) CreateTokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
 	return o.oauth2Config.TokenSource(ctx, token)
 }
+
+// return whether the token grants all the write scopes of the provider, as told by the provider along with the token
+func (p *Provider) grantsWriteAccess(token *oauth2.Token) bool {
+	if len(p.writeScopes) == 0 {
+		return false
+	}
+	scope, _ := token.Extra(scopeExtraKey).(string)
+	grantedScopes := strings.Fields(scope)
+	for _, writeScope := range p.writeScopes {
+		if !slices.Contains(grantedScopes, writeScope) {
+			return false
+		}
+	}
+	return true
+}
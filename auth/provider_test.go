@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"checkYoutube/test/mockoauth"
+	"context"
+	"github.com/gorilla/sessions"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProviderConfig(t *testing.T) {
+	t.Setenv("PROVIDER_CLIENT_SECRET_TEST", "secretTest")
+	writeConfig := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "provider.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    ProviderConfig
+		wantErr bool
+	}{
+		{
+			name: "success case",
+			content: `{"name": "sso", "client_id": "clientIDTest", "client_secret": "${PROVIDER_CLIENT_SECRET_TEST}",
+				"auth_url": "https://sso.test/authorize", "token_url": "https://sso.test/token",
+				"userinfo_source": "userinfo_endpoint", "userinfo_url": "https://sso.test/userinfo"}`,
+			want: ProviderConfig{
+				Name:           "sso",
+				ClientID:       "clientIDTest",
+				ClientSecret:   "secretTest",
+				AuthURL:        "https://sso.test/authorize",
+				TokenURL:       "https://sso.test/token",
+				UserinfoSource: EndpointUserinfoSource,
+				UserinfoURL:    "https://sso.test/userinfo",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "error case - malformed json",
+			content: `{"name": "sso"`,
+			wantErr: true,
+		},
+		{
+			name:    "error case - missing endpoints",
+			content: `{"name": "sso", "client_id": "clientIDTest", "userinfo_source": "id_token"}`,
+			wantErr: true,
+		},
+		{
+			name: "error case - unknown userinfo source",
+			content: `{"name": "sso", "client_id": "clientIDTest", "auth_url": "https://sso.test/authorize",
+				"token_url": "https://sso.test/token", "userinfo_source": "unknown"}`,
+			wantErr: true,
+		},
+		{
+			name: "error case - userinfo from ID token without jwks url",
+			content: `{"name": "sso", "client_id": "clientIDTest", "auth_url": "https://sso.test/authorize",
+				"token_url": "https://sso.test/token", "userinfo_source": "id_token"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadProviderConfig(writeConfig(t, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadProviderConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && (got.Name != tt.want.Name || got.ClientSecret != tt.want.ClientSecret ||
+				got.UserinfoURL != tt.want.UserinfoURL) {
+				t.Errorf("LoadProviderConfig() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateAuthURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     ProviderConfig
+		wantPrompt string
+	}{
+		{
+			name:       "success case - google prompts",
+			config:     GoogleProviderConfig("clientIDTest", "clientSecretTest", "redirectURLTest", "", ""),
+			wantPrompt: "select_account consent",
+		},
+		{
+			name: "success case - provider without prompts",
+			config: ProviderConfig{
+				Name:     "sso",
+				ClientID: "clientIDTest",
+				AuthURL:  "https://sso.test/authorize",
+				TokenURL: "https://sso.test/token",
+			},
+			wantPrompt: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authURL, err := url.Parse(CreateOauth2Config(tt.config).GenerateAuthURL("state", "verifier",
+				"nonceTest", true, true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := authURL.Query().Get("prompt"); got != tt.wantPrompt {
+				t.Errorf("GenerateAuthURL() prompt = %v, want %v", got, tt.wantPrompt)
+			}
+			if got := authURL.Query().Get("nonce"); got != "nonceTest" {
+				t.Errorf("GenerateAuthURL() nonce = %v, want %v", got, "nonceTest")
+			}
+		})
+	}
+}
+
+func TestGenerateWriteAuthURL(t *testing.T) {
+	config := GoogleProviderConfig("clientIDTest", "clientSecretTest", "redirectURLTest", "", "")
+	authURL, err := url.Parse(CreateOauth2Config(config).GenerateWriteAuthURL("state", "verifier", "nonceTest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	wantScope := "openid https://www.googleapis.com/auth/youtube.readonly " +
+		"https://www.googleapis.com/auth/userinfo.profile https://www.googleapis.com/auth/youtube.force-ssl"
+	if got := query.Get("scope"); got != wantScope {
+		t.Errorf("GenerateWriteAuthURL() scope = %v, want %v", got, wantScope)
+	}
+	if query.Get("prompt") != "consent" || query.Get("include_granted_scopes") != "true" {
+		t.Errorf("GenerateWriteAuthURL() prompt = %v, include_granted_scopes = %v", query.Get("prompt"),
+			query.Get("include_granted_scopes"))
+	}
+}
+
+func TestProvider_grantsWriteAccess(t *testing.T) {
+	provider := NewProvider(GoogleProviderConfig("clientIDTest", "clientSecretTest", "redirectURLTest", "", ""),
+		nil, false)
+	tests := []struct {
+		name     string
+		provider *Provider
+		scope    string
+		want     bool
+	}{
+		{
+			name:     "success case - write scope granted",
+			provider: provider,
+			scope:    "openid https://www.googleapis.com/auth/youtube.force-ssl",
+			want:     true,
+		},
+		{
+			name:     "success case - read-only scopes granted",
+			provider: provider,
+			scope:    "openid https://www.googleapis.com/auth/youtube.readonly",
+		},
+		{
+			name:     "success case - scopes not told",
+			provider: provider,
+		},
+		{
+			name:     "success case - provider without write scopes",
+			provider: NewProvider(ProviderConfig{Name: "sso"}, nil, false),
+			scope:    "openid https://www.googleapis.com/auth/youtube.force-ssl",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := (&oauth2.Token{}).WithExtra(map[string]interface{}{"scope": tt.scope})
+			if got := tt.provider.grantsWriteAccess(token); got != tt.want {
+				t.Errorf("grantsWriteAccess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// run the whole login flow against a local provider: login, provider approval and landing
+func TestLoginFlow(t *testing.T) {
+	const serverBasepath = "http://localhost:8900"
+	provider := test.NewOIDCProviderMock(t)
+	config := ProviderConfig{
+		Name:           "mock",
+		ClientID:       "clientIDTest",
+		ClientSecret:   "clientSecretTest",
+		RedirectURL:    serverBasepath + "/landing",
+		AuthURL:        provider.Issuer + "/authorize",
+		TokenURL:       provider.Issuer + "/token",
+		Scopes:         []string{OpenIDScope},
+		UserinfoSource: EndpointUserinfoSource,
+		UserinfoURL:    provider.Issuer + "/userinfo",
+		IssuerURL:      provider.Issuer,
+		JWKSURL:        provider.JWKSURL(),
+	}
+	noRedirectClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	tests := []struct {
+		name       string
+		loginOnly  bool
+		wantUserId string
+	}{
+		{
+			name:       "success case",
+			loginOnly:  false,
+			wantUserId: mockoauth.DefaultSubject,
+		},
+		{
+			name:       "success case - login-only provider",
+			loginOnly:  true,
+			wantUserId: "mock:" + mockoauth.DefaultSubject,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var storedUserId string
+			storage := &test.StorageMock{
+				UpsertRefreshTokenStub: func(_ context.Context, userId, refreshToken string) error {
+					storedUserId = userId
+					return nil
+				},
+				GetUserIdByAccountIdStub: func(_ context.Context, accountId string) (int64, error) {
+					return 1, nil
+				},
+				GetUserIdByLoginIdentityStub: func(_ context.Context, provider, subject string) (int64, error) {
+					return 1, nil
+				},
+				RecordUserLoginStub: func(_ context.Context, profile database.UserProfile) error {
+					return nil
+				},
+				AddLoginEventStub: func(_ context.Context, event database.LoginEvent) error {
+					return nil
+				},
+			}
+			loginProvider := NewProvider(config, nil, tt.loginOnly)
+			sessionStore := sessions.NewCookieStore([]byte("test"))
+
+			// start the login
+			recorder := httptest.NewRecorder()
+			Login(loginProvider.Oauth2C, sessionStore)(recorder, httptest.NewRequest(http.MethodGet, "/login", nil))
+			cookies := recorder.Result().Cookies()
+
+			// the provider approves the login and redirects back to the landing page
+			res, err := noRedirectClient.Get(recorder.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+			if res.StatusCode != http.StatusFound {
+				t.Fatalf("authorize status = %v, want %v", res.StatusCode, http.StatusFound)
+			}
+
+			// complete the login
+			req := httptest.NewRequest(http.MethodGet, res.Header.Get("Location"), nil)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			recorder = httptest.NewRecorder()
+			CheckVerifierMiddleware(Oauth2Redirect(loginProvider, loginProvider, sessionStore, storage, serverBasepath),
+				sessionStore, serverBasepath)(recorder, req)
+			if recorder.Code != http.StatusSeeOther {
+				t.Fatalf("Oauth2Redirect() = %v, want %v: %s", recorder.Code, http.StatusSeeOther,
+					recorder.Body.String())
+			}
+			if location := recorder.Header().Get("Location"); location != serverBasepath+"/check-youtube" {
+				t.Errorf("Oauth2Redirect() location = %v", location)
+			}
+			if storedUserId != tt.wantUserId {
+				t.Errorf("Oauth2Redirect() stored refresh token of %v, want %v", storedUserId, tt.wantUserId)
+			}
+		})
+	}
+}
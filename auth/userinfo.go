@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"checkYoutube/clients"
+	"checkYoutube/logging"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"log/slog"
+	"net/http"
+)
+
+// UserinfoSourceInterface retrieves the info of the logged user. The ID token claims are nil when the
+// provider does not issue ID tokens
+type UserinfoSourceInterface interface {
+	GetUserinfo(ctx context.Context, token *oauth2.Token, claims *IDTokenClaims) (clients.Userinfo, error)
+}
+
+// Provider is an oauth2 provider the users authenticate with
+type Provider struct {
+	Name    string
+	Oauth2C Oauth2Config
+	// IDTokenVerifier is nil when the provider does not issue ID tokens
+	IDTokenVerifier IDTokenVerifierInterface
+	Userinfo        UserinfoSourceInterface
+	// LoginOnly is set for providers used only for app-level login, whose tokens can't access the YouTube data
+	LoginOnly bool
+	// writeScopes are the scopes granting write access to the YouTube data
+	writeScopes []string
+}
+
+// NewProvider creates a new Provider from the given config
+func NewProvider(config ProviderConfig, pcf clients.PeopleClientFactoryInterface, loginOnly bool) *Provider {
+	oauth2C := CreateOauth2Config(config)
+	provider := &Provider{
+		Name:        config.Name,
+		Oauth2C:     oauth2C,
+		LoginOnly:   loginOnly,
+		writeScopes: config.WriteScopes,
+	}
+
+	if config.JWKSURL != "" {
+		provider.IDTokenVerifier = NewIDTokenVerifier(config.IssuerURL, config.JWKSURL, config.ClientID)
+	}
+
+	switch config.UserinfoSource {
+	case IDTokenUserinfoSource:
+		provider.Userinfo = &idTokenUserinfoSource{}
+	case EndpointUserinfoSource:
+		provider.Userinfo = &endpointUserinfoSource{oauth2C: oauth2C, userinfoURL: config.UserinfoURL}
+	default:
+		provider.Userinfo = &peopleUserinfoSource{oauth2C: oauth2C, pcf: pcf}
+	}
+
+	return provider
+}
+
+// the user info is retrieved from the Google People API
+type peopleUserinfoSource struct {
+	oauth2C Oauth2Config
+	pcf     clients.PeopleClientFactoryInterface
+}
+
+func (p *peopleUserinfoSource) GetUserinfo(ctx context.Context, token *oauth2.Token,
+	_ *IDTokenClaims) (clients.Userinfo, error) {
+	peopleSvc, err := p.pcf.NewClient(p.oauth2C.CreateTokenSource(ctx, token))
+	if err != nil {
+		return clients.Userinfo{}, fmt.Errorf("unable to create people service: %w", err)
+	}
+
+	return peopleSvc.GetLoggedUserinfo()
+}
+
+// the user info is read from the ID token claims
+type idTokenUserinfoSource struct{}
+
+func (i *idTokenUserinfoSource) GetUserinfo(_ context.Context, _ *oauth2.Token,
+	claims *IDTokenClaims) (clients.Userinfo, error) {
+	if claims == nil {
+		return clients.Userinfo{}, fmt.Errorf("no ID token claims to read the user info from")
+	}
+
+	displayName := claims.Name
+	if displayName == "" {
+		displayName = claims.Email
+	}
+	if displayName == "" {
+		displayName = claims.Subject
+	}
+
+	return clients.Userinfo{Id: claims.Subject, DisplayName: displayName, AvatarURL: claims.Picture,
+		Locale: claims.Locale}, nil
+}
+
+// the user info is retrieved from the OpenID Connect userinfo endpoint
+type endpointUserinfoSource struct {
+	oauth2C     Oauth2Config
+	userinfoURL string
+}
+
+func (e *endpointUserinfoSource) GetUserinfo(ctx context.Context, token *oauth2.Token,
+	claims *IDTokenClaims) (clients.Userinfo, error) {
+	const funcName = "GetUserinfo"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.userinfoURL, nil)
+	if err != nil {
+		return clients.Userinfo{}, err
+	}
+	res, err := e.oauth2C.CreateHTTPClient(ctx, token).Do(req)
+	if err != nil {
+		slog.Error(fmt.Sprintf("userinfo request failed: %s", err.Error()), logging.FuncNameAttr(funcName))
+		return clients.Userinfo{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return clients.Userinfo{}, fmt.Errorf("userinfo endpoint returned status: %d", res.StatusCode)
+	}
+
+	var userinfo struct {
+		Subject string `json:"sub"`
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		Picture string `json:"picture"`
+		Locale  string `json:"locale"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&userinfo); err != nil {
+		return clients.Userinfo{}, err
+	}
+
+	// the userinfo response must refer to the user the ID token was issued for
+	if claims != nil && userinfo.Subject != claims.Subject {
+		return clients.Userinfo{}, fmt.Errorf("userinfo subject %s does not match ID token subject %s",
+			userinfo.Subject, claims.Subject)
+	}
+
+	displayName := userinfo.Name
+	if displayName == "" {
+		displayName = userinfo.Email
+	}
+
+	return clients.Userinfo{Id: userinfo.Subject, DisplayName: displayName, AvatarURL: userinfo.Picture,
+		Locale: userinfo.Locale}, nil
+}
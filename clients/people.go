@@ -11,7 +11,7 @@ import (
 )
 
 type PeopleClientInterface interface {
-	GetLoggedUserinfo() Userinfo
+	GetLoggedUserinfo() (Userinfo, error)
 }
 
 type PeopleClientFactoryInterface interface {
@@ -27,6 +27,8 @@ type PeopleClientFactory struct{}
 type Userinfo struct {
 	Id          string
 	DisplayName string
+	AvatarURL   string
+	Locale      string
 }
 
 // NewClient creates a new people service client using the given token source
@@ -46,26 +48,32 @@ func (p *PeopleClientFactory) NewClient(ts oauth2.TokenSource) (PeopleClientInte
 	}, nil
 }
 
-func (p *peopleClient) GetLoggedUserinfo() Userinfo {
+func (p *peopleClient) GetLoggedUserinfo() (Userinfo, error) {
 	const funcName = "GetLoggedUserinfo"
 	user := Userinfo{}
 
 	userinfo, err := p.svc.People.
 		Get("people/me").
-		PersonFields("names,metadata").
+		PersonFields("names,metadata,photos,locales").
 		Do()
 	if err != nil {
 		slog.Error(fmt.Sprintf("error retrieving logged user info: %s", err.Error()),
 			logging.FuncNameAttr(funcName))
-		return user
+		return user, err
 	}
 
 	if len(userinfo.Names) > 0 {
 		user.DisplayName = userinfo.Names[0].DisplayName
 	}
+	if len(userinfo.Photos) > 0 {
+		user.AvatarURL = userinfo.Photos[0].Url
+	}
+	if len(userinfo.Locales) > 0 {
+		user.Locale = userinfo.Locales[0].Value
+	}
 	if len(userinfo.Metadata.Sources) > 0 && userinfo.Metadata.Sources[0].Id != "" {
 		user.Id = userinfo.Metadata.Sources[0].Id
 	}
 
-	return user
+	return user, nil
 }
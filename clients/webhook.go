@@ -0,0 +1,48 @@
+package clients
+
+import (
+	"bytes"
+	"checkYoutube/logging"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type WebhookClientInterface interface {
+	Post(ctx context.Context, url string, payload any) error
+}
+
+// WebhookClient posts notifications as JSON to the webhooks set by the users
+type WebhookClient struct {
+	HTTPClient *http.Client
+}
+
+// Post sends the payload as JSON to the given URL, failing when the webhook doesn't answer with a 2xx status
+func (c *WebhookClient) Post(ctx context.Context, url string, payload any) error {
+	const funcName = "Post"
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		slog.Error(fmt.Sprintf("error calling webhook: %s", err.Error()), logging.FuncNameAttr(funcName))
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", res.Status)
+	}
+	return nil
+}
@@ -1,21 +1,36 @@
 package clients
 
 import (
+	"checkYoutube/errors"
 	"checkYoutube/logging"
 	"context"
+	errors2 "errors"
 	"fmt"
 	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
 	"log/slog"
+	"net/http"
+	"time"
 )
 
+// errEnoughPlaylistItems stops paging through a playlist once the items needed are retrieved
+var errEnoughPlaylistItems = errors2.New("enough playlist items")
+
 type YoutubeClientInterface interface {
 	GetAndProcessSubscriptions(ctx context.Context,
 		processFunction func(*youtube.SubscriptionListResponse) error) error
 	GetLatestVideoFromPlaylist(playlistID string) (*youtube.PlaylistItem, error)
+	GetPlaylistVideos(ctx context.Context, playlistID string, maxResults int64) ([]*youtube.PlaylistItem, error)
+	GetPlaylistVideosSince(ctx context.Context, playlistID string, since time.Time,
+		maxResults int64) ([]*youtube.PlaylistItem, error)
 	GetVideos(ctx context.Context, videoIDs []string,
 		processFunction func(*youtube.VideoListResponse) error) error
+	GetVideoCategories(ctx context.Context, categoryIDs []string) ([]*youtube.VideoCategory, error)
+	GetChannels(ctx context.Context, channelIDs []string) ([]*youtube.Channel, error)
+	Subscribe(ctx context.Context, channelID string) (*youtube.Subscription, error)
+	Unsubscribe(ctx context.Context, subscriptionID string) error
 }
 
 type YoutubeClientFactoryInterface interface {
@@ -68,7 +83,7 @@ func (y *youtubeClient) GetLatestVideoFromPlaylist(playlistID string) (*youtube.
 	const funcName = "GetLatestVideoFromPlaylist"
 
 	playlistItemsResponse, err := y.svc.PlaylistItems.
-		List([]string{"snippet"}).
+		List([]string{"snippet", "status"}).
 		PlaylistId(playlistID).
 		MaxResults(1).
 		Do()
@@ -86,12 +101,81 @@ func (y *youtubeClient) GetLatestVideoFromPlaylist(playlistID string) (*youtube.
 	return nil, nil
 }
 
+// GetPlaylistVideos returns the first items of a playlist, none when the playlist doesn't exist
+func (y *youtubeClient) GetPlaylistVideos(ctx context.Context, playlistID string,
+	maxResults int64) ([]*youtube.PlaylistItem, error) {
+	const funcName = "GetPlaylistVideos"
+
+	playlistItemsResponse, err := y.svc.PlaylistItems.
+		List([]string{"snippet", "status"}).
+		PlaylistId(playlistID).
+		MaxResults(maxResults).
+		Context(ctx).
+		Do()
+	var apiErr *googleapi.Error
+	if errors2.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		slog.Debug(fmt.Sprintf("playlist %s not found", playlistID), logging.FuncNameAttr(funcName))
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("error retrieving YouTube videos from playlist %s: %s", playlistID, err.Error()),
+			logging.FuncNameAttr(funcName))
+		return nil, err
+	}
+
+	return playlistItemsResponse.Items, nil
+}
+
+// GetPlaylistVideosSince returns the items of a playlist newest first, paging through it until an item published
+// before the given time, included, or until maxResults items. None are returned when the playlist doesn't exist
+func (y *youtubeClient) GetPlaylistVideosSince(ctx context.Context, playlistID string, since time.Time,
+	maxResults int64) ([]*youtube.PlaylistItem, error) {
+	const funcName = "GetPlaylistVideosSince"
+
+	playlistItems := make([]*youtube.PlaylistItem, 0)
+	err := y.svc.PlaylistItems.
+		List([]string{"snippet", "status"}).
+		PlaylistId(playlistID).
+		MaxResults(50).
+		Pages(ctx, func(playlistItemsResponse *youtube.PlaylistItemListResponse) error {
+			for _, playlistItem := range playlistItemsResponse.Items {
+				playlistItems = append(playlistItems, playlistItem)
+				if int64(len(playlistItems)) >= maxResults {
+					return errEnoughPlaylistItems
+				}
+				if playlistItem.Snippet == nil {
+					continue
+				}
+				publishedAt, err := time.Parse(time.RFC3339, playlistItem.Snippet.PublishedAt)
+				if err == nil && publishedAt.Before(since) {
+					return errEnoughPlaylistItems
+				}
+			}
+			return nil
+		})
+	if errors2.Is(err, errEnoughPlaylistItems) {
+		return playlistItems, nil
+	}
+	var apiErr *googleapi.Error
+	if errors2.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		slog.Debug(fmt.Sprintf("playlist %s not found", playlistID), logging.FuncNameAttr(funcName))
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("error retrieving YouTube videos from playlist %s: %s", playlistID, err.Error()),
+			logging.FuncNameAttr(funcName))
+		return nil, err
+	}
+
+	return playlistItems, nil
+}
+
 func (y *youtubeClient) GetVideos(ctx context.Context, videoIDs []string,
 	processFunction func(*youtube.VideoListResponse) error) error {
 	const funcName = "GetVideos"
 
 	err := y.svc.Videos.
-		List([]string{"contentDetails"}).
+		List([]string{"contentDetails", "snippet", "liveStreamingDetails", "statistics", "status"}).
 		Id(videoIDs...).
 		MaxResults(50).
 		Pages(ctx, processFunction)
@@ -103,3 +187,94 @@ func (y *youtubeClient) GetVideos(ctx context.Context, videoIDs []string,
 
 	return nil
 }
+
+func (y *youtubeClient) GetVideoCategories(ctx context.Context, categoryIDs []string) ([]*youtube.VideoCategory,
+	error) {
+	const funcName = "GetVideoCategories"
+
+	categoriesResponse, err := y.svc.VideoCategories.
+		List([]string{"snippet"}).
+		Id(categoryIDs...).
+		Context(ctx).
+		Do()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error retrieving YouTube video categories with IDs %s: %s",
+			categoryIDs, err.Error()), logging.FuncNameAttr(funcName))
+		return nil, err
+	}
+
+	return categoriesResponse.Items, nil
+}
+
+// GetChannels returns the given channels that exist, up to 50, leaving out the deleted and terminated ones
+func (y *youtubeClient) GetChannels(ctx context.Context, channelIDs []string) ([]*youtube.Channel, error) {
+	const funcName = "GetChannels"
+
+	channelsResponse, err := y.svc.Channels.
+		List([]string{"id"}).
+		Id(channelIDs...).
+		MaxResults(50).
+		Context(ctx).
+		Do()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error retrieving YouTube channels with IDs %s: %s",
+			channelIDs, err.Error()), logging.FuncNameAttr(funcName))
+		return nil, err
+	}
+
+	return channelsResponse.Items, nil
+}
+
+// Subscribe subscribes the user to a channel, returning the new subscription. An errors.InsufficientScopeErr is
+// returned when the token can't change the YouTube data
+func (y *youtubeClient) Subscribe(ctx context.Context, channelID string) (*youtube.Subscription, error) {
+	const funcName = "Subscribe"
+
+	subscription, err := y.svc.Subscriptions.
+		Insert([]string{"snippet"}, &youtube.Subscription{
+			Snippet: &youtube.SubscriptionSnippet{
+				ResourceId: &youtube.ResourceId{Kind: "youtube#channel", ChannelId: channelID},
+			},
+		}).
+		Context(ctx).
+		Do()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error subscribing to YouTube channel %s: %s", channelID, err.Error()),
+			logging.FuncNameAttr(funcName))
+		return nil, writeErr(err)
+	}
+
+	return subscription, nil
+}
+
+// Unsubscribe deletes a subscription of the user. An errors.InsufficientScopeErr is returned when the token can't
+// change the YouTube data
+func (y *youtubeClient) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	const funcName = "Unsubscribe"
+
+	err := y.svc.Subscriptions.
+		Delete(subscriptionID).
+		Context(ctx).
+		Do()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error deleting YouTube subscription %s: %s", subscriptionID, err.Error()),
+			logging.FuncNameAttr(funcName))
+		return writeErr(err)
+	}
+
+	return nil
+}
+
+// wrap the error of a call changing the YouTube data in an errors.InsufficientScopeErr when due to the token
+// not being granted the write scopes
+func writeErr(err error) error {
+	var apiErr *googleapi.Error
+	if errors2.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+		for _, item := range apiErr.Errors {
+			if item.Reason == "insufficientPermissions" {
+				return errors.InsufficientScopeErr{Err: err}
+			}
+		}
+	}
+	return err
+}
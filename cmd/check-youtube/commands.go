@@ -0,0 +1,133 @@
+package main
+
+import (
+	"checkYoutube/database"
+	sessionsutils "checkYoutube/sessions"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+)
+
+const usage = `usage: check-youtube [command]
+
+Without a command the web server is started, applying the pending database migrations. Commands:
+  genkeys            generate a new session key pair to set in SESSION_KEYS
+  migrate up         apply the pending database migrations
+  migrate down [n]   revert the last n applied database migrations, default 1
+  migrate status     list the database migrations and whether they are applied
+`
+
+// run the given built-in command, returning the process exit code
+func runCommand(command string, args []string) int {
+	switch command {
+	case "genkeys":
+		return genKeys()
+	case "migrate":
+		return migrate(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", command, usage)
+		return 2
+	}
+}
+
+// print a new session key pair, along with the SESSION_KEYS value rotating the current keys
+func genKeys() int {
+	keyPair, err := sessionsutils.GenerateKeyPair()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate session keys: %s\n", err.Error())
+		return 1
+	}
+
+	fmt.Printf("SESSION_KEYS=%s\n", keyPair)
+	if current := os.Getenv("SESSION_KEYS"); current != "" {
+		// the previous keys are kept to accept the cookies they created, drop them once those have expired
+		fmt.Printf("\n# to rotate the current keys without logging users out:\nSESSION_KEYS=%s,%s\n", keyPair, current)
+	}
+	return 0
+}
+
+// run the database migrations subcommand
+func migrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		var err error
+		if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+			fmt.Fprintf(os.Stderr, "invalid number of migrations to revert: %s\n", args[1])
+			return 2
+		}
+	}
+
+	// interrupting the command cancels the running migration, rolling it back
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config, err := database.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid database config: %s\n", err.Error())
+		return 1
+	}
+	storage, err := database.New(ctx, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to database: %s\n", err.Error())
+		return 1
+	}
+	defer storage.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := storage.MigrateUp(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied  %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate up failed: %s\n", err.Error())
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		reverted, err := storage.MigrateDown(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate down failed: %s\n", err.Error())
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+	case "status":
+		statuses, err := storage.MigrationsStatus(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate status failed: %s\n", err.Error())
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		_ = w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command: %s\n\n%s", args[0], usage)
+		return 2
+	}
+	return 0
+}
@@ -7,78 +7,228 @@ import (
 	"checkYoutube/database"
 	"checkYoutube/handlers"
 	"checkYoutube/logging"
+	sessionsutils "checkYoutube/sessions"
 	"checkYoutube/web"
+	"context"
 	_ "embed"
 	"encoding/gob"
 	"fmt"
-	"github.com/gorilla/sessions"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"log/slog"
 	"net/http"
 	"os"
+	"time"
 )
 
+// timeout of the calls to the alert webhooks of the users
+const webhookTimeout = 10 * time.Second
+
 func main() {
 	const funcName = "main"
 
 	// configure logger
 	logging.ConfigureLogger(configs.GetEnvOrFallback("LOG_LEVEL", slog.LevelInfo.String()))
 
+	// run the built-in command, if any, instead of the server
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	// get env veriables
 	port := configs.GetEnvOrFallback("SERVER_PORT", "8900")
 	serverBasepath := fmt.Sprintf("http://localhost:%s", port)
-	clientID, err := configs.GetEnvOrErr("CLIENT_ID")
-	if err != nil {
-		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
-		os.Exit(-1)
+
+	// the provider granting access to the YouTube data is Google, unless a provider config file is given
+	var dataProviderConfig auth.ProviderConfig
+	var err error
+	if providerConfigPath := os.Getenv("OAUTH_PROVIDER_CONFIG"); providerConfigPath != "" {
+		dataProviderConfig, err = auth.LoadProviderConfig(providerConfigPath)
+	} else {
+		dataProviderConfig, err = googleProviderConfig()
 	}
-	clientSecret, err := configs.GetEnvOrErr("CLIENT_SECRET")
 	if err != nil {
 		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
 		os.Exit(-1)
 	}
-	redirectURL, err := configs.GetEnvOrErr("OAUTH_LANDING_PAGE")
+
+	// connect to database, used to store users' refresh token
+	databaseConfig, err := database.LoadConfig()
 	if err != nil {
-		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+		slog.Error(fmt.Sprintf("invalid database config: %s", err.Error()), logging.FuncNameAttr(funcName))
 		os.Exit(-1)
 	}
-
-	// connect to database, used to store users' refresh token
-	storage := new(database.Storage)
-	if err = storage.Init(); err != nil {
+	storage, err := database.New(context.Background(), databaseConfig)
+	if err != nil {
 		slog.Error(fmt.Sprintf("failed to connect to database: %s", err.Error()), logging.FuncNameAttr(funcName))
 		os.Exit(-1)
 	}
+	defer storage.Close()
 
 	// run database migrations
-	err = storage.RunMigrations()
+	err = storage.RunMigrations(context.Background())
 	if err != nil {
 		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
 		os.Exit(-1)
 	}
 
 	// session storage, used to store the data needed for the oauth2 login flow
-	sessionStore := sessions.NewCookieStore([]byte((os.Getenv("SESSION_KEY"))))
+	sessionKeyPairs, err := sessionKeys()
+	if err != nil {
+		slog.Error(fmt.Sprintf("invalid session keys: %s", err.Error()), logging.FuncNameAttr(funcName))
+		os.Exit(-1)
+	}
+	sessionStore := sessionsutils.NewCookieStore(sessionKeyPairs)
 	gob.Register(&auth.TokenInfo{})
 
-	// create oauth2 config
-	oauth2C := auth.CreateOauth2Config(clientID, clientSecret, redirectURL)
-
 	// client services factory
 	pcf := &clients.PeopleClientFactory{}
 	ytcf := &clients.YoutubeClientFactory{}
+	webhook := &clients.WebhookClient{HTTPClient: &http.Client{Timeout: webhookTimeout}}
+
+	// create the oauth2 providers: users log in with the data provider, unless a login-only provider
+	// (e.g. a company SSO) is configured, in which case the data provider is used only to link accounts
+	dataProvider := auth.NewProvider(dataProviderConfig, pcf, false)
+	loginProvider := dataProvider
+	if loginProviderConfigPath := os.Getenv("LOGIN_PROVIDER_CONFIG"); loginProviderConfigPath != "" {
+		loginProviderConfig, err := auth.LoadProviderConfig(loginProviderConfigPath)
+		if err != nil {
+			slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+			os.Exit(-1)
+		}
+		loginProvider = auth.NewProvider(loginProviderConfig, pcf, true)
+		slog.Info(fmt.Sprintf("users log in with provider %s", loginProvider.Name), logging.FuncNameAttr(funcName))
+	}
+	oauth2C := dataProvider.Oauth2C
 
 	// register handlers
-	http.HandleFunc("/login", auth.Login(oauth2C, sessionStore))
+	http.HandleFunc("/login", auth.Login(loginProvider.Oauth2C, sessionStore))
 	http.HandleFunc("/landing", auth.CheckVerifierMiddleware(
-		auth.Oauth2Redirect(oauth2C, sessionStore, storage, pcf, serverBasepath), sessionStore, serverBasepath))
+		auth.Oauth2Redirect(loginProvider, dataProvider, sessionStore, storage, serverBasepath),
+		sessionStore, serverBasepath))
 	http.HandleFunc("/check-youtube", auth.CheckTokenMiddleware(
-		handlers.GetYoutubeChannelsVideos(oauth2C, ytcf, serverBasepath, string(web.HtmlTemplate)),
-		oauth2C, storage, sessionStore, serverBasepath))
+		handlers.GetYoutubeChannelsVideos(oauth2C, ytcf, storage, serverBasepath, string(web.HtmlTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
 	http.HandleFunc("/switch-account", auth.CheckVerifierMiddleware(
-		auth.SwitchAccount(oauth2C), sessionStore, serverBasepath))
+		auth.SwitchAccount(loginProvider.Oauth2C), sessionStore, serverBasepath))
+	http.HandleFunc("/settings", auth.CheckTokenMiddleware(
+		handlers.GetSettings(storage, serverBasepath, string(web.SettingsTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/account", auth.CheckTokenMiddleware(
+		handlers.GetAccount(storage, serverBasepath, string(web.AccountTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/rules", auth.CheckTokenMiddleware(
+		handlers.GetRules(storage, serverBasepath, string(web.RulesTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/add-channel-rules", auth.CheckTokenMiddleware(
+		handlers.AddChannelRules(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/delete-channel-rule", auth.CheckTokenMiddleware(
+		handlers.DeleteChannelRule(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/add-video-rule", auth.CheckTokenMiddleware(
+		handlers.AddVideoRule(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/delete-video-rule", auth.CheckTokenMiddleware(
+		handlers.DeleteVideoRule(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/explain-channel", auth.CheckTokenMiddleware(
+		handlers.ExplainChannel(oauth2C, ytcf, storage), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/alerts", auth.CheckTokenMiddleware(
+		handlers.GetAlerts(storage, serverBasepath, string(web.AlertsTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/create-alert", auth.CheckTokenMiddleware(
+		handlers.CreateAlert(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/delete-alert", auth.CheckTokenMiddleware(
+		handlers.DeleteAlert(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/mark-alerts-read", auth.CheckTokenMiddleware(
+		handlers.MarkAlertsRead(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/search", auth.CheckTokenMiddleware(
+		handlers.GetSearch(storage, serverBasepath, string(web.SearchTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/subscriptions", auth.CheckTokenMiddleware(
+		handlers.GetSubscriptions(oauth2C, ytcf, storage, serverBasepath, string(web.SubscriptionsTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/subscribe", auth.CheckTokenMiddleware(
+		handlers.Subscribe(oauth2C, ytcf, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/unsubscribe", auth.CheckTokenMiddleware(
+		handlers.Unsubscribe(oauth2C, ytcf, storage, serverBasepath, string(web.SubscriptionsTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/export-subscriptions", auth.CheckTokenMiddleware(
+		handlers.ExportSubscriptions(oauth2C, ytcf, storage, serverBasepath), loginProvider.Oauth2C, storage,
+		sessionStore, serverBasepath))
+	http.HandleFunc("/import-subscriptions", auth.CheckTokenMiddleware(
+		handlers.ImportSubscriptions(oauth2C, ytcf, storage, serverBasepath, string(web.SubscriptionsTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/unfollow", auth.CheckTokenMiddleware(
+		handlers.Unfollow(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/export-feed", auth.CheckTokenMiddleware(
+		handlers.ExportFeed(oauth2C, ytcf, storage), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/subscription-history", auth.CheckTokenMiddleware(
+		handlers.GetSubscriptionHistory(storage, serverBasepath, string(web.SubscriptionHistoryTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/inactive-channels", auth.CheckTokenMiddleware(
+		handlers.GetInactiveChannels(oauth2C, ytcf, storage, serverBasepath, string(web.InactiveChannelsTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/grant-write-access", auth.CheckTokenMiddleware(
+		auth.GrantWriteAccess(loginProvider.Oauth2C, sessionStore), loginProvider.Oauth2C, storage, sessionStore,
+		serverBasepath))
+	http.HandleFunc("/update-preferences", auth.CheckTokenMiddleware(
+		handlers.UpdatePreferences(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/link-account", auth.CheckTokenMiddleware(
+		auth.LinkAccount(oauth2C, sessionStore), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/unlink-account", auth.CheckTokenMiddleware(
+		handlers.UnlinkAccount(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/create-access-token", auth.CheckTokenMiddleware(
+		handlers.CreateAccessToken(storage, serverBasepath, string(web.SettingsTemplate)),
+		loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/delete-access-token", auth.CheckTokenMiddleware(
+		handlers.DeleteAccessToken(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/sync", auth.CheckTokenMiddleware(
+		handlers.SyncFeed(oauth2C, ytcf, webhook, storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore,
+		serverBasepath))
 	http.HandleFunc("/mark-as-viewed", auth.CheckTokenMiddleware(
-		handlers.MarkAsViewed(oauth2C, serverBasepath), oauth2C, storage, sessionStore, serverBasepath))
+		handlers.MarkAsViewed(oauth2C, storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore,
+		serverBasepath))
+	http.HandleFunc("/create-channel-group", auth.CheckTokenMiddleware(
+		handlers.CreateChannelGroup(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/rename-channel-group", auth.CheckTokenMiddleware(
+		handlers.RenameChannelGroup(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/delete-channel-group", auth.CheckTokenMiddleware(
+		handlers.DeleteChannelGroup(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+	http.HandleFunc("/assign-channel-group", auth.CheckTokenMiddleware(
+		handlers.AssignChannelGroup(storage, serverBasepath), loginProvider.Oauth2C, storage, sessionStore, serverBasepath))
+
+	// register API handlers, authenticated by personal access tokens
+	http.HandleFunc("/api/v1/feed", auth.CheckAccessTokenMiddleware(
+		handlers.GetFeed(oauth2C, ytcf, storage), storage, auth.ReadFeedScope))
+	http.HandleFunc("/api/v1/feed/explain", auth.CheckAccessTokenMiddleware(
+		handlers.ExplainChannel(oauth2C, ytcf, storage), storage, auth.ReadFeedScope))
+	http.HandleFunc("/api/v1/feed/backlog", auth.CheckAccessTokenMiddleware(
+		handlers.BacklogAPI(oauth2C, ytcf, storage), storage, auth.ReadFeedScope))
+	http.HandleFunc("/api/v1/feed/export", auth.CheckAccessTokenMiddleware(
+		handlers.ExportFeed(oauth2C, ytcf, storage), storage, auth.ReadFeedScope))
+	http.HandleFunc("/api/v1/feed/sync", auth.CheckAccessTokenMiddleware(
+		handlers.SyncFeedAPI(oauth2C, ytcf, webhook, storage), storage, auth.SyncFeedScope))
+	http.HandleFunc("/api/v1/mark-as-viewed", auth.CheckAccessTokenMiddleware(
+		handlers.MarkAsViewed(oauth2C, storage, serverBasepath), storage, auth.MarkViewedScope))
+	http.HandleFunc("/api/v1/accounts", auth.CheckAccessTokenMiddleware(
+		handlers.LinkedAccountsAPI(storage), storage, auth.ManageSettingsScope))
+	http.HandleFunc("/api/v1/preferences", auth.CheckAccessTokenMiddleware(
+		handlers.PreferencesAPI(storage), storage, auth.ManageSettingsScope))
+	http.HandleFunc("/api/v1/groups", auth.CheckAccessTokenMiddleware(
+		handlers.ChannelGroupsAPI(storage), storage, auth.ManageSettingsScope))
+	http.HandleFunc("/api/v1/groups/channels", auth.CheckAccessTokenMiddleware(
+		handlers.ChannelGroupChannelsAPI(storage), storage, auth.ManageSettingsScope))
+	http.HandleFunc("/api/v1/video-rules", auth.CheckAccessTokenMiddleware(
+		handlers.VideoRulesAPI(storage), storage, auth.ManageSettingsScope))
+	http.HandleFunc("/api/v1/alerts", auth.CheckAccessTokenMiddleware(
+		handlers.AlertsAPI(storage), storage, auth.ManageSettingsScope))
+	// marking the matches as read is a write, granted by the settings scope only
+	http.HandleFunc("/api/v1/alerts/matches", auth.CheckAccessTokenMiddleware(
+		handlers.AlertMatchesAPI(storage), storage, auth.ReadFeedScope))
+	http.HandleFunc("POST /api/v1/alerts/matches", auth.CheckAccessTokenMiddleware(
+		handlers.AlertMatchesAPI(storage), storage, auth.ManageSettingsScope))
+	http.HandleFunc("/api/v1/videos/search", auth.CheckAccessTokenMiddleware(
+		handlers.SearchVideosAPI(storage), storage, auth.ReadFeedScope))
+	http.HandleFunc("/api/v1/subscriptions/changes", auth.CheckAccessTokenMiddleware(
+		handlers.SubscriptionChangesAPI(storage), storage, auth.ReadFeedScope))
 	http.Handle("/static/", http.FileServer(http.FS(web.StaticContent)))
 
 	// start the server
@@ -88,3 +238,45 @@ func main() {
 		os.Exit(-1)
 	}
 }
+
+// return the config of the Google provider from the env variables
+func googleProviderConfig() (auth.ProviderConfig, error) {
+	clientID, err := configs.GetEnvOrErr("CLIENT_ID")
+	if err != nil {
+		return auth.ProviderConfig{}, err
+	}
+	clientSecret, err := configs.GetEnvOrErr("CLIENT_SECRET")
+	if err != nil {
+		return auth.ProviderConfig{}, err
+	}
+	redirectURL, err := configs.GetEnvOrErr("OAUTH_LANDING_PAGE")
+	if err != nil {
+		return auth.ProviderConfig{}, err
+	}
+	oidcIssuerURL := configs.GetEnvOrFallback("OIDC_ISSUER_URL", "https://accounts.google.com")
+	oidcJWKSURL := configs.GetEnvOrFallback("OIDC_JWKS_URL", "https://www.googleapis.com/oauth2/v3/certs")
+
+	return auth.GoogleProviderConfig(clientID, clientSecret, redirectURL, oidcIssuerURL, oidcJWKSURL), nil
+}
+
+// return the session key pairs from the env variables, the current one first. SESSION_KEY is still accepted as
+// a single authentication key when SESSION_KEYS is not set
+func sessionKeys() ([]sessionsutils.KeyPair, error) {
+	const funcName = "sessionKeys"
+
+	if sessionKeys := os.Getenv("SESSION_KEYS"); sessionKeys != "" {
+		return sessionsutils.ParseKeyPairs(sessionKeys)
+	}
+
+	sessionKey := os.Getenv("SESSION_KEY")
+	if sessionKey == "" {
+		return nil, fmt.Errorf("SESSION_KEYS not set, generate the keys running: check-youtube genkeys")
+	}
+	slog.Warn("SESSION_KEY is deprecated, session cookies are not encrypted: use SESSION_KEYS instead",
+		logging.FuncNameAttr(funcName))
+	keyPair := sessionsutils.KeyPair{AuthKey: []byte(sessionKey)}
+	if err := keyPair.Validate(); err != nil {
+		return nil, err
+	}
+	return []sessionsutils.KeyPair{keyPair}, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"checkYoutube/configs"
+	"checkYoutube/logging"
+	"checkYoutube/test/mockoauth"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// local oauth2 and OpenID Connect provider, approving every login: it lets the login flow run in development
+// without Google or a company SSO, see OAUTH_PROVIDER_CONFIG and LOGIN_PROVIDER_CONFIG
+func main() {
+	const funcName = "main"
+
+	// configure logger
+	logging.ConfigureLogger(configs.GetEnvOrFallback("LOG_LEVEL", slog.LevelInfo.String()))
+
+	port := configs.GetEnvOrFallback("MOCK_OAUTH_PORT", "8901")
+	server, err := mockoauth.NewServer()
+	if err != nil {
+		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+		os.Exit(-1)
+	}
+	server.Issuer = configs.GetEnvOrFallback("MOCK_OAUTH_ISSUER", fmt.Sprintf("http://localhost:%s", port))
+
+	slog.Info(fmt.Sprintf("mock oauth server listening on port %s, issuer %s", port, server.Issuer),
+		logging.FuncNameAttr(funcName))
+	if err = http.ListenAndServe(fmt.Sprintf(":%s", port), server); err != nil {
+		slog.Error(err.Error(), logging.FuncNameAttr(funcName))
+		os.Exit(-1)
+	}
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"checkYoutube/logging"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// AccessToken is a personal access token of an app user, only its hash is stored
+type AccessToken struct {
+	Id     int64
+	UserId int64
+	Name   string
+	Scopes []string
+	// ExpiresAt and LastUsedAt are nil when the token never expires or was never used
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+const accessTokenColumns = "id, user_id, name, scopes, expires_at, last_used_at, created_at"
+
+// CreateAccessToken stores a new personal access token of the given user, returning its id
+func (s *Storage) CreateAccessToken(ctx context.Context, userId int64, name, tokenHash string, scopes []string,
+	expiresAt *time.Time) (int64, error) {
+	const funcName = "CreateAccessToken"
+
+	var id int64
+	err := s.q.QueryRowContext(ctx, s.rebind("INSERT INTO access_tokens "+
+		"(user_id, name, token_hash, scopes, expires_at) VALUES (?, ?, ?, ?, ?) RETURNING id"),
+		userId, name, tokenHash, strings.Join(scopes, " "), expiresAt).Scan(&id)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to create access token: %s", err.Error()), logging.FuncNameAttr(funcName))
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetAccessTokenByHash returns the personal access token having the given hash, nil if not found
+func (s *Storage) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*AccessToken, error) {
+	row := s.q.QueryRowContext(ctx, s.rebind("SELECT "+accessTokenColumns+" FROM access_tokens "+
+		"WHERE token_hash = ?"),
+		tokenHash)
+	accessToken, err := scanAccessToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return accessToken, err
+}
+
+// GetAccessTokens returns the personal access tokens of the given user
+func (s *Storage) GetAccessTokens(ctx context.Context, userId int64) ([]AccessToken, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT "+accessTokenColumns+" FROM access_tokens "+
+		"WHERE user_id = ? "+
+		"ORDER BY created_at, id"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accessTokens := make([]AccessToken, 0)
+	for rows.Next() {
+		accessToken, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		accessTokens = append(accessTokens, *accessToken)
+	}
+	return accessTokens, rows.Err()
+}
+
+// UpdateAccessTokenLastUsed sets the last time the given personal access token was used to now
+func (s *Storage) UpdateAccessTokenLastUsed(ctx context.Context, id int64) error {
+	_, err := s.q.ExecContext(ctx, s.rebind("UPDATE access_tokens SET last_used_at = ? WHERE id = ?"),
+		time.Now().UTC(), id)
+	return err
+}
+
+// DeleteAccessToken revokes a personal access token of the given user
+func (s *Storage) DeleteAccessToken(ctx context.Context, userId, id int64) error {
+	res, err := s.q.ExecContext(ctx, s.rebind("DELETE FROM access_tokens WHERE user_id = ? AND id = ?"),
+		userId, id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return fmt.Errorf("access token %d not found for user %d", id, userId)
+	}
+	return nil
+}
+
+func scanAccessToken(row interface{ Scan(dest ...any) error }) (*AccessToken, error) {
+	var accessToken AccessToken
+	var scopes string
+	err := row.Scan(&accessToken.Id, &accessToken.UserId, &accessToken.Name, &scopes, &accessToken.ExpiresAt,
+		&accessToken.LastUsedAt, &accessToken.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	accessToken.Scopes = strings.Fields(scopes)
+	return &accessToken, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"checkYoutube/logging"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// LinkedAccount is a Google account linked to an app-level user
+type LinkedAccount struct {
+	AccountId    string
+	DisplayName  string
+	RefreshToken string
+	CreatedAt    time.Time
+}
+
+// GetUserIdByAccountId returns the id of the app user the given Google account is linked to, 0 if not linked
+func (s *Storage) GetUserIdByAccountId(ctx context.Context, accountId string) (int64, error) {
+	const funcName = "GetUserIdByAccountId"
+
+	var userId int64
+	row := s.q.QueryRowContext(ctx, s.rebind("SELECT user_id FROM linked_accounts WHERE account_id = ?"),
+		accountId)
+	err := row.Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.Debug(fmt.Sprintf("account %s is not linked to any user", accountId), logging.FuncNameAttr(funcName))
+		return 0, nil
+	}
+	return userId, err
+}
+
+// CreateUserWithAccount creates a new app user and links the given Google account to it
+func (s *Storage) CreateUserWithAccount(ctx context.Context, accountId, displayName string) (int64, error) {
+	const funcName = "CreateUserWithAccount"
+
+	var userId int64
+	err := s.withTx(ctx, func(tx *Storage) error {
+		var err error
+		if userId, err = tx.createUser(ctx); err != nil {
+			slog.Error(fmt.Sprintf("failed to create user: %s", err.Error()), logging.FuncNameAttr(funcName))
+			return err
+		}
+
+		_, err = tx.q.ExecContext(ctx, tx.rebind("INSERT INTO linked_accounts (user_id, account_id, display_name) "+
+			"VALUES (?, ?, ?)"), userId, accountId, displayName)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to link account to the new user: %s", err.Error()),
+				logging.FuncNameAttr(funcName))
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+// create a new app user, returning its id
+func (s *Storage) createUser(ctx context.Context) (int64, error) {
+	// RETURNING is used in place of LastInsertId, not supported by the PostgreSQL driver
+	var userId int64
+	err := s.q.QueryRowContext(ctx, "INSERT INTO users DEFAULT VALUES RETURNING id").Scan(&userId)
+	return userId, err
+}
+
+// LinkAccount links a Google account to the given user, moving it away from any user it was previously linked to
+func (s *Storage) LinkAccount(ctx context.Context, userId int64, accountId, displayName string) error {
+	_, err := s.q.ExecContext(ctx, s.rebind("INSERT INTO linked_accounts (user_id, account_id, display_name) "+
+		"VALUES (?, ?, ?) "+
+		"ON CONFLICT(account_id) DO UPDATE SET user_id = excluded.user_id, display_name = excluded.display_name"),
+		userId, accountId, displayName)
+	return err
+}
+
+// GetLinkedAccounts returns the Google accounts linked to the given user, along with their stored refresh token
+func (s *Storage) GetLinkedAccounts(ctx context.Context, userId int64) ([]LinkedAccount, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT la.account_id, la.display_name, "+
+		"COALESCE(a.refresh_token, ''), la.created_at "+
+		"FROM linked_accounts la LEFT JOIN auth a ON a.user_id = la.account_id "+
+		"WHERE la.user_id = ? ORDER BY la.created_at, la.account_id"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]LinkedAccount, 0)
+	for rows.Next() {
+		var account LinkedAccount
+		if err = rows.Scan(&account.AccountId, &account.DisplayName, &account.RefreshToken,
+			&account.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// UnlinkAccount removes the link between a Google account and the given user, deleting its refresh token
+func (s *Storage) UnlinkAccount(ctx context.Context, userId int64, accountId string) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		res, err := tx.q.ExecContext(ctx, tx.rebind("DELETE FROM linked_accounts "+
+			"WHERE user_id = ? AND account_id = ?"), userId, accountId)
+		if err != nil {
+			return err
+		}
+		if deleted, err := res.RowsAffected(); err != nil {
+			return err
+		} else if deleted == 0 {
+			return fmt.Errorf("account %s is not linked to user %d", accountId, userId)
+		}
+
+		_, err = tx.q.ExecContext(ctx, tx.rebind("DELETE FROM auth WHERE user_id = ?"), accountId)
+		return err
+	})
+}
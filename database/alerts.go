@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Alert is a saved query of an app user, matched against the videos uploaded by all the subscribed channels
+type Alert struct {
+	Id        int64
+	UserId    int64
+	Query     string
+	CreatedAt time.Time
+	// CheckedAt is the start of the latest sync the alert was matched in, nil when it never was
+	CheckedAt *time.Time
+}
+
+// AlertMatch is a video matching an alert, recorded once per alert in the alerts inbox of the user
+type AlertMatch struct {
+	Id      int64
+	AlertId int64
+	UserId  int64
+	// Query is the query of the alert, filled in when the match is read from the inbox
+	Query        string
+	VideoID      string
+	VideoTitle   string
+	ChannelID    string
+	ChannelTitle string
+	PublishedAt  string
+	ReadAt       *time.Time
+	CreatedAt    time.Time
+}
+
+// CreateAlert stores a new alert of the given user, returning its id
+func (s *Storage) CreateAlert(ctx context.Context, userId int64, query string) (int64, error) {
+	var id int64
+	err := s.q.QueryRowContext(ctx, s.rebind("INSERT INTO alerts (user_id, query) VALUES (?, ?) RETURNING id"),
+		userId, query).Scan(&id)
+	return id, err
+}
+
+// GetAlerts returns the alerts of the given user sorted by query
+func (s *Storage) GetAlerts(ctx context.Context, userId int64) ([]Alert, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT id, user_id, query, created_at, checked_at FROM alerts "+
+		"WHERE user_id = ? "+
+		"ORDER BY query, id"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make([]Alert, 0)
+	for rows.Next() {
+		var alert Alert
+		if err = rows.Scan(&alert.Id, &alert.UserId, &alert.Query, &alert.CreatedAt, &alert.CheckedAt); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// MarkAlertsChecked records that the alerts of the given user were matched against the uploads published until the
+// given time
+func (s *Storage) MarkAlertsChecked(ctx context.Context, userId int64, checkedAt time.Time) error {
+	_, err := s.q.ExecContext(ctx, s.rebind("UPDATE alerts SET checked_at = ? WHERE user_id = ?"), checkedAt, userId)
+	return err
+}
+
+// DeleteAlert removes an alert of the given user along with its matches
+func (s *Storage) DeleteAlert(ctx context.Context, userId, id int64) error {
+	res, err := s.q.ExecContext(ctx, s.rebind("DELETE FROM alerts WHERE user_id = ? AND id = ?"), userId, id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return fmt.Errorf("alert %d not found for user %d", id, userId)
+	}
+	return nil
+}
+
+// AddAlertMatches records the given matches, skipping the videos already recorded for their alert. The matches
+// recorded are returned
+func (s *Storage) AddAlertMatches(ctx context.Context, matches []AlertMatch) ([]AlertMatch, error) {
+	added := make([]AlertMatch, 0)
+	err := s.withTx(ctx, func(tx *Storage) error {
+		for _, match := range matches {
+			res, err := tx.q.ExecContext(ctx, tx.rebind("INSERT INTO alert_matches "+
+				"(alert_id, user_id, video_id, video_title, channel_id, channel_title, published_at) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?) "+
+				"ON CONFLICT (alert_id, video_id) DO NOTHING"), match.AlertId, match.UserId, match.VideoID,
+				match.VideoTitle, match.ChannelID, match.ChannelTitle, match.PublishedAt)
+			if err != nil {
+				return err
+			}
+			if inserted, err := res.RowsAffected(); err != nil {
+				return err
+			} else if inserted > 0 {
+				added = append(added, match)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// GetAlertMatches returns the latest alert matches of the given user, newest first
+func (s *Storage) GetAlertMatches(ctx context.Context, userId int64, limit int) ([]AlertMatch, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT m.id, m.alert_id, m.user_id, a.query, m.video_id, "+
+		"m.video_title, m.channel_id, m.channel_title, m.published_at, m.read_at, m.created_at "+
+		"FROM alert_matches m JOIN alerts a ON a.id = m.alert_id "+
+		"WHERE m.user_id = ? "+
+		"ORDER BY m.created_at DESC, m.id DESC "+
+		"LIMIT ?"), userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]AlertMatch, 0)
+	for rows.Next() {
+		var match AlertMatch
+		err = rows.Scan(&match.Id, &match.AlertId, &match.UserId, &match.Query, &match.VideoID, &match.VideoTitle,
+			&match.ChannelID, &match.ChannelTitle, &match.PublishedAt, &match.ReadAt, &match.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// CountUnreadAlertMatches returns the number of alert matches of the given user not read yet
+func (s *Storage) CountUnreadAlertMatches(ctx context.Context, userId int64) (int, error) {
+	var count int
+	err := s.q.QueryRowContext(ctx, s.rebind("SELECT COUNT(*) FROM alert_matches "+
+		"WHERE user_id = ? AND read_at IS NULL"), userId).Scan(&count)
+	return count, err
+}
+
+// MarkAlertMatchesRead marks all the alert matches of the given user as read
+func (s *Storage) MarkAlertMatchesRead(ctx context.Context, userId int64) error {
+	_, err := s.q.ExecContext(ctx, s.rebind("UPDATE alert_matches SET read_at = CURRENT_TIMESTAMP "+
+		"WHERE user_id = ? AND read_at IS NULL"), userId)
+	return err
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// kinds of channel rules
+const (
+	// MuteRule never shows the channel
+	MuteRule = "mute"
+	// SnoozeRule doesn't show the channel until a date
+	SnoozeRule = "snooze"
+	// TitleMatchRule shows the channel only if the title of its latest video contains a pattern
+	TitleMatchRule = "title_match"
+)
+
+// ChannelRule is a rule of an app user about a subscribed channel, a channel has at most a rule of each kind
+type ChannelRule struct {
+	Id           int64
+	UserId       int64
+	ChannelID    string
+	ChannelTitle string
+	Kind         string
+	// SnoozedUntil is set for snooze rules only
+	SnoozedUntil *time.Time
+	// TitlePattern is set for title match rules only
+	TitlePattern string
+	CreatedAt    time.Time
+}
+
+// Active tells whether the rule still applies at the given time, snooze rules expire
+func (r ChannelRule) Active(now time.Time) bool {
+	return r.Kind != SnoozeRule || (r.SnoozedUntil != nil && r.SnoozedUntil.After(now))
+}
+
+const channelRuleColumns = "id, user_id, channel_id, channel_title, kind, snoozed_until, title_pattern, created_at"
+
+// UpsertChannelRule stores a channel rule, replacing the one of the same kind for the channel if any
+func (s *Storage) UpsertChannelRule(ctx context.Context, rule ChannelRule) error {
+	_, err := s.q.ExecContext(ctx, s.rebind("INSERT INTO channel_rules "+
+		"(user_id, channel_id, channel_title, kind, snoozed_until, title_pattern) VALUES (?, ?, ?, ?, ?, ?) "+
+		"ON CONFLICT(user_id, channel_id, kind) DO UPDATE SET channel_title = excluded.channel_title, "+
+		"snoozed_until = excluded.snoozed_until, title_pattern = excluded.title_pattern, "+
+		"created_at = CURRENT_TIMESTAMP"),
+		rule.UserId, rule.ChannelID, rule.ChannelTitle, rule.Kind, rule.SnoozedUntil, rule.TitlePattern)
+	return err
+}
+
+// GetChannelRules returns the channel rules of the given user, expired ones included
+func (s *Storage) GetChannelRules(ctx context.Context, userId int64) ([]ChannelRule, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT "+channelRuleColumns+" FROM channel_rules "+
+		"WHERE user_id = ? "+
+		"ORDER BY channel_title, channel_id, kind"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]ChannelRule, 0)
+	for rows.Next() {
+		var rule ChannelRule
+		err = rows.Scan(&rule.Id, &rule.UserId, &rule.ChannelID, &rule.ChannelTitle, &rule.Kind,
+			&rule.SnoozedUntil, &rule.TitlePattern, &rule.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteChannelRule removes a channel rule of the given user
+func (s *Storage) DeleteChannelRule(ctx context.Context, userId, id int64) error {
+	res, err := s.q.ExecContext(ctx, s.rebind("DELETE FROM channel_rules WHERE user_id = ? AND id = ?"),
+		userId, id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return fmt.Errorf("channel rule %d not found for user %d", id, userId)
+	}
+	return nil
+}
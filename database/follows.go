@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FollowedChannel is a channel followed locally by an app user, without a YouTube subscription
+type FollowedChannel struct {
+	UserId       int64
+	ChannelID    string
+	ChannelTitle string
+	CreatedAt    time.Time
+}
+
+// GetFollowedChannels returns the channels followed by the given user sorted by title
+func (s *Storage) GetFollowedChannels(ctx context.Context, userId int64) ([]FollowedChannel, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT user_id, channel_id, channel_title, created_at "+
+		"FROM followed_channels "+
+		"WHERE user_id = ? "+
+		"ORDER BY LOWER(channel_title), channel_id"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := make([]FollowedChannel, 0)
+	for rows.Next() {
+		var channel FollowedChannel
+		err = rows.Scan(&channel.UserId, &channel.ChannelID, &channel.ChannelTitle, &channel.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+// AddFollowedChannels makes the given user follow the given channels, skipping the ones already followed. The
+// number of channels added is returned
+func (s *Storage) AddFollowedChannels(ctx context.Context, userId int64, channels []FollowedChannel) (int, error) {
+	added := 0
+	err := s.withTx(ctx, func(tx *Storage) error {
+		for _, channel := range channels {
+			res, err := tx.q.ExecContext(ctx, tx.rebind("INSERT INTO followed_channels "+
+				"(user_id, channel_id, channel_title) VALUES (?, ?, ?) "+
+				"ON CONFLICT (user_id, channel_id) DO NOTHING"), userId, channel.ChannelID, channel.ChannelTitle)
+			if err != nil {
+				return err
+			}
+			inserted, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			added += int(inserted)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// DeleteFollowedChannel makes the given user stop following a channel
+func (s *Storage) DeleteFollowedChannel(ctx context.Context, userId int64, channelId string) error {
+	res, err := s.q.ExecContext(ctx, s.rebind("DELETE FROM followed_channels WHERE user_id = ? AND channel_id = ?"),
+		userId, channelId)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return fmt.Errorf("channel %s not followed by user %d", channelId, userId)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChannelGroup is a named group of YouTube channels of an app user, a channel can be in several groups
+type ChannelGroup struct {
+	Id         int64
+	UserId     int64
+	Name       string
+	ChannelIDs []string
+	CreatedAt  time.Time
+}
+
+// CreateChannelGroup stores a new empty channel group of the given user, returning its id
+func (s *Storage) CreateChannelGroup(ctx context.Context, userId int64, name string) (int64, error) {
+	var id int64
+	err := s.q.QueryRowContext(ctx, s.rebind("INSERT INTO channel_groups (user_id, name) VALUES (?, ?) "+
+		"RETURNING id"), userId, name).Scan(&id)
+	return id, err
+}
+
+// GetChannelGroups returns the channel groups of the given user sorted by name, along with their channels
+func (s *Storage) GetChannelGroups(ctx context.Context, userId int64) ([]ChannelGroup, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT id, user_id, name, created_at FROM channel_groups "+
+		"WHERE user_id = ? "+
+		"ORDER BY name, id"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]ChannelGroup, 0)
+	indexById := make(map[int64]int)
+	for rows.Next() {
+		group := ChannelGroup{ChannelIDs: []string{}}
+		if err = rows.Scan(&group.Id, &group.UserId, &group.Name, &group.CreatedAt); err != nil {
+			return nil, err
+		}
+		indexById[group.Id] = len(groups)
+		groups = append(groups, group)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := s.q.QueryContext(ctx, s.rebind("SELECT m.group_id, m.channel_id "+
+		"FROM channel_group_members m JOIN channel_groups g ON g.id = m.group_id "+
+		"WHERE g.user_id = ? "+
+		"ORDER BY m.group_id, m.channel_id"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer members.Close()
+
+	for members.Next() {
+		var groupId int64
+		var channelID string
+		if err = members.Scan(&groupId, &channelID); err != nil {
+			return nil, err
+		}
+		if i, ok := indexById[groupId]; ok {
+			groups[i].ChannelIDs = append(groups[i].ChannelIDs, channelID)
+		}
+	}
+	return groups, members.Err()
+}
+
+// RenameChannelGroup changes the name of a channel group of the given user
+func (s *Storage) RenameChannelGroup(ctx context.Context, userId, id int64, name string) error {
+	res, err := s.q.ExecContext(ctx, s.rebind("UPDATE channel_groups SET name = ? WHERE user_id = ? AND id = ?"),
+		name, userId, id)
+	if err != nil {
+		return err
+	}
+	return checkChannelGroupAffected(res, userId, id)
+}
+
+// DeleteChannelGroup removes a channel group of the given user, the channels are left untouched
+func (s *Storage) DeleteChannelGroup(ctx context.Context, userId, id int64) error {
+	res, err := s.q.ExecContext(ctx, s.rebind("DELETE FROM channel_groups WHERE user_id = ? AND id = ?"),
+		userId, id)
+	if err != nil {
+		return err
+	}
+	return checkChannelGroupAffected(res, userId, id)
+}
+
+// AddChannelsToGroup adds the given channels to a channel group of the user, the ones already in it are skipped
+func (s *Storage) AddChannelsToGroup(ctx context.Context, userId, groupId int64, channelIDs []string) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		if err := tx.checkChannelGroupOwner(ctx, userId, groupId); err != nil {
+			return err
+		}
+		for _, channelID := range channelIDs {
+			_, err := tx.q.ExecContext(ctx, tx.rebind("INSERT INTO channel_group_members (group_id, channel_id) "+
+				"VALUES (?, ?) "+
+				"ON CONFLICT (group_id, channel_id) DO NOTHING"), groupId, channelID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveChannelsFromGroup removes the given channels from a channel group of the user
+func (s *Storage) RemoveChannelsFromGroup(ctx context.Context, userId, groupId int64, channelIDs []string) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		if err := tx.checkChannelGroupOwner(ctx, userId, groupId); err != nil {
+			return err
+		}
+		for _, channelID := range channelIDs {
+			_, err := tx.q.ExecContext(ctx, tx.rebind("DELETE FROM channel_group_members "+
+				"WHERE group_id = ? AND channel_id = ?"), groupId, channelID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// return an error if the channel group doesn't exist or doesn't belong to the given user
+func (s *Storage) checkChannelGroupOwner(ctx context.Context, userId, id int64) error {
+	var found int
+	err := s.q.QueryRowContext(ctx, s.rebind("SELECT 1 FROM channel_groups WHERE user_id = ? AND id = ?"),
+		userId, id).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("channel group %d not found for user %d", id, userId)
+	}
+	return err
+}
+
+// return an error if no channel group was changed by the query
+func checkChannelGroupAffected(res sql.Result, userId, id int64) error {
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return fmt.Errorf("channel group %d not found for user %d", id, userId)
+	}
+	return nil
+}
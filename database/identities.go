@@ -0,0 +1,54 @@
+package database
+
+import (
+	"checkYoutube/logging"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// GetUserIdByLoginIdentity returns the id of the app user having the given identity on a login provider, 0 if none
+func (s *Storage) GetUserIdByLoginIdentity(ctx context.Context, provider, subject string) (int64, error) {
+	const funcName = "GetUserIdByLoginIdentity"
+
+	var userId int64
+	row := s.q.QueryRowContext(ctx, s.rebind("SELECT user_id FROM login_identities "+
+		"WHERE provider = ? AND subject = ?"),
+		provider, subject)
+	err := row.Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.Debug(fmt.Sprintf("identity %s of provider %s has no user", subject, provider),
+			logging.FuncNameAttr(funcName))
+		return 0, nil
+	}
+	return userId, err
+}
+
+// CreateUserWithLoginIdentity creates a new app user logging in with the given identity of a login provider
+func (s *Storage) CreateUserWithLoginIdentity(ctx context.Context, provider, subject,
+	displayName string) (int64, error) {
+	const funcName = "CreateUserWithLoginIdentity"
+
+	var userId int64
+	err := s.withTx(ctx, func(tx *Storage) error {
+		var err error
+		if userId, err = tx.createUser(ctx); err != nil {
+			slog.Error(fmt.Sprintf("failed to create user: %s", err.Error()), logging.FuncNameAttr(funcName))
+			return err
+		}
+
+		_, err = tx.q.ExecContext(ctx, tx.rebind("INSERT INTO login_identities "+
+			"(user_id, provider, subject, display_name) VALUES (?, ?, ?, ?)"), userId, provider, subject, displayName)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to add login identity to the new user: %s", err.Error()),
+				logging.FuncNameAttr(funcName))
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
@@ -0,0 +1,324 @@
+package database
+
+import (
+	"checkYoutube/logging"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// each dialect has its own migrations, in the directory named after it
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+const (
+	migrationsDir = "migrations"
+	// a lock older than this is considered left behind by a crashed runner
+	migrationLockStaleAfter = 10 * time.Minute
+	migrationLockTimeout    = 30 * time.Second
+	migrationLockRetryDelay = 500 * time.Millisecond
+)
+
+// migration files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// an up file starting with this comment needs the named database feature, see Storage.supports
+const migrationRequiresPrefix = "-- requires: "
+
+// Migration is a versioned change of the database schema, along with the SQL reverting it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Requires is the database feature the migration needs, the migration is left out when it's missing
+	Requires string
+}
+
+// MigrationStatus tells whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	// AppliedAt is nil when the migration is pending
+	AppliedAt *time.Time
+}
+
+// RunMigrations applies the pending database migrations
+func (s *Storage) RunMigrations(ctx context.Context) error {
+	_, err := s.MigrateUp(ctx)
+	return err
+}
+
+// MigrateUp applies the pending migrations in order, returning the applied ones
+func (s *Storage) MigrateUp(ctx context.Context) ([]Migration, error) {
+	const funcName = "MigrateUp"
+
+	migrations, err := s.availableMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0)
+	err = s.withMigrationLock(ctx, func() error {
+		appliedAt, err := s.appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if _, ok := appliedAt[migration.Version]; ok {
+				continue
+			}
+			if err = s.applyMigration(ctx, migration, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+			slog.Info(fmt.Sprintf("migration %04d_%s applied", migration.Version, migration.Name),
+				logging.FuncNameAttr(funcName))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to run migrations: %s", err.Error()), logging.FuncNameAttr(funcName))
+	}
+	return applied, err
+}
+
+// MigrateDown reverts the given number of applied migrations, the latest first, returning the reverted ones
+func (s *Storage) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	const funcName = "MigrateDown"
+
+	migrations, err := s.availableMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := make([]Migration, 0)
+	err = s.withMigrationLock(ctx, func() error {
+		appliedAt, err := s.appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := appliedAt[migration.Version]; !ok {
+				continue
+			}
+			if err = s.applyMigration(ctx, migration, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+			slog.Info(fmt.Sprintf("migration %04d_%s reverted", migration.Version, migration.Name),
+				logging.FuncNameAttr(funcName))
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to revert migrations: %s", err.Error()), logging.FuncNameAttr(funcName))
+	}
+	return reverted, err
+}
+
+// MigrationsStatus returns every known migration, telling whether it has been applied
+func (s *Storage) MigrationsStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := s.availableMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.createMigrationsTables(ctx); err != nil {
+		return nil, err
+	}
+	appliedAt, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Migration: migration}
+		if at, ok := appliedAt[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// run the migration, or revert it, and record it in a single transaction
+func (s *Storage) applyMigration(ctx context.Context, migration Migration, up bool) error {
+	statements, record := migration.Down, "DELETE FROM schema_migrations WHERE version = ?"
+	args := []any{migration.Version}
+	if up {
+		statements, record = migration.Up, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"
+		args = append(args, migration.Name, time.Now().UTC())
+	}
+	return s.withTx(ctx, func(tx *Storage) error {
+		if _, err := tx.q.ExecContext(ctx, statements); err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		_, err := tx.q.ExecContext(ctx, tx.rebind(record), args...)
+		return err
+	})
+}
+
+// return the applied migrations versions along with the time they were applied
+func (s *Storage) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := s.q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err = rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	return appliedAt, rows.Err()
+}
+
+func (s *Storage) createMigrationsTables(ctx context.Context) error {
+	_, err := s.q.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    INTEGER PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP    NOT NULL
+);
+CREATE TABLE IF NOT EXISTS schema_migrations_lock
+(
+    id        INTEGER PRIMARY KEY CHECK (id = 1),
+    owner     VARCHAR(255) NOT NULL,
+    locked_at TIMESTAMP    NOT NULL
+);`)
+	return err
+}
+
+// run the function holding the migrations lock, so that concurrent runners don't apply the same migrations
+func (s *Storage) withMigrationLock(ctx context.Context, f func() error) error {
+	const funcName = "withMigrationLock"
+
+	if err := s.createMigrationsTables(ctx); err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	deadline := time.Now().Add(migrationLockTimeout)
+	for {
+		// a lock left behind by a crashed runner is released first
+		_, err := s.q.ExecContext(ctx, s.rebind("DELETE FROM schema_migrations_lock WHERE locked_at < ?"),
+			time.Now().UTC().Add(-migrationLockStaleAfter))
+		if err != nil {
+			return err
+		}
+		_, err = s.q.ExecContext(ctx, s.rebind("INSERT INTO schema_migrations_lock (id, owner, locked_at) "+
+			"VALUES (1, ?, ?)"),
+			owner, time.Now().UTC())
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the migrations lock: %w", err)
+		}
+		slog.Info("waiting for another migrations runner to release the lock", logging.FuncNameAttr(funcName))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(migrationLockRetryDelay):
+		}
+	}
+	defer func() {
+		// the lock is released even when the context is canceled
+		_, err := s.q.ExecContext(context.WithoutCancel(ctx),
+			s.rebind("DELETE FROM schema_migrations_lock WHERE id = 1 AND owner = ?"), owner)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to release the migrations lock: %s", err.Error()),
+				logging.FuncNameAttr(funcName))
+		}
+	}()
+
+	return f()
+}
+
+// return the directory of the migrations written for the storage dialect
+func (s *Storage) migrationsDir() string {
+	return path.Join(migrationsDir, string(s.dialect))
+}
+
+// return the migrations of the storage dialect, leaving out the ones needing a feature the database lacks
+func (s *Storage) availableMigrations() ([]Migration, error) {
+	migrations, err := loadMigrations(migrationFiles, s.migrationsDir())
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(migrations, func(migration Migration) bool {
+		return migration.Requires != "" && !s.supports(migration.Requires)
+	}), nil
+}
+
+// read the migrations in the given directory of the file system, sorted by version
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		matches := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration version %d used by both %s and %s", version, migration.Name,
+				matches[2])
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+			firstLine, _, _ := strings.Cut(migration.Up, "\n")
+			if requires, ok := strings.CutPrefix(strings.TrimSpace(firstLine), migrationRequiresPrefix); ok {
+				migration.Requires = strings.TrimSpace(requires)
+			}
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", migration.Version,
+				migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id         BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS linked_accounts
+(
+    user_id      BIGINT              NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    account_id   VARCHAR(255) UNIQUE NOT NULL,
+    display_name VARCHAR(255)        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ         NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
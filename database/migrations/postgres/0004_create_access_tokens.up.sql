@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS access_tokens
+(
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT             NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         VARCHAR(255)       NOT NULL,
+    token_hash   VARCHAR(64) UNIQUE NOT NULL,
+    scopes       VARCHAR(255)       NOT NULL,
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ        NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN display_name  VARCHAR(255)  NOT NULL DEFAULT '',
+    ADD COLUMN avatar_url    VARCHAR(1024) NOT NULL DEFAULT '',
+    ADD COLUMN locale        VARCHAR(35)   NOT NULL DEFAULT '',
+    ADD COLUMN last_login_at TIMESTAMPTZ;
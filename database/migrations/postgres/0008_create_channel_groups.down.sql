@@ -0,0 +1,2 @@
+DROP TABLE channel_group_members;
+DROP TABLE channel_groups;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS channel_rules
+(
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    channel_id    VARCHAR(64)  NOT NULL,
+    channel_title VARCHAR(255) NOT NULL DEFAULT '',
+    kind          VARCHAR(16)  NOT NULL,
+    snoozed_until TIMESTAMPTZ,
+    title_pattern VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, channel_id, kind)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS video_rules
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    action     VARCHAR(16)  NOT NULL,
+    channel_id VARCHAR(64)  NOT NULL DEFAULT '',
+    field      VARCHAR(16)  NOT NULL,
+    operator   VARCHAR(16)  NOT NULL,
+    value      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS video_rules_user_id_idx ON video_rules (user_id);
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS alerts
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    query      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, query)
+);
+
+CREATE TABLE IF NOT EXISTS alert_matches
+(
+    id            BIGSERIAL PRIMARY KEY,
+    alert_id      BIGINT       NOT NULL REFERENCES alerts (id) ON DELETE CASCADE,
+    user_id       BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    video_id      VARCHAR(64)  NOT NULL,
+    video_title   VARCHAR(255) NOT NULL DEFAULT '',
+    channel_id    VARCHAR(64)  NOT NULL,
+    channel_title VARCHAR(255) NOT NULL DEFAULT '',
+    published_at  VARCHAR(64)  NOT NULL DEFAULT '',
+    read_at       TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (alert_id, video_id)
+);
+
+CREATE INDEX IF NOT EXISTS alert_matches_user_id_idx ON alert_matches (user_id, created_at);
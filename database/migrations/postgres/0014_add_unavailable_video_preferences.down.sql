@@ -0,0 +1,4 @@
+ALTER TABLE user_preferences
+    DROP COLUMN region,
+    DROP COLUMN skip_unavailable_videos,
+    DROP COLUMN detect_members_only_videos;
@@ -0,0 +1,4 @@
+ALTER TABLE user_preferences
+    ADD COLUMN region                     VARCHAR(2) NOT NULL DEFAULT '',
+    ADD COLUMN skip_unavailable_videos    BOOLEAN    NOT NULL DEFAULT TRUE,
+    ADD COLUMN detect_members_only_videos BOOLEAN    NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+DROP TABLE followed_channels;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS followed_channels
+(
+    user_id       BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    channel_id    VARCHAR(64)  NOT NULL,
+    channel_title VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, channel_id)
+);
@@ -1,3 +1,4 @@
+-- databases created before versioned migrations already have this table
 CREATE TABLE IF NOT EXISTS auth
 (
     user_id       VARCHAR(255) UNIQUE NOT NULL,
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS linked_accounts
+(
+    user_id      INTEGER             NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    account_id   VARCHAR(255) UNIQUE NOT NULL,
+    display_name VARCHAR(255)        NOT NULL DEFAULT '',
+    created_at   TIMESTAMP           NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
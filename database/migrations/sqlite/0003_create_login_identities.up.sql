@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS login_identities
+(
+    user_id      INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider     VARCHAR(255) NOT NULL,
+    subject      VARCHAR(255) NOT NULL,
+    display_name VARCHAR(255) NOT NULL DEFAULT '',
+    created_at   TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS access_tokens
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER            NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         VARCHAR(255)       NOT NULL,
+    token_hash   VARCHAR(64) UNIQUE NOT NULL,
+    scopes       VARCHAR(255)       NOT NULL,
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at   TIMESTAMP          NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS login_history
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    provider   VARCHAR(255) NOT NULL,
+    subject    VARCHAR(255) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45)  NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    outcome    VARCHAR(32)  NOT NULL,
+    reason     VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_history_user_id_idx ON login_history (user_id, created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_preferences
+(
+    user_id            INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    default_view       VARCHAR(16)  NOT NULL,
+    sort_column        VARCHAR(16)  NOT NULL,
+    sort_direction     VARCHAR(4)   NOT NULL,
+    timezone           VARCHAR(64)  NOT NULL DEFAULT '',
+    hidden_video_types VARCHAR(255) NOT NULL DEFAULT '',
+    page_size          INTEGER      NOT NULL DEFAULT 0,
+    theme              VARCHAR(16)  NOT NULL,
+    updated_at         TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
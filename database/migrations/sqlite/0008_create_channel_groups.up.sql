@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS channel_groups
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS channel_group_members
+(
+    group_id   INTEGER     NOT NULL REFERENCES channel_groups (id) ON DELETE CASCADE,
+    channel_id VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, channel_id)
+);
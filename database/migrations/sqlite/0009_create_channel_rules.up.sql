@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS channel_rules
+(
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id       INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    channel_id    VARCHAR(64)  NOT NULL,
+    channel_title VARCHAR(255) NOT NULL DEFAULT '',
+    kind          VARCHAR(16)  NOT NULL,
+    snoozed_until TIMESTAMP,
+    title_pattern VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, channel_id, kind)
+);
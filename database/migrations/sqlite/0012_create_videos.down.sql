@@ -0,0 +1,2 @@
+DROP TABLE user_videos;
+DROP TABLE videos;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS videos
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    video_id         VARCHAR(64)  NOT NULL UNIQUE,
+    channel_id       VARCHAR(64)  NOT NULL,
+    channel_title    VARCHAR(255) NOT NULL DEFAULT '',
+    title            VARCHAR(255) NOT NULL DEFAULT '',
+    description      TEXT         NOT NULL DEFAULT '',
+    published_at     TIMESTAMP,
+    duration_seconds INTEGER      NOT NULL DEFAULT 0,
+    type             VARCHAR(16)  NOT NULL DEFAULT '',
+    updated_at       TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS videos_channel_id_idx ON videos (channel_id, published_at);
+
+CREATE TABLE IF NOT EXISTS user_videos
+(
+    user_id       INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    video_id      VARCHAR(64) NOT NULL REFERENCES videos (video_id) ON DELETE CASCADE,
+    discovered_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, video_id)
+);
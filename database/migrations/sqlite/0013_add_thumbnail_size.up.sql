@@ -0,0 +1 @@
+ALTER TABLE user_preferences ADD COLUMN thumbnail_size VARCHAR(16) NOT NULL DEFAULT 'default';
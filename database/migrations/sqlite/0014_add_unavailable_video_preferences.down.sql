@@ -0,0 +1,3 @@
+ALTER TABLE user_preferences DROP COLUMN region;
+ALTER TABLE user_preferences DROP COLUMN skip_unavailable_videos;
+ALTER TABLE user_preferences DROP COLUMN detect_members_only_videos;
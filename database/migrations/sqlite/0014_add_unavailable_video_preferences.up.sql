@@ -0,0 +1,3 @@
+ALTER TABLE user_preferences ADD COLUMN region VARCHAR(2) NOT NULL DEFAULT '';
+ALTER TABLE user_preferences ADD COLUMN skip_unavailable_videos BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE user_preferences ADD COLUMN detect_members_only_videos BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+ALTER TABLE user_preferences DROP COLUMN inactive_channel_days;
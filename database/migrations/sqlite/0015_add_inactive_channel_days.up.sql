@@ -0,0 +1 @@
+ALTER TABLE user_preferences ADD COLUMN inactive_channel_days INTEGER NOT NULL DEFAULT 365;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS subscribed_channels
+(
+    user_id       INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    account_id    VARCHAR(255) NOT NULL,
+    channel_id    VARCHAR(64)  NOT NULL,
+    channel_title VARCHAR(255) NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, account_id, channel_id)
+);
+
+CREATE TABLE IF NOT EXISTS subscription_changes
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id        INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    account_id     VARCHAR(255) NOT NULL,
+    channel_id     VARCHAR(64)  NOT NULL,
+    channel_title  VARCHAR(255) NOT NULL DEFAULT '',
+    previous_title VARCHAR(255) NOT NULL DEFAULT '',
+    kind           VARCHAR(16)  NOT NULL,
+    detected_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS subscription_changes_user_id_idx ON subscription_changes (user_id, detected_at);
@@ -0,0 +1 @@
+ALTER TABLE user_preferences ADD COLUMN daily_watch_minutes INTEGER NOT NULL DEFAULT 60;
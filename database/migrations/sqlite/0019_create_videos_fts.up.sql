@@ -0,0 +1,4 @@
+-- requires: fts5
+CREATE VIRTUAL TABLE IF NOT EXISTS videos_fts USING fts5(title, description, channel_title);
+DELETE FROM videos_fts;
+INSERT INTO videos_fts (rowid, title, description, channel_title) SELECT id, title, description, channel_title FROM videos;
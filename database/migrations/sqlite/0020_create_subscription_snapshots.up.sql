@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS subscription_snapshots
+(
+    user_id    INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    account_id VARCHAR(255) NOT NULL,
+    taken_at   TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, account_id)
+);
+
+-- the accounts having subscriptions stored were snapshotted already
+INSERT INTO subscription_snapshots (user_id, account_id, taken_at)
+SELECT user_id, account_id, MAX(last_seen_at) FROM subscribed_channels GROUP BY user_id, account_id;
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_loadMigrations(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  []int
+		// wantRequires are the features required by the migrations, by version
+		wantRequires map[int]string
+		wantErr      bool
+	}{
+		{
+			name: "success case - sorted by version",
+			files: fstest.MapFS{
+				"migrations/0010_second.up.sql":   {Data: []byte("up")},
+				"migrations/0010_second.down.sql": {Data: []byte("down")},
+				"migrations/0002_first.up.sql":    {Data: []byte("up")},
+				"migrations/0002_first.down.sql":  {Data: []byte("down")},
+			},
+			want:    []int{2, 10},
+			wantErr: false,
+		},
+		{
+			name: "success case - migration requiring a feature",
+			files: fstest.MapFS{
+				"migrations/0001_first.up.sql":    {Data: []byte("up")},
+				"migrations/0001_first.down.sql":  {Data: []byte("down")},
+				"migrations/0002_search.up.sql":   {Data: []byte("-- requires: fts5\nup")},
+				"migrations/0002_search.down.sql": {Data: []byte("down")},
+			},
+			want:         []int{1, 2},
+			wantRequires: map[int]string{2: "fts5"},
+			wantErr:      false,
+		},
+		{
+			name: "error case - missing down file",
+			files: fstest.MapFS{
+				"migrations/0001_first.up.sql": {Data: []byte("up")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error case - duplicated version",
+			files: fstest.MapFS{
+				"migrations/0001_first.up.sql":   {Data: []byte("up")},
+				"migrations/0001_other.down.sql": {Data: []byte("down")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error case - invalid file name",
+			files: fstest.MapFS{
+				"migrations/first.sql": {Data: []byte("up")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadMigrations(tt.files, "migrations")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var gotVersions []int
+			for _, migration := range got {
+				gotVersions = append(gotVersions, migration.Version)
+				if migration.Requires != tt.wantRequires[migration.Version] {
+					t.Errorf("loadMigrations() migration %d requires %q, want %q", migration.Version,
+						migration.Requires, tt.wantRequires[migration.Version])
+				}
+			}
+			if fmt.Sprint(gotVersions) != fmt.Sprint(tt.want) {
+				t.Errorf("loadMigrations() got = %v, want %v", gotVersions, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorage_MigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	migrations, err := storage.availableMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a database created before versioned migrations, having the auth table only
+	_, err = storage.db.Exec("CREATE TABLE auth (user_id VARCHAR(255) UNIQUE NOT NULL, " +
+		"refresh_token VARCHAR(255) NOT NULL, created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+		"updated_at TIMESTAMP); INSERT INTO auth (user_id, refresh_token) VALUES ('1', 'refreshToken')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := storage.MigrateUp(ctx)
+	if err != nil || len(applied) != len(migrations) {
+		t.Fatalf("MigrateUp() applied %v migrations, error = %v, want %v", len(applied), err, len(migrations))
+	}
+	if refreshToken, err := storage.GetRefreshTokenByUserId(ctx, "1"); err != nil || refreshToken != "refreshToken" {
+		t.Errorf("MigrateUp() lost the existing refresh token: %v, %v", refreshToken, err)
+	}
+	if applied, err = storage.MigrateUp(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("MigrateUp() applied %v migrations twice, error = %v", len(applied), err)
+	}
+
+	reverted, err := storage.MigrateDown(ctx, 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != migrations[len(migrations)-1].Version {
+		t.Fatalf("MigrateDown() reverted = %v, error = %v", reverted, err)
+	}
+	statuses, err := storage.MigrationsStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range statuses {
+		if pending := i == len(statuses)-1; (status.AppliedAt == nil) != pending {
+			t.Errorf("MigrationsStatus() migration %d applied at %v, want pending %v", status.Version,
+				status.AppliedAt, pending)
+		}
+	}
+
+	if reverted, err = storage.MigrateDown(ctx, len(migrations)); err != nil || len(reverted) != len(migrations)-1 {
+		t.Errorf("MigrateDown() reverted %v migrations, error = %v", len(reverted), err)
+	}
+}
+
+func TestStorage_MigrateUp_concurrentRunners(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	const runners = 4
+
+	var wg sync.WaitGroup
+	errs := make(chan error, runners)
+	for i := 0; i < runners; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := openTestStorage(t, dbPath).MigrateUp(ctx)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("MigrateUp() error = %v", err)
+		}
+	}
+
+	migrations, err := openTestStorage(t, dbPath).availableMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err = openTestStorage(t, dbPath).db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(migrations) {
+		t.Errorf("schema_migrations has %v rows, want %v", count, len(migrations))
+	}
+}
+
+func newTestStorage(t *testing.T) *Storage {
+	return openTestStorage(t, filepath.Join(t.TempDir(), "test.db"))
+}
+
+func openTestStorage(t *testing.T, dbPath string) *Storage {
+	storage, err := New(context.Background(), Config{
+		Dialect:        SQLiteDialect,
+		DataSourceName: dbPath,
+		Pool:           PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+	return storage
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// feed views
+const (
+	FilteredView = "filtered"
+	AllView      = "all"
+)
+
+// feed sort columns and directions
+const (
+	SortByChannel     = "channel"
+	SortByPublishDate = "published"
+	SortByDuration    = "duration"
+	SortByNewItems    = "new_items"
+	SortAscending     = "asc"
+	SortDescending    = "desc"
+)
+
+// page themes
+const (
+	DarkTheme  = "dark"
+	LightTheme = "light"
+)
+
+// sizes of the video thumbnails shown in the feed, as named by YouTube, NoThumbnail hiding them
+const (
+	NoThumbnail       = "none"
+	DefaultThumbnail  = "default"
+	MediumThumbnail   = "medium"
+	HighThumbnail     = "high"
+	StandardThumbnail = "standard"
+	MaxResThumbnail   = "maxres"
+)
+
+// Preferences are the settings of an app user applied to the feed page
+type Preferences struct {
+	// DefaultView is the view shown when the feed page is opened without choosing one
+	DefaultView   string
+	SortColumn    string
+	SortDirection string
+	// Timezone is the IANA name of the timezone dates are shown in, empty for the one of the browser
+	Timezone         string
+	HiddenVideoTypes []string
+	// PageSize is the number of channels in a page, 0 to show all of them
+	PageSize      int
+	Theme         string
+	ThumbnailSize string
+	// Region is the ISO 3166-1 alpha-2 code of the country the videos are watched from, empty to ignore the region
+	// restrictions of the videos
+	Region string
+	// SkipUnavailableVideos replaces the latest videos that can't be watched with the newest watchable ones
+	SkipUnavailableVideos bool
+	// DetectMembersOnlyVideos checks the members-only videos of each channel, costing a YouTube API call per channel
+	DetectMembersOnlyVideos bool
+	// InactiveChannelDays is the number of days without uploads after which a channel is reported as dead
+	InactiveChannelDays int
+	// DailyWatchMinutes is the time spent watching videos each day, estimating the days to catch up on the backlog
+	DailyWatchMinutes int
+	// AlertWebhookURL is the URL the new alert matches are posted to as JSON, empty to only list them in the inbox
+	AlertWebhookURL string
+}
+
+// DefaultPreferences returns the preferences of the users that never changed them
+func DefaultPreferences() Preferences {
+	return Preferences{
+		DefaultView:           FilteredView,
+		SortColumn:            SortByChannel,
+		SortDirection:         SortAscending,
+		HiddenVideoTypes:      []string{},
+		Theme:                 DarkTheme,
+		ThumbnailSize:         DefaultThumbnail,
+		SkipUnavailableVideos: true,
+		InactiveChannelDays:   365,
+		DailyWatchMinutes:     60,
+	}
+}
+
+// GetPreferences returns the preferences of the given user, the default ones if never stored
+func (s *Storage) GetPreferences(ctx context.Context, userId int64) (Preferences, error) {
+	var preferences Preferences
+	var hiddenVideoTypes string
+	err := s.q.QueryRowContext(ctx, s.rebind("SELECT default_view, sort_column, sort_direction, timezone, "+
+		"hidden_video_types, page_size, theme, thumbnail_size, region, skip_unavailable_videos, "+
+		"detect_members_only_videos, inactive_channel_days, daily_watch_minutes, alert_webhook_url FROM user_preferences "+
+		"WHERE user_id = ?"), userId).
+		Scan(&preferences.DefaultView, &preferences.SortColumn, &preferences.SortDirection, &preferences.Timezone,
+			&hiddenVideoTypes, &preferences.PageSize, &preferences.Theme, &preferences.ThumbnailSize,
+			&preferences.Region, &preferences.SkipUnavailableVideos, &preferences.DetectMembersOnlyVideos,
+			&preferences.InactiveChannelDays, &preferences.DailyWatchMinutes, &preferences.AlertWebhookURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultPreferences(), nil
+	}
+	if err != nil {
+		return Preferences{}, err
+	}
+	preferences.HiddenVideoTypes = strings.Fields(hiddenVideoTypes)
+	return preferences, nil
+}
+
+// UpsertPreferences stores the preferences of the given user
+func (s *Storage) UpsertPreferences(ctx context.Context, userId int64, preferences Preferences) error {
+	_, err := s.q.ExecContext(ctx, s.rebind("INSERT INTO user_preferences "+
+		"(user_id, default_view, sort_column, sort_direction, timezone, hidden_video_types, page_size, theme, "+
+		"thumbnail_size, region, skip_unavailable_videos, detect_members_only_videos, inactive_channel_days, "+
+		"daily_watch_minutes, alert_webhook_url) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+		"ON CONFLICT(user_id) DO UPDATE SET default_view = excluded.default_view, "+
+		"sort_column = excluded.sort_column, sort_direction = excluded.sort_direction, "+
+		"timezone = excluded.timezone, hidden_video_types = excluded.hidden_video_types, "+
+		"page_size = excluded.page_size, theme = excluded.theme, thumbnail_size = excluded.thumbnail_size, "+
+		"region = excluded.region, skip_unavailable_videos = excluded.skip_unavailable_videos, "+
+		"detect_members_only_videos = excluded.detect_members_only_videos, "+
+		"inactive_channel_days = excluded.inactive_channel_days, "+
+		"daily_watch_minutes = excluded.daily_watch_minutes, alert_webhook_url = excluded.alert_webhook_url, "+
+		"updated_at = CURRENT_TIMESTAMP"),
+		userId, preferences.DefaultView, preferences.SortColumn, preferences.SortDirection, preferences.Timezone,
+		strings.Join(preferences.HiddenVideoTypes, " "), preferences.PageSize, preferences.Theme,
+		preferences.ThumbnailSize, preferences.Region, preferences.SkipUnavailableVideos,
+		preferences.DetectMembersOnlyVideos, preferences.InactiveChannelDays, preferences.DailyWatchMinutes,
+		preferences.AlertWebhookURL)
+	return err
+}
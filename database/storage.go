@@ -3,62 +3,333 @@ package database
 import (
 	"checkYoutube/configs"
 	"checkYoutube/logging"
+	"context"
 	"database/sql"
-	_ "embed"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-//go:embed migrations/schema.sql
-var schema []byte
+// Dialect is the SQL dialect of a storage backend
+type Dialect string
+
+const (
+	SQLiteDialect   Dialect = "sqlite"
+	PostgresDialect Dialect = "postgres"
+)
+
+// database/sql driver of each dialect
+var dialectDrivers = map[Dialect]string{
+	SQLiteDialect:   "sqlite3",
+	PostgresDialect: "postgres",
+}
+
+// SQLite connection settings: WAL lets readers run alongside the writer, the busy timeout makes other processes
+// wait for the write lock instead of failing, and transactions take the write lock upfront
+var sqliteParams = map[string]string{
+	"_journal_mode": "WAL",
+	"_busy_timeout": "5000",
+	"_foreign_keys": "on",
+	"_txlock":       "immediate",
+}
 
 type StorageInterface interface {
-	Init() error
-	RunMigrations() error
-	GetRefreshTokenByUserId(userId string) (string, error)
-	UpsertRefreshToken(userId, refreshToken string) error
+	RunMigrations(ctx context.Context) error
+	// WithTx runs f in a transaction, committed if f returns no error and rolled back otherwise
+	WithTx(ctx context.Context, f func(tx StorageInterface) error) error
+	GetRefreshTokenByUserId(ctx context.Context, userId string) (string, error)
+	UpsertRefreshToken(ctx context.Context, userId, refreshToken string) error
+	GetUserIdByAccountId(ctx context.Context, accountId string) (int64, error)
+	CreateUserWithAccount(ctx context.Context, accountId, displayName string) (int64, error)
+	LinkAccount(ctx context.Context, userId int64, accountId, displayName string) error
+	GetLinkedAccounts(ctx context.Context, userId int64) ([]LinkedAccount, error)
+	UnlinkAccount(ctx context.Context, userId int64, accountId string) error
+	GetUserIdByLoginIdentity(ctx context.Context, provider, subject string) (int64, error)
+	CreateUserWithLoginIdentity(ctx context.Context, provider, subject, displayName string) (int64, error)
+	CreateAccessToken(ctx context.Context, userId int64, name, tokenHash string, scopes []string,
+		expiresAt *time.Time) (int64, error)
+	GetAccessTokenByHash(ctx context.Context, tokenHash string) (*AccessToken, error)
+	GetAccessTokens(ctx context.Context, userId int64) ([]AccessToken, error)
+	UpdateAccessTokenLastUsed(ctx context.Context, id int64) error
+	DeleteAccessToken(ctx context.Context, userId, id int64) error
+	GetUserProfile(ctx context.Context, userId int64) (*UserProfile, error)
+	RecordUserLogin(ctx context.Context, profile UserProfile) error
+	AddLoginEvent(ctx context.Context, event LoginEvent) error
+	GetLoginHistory(ctx context.Context, userId int64, limit int) ([]LoginEvent, error)
+	GetPreferences(ctx context.Context, userId int64) (Preferences, error)
+	UpsertPreferences(ctx context.Context, userId int64, preferences Preferences) error
+	CreateChannelGroup(ctx context.Context, userId int64, name string) (int64, error)
+	GetChannelGroups(ctx context.Context, userId int64) ([]ChannelGroup, error)
+	RenameChannelGroup(ctx context.Context, userId, id int64, name string) error
+	DeleteChannelGroup(ctx context.Context, userId, id int64) error
+	AddChannelsToGroup(ctx context.Context, userId, groupId int64, channelIDs []string) error
+	RemoveChannelsFromGroup(ctx context.Context, userId, groupId int64, channelIDs []string) error
+	UpsertChannelRule(ctx context.Context, rule ChannelRule) error
+	GetChannelRules(ctx context.Context, userId int64) ([]ChannelRule, error)
+	DeleteChannelRule(ctx context.Context, userId, id int64) error
+	CreateVideoRule(ctx context.Context, rule VideoRule) (int64, error)
+	GetVideoRules(ctx context.Context, userId int64) ([]VideoRule, error)
+	DeleteVideoRule(ctx context.Context, userId, id int64) error
+	CreateAlert(ctx context.Context, userId int64, query string) (int64, error)
+	GetAlerts(ctx context.Context, userId int64) ([]Alert, error)
+	MarkAlertsChecked(ctx context.Context, userId int64, checkedAt time.Time) error
+	DeleteAlert(ctx context.Context, userId, id int64) error
+	AddAlertMatches(ctx context.Context, matches []AlertMatch) ([]AlertMatch, error)
+	GetAlertMatches(ctx context.Context, userId int64, limit int) ([]AlertMatch, error)
+	CountUnreadAlertMatches(ctx context.Context, userId int64) (int, error)
+	MarkAlertMatchesRead(ctx context.Context, userId int64) error
+	UpsertVideos(ctx context.Context, userId int64, videos []Video) error
+	GetVideoChannels(ctx context.Context, userId int64) ([]VideoChannel, error)
+	SearchVideos(ctx context.Context, search VideoSearch) ([]VideoSearchResult, error)
+	LockSubscribedChannels(ctx context.Context, userId int64, accountId string) error
+	GetSubscribedChannels(ctx context.Context, userId int64, accountId string) ([]SubscribedChannel, error)
+	HasSubscriptionSnapshot(ctx context.Context, userId int64, accountId string) (bool, error)
+	ReplaceSubscribedChannels(ctx context.Context, userId int64, accountId string, channels []SubscribedChannel) error
+	AddSubscriptionChanges(ctx context.Context, changes []SubscriptionChange) error
+	GetSubscriptionChanges(ctx context.Context, userId int64, limit int) ([]SubscriptionChange, error)
+	GetFollowedChannels(ctx context.Context, userId int64) ([]FollowedChannel, error)
+	AddFollowedChannels(ctx context.Context, userId int64, channels []FollowedChannel) (int, error)
+	DeleteFollowedChannel(ctx context.Context, userId int64, channelId string) error
+}
+
+// querier runs the queries of a storage, either on the database or within a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 type Storage struct {
 	db *sql.DB
+	// q is the transaction of a storage passed to WithTx, the database otherwise
+	q       querier
+	dialect Dialect
+	// fts5 tells whether SQLite has the FTS5 module, built in by the sqlite_fts5 tag
+	fts5 bool
 }
 
-func (s *Storage) Init() error {
-	const funcName = "Init"
+// Config holds the database connection settings
+type Config struct {
+	Dialect        Dialect
+	DataSourceName string
+	Pool           PoolConfig
+}
+
+// PoolConfig holds the connection pool settings, zero values keep the database/sql defaults
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// LoadConfig reads the database config from the DATABASE_URL env variable, falling back to the
+// SQLite database at SQLITE_DB_PATH, along with the connection pool settings
+func LoadConfig() (Config, error) {
+	const funcName = "LoadConfig"
 
-	dbPath, err := configs.GetEnvOrErr("SQLITE_DB_PATH")
+	dsn, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || dsn == "" {
+		dbPath, err := configs.GetEnvOrErr("SQLITE_DB_PATH")
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to get database path: %s", err.Error()), logging.FuncNameAttr(funcName))
+			return Config{}, err
+		}
+		dsn = "sqlite://" + dbPath
+	}
+	dialect, dataSourceName, err := ParseDSN(dsn)
 	if err != nil {
-		slog.Error(fmt.Sprintf("failed to get database path: %s", err.Error()), logging.FuncNameAttr(funcName))
-		return err
+		return Config{}, err
+	}
+	poolConfig, err := LoadPoolConfig(dialect)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid connection pool settings: %w", err)
 	}
 
-	s.db, err = sql.Open("sqlite3", dbPath)
+	return Config{Dialect: dialect, DataSourceName: dataSourceName, Pool: poolConfig}, nil
+}
+
+// New connects to the database of the given config
+func New(ctx context.Context, config Config) (*Storage, error) {
+	const funcName = "New"
+
+	driver, ok := dialectDrivers[config.Dialect]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database dialect: %s", config.Dialect)
+	}
+	dataSourceName := config.DataSourceName
+	if config.Dialect == SQLiteDialect {
+		dataSourceName = sqliteDataSourceName(dataSourceName)
+	}
+
+	db, err := sql.Open(driver, dataSourceName)
 	if err != nil {
-		slog.Error(fmt.Sprintf("failed to connect to database: %s", err.Error()), logging.FuncNameAttr(funcName))
-		return err
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.Pool.MaxOpenConns)
+	if config.Pool.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(config.Pool.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(config.Pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.Pool.ConnMaxIdleTime)
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	storage := &Storage{db: db, q: db, dialect: config.Dialect}
+	if config.Dialect == SQLiteDialect {
+		err = db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&storage.fts5)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		if !storage.fts5 {
+			slog.Info("SQLite lacks the FTS5 module, videos are searched without the full-text index",
+				logging.FuncNameAttr(funcName))
+		}
+	}
+
+	slog.Info(fmt.Sprintf("%s database connection created", config.Dialect), logging.FuncNameAttr(funcName))
+	return storage, nil
+}
+
+// Close closes the database connections
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// ParseDSN returns the dialect selected by the scheme of the DSN along with the data source name to open:
+// postgres:// and postgresql:// URLs select PostgreSQL, sqlite:// URLs select SQLite
+func ParseDSN(dsn string) (Dialect, string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return PostgresDialect, dsn, nil
+	}
+	if dbPath, found := strings.CutPrefix(dsn, "sqlite://"); found && dbPath != "" {
+		return SQLiteDialect, dbPath, nil
+	}
+	return "", "", fmt.Errorf("unsupported database URL, expected a postgres:// or sqlite:// URL")
+}
+
+// LoadPoolConfig reads the connection pool settings from the DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS,
+// DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME env variables, falling back to the dialect defaults.
+// SQLite defaults to a single connection, so that writes are serialized instead of failing as busy
+func LoadPoolConfig(dialect Dialect) (PoolConfig, error) {
+	poolConfig := PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1}
+	if dialect == PostgresDialect {
+		poolConfig = PoolConfig{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute}
+	}
+
+	var err error
+	for varName, value := range map[string]*int{
+		"DB_MAX_OPEN_CONNS": &poolConfig.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &poolConfig.MaxIdleConns,
+	} {
+		if varValue, ok := os.LookupEnv(varName); ok && varValue != "" {
+			if *value, err = strconv.Atoi(varValue); err != nil || *value < 0 {
+				return PoolConfig{}, fmt.Errorf("%s must be a non-negative integer, got %s", varName, varValue)
+			}
+		}
 	}
+	for varName, value := range map[string]*time.Duration{
+		"DB_CONN_MAX_LIFETIME":  &poolConfig.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME": &poolConfig.ConnMaxIdleTime,
+	} {
+		if varValue, ok := os.LookupEnv(varName); ok && varValue != "" {
+			if *value, err = time.ParseDuration(varValue); err != nil || *value < 0 {
+				return PoolConfig{}, fmt.Errorf("%s must be a non-negative duration, got %s", varName, varValue)
+			}
+		}
+	}
+	return poolConfig, nil
+}
 
-	slog.Info("sqLite database connection created", logging.FuncNameAttr(funcName))
-	return nil
+// add the SQLite connection settings to the database path, unless already set by it
+func sqliteDataSourceName(dbPath string) string {
+	dbPath, rawQuery, _ := strings.Cut(dbPath, "?")
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		params = url.Values{}
+	}
+	for name, value := range sqliteParams {
+		if !params.Has(name) {
+			params.Set(name, value)
+		}
+	}
+	return dbPath + "?" + params.Encode()
 }
 
-// RunMigrations runs database migrations from embedded files
-func (s *Storage) RunMigrations() error {
-	const funcName = "RunMigrations"
+// Dialect returns the SQL dialect of the connected database
+func (s *Storage) Dialect() Dialect {
+	return s.dialect
+}
 
-	_, err := s.db.Exec(string(schema))
+// tell whether the database has the given feature, needed by some migrations
+func (s *Storage) supports(feature string) bool {
+	switch feature {
+	case "fts5":
+		return s.fts5
+	default:
+		return false
+	}
+}
+
+func (s *Storage) WithTx(ctx context.Context, f func(tx StorageInterface) error) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		return f(tx)
+	})
+}
+
+// run f with a storage bound to a new transaction, or to the current one when already in a transaction
+func (s *Storage) withTx(ctx context.Context, f func(tx *Storage) error) error {
+	if _, inTx := s.q.(*sql.Tx); inTx {
+		return f(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		slog.Error(fmt.Sprintf("failed to run migrations: %s", err.Error()), logging.FuncNameAttr(funcName))
+		return err
 	}
-	return err
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = f(&Storage{db: s.db, q: tx, dialect: s.dialect, fts5: s.fts5}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebind turns the ? placeholders of the query into the ones of the storage dialect
+func (s *Storage) rebind(query string) string {
+	if s.dialect != PostgresDialect {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
 }
 
-func (s *Storage) GetRefreshTokenByUserId(userId string) (string, error) {
+func (s *Storage) GetRefreshTokenByUserId(ctx context.Context, userId string) (string, error) {
 	const funcName = "GetRefreshTokenByUserId"
 
 	var refreshToken string
-	row := s.db.QueryRow("SELECT refresh_token FROM auth WHERE user_id = ?", userId)
+	row := s.q.QueryRowContext(ctx, s.rebind("SELECT refresh_token FROM auth WHERE user_id = ?"), userId)
 	err := row.Scan(&refreshToken)
 	if errors.Is(err, sql.ErrNoRows) {
 		slog.Warn(fmt.Sprintf("no entry found in database for userId %s", userId), logging.FuncNameAttr(funcName))
@@ -67,9 +338,9 @@ func (s *Storage) GetRefreshTokenByUserId(userId string) (string, error) {
 	return refreshToken, err
 }
 
-func (s *Storage) UpsertRefreshToken(userId, refreshToken string) error {
-	_, err := s.db.Exec("INSERT INTO auth (user_id, refresh_token) VALUES (?, ?) "+
-		"ON CONFLICT(user_id) DO UPDATE SET refresh_token = excluded.refresh_token, updated_at = datetime('now')",
+func (s *Storage) UpsertRefreshToken(ctx context.Context, userId, refreshToken string) error {
+	_, err := s.q.ExecContext(ctx, s.rebind("INSERT INTO auth (user_id, refresh_token) VALUES (?, ?) "+
+		"ON CONFLICT(user_id) DO UPDATE SET refresh_token = excluded.refresh_token, updated_at = CURRENT_TIMESTAMP"),
 		userId, refreshToken)
 	return err
 }
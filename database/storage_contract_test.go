@@ -0,0 +1,855 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	_ "github.com/lib/pq"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the contract every storage backend must fulfil, run against a freshly migrated storage for each case
+func testStorageContract(t *testing.T, newStorage func(t *testing.T) *Storage) {
+	ctx := context.Background()
+
+	t.Run("refresh tokens", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		if refreshToken, err := storage.GetRefreshTokenByUserId(ctx, "account1"); err != nil || refreshToken != "" {
+			t.Errorf("GetRefreshTokenByUserId() got = %v, error = %v, want no token", refreshToken, err)
+		}
+		for _, want := range []string{"refreshToken1", "refreshToken2"} {
+			if err := storage.UpsertRefreshToken(ctx, "account1", want); err != nil {
+				t.Fatalf("UpsertRefreshToken() error = %v", err)
+			}
+			if got, err := storage.GetRefreshTokenByUserId(ctx, "account1"); err != nil || got != want {
+				t.Errorf("GetRefreshTokenByUserId() got = %v, error = %v, want %v", got, err, want)
+			}
+		}
+	})
+
+	t.Run("linked accounts", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		if userId, err := storage.GetUserIdByAccountId(ctx, "account1"); err != nil || userId != 0 {
+			t.Errorf("GetUserIdByAccountId() got = %v, error = %v, want 0", userId, err)
+		}
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil || userId == 0 {
+			t.Fatalf("CreateUserWithAccount() got = %v, error = %v", userId, err)
+		}
+		if got, err := storage.GetUserIdByAccountId(ctx, "account1"); err != nil || got != userId {
+			t.Errorf("GetUserIdByAccountId() got = %v, error = %v, want %v", got, err, userId)
+		}
+		otherUserId, err := storage.CreateUserWithAccount(ctx, "account3", "user3")
+		if err != nil || otherUserId == userId {
+			t.Fatalf("CreateUserWithAccount() got = %v, error = %v, want a new user", otherUserId, err)
+		}
+
+		if err = storage.UpsertRefreshToken(ctx, "account2", "refreshToken2"); err != nil {
+			t.Fatal(err)
+		}
+		if err = storage.LinkAccount(ctx, userId, "account2", "user2"); err != nil {
+			t.Fatalf("LinkAccount() error = %v", err)
+		}
+		// an account linked to another user is moved
+		if err = storage.LinkAccount(ctx, userId, "account3", "user3 renamed"); err != nil {
+			t.Fatalf("LinkAccount() error = %v", err)
+		}
+		assertLinkedAccounts(t, storage, userId, []LinkedAccount{
+			{AccountId: "account1", DisplayName: "user1"},
+			{AccountId: "account2", DisplayName: "user2", RefreshToken: "refreshToken2"},
+			{AccountId: "account3", DisplayName: "user3 renamed"},
+		})
+		assertLinkedAccounts(t, storage, otherUserId, []LinkedAccount{})
+
+		if err = storage.UnlinkAccount(ctx, userId, "account2"); err != nil {
+			t.Fatalf("UnlinkAccount() error = %v", err)
+		}
+		if refreshToken, err := storage.GetRefreshTokenByUserId(ctx, "account2"); err != nil || refreshToken != "" {
+			t.Errorf("UnlinkAccount() kept the refresh token %v, error = %v", refreshToken, err)
+		}
+		if err = storage.UnlinkAccount(ctx, otherUserId, "account1"); err == nil {
+			t.Errorf("UnlinkAccount() of an account linked to another user error = nil")
+		}
+		assertLinkedAccounts(t, storage, userId, []LinkedAccount{
+			{AccountId: "account1", DisplayName: "user1"},
+			{AccountId: "account3", DisplayName: "user3 renamed"},
+		})
+	})
+
+	t.Run("login identities", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		if userId, err := storage.GetUserIdByLoginIdentity(ctx, "sso", "subject1"); err != nil || userId != 0 {
+			t.Errorf("GetUserIdByLoginIdentity() got = %v, error = %v, want 0", userId, err)
+		}
+		userId, err := storage.CreateUserWithLoginIdentity(ctx, "sso", "subject1", "user1")
+		if err != nil || userId == 0 {
+			t.Fatalf("CreateUserWithLoginIdentity() got = %v, error = %v", userId, err)
+		}
+		if got, err := storage.GetUserIdByLoginIdentity(ctx, "sso", "subject1"); err != nil || got != userId {
+			t.Errorf("GetUserIdByLoginIdentity() got = %v, error = %v, want %v", got, err, userId)
+		}
+		if got, err := storage.GetUserIdByLoginIdentity(ctx, "other", "subject1"); err != nil || got != 0 {
+			t.Errorf("GetUserIdByLoginIdentity() of another provider got = %v, error = %v, want 0", got, err)
+		}
+		if _, err = storage.CreateUserWithLoginIdentity(ctx, "sso", "subject1", "user1"); err == nil {
+			t.Errorf("CreateUserWithLoginIdentity() of an existing identity error = nil")
+		}
+	})
+
+	t.Run("access tokens", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expiresAt := time.Now().Add(24 * time.Hour).UTC()
+		id1, err := storage.CreateAccessToken(ctx, userId, "token1", "hash1", []string{"feed:read", "feed:mark_viewed"},
+			&expiresAt)
+		if err != nil || id1 == 0 {
+			t.Fatalf("CreateAccessToken() got = %v, error = %v", id1, err)
+		}
+		id2, err := storage.CreateAccessToken(ctx, userId, "token2", "hash2", []string{"settings:manage"}, nil)
+		if err != nil || id2 == id1 {
+			t.Fatalf("CreateAccessToken() got = %v, error = %v, want a new id", id2, err)
+		}
+		if _, err = storage.CreateAccessToken(ctx, userId, "token3", "hash1", nil, nil); err == nil {
+			t.Errorf("CreateAccessToken() with an existing hash error = nil")
+		}
+
+		accessToken, err := storage.GetAccessTokenByHash(ctx, "hash1")
+		if err != nil || accessToken == nil {
+			t.Fatalf("GetAccessTokenByHash() got = %v, error = %v", accessToken, err)
+		}
+		if accessToken.Id != id1 || accessToken.UserId != userId || accessToken.Name != "token1" ||
+			!slices.Equal(accessToken.Scopes, []string{"feed:read", "feed:mark_viewed"}) ||
+			accessToken.LastUsedAt != nil || accessToken.CreatedAt.IsZero() {
+			t.Errorf("GetAccessTokenByHash() got = %+v", accessToken)
+		}
+		if accessToken.ExpiresAt == nil || accessToken.ExpiresAt.Sub(expiresAt).Abs() > time.Millisecond {
+			t.Errorf("GetAccessTokenByHash() expires at %v, want %v", accessToken.ExpiresAt, expiresAt)
+		}
+		if accessToken, err = storage.GetAccessTokenByHash(ctx, "unknown"); err != nil || accessToken != nil {
+			t.Errorf("GetAccessTokenByHash() got = %v, error = %v, want nil", accessToken, err)
+		}
+
+		if err = storage.UpdateAccessTokenLastUsed(ctx, id2); err != nil {
+			t.Fatalf("UpdateAccessTokenLastUsed() error = %v", err)
+		}
+		accessTokens, err := storage.GetAccessTokens(ctx, userId)
+		if err != nil || len(accessTokens) != 2 {
+			t.Fatalf("GetAccessTokens() got = %v, error = %v", accessTokens, err)
+		}
+		if accessTokens[0].Id != id1 || accessTokens[1].Id != id2 || accessTokens[1].ExpiresAt != nil ||
+			accessTokens[1].LastUsedAt == nil {
+			t.Errorf("GetAccessTokens() got = %+v", accessTokens)
+		}
+
+		if err = storage.DeleteAccessToken(ctx, userId+1, id1); err == nil {
+			t.Errorf("DeleteAccessToken() of another user token error = nil")
+		}
+		if err = storage.DeleteAccessToken(ctx, userId, id1); err != nil {
+			t.Errorf("DeleteAccessToken() error = %v", err)
+		}
+		if accessToken, err = storage.GetAccessTokenByHash(ctx, "hash1"); err != nil || accessToken != nil {
+			t.Errorf("GetAccessTokenByHash() of a deleted token got = %v, error = %v", accessToken, err)
+		}
+	})
+
+	t.Run("user profiles and login history", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		profile, err := storage.GetUserProfile(ctx, userId)
+		if err != nil || profile == nil || profile.FirstSeenAt.IsZero() || profile.LastLoginAt != nil {
+			t.Fatalf("GetUserProfile() of a new user got = %+v, error = %v", profile, err)
+		}
+		if profile, err = storage.GetUserProfile(ctx, userId+1); err != nil || profile != nil {
+			t.Errorf("GetUserProfile() of an unknown user got = %+v, error = %v, want nil", profile, err)
+		}
+
+		err = storage.RecordUserLogin(ctx, UserProfile{UserId: userId, DisplayName: "user1",
+			AvatarURL: "https://example.com/avatar.png", Locale: "it"})
+		if err != nil {
+			t.Fatalf("RecordUserLogin() error = %v", err)
+		}
+		profile, err = storage.GetUserProfile(ctx, userId)
+		if err != nil || profile == nil {
+			t.Fatalf("GetUserProfile() got = %+v, error = %v", profile, err)
+		}
+		if profile.DisplayName != "user1" || profile.AvatarURL != "https://example.com/avatar.png" ||
+			profile.Locale != "it" || profile.LastLoginAt == nil {
+			t.Errorf("GetUserProfile() got = %+v", profile)
+		}
+
+		for _, event := range []LoginEvent{
+			{UserId: userId, Provider: "google", Subject: "account1", IPAddress: "127.0.0.1", UserAgent: "test",
+				Outcome: LoginFailed, Reason: "test error"},
+			{Provider: "google", IPAddress: "127.0.0.1", Outcome: LoginFailed, Reason: "unknown user"},
+			{UserId: userId, Provider: "google", Subject: "account1", IPAddress: "::1", UserAgent: "test",
+				Outcome: LoginSucceeded},
+		} {
+			if err = storage.AddLoginEvent(ctx, event); err != nil {
+				t.Fatalf("AddLoginEvent() error = %v", err)
+			}
+		}
+		events, err := storage.GetLoginHistory(ctx, userId, 10)
+		if err != nil || len(events) != 2 {
+			t.Fatalf("GetLoginHistory() got = %+v, error = %v", events, err)
+		}
+		if events[0].Outcome != LoginSucceeded || events[0].IPAddress != "::1" || events[0].CreatedAt.IsZero() ||
+			events[1].Outcome != LoginFailed || events[1].Reason != "test error" || events[1].UserId != userId {
+			t.Errorf("GetLoginHistory() got = %+v", events)
+		}
+		if events, err = storage.GetLoginHistory(ctx, userId, 1); err != nil || len(events) != 1 {
+			t.Errorf("GetLoginHistory() with limit 1 got = %+v, error = %v", events, err)
+		}
+	})
+
+	t.Run("preferences", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		preferences, err := storage.GetPreferences(ctx, userId)
+		if err != nil || !reflect.DeepEqual(preferences, DefaultPreferences()) {
+			t.Fatalf("GetPreferences() of a new user got = %+v, error = %v, want the default ones", preferences, err)
+		}
+
+		want := Preferences{DefaultView: AllView, SortColumn: SortByPublishDate, SortDirection: SortDescending,
+			Timezone: "Europe/Rome", HiddenVideoTypes: []string{"short", "live"}, PageSize: 50, Theme: LightTheme,
+			ThumbnailSize: MediumThumbnail, Region: "IT", SkipUnavailableVideos: false, DetectMembersOnlyVideos: true,
+			InactiveChannelDays: 180, DailyWatchMinutes: 90, AlertWebhookURL: "https://example.com/hook"}
+		for _, stored := range []Preferences{DefaultPreferences(), want} {
+			if err = storage.UpsertPreferences(ctx, userId, stored); err != nil {
+				t.Fatalf("UpsertPreferences() error = %v", err)
+			}
+		}
+		if preferences, err = storage.GetPreferences(ctx, userId); err != nil || !reflect.DeepEqual(preferences, want) {
+			t.Errorf("GetPreferences() got = %+v, error = %v, want %+v", preferences, err, want)
+		}
+	})
+
+	t.Run("channel groups", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherUserId, err := storage.CreateUserWithAccount(ctx, "account2", "user2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		musicId, err := storage.CreateChannelGroup(ctx, userId, "Music")
+		if err != nil {
+			t.Fatalf("CreateChannelGroup() error = %v", err)
+		}
+		techId, err := storage.CreateChannelGroup(ctx, userId, "Tech")
+		if err != nil {
+			t.Fatalf("CreateChannelGroup() error = %v", err)
+		}
+		if _, err = storage.CreateChannelGroup(ctx, userId, "Music"); err == nil {
+			t.Errorf("CreateChannelGroup() with a duplicated name expected an error")
+		}
+		if _, err = storage.CreateChannelGroup(ctx, otherUserId, "Music"); err != nil {
+			t.Errorf("CreateChannelGroup() with the name of a group of another user error = %v", err)
+		}
+
+		if err = storage.AddChannelsToGroup(ctx, userId, musicId, []string{"channel2", "channel1"}); err != nil {
+			t.Fatalf("AddChannelsToGroup() error = %v", err)
+		}
+		if err = storage.AddChannelsToGroup(ctx, userId, musicId, []string{"channel1", "channel3"}); err != nil {
+			t.Fatalf("AddChannelsToGroup() with a channel already in the group error = %v", err)
+		}
+		if err = storage.AddChannelsToGroup(ctx, userId, techId, []string{"channel1"}); err != nil {
+			t.Fatalf("AddChannelsToGroup() error = %v", err)
+		}
+		if err = storage.AddChannelsToGroup(ctx, otherUserId, musicId, []string{"channel4"}); err == nil {
+			t.Errorf("AddChannelsToGroup() to a group of another user expected an error")
+		}
+		if err = storage.RemoveChannelsFromGroup(ctx, userId, musicId, []string{"channel2"}); err != nil {
+			t.Fatalf("RemoveChannelsFromGroup() error = %v", err)
+		}
+		if err = storage.RenameChannelGroup(ctx, userId, techId, "Tech talks"); err != nil {
+			t.Fatalf("RenameChannelGroup() error = %v", err)
+		}
+		if err = storage.RenameChannelGroup(ctx, otherUserId, techId, "Kids"); err == nil {
+			t.Errorf("RenameChannelGroup() of a group of another user expected an error")
+		}
+
+		groups, err := storage.GetChannelGroups(ctx, userId)
+		if err != nil {
+			t.Fatalf("GetChannelGroups() error = %v", err)
+		}
+		var got []string
+		for _, group := range groups {
+			got = append(got, group.Name+": "+strings.Join(group.ChannelIDs, " "))
+		}
+		want := []string{"Music: channel1 channel3", "Tech talks: channel1"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetChannelGroups() got = %v, want %v", got, want)
+		}
+
+		if err = storage.DeleteChannelGroup(ctx, otherUserId, musicId); err == nil {
+			t.Errorf("DeleteChannelGroup() of a group of another user expected an error")
+		}
+		if err = storage.DeleteChannelGroup(ctx, userId, musicId); err != nil {
+			t.Fatalf("DeleteChannelGroup() error = %v", err)
+		}
+		if groups, err = storage.GetChannelGroups(ctx, userId); err != nil || len(groups) != 1 || groups[0].Id != techId {
+			t.Errorf("GetChannelGroups() after delete got = %+v, error = %v, want only group %d", groups, err, techId)
+		}
+	})
+
+	t.Run("channel rules", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+		expired, snoozed := now.Add(-time.Hour), now.Add(7*24*time.Hour)
+		for _, rule := range []ChannelRule{
+			{UserId: userId, ChannelID: "channel1", ChannelTitle: "b", Kind: MuteRule},
+			{UserId: userId, ChannelID: "channel2", ChannelTitle: "a", Kind: SnoozeRule, SnoozedUntil: &expired},
+			{UserId: userId, ChannelID: "channel2", ChannelTitle: "a", Kind: SnoozeRule, SnoozedUntil: &snoozed},
+			{UserId: userId, ChannelID: "channel2", ChannelTitle: "a", Kind: TitleMatchRule, TitlePattern: "tutorial"},
+			{UserId: userId, ChannelID: "channel3", ChannelTitle: "c", Kind: SnoozeRule, SnoozedUntil: &expired},
+		} {
+			if err = storage.UpsertChannelRule(ctx, rule); err != nil {
+				t.Fatalf("UpsertChannelRule() error = %v", err)
+			}
+		}
+
+		rules, err := storage.GetChannelRules(ctx, userId)
+		if err != nil {
+			t.Fatalf("GetChannelRules() error = %v", err)
+		}
+		var got []string
+		for _, rule := range rules {
+			got = append(got, fmt.Sprintf("%s %s %s %v", rule.ChannelID, rule.Kind, rule.TitlePattern,
+				rule.Active(now)))
+		}
+		want := []string{"channel2 snooze  true", "channel2 title_match tutorial true", "channel1 mute  true",
+			"channel3 snooze  false"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetChannelRules() got = %v, want %v", got, want)
+		}
+		if !rules[0].SnoozedUntil.Equal(snoozed) {
+			t.Errorf("GetChannelRules() snoozed until %v, want %v", rules[0].SnoozedUntil, snoozed)
+		}
+
+		if err = storage.DeleteChannelRule(ctx, userId+1, rules[0].Id); err == nil {
+			t.Errorf("DeleteChannelRule() of a rule of another user expected an error")
+		}
+		if err = storage.DeleteChannelRule(ctx, userId, rules[0].Id); err != nil {
+			t.Fatalf("DeleteChannelRule() error = %v", err)
+		}
+		if rules, err = storage.GetChannelRules(ctx, userId); err != nil || len(rules) != 3 {
+			t.Errorf("GetChannelRules() after delete got = %+v, error = %v, want 3 rules", rules, err)
+		}
+	})
+
+	t.Run("video rules", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, rule := range []VideoRule{
+			{UserId: userId, Action: ExcludeAction, Field: "title", Operator: "contains", Value: "#shorts"},
+			{UserId: userId, Action: IncludeAction, ChannelID: "channel1", Field: "title", Operator: "contains",
+				Value: "tutorial"},
+		} {
+			id, err := storage.CreateVideoRule(ctx, rule)
+			if err != nil {
+				t.Fatalf("CreateVideoRule() error = %v", err)
+			}
+			ids = append(ids, id)
+		}
+
+		rules, err := storage.GetVideoRules(ctx, userId)
+		if err != nil {
+			t.Fatalf("GetVideoRules() error = %v", err)
+		}
+		var got []string
+		for _, rule := range rules {
+			got = append(got, fmt.Sprintf("%d %s %s %s %s %s", rule.Id, rule.Action, rule.ChannelID, rule.Field,
+				rule.Operator, rule.Value))
+		}
+		want := []string{fmt.Sprintf("%d exclude  title contains #shorts", ids[0]),
+			fmt.Sprintf("%d include channel1 title contains tutorial", ids[1])}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetVideoRules() got = %v, want %v", got, want)
+		}
+
+		if err = storage.DeleteVideoRule(ctx, userId+1, ids[0]); err == nil {
+			t.Errorf("DeleteVideoRule() of a rule of another user expected an error")
+		}
+		if err = storage.DeleteVideoRule(ctx, userId, ids[0]); err != nil {
+			t.Fatalf("DeleteVideoRule() error = %v", err)
+		}
+		if rules, err = storage.GetVideoRules(ctx, userId); err != nil || len(rules) != 1 {
+			t.Errorf("GetVideoRules() after delete got = %+v, error = %v, want 1 rule", rules, err)
+		}
+	})
+
+	t.Run("alerts", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		goId, err := storage.CreateAlert(ctx, userId, "go 1.24")
+		if err != nil {
+			t.Fatalf("CreateAlert() error = %v", err)
+		}
+		rustId, err := storage.CreateAlert(ctx, userId, "rust")
+		if err != nil {
+			t.Fatalf("CreateAlert() error = %v", err)
+		}
+		if _, err = storage.CreateAlert(ctx, userId, "rust"); err == nil {
+			t.Errorf("CreateAlert() of a duplicated query expected an error")
+		}
+		alerts, err := storage.GetAlerts(ctx, userId)
+		if err != nil || len(alerts) != 2 || alerts[0].Query != "go 1.24" || alerts[0].CheckedAt != nil {
+			t.Errorf("GetAlerts() got = %+v, error = %v", alerts, err)
+		}
+		checkedAt := time.Now().UTC().Truncate(time.Second)
+		if err = storage.MarkAlertsChecked(ctx, userId, checkedAt); err != nil {
+			t.Fatalf("MarkAlertsChecked() error = %v", err)
+		}
+		alerts, err = storage.GetAlerts(ctx, userId)
+		if err != nil || len(alerts) != 2 || alerts[1].CheckedAt == nil || !alerts[1].CheckedAt.Equal(checkedAt) {
+			t.Errorf("GetAlerts() after MarkAlertsChecked() got = %+v, error = %v, want checked at %v", alerts, err,
+				checkedAt)
+		}
+
+		// a video already recorded for an alert is skipped
+		match := AlertMatch{AlertId: goId, UserId: userId, VideoID: "video1", VideoTitle: "Go 1.24 released",
+			ChannelID: "channel1", ChannelTitle: "Go", PublishedAt: "2025-02-11T00:00:00Z"}
+		added, err := storage.AddAlertMatches(ctx, []AlertMatch{match})
+		if err != nil || len(added) != 1 {
+			t.Fatalf("AddAlertMatches() got = %+v, error = %v", added, err)
+		}
+		rustMatch := match
+		rustMatch.AlertId = rustId
+		added, err = storage.AddAlertMatches(ctx, []AlertMatch{match, rustMatch})
+		if err != nil || len(added) != 1 || added[0].AlertId != rustId {
+			t.Fatalf("AddAlertMatches() got = %+v, error = %v", added, err)
+		}
+
+		matches, err := storage.GetAlertMatches(ctx, userId, 10)
+		if err != nil {
+			t.Fatalf("GetAlertMatches() error = %v", err)
+		}
+		var got []string
+		for _, match := range matches {
+			got = append(got, fmt.Sprintf("%s %s %s %v", match.Query, match.VideoID, match.ChannelTitle,
+				match.ReadAt == nil))
+		}
+		want := []string{"rust video1 Go true", "go 1.24 video1 Go true"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAlertMatches() got = %v, want %v", got, want)
+		}
+		if count, err := storage.CountUnreadAlertMatches(ctx, userId); err != nil || count != 2 {
+			t.Errorf("CountUnreadAlertMatches() got = %d, error = %v, want 2", count, err)
+		}
+		if err = storage.MarkAlertMatchesRead(ctx, userId); err != nil {
+			t.Fatalf("MarkAlertMatchesRead() error = %v", err)
+		}
+		if count, err := storage.CountUnreadAlertMatches(ctx, userId); err != nil || count != 0 {
+			t.Errorf("CountUnreadAlertMatches() after read got = %d, error = %v, want 0", count, err)
+		}
+
+		if err = storage.DeleteAlert(ctx, userId+1, goId); err == nil {
+			t.Errorf("DeleteAlert() of an alert of another user expected an error")
+		}
+		if err = storage.DeleteAlert(ctx, userId, goId); err != nil {
+			t.Fatalf("DeleteAlert() error = %v", err)
+		}
+		if matches, err = storage.GetAlertMatches(ctx, userId, 10); err != nil || len(matches) != 1 {
+			t.Errorf("GetAlertMatches() after delete got = %+v, error = %v, want 1 match", matches, err)
+		}
+	})
+
+	t.Run("subscription tracking", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		channels := []SubscribedChannel{{ChannelID: "channel1", ChannelTitle: "Go"},
+			{ChannelID: "channel2", ChannelTitle: "Rust"}}
+		if taken, err := storage.HasSubscriptionSnapshot(ctx, userId, "account1"); err != nil || taken {
+			t.Errorf("HasSubscriptionSnapshot() before the first snapshot got = %v, error = %v", taken, err)
+		}
+		if err = storage.ReplaceSubscribedChannels(ctx, userId, "account1", channels); err != nil {
+			t.Fatalf("ReplaceSubscribedChannels() error = %v", err)
+		}
+		// an account without subscriptions is snapshotted too
+		if err = storage.ReplaceSubscribedChannels(ctx, userId, "account3", nil); err != nil {
+			t.Fatalf("ReplaceSubscribedChannels() error = %v", err)
+		}
+		for _, accountId := range []string{"account1", "account3"} {
+			if taken, err := storage.HasSubscriptionSnapshot(ctx, userId, accountId); err != nil || !taken {
+				t.Errorf("HasSubscriptionSnapshot() of %s got = %v, error = %v, want true", accountId, taken, err)
+			}
+		}
+		err = storage.WithTx(ctx, func(tx StorageInterface) error {
+			return tx.LockSubscribedChannels(ctx, userId, "account1")
+		})
+		if err != nil {
+			t.Fatalf("LockSubscribedChannels() error = %v", err)
+		}
+		// channel1 is renamed, channel2 removed and channel3 added, the other accounts are left alone
+		channels = []SubscribedChannel{{ChannelID: "channel1", ChannelTitle: "The Go channel"},
+			{ChannelID: "channel3", ChannelTitle: "Zig"}}
+		if err = storage.ReplaceSubscribedChannels(ctx, userId, "account1", channels); err != nil {
+			t.Fatalf("ReplaceSubscribedChannels() error = %v", err)
+		}
+		if err = storage.ReplaceSubscribedChannels(ctx, userId, "account2", channels[:1]); err != nil {
+			t.Fatalf("ReplaceSubscribedChannels() error = %v", err)
+		}
+		stored, err := storage.GetSubscribedChannels(ctx, userId, "account1")
+		if err != nil {
+			t.Fatalf("GetSubscribedChannels() error = %v", err)
+		}
+		var got []string
+		for _, channel := range stored {
+			got = append(got, fmt.Sprintf("%s %s", channel.ChannelID, channel.ChannelTitle))
+		}
+		want := []string{"channel1 The Go channel", "channel3 Zig"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetSubscribedChannels() got = %v, want %v", got, want)
+		}
+
+		changes := []SubscriptionChange{
+			{UserId: userId, AccountId: "account1", ChannelID: "channel2", ChannelTitle: "Rust",
+				Kind: SubscriptionRemoved},
+			{UserId: userId, AccountId: "account1", ChannelID: "channel1", ChannelTitle: "The Go channel",
+				PreviousTitle: "Go", Kind: ChannelRenamed},
+		}
+		if err = storage.AddSubscriptionChanges(ctx, changes); err != nil {
+			t.Fatalf("AddSubscriptionChanges() error = %v", err)
+		}
+		recorded, err := storage.GetSubscriptionChanges(ctx, userId, 1)
+		if err != nil || len(recorded) != 1 || recorded[0].Kind != ChannelRenamed ||
+			recorded[0].PreviousTitle != "Go" {
+			t.Errorf("GetSubscriptionChanges() got = %+v, error = %v", recorded, err)
+		}
+	})
+
+	t.Run("followed channels", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		channels := []FollowedChannel{{ChannelID: "channel1", ChannelTitle: "rust"},
+			{ChannelID: "channel2", ChannelTitle: "Go"}}
+		if added, err := storage.AddFollowedChannels(ctx, userId, channels); err != nil || added != 2 {
+			t.Fatalf("AddFollowedChannels() = %d, error = %v", added, err)
+		}
+		// the channels already followed are skipped
+		channels = append(channels, FollowedChannel{ChannelID: "channel3", ChannelTitle: "Zig"})
+		if added, err := storage.AddFollowedChannels(ctx, userId, channels); err != nil || added != 1 {
+			t.Fatalf("AddFollowedChannels() = %d, error = %v", added, err)
+		}
+		if err = storage.DeleteFollowedChannel(ctx, userId, "channel3"); err != nil {
+			t.Fatalf("DeleteFollowedChannel() error = %v", err)
+		}
+		if err = storage.DeleteFollowedChannel(ctx, userId, "channel3"); err == nil {
+			t.Errorf("DeleteFollowedChannel() of a channel not followed, want error")
+		}
+		followed, err := storage.GetFollowedChannels(ctx, userId)
+		if err != nil {
+			t.Fatalf("GetFollowedChannels() error = %v", err)
+		}
+		var got []string
+		for _, channel := range followed {
+			got = append(got, channel.ChannelID)
+		}
+		want := []string{"channel2", "channel1"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetFollowedChannels() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("videos", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		userId, err := storage.CreateUserWithAccount(ctx, "account1", "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherUserId, err := storage.CreateUserWithAccount(ctx, "account2", "user2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		day := func(d int) *time.Time {
+			date := time.Date(2025, time.February, d, 12, 0, 0, 0, time.UTC)
+			return &date
+		}
+		videos := []Video{
+			{VideoID: "video1", ChannelID: "channel1", ChannelTitle: "Go", Title: "Go 1.24 released",
+				Description: "What's new in the release", PublishedAt: day(11), DurationSeconds: 600, Type: "video"},
+			{VideoID: "video2", ChannelID: "channel2", ChannelTitle: "Systems", Title: "Weekly news",
+				Description: "Rust 1.85 and the 2024 edition", PublishedAt: day(20), DurationSeconds: 60, Type: "short"},
+			{VideoID: "video3", ChannelID: "channel1", ChannelTitle: "Go", Title: "Generic type aliases",
+				Description: "A deep dive in 100% of the generics", PublishedAt: day(25), DurationSeconds: 1200,
+				Type: "video"},
+		}
+		if err = storage.UpsertVideos(ctx, userId, videos); err != nil {
+			t.Fatalf("UpsertVideos() error = %v", err)
+		}
+		// updating a video already stored
+		videos[0].Title = "Go 1.24 is released"
+		if err = storage.UpsertVideos(ctx, userId, videos[:1]); err != nil {
+			t.Fatalf("UpsertVideos() update error = %v", err)
+		}
+		if err = storage.UpsertVideos(ctx, otherUserId, videos[1:2]); err != nil {
+			t.Fatalf("UpsertVideos() other user error = %v", err)
+		}
+
+		search := func(search VideoSearch) []string {
+			t.Helper()
+			search.Limit = 10
+			results, err := storage.SearchVideos(ctx, search)
+			if err != nil {
+				t.Fatalf("SearchVideos(%+v) error = %v", search, err)
+			}
+			got := make([]string, 0)
+			for _, result := range results {
+				got = append(got, result.VideoID)
+				if search.Query != "" && !strings.Contains(result.Snippet, SnippetMatchStart) {
+					t.Errorf("SearchVideos(%+v) snippet %q without a match", search, result.Snippet)
+				}
+			}
+			slices.Sort(got)
+			return got
+		}
+		tests := []struct {
+			search VideoSearch
+			want   []string
+		}{
+			{search: VideoSearch{UserId: userId}, want: []string{"video1", "video2", "video3"}},
+			{search: VideoSearch{UserId: userId, Query: "RELEASED"}, want: []string{"video1"}},
+			{search: VideoSearch{UserId: userId, Query: `"is released"`}, want: []string{"video1"}},
+			{search: VideoSearch{UserId: userId, Query: `"released is"`}, want: []string{}},
+			{search: VideoSearch{UserId: userId, Query: "gener*"}, want: []string{"video3"}},
+			{search: VideoSearch{UserId: userId, Query: "go"}, want: []string{"video1", "video3"}},
+			{search: VideoSearch{UserId: userId, Query: "go rust"}, want: []string{}},
+			{search: VideoSearch{UserId: userId, Query: "100%"}, want: []string{"video3"}},
+			{search: VideoSearch{UserId: userId, ChannelID: "channel1"}, want: []string{"video1", "video3"}},
+			{search: VideoSearch{UserId: userId, PublishedFrom: day(20), PublishedTo: day(25)},
+				want: []string{"video2"}},
+			{search: VideoSearch{UserId: otherUserId}, want: []string{"video2"}},
+		}
+		for _, tt := range tests {
+			if got := search(tt.search); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchVideos(%+v) got = %v, want %v", tt.search, got, tt.want)
+			}
+		}
+
+		results, err := storage.SearchVideos(ctx, VideoSearch{UserId: userId, Limit: 1, Offset: 1})
+		if err != nil || len(results) != 1 || results[0].VideoID != "video2" || results[0].PublishedAt == nil ||
+			!results[0].PublishedAt.Equal(*day(20)) || results[0].DurationSeconds != 60 {
+			t.Errorf("SearchVideos() second page got = %+v, error = %v, want video2", results, err)
+		}
+
+		channels, err := storage.GetVideoChannels(ctx, userId)
+		want := []VideoChannel{{ChannelID: "channel1", ChannelTitle: "Go"},
+			{ChannelID: "channel2", ChannelTitle: "Systems"}}
+		if err != nil || !reflect.DeepEqual(channels, want) {
+			t.Errorf("GetVideoChannels() got = %+v, error = %v, want %+v", channels, err, want)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		// a failing transaction leaves nothing behind, nested calls included
+		err := storage.WithTx(ctx, func(tx StorageInterface) error {
+			if _, err := tx.CreateUserWithAccount(ctx, "account1", "user1"); err != nil {
+				return err
+			}
+			if err := tx.UpsertRefreshToken(ctx, "account1", "refreshToken1"); err != nil {
+				return err
+			}
+			return errors.New("rollback")
+		})
+		if err == nil || err.Error() != "rollback" {
+			t.Fatalf("WithTx() error = %v, want rollback", err)
+		}
+		if userId, err := storage.GetUserIdByAccountId(ctx, "account1"); err != nil || userId != 0 {
+			t.Errorf("WithTx() kept user %v after rollback, error = %v", userId, err)
+		}
+		if refreshToken, err := storage.GetRefreshTokenByUserId(ctx, "account1"); err != nil || refreshToken != "" {
+			t.Errorf("WithTx() kept refresh token %v after rollback, error = %v", refreshToken, err)
+		}
+
+		var userId int64
+		err = storage.WithTx(ctx, func(tx StorageInterface) error {
+			var err error
+			if userId, err = tx.CreateUserWithAccount(ctx, "account1", "user1"); err != nil {
+				return err
+			}
+			return tx.UpsertRefreshToken(ctx, "account1", "refreshToken1")
+		})
+		if err != nil {
+			t.Fatalf("WithTx() error = %v", err)
+		}
+		assertLinkedAccounts(t, storage, userId, []LinkedAccount{
+			{AccountId: "account1", DisplayName: "user1", RefreshToken: "refreshToken1"},
+		})
+
+		// a canceled context aborts the operation
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err = storage.GetLinkedAccounts(canceledCtx, userId); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetLinkedAccounts() with a canceled context error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("migrations", func(t *testing.T) {
+		storage := newMigratedStorage(t, newStorage)
+
+		migrations, err := storage.availableMigrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reverted, err := storage.MigrateDown(ctx, len(migrations)); err != nil || len(reverted) != len(migrations) {
+			t.Fatalf("MigrateDown() reverted %v migrations, error = %v", len(reverted), err)
+		}
+		statuses, err := storage.MigrationsStatus(ctx)
+		if err != nil || len(statuses) != len(migrations) {
+			t.Fatalf("MigrationsStatus() got = %v, error = %v", statuses, err)
+		}
+		for _, status := range statuses {
+			if status.AppliedAt != nil {
+				t.Errorf("MigrationsStatus() migration %d applied at %v, want pending", status.Version,
+					status.AppliedAt)
+			}
+		}
+		if applied, err := storage.MigrateUp(ctx); err != nil || len(applied) != len(migrations) {
+			t.Errorf("MigrateUp() applied %v migrations, error = %v", len(applied), err)
+		}
+	})
+}
+
+func TestSQLiteStorage_contract(t *testing.T) {
+	testStorageContract(t, newTestStorage)
+}
+
+// runs against the PostgreSQL database given by the POSTGRES_TEST_DSN env variable, each case in its own schema
+func TestPostgresStorage_contract(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+	testStorageContract(t, func(t *testing.T) *Storage {
+		return newPostgresTestStorage(t, dsn)
+	})
+}
+
+func newMigratedStorage(t *testing.T, newStorage func(t *testing.T) *Storage) *Storage {
+	storage := newStorage(t)
+	if err := storage.RunMigrations(context.Background()); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	return storage
+}
+
+func newPostgresTestStorage(t *testing.T, dsn string) *Storage {
+	ctx := context.Background()
+	admin, err := New(ctx, Config{Dialect: PostgresDialect, DataSourceName: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = admin.Close()
+	})
+
+	suffix := make([]byte, 8)
+	if _, err = rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	schema := "contract_" + hex.EncodeToString(suffix)
+	if _, err = admin.db.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.db.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	// every connection of the storage uses the new schema
+	dsnURL, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := dsnURL.Query()
+	query.Set("search_path", schema)
+	dsnURL.RawQuery = query.Encode()
+
+	storage, err := New(ctx, Config{
+		Dialect:        PostgresDialect,
+		DataSourceName: dsnURL.String(),
+		Pool:           PoolConfig{MaxOpenConns: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+	return storage
+}
+
+func assertLinkedAccounts(t *testing.T, storage *Storage, userId int64, want []LinkedAccount) {
+	t.Helper()
+
+	got, err := storage.GetLinkedAccounts(context.Background(), userId)
+	if err != nil {
+		t.Fatalf("GetLinkedAccounts() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetLinkedAccounts() got = %+v, want %+v", got, want)
+	}
+	// accounts linked within the same second have no defined order in SQLite
+	slices.SortFunc(got, func(a, b LinkedAccount) int {
+		return strings.Compare(a.AccountId, b.AccountId)
+	})
+	for i := range got {
+		if got[i].CreatedAt.IsZero() {
+			t.Errorf("GetLinkedAccounts() account %s has no creation time", got[i].AccountId)
+		}
+		got[i].CreatedAt = time.Time{}
+		if got[i] != want[i] {
+			t.Errorf("GetLinkedAccounts() got = %+v, want %+v", got[i], want[i])
+		}
+	}
+}
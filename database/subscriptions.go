@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// kinds of the subscription changes
+const (
+	SubscriptionAdded   = "added"
+	SubscriptionRemoved = "removed"
+	ChannelRenamed      = "renamed"
+	// ChannelTerminated is a subscription removed because the channel no longer exists
+	ChannelTerminated = "terminated"
+)
+
+// SubscribedChannel is a channel a Google account of an app user was subscribed to at the last sync
+type SubscribedChannel struct {
+	UserId       int64
+	AccountId    string
+	ChannelID    string
+	ChannelTitle string
+	FirstSeenAt  time.Time
+	LastSeenAt   time.Time
+}
+
+// SubscriptionChange is a difference between two syncs of the subscriptions of a Google account
+type SubscriptionChange struct {
+	Id           int64
+	UserId       int64
+	AccountId    string
+	ChannelID    string
+	ChannelTitle string
+	// PreviousTitle is the title of a renamed channel before the change
+	PreviousTitle string
+	Kind          string
+	DetectedAt    time.Time
+}
+
+// LockSubscribedChannels locks the subscriptions of the given Google account of the user until the end of the
+// transaction, so that concurrent syncs of the account read and replace them one after the other. SQLite
+// transactions already take the write lock when they begin, Postgres ones take an advisory lock on the account
+func (s *Storage) LockSubscribedChannels(ctx context.Context, userId int64, accountId string) error {
+	if s.dialect != PostgresDialect {
+		return nil
+	}
+	_, err := s.q.ExecContext(ctx, s.rebind("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))"),
+		fmt.Sprintf("subscribed_channels/%d/%s", userId, accountId))
+	return err
+}
+
+// GetSubscribedChannels returns the channels the given Google account of the user was subscribed to at the last
+// sync, sorted by the time they were first seen
+func (s *Storage) GetSubscribedChannels(ctx context.Context, userId int64,
+	accountId string) ([]SubscribedChannel, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT user_id, account_id, channel_id, channel_title, "+
+		"first_seen_at, last_seen_at FROM subscribed_channels "+
+		"WHERE user_id = ? AND account_id = ? "+
+		"ORDER BY first_seen_at, channel_id"), userId, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := make([]SubscribedChannel, 0)
+	for rows.Next() {
+		var channel SubscribedChannel
+		err = rows.Scan(&channel.UserId, &channel.AccountId, &channel.ChannelID, &channel.ChannelTitle,
+			&channel.FirstSeenAt, &channel.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+// HasSubscriptionSnapshot tells whether the subscriptions of the given Google account of the user were stored
+// already, even if there were none
+func (s *Storage) HasSubscriptionSnapshot(ctx context.Context, userId int64, accountId string) (bool, error) {
+	var count int
+	err := s.q.QueryRowContext(ctx, s.rebind("SELECT COUNT(*) FROM subscription_snapshots "+
+		"WHERE user_id = ? AND account_id = ?"), userId, accountId).Scan(&count)
+	return count > 0, err
+}
+
+// ReplaceSubscribedChannels stores the channels the given Google account of the user is subscribed to, keeping
+// the time the ones already stored were first seen and removing the ones no longer given. The time of the
+// snapshot is recorded along with them
+func (s *Storage) ReplaceSubscribedChannels(ctx context.Context, userId int64, accountId string,
+	channels []SubscribedChannel) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		_, err := tx.q.ExecContext(ctx, tx.rebind("INSERT INTO subscription_snapshots (user_id, account_id) "+
+			"VALUES (?, ?) ON CONFLICT (user_id, account_id) DO UPDATE SET taken_at = CURRENT_TIMESTAMP"),
+			userId, accountId)
+		if err != nil {
+			return err
+		}
+		stored, err := tx.GetSubscribedChannels(ctx, userId, accountId)
+		if err != nil {
+			return err
+		}
+		current := make(map[string]bool, len(channels))
+		for _, channel := range channels {
+			current[channel.ChannelID] = true
+			_, err = tx.q.ExecContext(ctx, tx.rebind("INSERT INTO subscribed_channels "+
+				"(user_id, account_id, channel_id, channel_title) VALUES (?, ?, ?, ?) "+
+				"ON CONFLICT (user_id, account_id, channel_id) DO UPDATE SET "+
+				"channel_title = excluded.channel_title, last_seen_at = CURRENT_TIMESTAMP"),
+				userId, accountId, channel.ChannelID, channel.ChannelTitle)
+			if err != nil {
+				return err
+			}
+		}
+		for _, channel := range stored {
+			if current[channel.ChannelID] {
+				continue
+			}
+			_, err = tx.q.ExecContext(ctx, tx.rebind("DELETE FROM subscribed_channels "+
+				"WHERE user_id = ? AND account_id = ? AND channel_id = ?"), userId, accountId, channel.ChannelID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddSubscriptionChanges records the given subscription changes
+func (s *Storage) AddSubscriptionChanges(ctx context.Context, changes []SubscriptionChange) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		for _, change := range changes {
+			_, err := tx.q.ExecContext(ctx, tx.rebind("INSERT INTO subscription_changes "+
+				"(user_id, account_id, channel_id, channel_title, previous_title, kind) "+
+				"VALUES (?, ?, ?, ?, ?, ?)"), change.UserId, change.AccountId, change.ChannelID,
+				change.ChannelTitle, change.PreviousTitle, change.Kind)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSubscriptionChanges returns the latest subscription changes of the given user, newest first
+func (s *Storage) GetSubscriptionChanges(ctx context.Context, userId int64,
+	limit int) ([]SubscriptionChange, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT id, user_id, account_id, channel_id, channel_title, "+
+		"previous_title, kind, detected_at FROM subscription_changes "+
+		"WHERE user_id = ? "+
+		"ORDER BY detected_at DESC, id DESC "+
+		"LIMIT ?"), userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]SubscriptionChange, 0)
+	for rows.Next() {
+		var change SubscriptionChange
+		err = rows.Scan(&change.Id, &change.UserId, &change.AccountId, &change.ChannelID, &change.ChannelTitle,
+			&change.PreviousTitle, &change.Kind, &change.DetectedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// login outcomes recorded in the login history
+const (
+	LoginSucceeded = "success"
+	LoginFailed    = "failure"
+)
+
+// UserProfile is the profile of an app user, as last retrieved when they logged in
+type UserProfile struct {
+	UserId      int64
+	DisplayName string
+	AvatarURL   string
+	Locale      string
+	// FirstSeenAt is when the user was created, LastLoginAt is nil until a login of the user is recorded
+	FirstSeenAt time.Time
+	LastLoginAt *time.Time
+}
+
+// LoginEvent is a login attempt recorded in the login history
+type LoginEvent struct {
+	Id int64
+	// UserId is 0 when the login failed before the app user was known
+	UserId    int64
+	Provider  string
+	Subject   string
+	IPAddress string
+	UserAgent string
+	Outcome   string
+	// Reason is why the login failed
+	Reason    string
+	CreatedAt time.Time
+}
+
+// GetUserProfile returns the profile of the given user, nil if the user does not exist
+func (s *Storage) GetUserProfile(ctx context.Context, userId int64) (*UserProfile, error) {
+	profile := UserProfile{UserId: userId}
+	err := s.q.QueryRowContext(ctx, s.rebind("SELECT display_name, avatar_url, locale, created_at, last_login_at "+
+		"FROM users WHERE id = ?"), userId).Scan(&profile.DisplayName, &profile.AvatarURL, &profile.Locale,
+		&profile.FirstSeenAt, &profile.LastLoginAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// RecordUserLogin stores the profile of a user logging in, setting their last login time to now
+func (s *Storage) RecordUserLogin(ctx context.Context, profile UserProfile) error {
+	_, err := s.q.ExecContext(ctx, s.rebind("UPDATE users SET display_name = ?, avatar_url = ?, locale = ?, "+
+		"last_login_at = ? WHERE id = ?"), profile.DisplayName, profile.AvatarURL, profile.Locale, time.Now().UTC(),
+		profile.UserId)
+	return err
+}
+
+// AddLoginEvent records a login attempt in the login history
+func (s *Storage) AddLoginEvent(ctx context.Context, event LoginEvent) error {
+	userId := sql.NullInt64{Int64: event.UserId, Valid: event.UserId != 0}
+	_, err := s.q.ExecContext(ctx, s.rebind("INSERT INTO login_history "+
+		"(user_id, provider, subject, ip_address, user_agent, outcome, reason, created_at) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?)"), userId, event.Provider, event.Subject, event.IPAddress, event.UserAgent,
+		event.Outcome, event.Reason, time.Now().UTC())
+	return err
+}
+
+// GetLoginHistory returns the latest login attempts of the given user, the most recent first
+func (s *Storage) GetLoginHistory(ctx context.Context, userId int64, limit int) ([]LoginEvent, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT id, user_id, provider, subject, ip_address, user_agent, "+
+		"outcome, reason, created_at FROM login_history WHERE user_id = ? ORDER BY created_at DESC, id DESC "+
+		"LIMIT ?"), userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]LoginEvent, 0)
+	for rows.Next() {
+		var event LoginEvent
+		var eventUserId sql.NullInt64
+		if err = rows.Scan(&event.Id, &eventUserId, &event.Provider, &event.Subject, &event.IPAddress,
+			&event.UserAgent, &event.Outcome, &event.Reason, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.UserId = eventUserId.Int64
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
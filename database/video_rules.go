@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// actions of video rules
+const (
+	// IncludeAction shows only the videos matching at least one of the include rules applying to their channel
+	IncludeAction = "include"
+	// ExcludeAction hides the videos matching the rule
+	ExcludeAction = "exclude"
+)
+
+// VideoRule is a rule of an app user including or excluding videos according to a field of theirs, for a channel
+// or for all of them
+type VideoRule struct {
+	Id     int64
+	UserId int64
+	Action string
+	// ChannelID is empty for rules applying to all the channels
+	ChannelID string
+	Field     string
+	Operator  string
+	Value     string
+	CreatedAt time.Time
+}
+
+// CreateVideoRule stores a new video rule, returning its id
+func (s *Storage) CreateVideoRule(ctx context.Context, rule VideoRule) (int64, error) {
+	var id int64
+	err := s.q.QueryRowContext(ctx, s.rebind("INSERT INTO video_rules "+
+		"(user_id, action, channel_id, field, operator, value) VALUES (?, ?, ?, ?, ?, ?) RETURNING id"),
+		rule.UserId, rule.Action, rule.ChannelID, rule.Field, rule.Operator, rule.Value).Scan(&id)
+	return id, err
+}
+
+// GetVideoRules returns the video rules of the given user in creation order
+func (s *Storage) GetVideoRules(ctx context.Context, userId int64) ([]VideoRule, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT id, user_id, action, channel_id, field, operator, value, "+
+		"created_at FROM video_rules "+
+		"WHERE user_id = ? "+
+		"ORDER BY id"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]VideoRule, 0)
+	for rows.Next() {
+		var rule VideoRule
+		err = rows.Scan(&rule.Id, &rule.UserId, &rule.Action, &rule.ChannelID, &rule.Field, &rule.Operator,
+			&rule.Value, &rule.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteVideoRule removes a video rule of the given user
+func (s *Storage) DeleteVideoRule(ctx context.Context, userId, id int64) error {
+	res, err := s.q.ExecContext(ctx, s.rebind("DELETE FROM video_rules WHERE user_id = ? AND id = ?"), userId, id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return fmt.Errorf("video rule %d not found for user %d", id, userId)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// markers of the matching terms in the snippets of the video search results
+const (
+	SnippetMatchStart = "\x02"
+	SnippetMatchEnd   = "\x03"
+)
+
+// number of characters around the first match in the snippets built without the full-text index
+const snippetContext = 60
+
+// Video is a YouTube video discovered in the subscriptions of the users
+type Video struct {
+	VideoID         string
+	ChannelID       string
+	ChannelTitle    string
+	Title           string
+	Description     string
+	PublishedAt     *time.Time
+	DurationSeconds int64
+	Type            string
+	UpdatedAt       time.Time
+}
+
+// VideoChannel is a channel having videos in the archive of a user
+type VideoChannel struct {
+	ChannelID    string
+	ChannelTitle string
+}
+
+// VideoSearch is a search in the videos discovered by a user
+type VideoSearch struct {
+	UserId int64
+	// Query is made of terms all matching the title, the description or the channel title of the videos. Terms
+	// ending with * match as prefix, terms between double quotes as phrase
+	Query     string
+	ChannelID string
+	// PublishedFrom and PublishedTo limit the results to the videos published in [PublishedFrom, PublishedTo)
+	PublishedFrom *time.Time
+	PublishedTo   *time.Time
+	Limit         int
+	Offset        int
+}
+
+// VideoSearchResult is a video matching a search, along with a snippet where the matching terms are between
+// SnippetMatchStart and SnippetMatchEnd
+type VideoSearchResult struct {
+	Video
+	Snippet string
+}
+
+// searchTerm is a term of a video search query
+type searchTerm struct {
+	Text   string
+	Prefix bool
+}
+
+// UpsertVideos stores the given videos, updating the ones already stored, as discovered by the given user
+func (s *Storage) UpsertVideos(ctx context.Context, userId int64, videos []Video) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		for _, video := range videos {
+			var publishedAt *time.Time
+			if video.PublishedAt != nil {
+				utc := video.PublishedAt.UTC().Truncate(time.Second)
+				publishedAt = &utc
+			}
+			var id int64
+			err := tx.q.QueryRowContext(ctx, tx.rebind("INSERT INTO videos "+
+				"(video_id, channel_id, channel_title, title, description, published_at, duration_seconds, type) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?) "+
+				"ON CONFLICT (video_id) DO UPDATE SET channel_id = excluded.channel_id, "+
+				"channel_title = excluded.channel_title, title = excluded.title, "+
+				"description = excluded.description, published_at = excluded.published_at, "+
+				"duration_seconds = excluded.duration_seconds, type = excluded.type, "+
+				"updated_at = CURRENT_TIMESTAMP "+
+				"RETURNING id"), video.VideoID, video.ChannelID, video.ChannelTitle, video.Title,
+				video.Description, publishedAt, video.DurationSeconds, video.Type).Scan(&id)
+			if err != nil {
+				return err
+			}
+			_, err = tx.q.ExecContext(ctx, tx.rebind("INSERT INTO user_videos (user_id, video_id) VALUES (?, ?) "+
+				"ON CONFLICT (user_id, video_id) DO NOTHING"), userId, video.VideoID)
+			if err != nil {
+				return err
+			}
+
+			if tx.fts5 {
+				if _, err = tx.q.ExecContext(ctx, "DELETE FROM videos_fts WHERE rowid = ?", id); err != nil {
+					return err
+				}
+				_, err = tx.q.ExecContext(ctx, "INSERT INTO videos_fts (rowid, title, description, channel_title) "+
+					"VALUES (?, ?, ?, ?)", id, video.Title, video.Description, video.ChannelTitle)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// GetVideoChannels returns the channels having videos discovered by the given user, sorted by title
+func (s *Storage) GetVideoChannels(ctx context.Context, userId int64) ([]VideoChannel, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind("SELECT v.channel_id, MAX(v.channel_title) FROM videos v "+
+		"JOIN user_videos u ON u.video_id = v.video_id "+
+		"WHERE u.user_id = ? "+
+		"GROUP BY v.channel_id "+
+		"ORDER BY LOWER(MAX(v.channel_title)), v.channel_id"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := make([]VideoChannel, 0)
+	for rows.Next() {
+		var channel VideoChannel
+		if err = rows.Scan(&channel.ChannelID, &channel.ChannelTitle); err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+// SearchVideos returns the videos discovered by the user matching the search, the best matches first on SQLite
+// having the FTS5 module, the latest published first otherwise
+func (s *Storage) SearchVideos(ctx context.Context, search VideoSearch) ([]VideoSearchResult, error) {
+	terms := parseSearchTerms(search.Query)
+	columns := "v.video_id, v.channel_id, v.channel_title, v.title, v.description, v.published_at, " +
+		"v.duration_seconds, v.type, v.updated_at"
+	fullText := s.fts5 && len(terms) > 0
+
+	var query strings.Builder
+	var args []any
+	if fullText {
+		query.WriteString("SELECT " + columns + ", snippet(videos_fts, -1, char(2), char(3), '…', 24) " +
+			"FROM videos_fts f JOIN videos v ON v.id = f.rowid " +
+			"JOIN user_videos u ON u.video_id = v.video_id " +
+			"WHERE videos_fts MATCH ? AND u.user_id = ?")
+		args = append(args, ftsQuery(terms), search.UserId)
+	} else {
+		query.WriteString("SELECT " + columns + ", '' FROM videos v " +
+			"JOIN user_videos u ON u.video_id = v.video_id " +
+			"WHERE u.user_id = ?")
+		args = append(args, search.UserId)
+		for _, term := range terms {
+			query.WriteString(" AND (LOWER(v.title) LIKE ? ESCAPE '\\' OR LOWER(v.description) LIKE ? ESCAPE '\\' " +
+				"OR LOWER(v.channel_title) LIKE ? ESCAPE '\\')")
+			pattern := "%" + escapeLike(strings.ToLower(term.Text)) + "%"
+			args = append(args, pattern, pattern, pattern)
+		}
+	}
+	if search.ChannelID != "" {
+		query.WriteString(" AND v.channel_id = ?")
+		args = append(args, search.ChannelID)
+	}
+	if search.PublishedFrom != nil {
+		query.WriteString(" AND v.published_at >= ?")
+		args = append(args, search.PublishedFrom.UTC().Truncate(time.Second))
+	}
+	if search.PublishedTo != nil {
+		query.WriteString(" AND v.published_at < ?")
+		args = append(args, search.PublishedTo.UTC().Truncate(time.Second))
+	}
+	if fullText {
+		query.WriteString(" ORDER BY bm25(videos_fts), v.published_at DESC, v.id DESC")
+	} else {
+		query.WriteString(" ORDER BY v.published_at DESC, v.id DESC")
+	}
+	query.WriteString(" LIMIT ? OFFSET ?")
+	args = append(args, search.Limit, search.Offset)
+
+	rows, err := s.q.QueryContext(ctx, s.rebind(query.String()), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]VideoSearchResult, 0)
+	for rows.Next() {
+		var result VideoSearchResult
+		var publishedAt sql.NullTime
+		err = rows.Scan(&result.VideoID, &result.ChannelID, &result.ChannelTitle, &result.Title,
+			&result.Description, &publishedAt, &result.DurationSeconds, &result.Type, &result.UpdatedAt,
+			&result.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		if publishedAt.Valid {
+			result.PublishedAt = &publishedAt.Time
+		}
+		if !fullText {
+			result.Snippet = likeSnippet(result.Video, terms)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// split a search query into terms: words, words ending with * matching as prefix and phrases between double quotes
+func parseSearchTerms(query string) []searchTerm {
+	terms := make([]searchTerm, 0)
+	for query = strings.TrimSpace(query); query != ""; query = strings.TrimSpace(query) {
+		if rest, found := strings.CutPrefix(query, `"`); found {
+			phrase, after, _ := strings.Cut(rest, `"`)
+			if phrase = strings.Join(strings.Fields(phrase), " "); phrase != "" {
+				terms = append(terms, searchTerm{Text: phrase})
+			}
+			query = after
+			continue
+		}
+		word, after, _ := strings.Cut(query, " ")
+		query = after
+		word = strings.ReplaceAll(word, `"`, "")
+		term := searchTerm{Text: strings.TrimRight(word, "*")}
+		term.Prefix = term.Text != word
+		if term.Text != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// return the FTS5 query matching all the terms, each quoted so that no character has a special meaning
+func ftsQuery(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		part := `"` + strings.ReplaceAll(term.Text, `"`, `""`) + `"`
+		if term.Prefix {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// escape the LIKE wildcards of a pattern
+func escapeLike(pattern string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(pattern)
+}
+
+// return the part of the first of the description, the title and the channel title matching, around the first term
+// found, with the terms between the snippet markers
+func likeSnippet(video Video, terms []searchTerm) string {
+	text, start := video.Title, 0
+	for _, field := range []string{video.Description, video.Title, video.ChannelTitle} {
+		if i := firstMatch(field, terms); i >= 0 {
+			text, start = field, i
+			break
+		}
+	}
+
+	// cut the text around the first match, on rune boundaries
+	from, to := max(start-snippetContext, 0), min(start+snippetContext, len(text))
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	snippet := text[from:to]
+
+	var result strings.Builder
+	if from > 0 {
+		result.WriteString("…")
+	}
+	for snippet != "" {
+		i := firstMatch(snippet, terms)
+		if i < 0 {
+			result.WriteString(snippet)
+			break
+		}
+		length := matchLength(snippet[i:], terms)
+		result.WriteString(snippet[:i] + SnippetMatchStart + snippet[i:i+length] + SnippetMatchEnd)
+		snippet = snippet[i+length:]
+	}
+	if to < len(text) {
+		result.WriteString("…")
+	}
+	return result.String()
+}
+
+// return the byte index of the first term found in the text, case-insensitively, or -1
+func firstMatch(text string, terms []searchTerm) int {
+	first := -1
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		// lowercasing keeps the byte length of most texts, the index is discarded otherwise
+		if i := strings.Index(lower, strings.ToLower(term.Text)); i >= 0 && len(lower) == len(text) &&
+			(first < 0 || i < first) {
+			first = i
+		}
+	}
+	return first
+}
+
+// return the length of the longest term the text starts with, case-insensitively
+func matchLength(text string, terms []searchTerm) int {
+	length := 0
+	for _, term := range terms {
+		if len(term.Text) <= len(text) && strings.EqualFold(text[:len(term.Text)], term.Text) {
+			length = max(length, len(term.Text))
+		}
+	}
+	return max(length, 1)
+}
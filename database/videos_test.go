@@ -0,0 +1,98 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseSearchTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []searchTerm
+		// wantFTS is the FTS5 query of the terms
+		wantFTS string
+	}{
+		{
+			name:    "success case - words",
+			query:   "  go   generics ",
+			want:    []searchTerm{{Text: "go"}, {Text: "generics"}},
+			wantFTS: `"go" "generics"`,
+		},
+		{
+			name:    "success case - prefix and phrase",
+			query:   `gener* "type   aliases" go`,
+			want:    []searchTerm{{Text: "gener", Prefix: true}, {Text: "type aliases"}, {Text: "go"}},
+			wantFTS: `"gener"* "type aliases" "go"`,
+		},
+		{
+			name:    "success case - special characters",
+			query:   `"unterminated OR NEAR(a b) -x`,
+			want:    []searchTerm{{Text: "unterminated OR NEAR(a b) -x"}},
+			wantFTS: `"unterminated OR NEAR(a b) -x"`,
+		},
+		{
+			name:    "success case - empty terms skipped",
+			query:   `* "" a"b`,
+			want:    []searchTerm{{Text: "ab"}},
+			wantFTS: `"ab"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSearchTerms(tt.query)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSearchTerms() got = %+v, want %+v", got, tt.want)
+			}
+			if fts := ftsQuery(got); fts != tt.wantFTS {
+				t.Errorf("ftsQuery() got = %v, want %v", fts, tt.wantFTS)
+			}
+		})
+	}
+}
+
+func Test_likeSnippet(t *testing.T) {
+	mark := func(s string) string {
+		return SnippetMatchStart + s + SnippetMatchEnd
+	}
+	tests := []struct {
+		name  string
+		video Video
+		query string
+		want  string
+	}{
+		{
+			name:  "success case - description",
+			video: Video{Title: "Weekly news", Description: "Rust 1.85 and the Rust 2024 edition"},
+			query: "rust edition",
+			want:  mark("Rust") + " 1.85 and the " + mark("Rust") + " 2024 " + mark("edition"),
+		},
+		{
+			name:  "success case - title",
+			video: Video{Title: "Go 1.24 released", Description: "What's new"},
+			query: `"1.24 released"`,
+			want:  "Go " + mark("1.24 released"),
+		},
+		{
+			name:  "success case - long description cut around the match",
+			video: Video{Title: "Go", Description: strings.Repeat("é", 100) + "generics" + strings.Repeat("a", 100)},
+			query: "gener*",
+			want: "…" + strings.Repeat("é", 30) + mark("gener") + "ics" + strings.Repeat("a", snippetContext-8) +
+				"…",
+		},
+		{
+			name:  "success case - channel title",
+			video: Video{Title: "Generics", ChannelTitle: "Go"},
+			query: "go",
+			want:  mark("Go"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := likeSnippet(tt.video, parseSearchTerms(tt.query)); got != tt.want {
+				t.Errorf("likeSnippet() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
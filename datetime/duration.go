@@ -7,8 +7,12 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// ISO 8601 durations of YouTube videos, made of days, hours, minutes and seconds
+var videoDurationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
 // FormatISO8601Duration formats an ISO 8601 duration to a human-readable format
 func FormatISO8601Duration(duration, username string) (string, error) {
 	const funcName = "FormatISO8601Duration"
@@ -61,3 +65,24 @@ func FormatISO8601Duration(duration, username string) (string, error) {
 
 	return result, nil
 }
+
+// ParseISO8601Duration parses the ISO 8601 duration of a YouTube video, e.g. PT1H2M3S
+func ParseISO8601Duration(duration string) (time.Duration, error) {
+	matches := videoDurationRegex.FindStringSubmatch(duration)
+	if matches == nil || duration == "P" || strings.HasSuffix(duration, "T") {
+		return 0, fmt.Errorf("invalid ISO 8601 duration: %s", duration)
+	}
+
+	var result time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if matches[i+1] == "" {
+			continue
+		}
+		value, err := strconv.Atoi(matches[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration: %s", duration)
+		}
+		result += time.Duration(value) * unit
+	}
+	return result, nil
+}
@@ -1,6 +1,9 @@
 package datetime
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestFormatISO8601Duration(t *testing.T) {
 	type args struct {
@@ -99,3 +102,53 @@ func TestFormatISO8601Duration(t *testing.T) {
 		})
 	}
 }
+
+func TestParseISO8601Duration(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "success case - hours, minutes, seconds",
+			duration: "PT1H2M3S",
+			want:     time.Hour + 2*time.Minute + 3*time.Second,
+			wantErr:  false,
+		},
+		{
+			name:     "success case - days",
+			duration: "P1DT30S",
+			want:     24*time.Hour + 30*time.Second,
+			wantErr:  false,
+		},
+		{
+			name:     "success case - live stream",
+			duration: "P0D",
+			want:     0,
+			wantErr:  false,
+		},
+		{
+			name:     "error case - missing time values",
+			duration: "PT",
+			wantErr:  true,
+		},
+		{
+			name:     "error case - invalid duration format",
+			duration: "INVALID",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseISO8601Duration(tt.duration)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseISO8601Duration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseISO8601Duration() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -25,3 +25,12 @@ type ValueNotFoundInCtx struct {
 func (e ValueNotFoundInCtx) Error() string {
 	return fmt.Sprintf("%s not found in context", e.Key.String())
 }
+
+// InsufficientScopeErr is returned when the token wasn't granted the scopes needed to change the YouTube data
+type InsufficientScopeErr struct {
+	Err error
+}
+
+func (e InsufficientScopeErr) Error() string {
+	return fmt.Sprintf("insufficient scopes: %s", e.Err.Error())
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"fmt"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+)
+
+// number of login attempts shown in the account page
+const loginHistoryLimit = 20
+
+type accountTemplateResponse struct {
+	Username       string
+	Profile        *database.UserProfile
+	LoginHistory   []database.LoginEvent
+	Theme          string
+	ServerBasepath string
+}
+
+// GetAccount renders the account page, showing the profile of the logged user and their latest login attempts
+func GetAccount(storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "GetAccount"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		profile, err := storage.GetUserProfile(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve user profile: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if profile == nil {
+			err = fmt.Errorf("user %d not found", tokenInfo.AppUserId)
+			slog.Error(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		loginHistory, err := storage.GetLoginHistory(r.Context(), tokenInfo.AppUserId, loginHistoryLimit)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve login history: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := accountTemplateResponse{
+			Username:       tokenInfo.Username,
+			Profile:        profile,
+			LoginHistory:   loginHistory,
+			Theme:          userPreferences(r, storage, tokenInfo, funcName).Theme,
+			ServerBasepath: serverBasepath,
+		}
+
+		// render response as HTML using a template
+		tmpl, err := template.New("accountTemplate.tmpl").Parse(htmlTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = tmpl.Execute(w, response)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"fmt"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetAccount(t *testing.T) {
+	// mocks
+	const (
+		serverBasepath = "http://localhost:8900"
+		tokenNotFound  = "redirect case - token not found in context"
+	)
+	getUserProfileStub := func(_ context.Context, userId int64) (*database.UserProfile, error) {
+		return &database.UserProfile{UserId: userId, DisplayName: "usertest", FirstSeenAt: time.Now()}, nil
+	}
+
+	type args struct {
+		storage        database.StorageInterface
+		serverBasepath string
+		htmlTemplate   string
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "success case",
+			args: args{
+				storage: &test.StorageMock{
+					GetUserProfileStub: getUserProfileStub,
+					GetLoginHistoryStub: func(_ context.Context, userId int64, limit int) ([]database.LoginEvent, error) {
+						return []database.LoginEvent{{Id: 1, UserId: userId, Outcome: database.LoginSucceeded}}, nil
+					},
+					GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+						return database.DefaultPreferences(), nil
+					},
+				},
+				serverBasepath: serverBasepath,
+				htmlTemplate:   "",
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "error case - user not found",
+			args: args{
+				storage: &test.StorageMock{
+					GetUserProfileStub: func(_ context.Context, userId int64) (*database.UserProfile, error) {
+						return nil, nil
+					},
+				},
+				serverBasepath: serverBasepath,
+				htmlTemplate:   "",
+			},
+			want: http.StatusNotFound,
+		},
+		{
+			name: "error case - storage error",
+			args: args{
+				storage: &test.StorageMock{
+					GetUserProfileStub: getUserProfileStub,
+					GetLoginHistoryStub: func(_ context.Context, userId int64, limit int) ([]database.LoginEvent, error) {
+						return nil, fmt.Errorf("test error")
+					},
+				},
+				serverBasepath: serverBasepath,
+				htmlTemplate:   "",
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: tokenNotFound,
+			args: args{
+				storage:        &test.StorageMock{},
+				serverBasepath: serverBasepath,
+				htmlTemplate:   "",
+			},
+			want: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/account", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(),
+					&auth.TokenInfo{Token: &oauth2.Token{}, UserId: "1", AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetAccount(tt.args.storage, tt.args.serverBasepath, tt.args.htmlTemplate)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("GetAccount() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/youtube/v3"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// max length of the query of an alert
+const maxAlertQueryLength = 255
+
+// number of the latest alert matches shown in the inbox
+const alertInboxSize = 200
+
+type alertsTemplateResponse struct {
+	Username       string
+	Alerts         []database.Alert
+	Matches        []database.AlertMatch
+	UnreadCount    int
+	Theme          string
+	ServerBasepath string
+}
+
+type alertRequest struct {
+	Query string `json:"query"`
+}
+
+type alertResponse struct {
+	ID        int64  `json:"id"`
+	Query     string `json:"query"`
+	CreatedAt string `json:"created_at"`
+}
+
+type alertMatchResponse struct {
+	ID           int64  `json:"id"`
+	AlertID      int64  `json:"alert_id"`
+	Query        string `json:"query"`
+	VideoID      string `json:"video_id"`
+	VideoTitle   string `json:"video_title"`
+	VideoURL     string `json:"video_url"`
+	ChannelID    string `json:"channel_id"`
+	ChannelTitle string `json:"channel_title"`
+	PublishedAt  string `json:"published_at"`
+	Read         bool   `json:"read"`
+	MatchedAt    string `json:"matched_at"`
+}
+
+// return the trimmed alert query, or an error when it's not valid
+func alertQuery(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return "", fmt.Errorf("missing alert query")
+	}
+	if utf8.RuneCountInString(query) > maxAlertQueryLength {
+		return "", fmt.Errorf("alert query longer than %d characters", maxAlertQueryLength)
+	}
+	return query, nil
+}
+
+// max number of new uploads of a channel matched against the alerts
+const maxAlertUploads = 50
+
+// alertMatchesNotification is the payload posted to the alert webhook of the user
+type alertMatchesNotification struct {
+	Matches []alertMatchResponse `json:"matches"`
+}
+
+// return the time after which the uploads are new to an alert: its creation or, once matched, the start of the
+// latest sync
+func alertSince(alert database.Alert) time.Time {
+	if alert.CheckedAt != nil && alert.CheckedAt.After(alert.CreatedAt) {
+		return *alert.CheckedAt
+	}
+	return alert.CreatedAt
+}
+
+// return the new uploads of the channels published after the given time, one channel each with the upload as latest
+// video: the NewItemCount newest items of their uploads playlist, up to maxAlertUploads. The latest video stands for
+// the uploads of a channel when its playlist can't be retrieved, or when no YouTube service is available
+func newUploads(svc clients.YoutubeClientInterface, ytChannels []YTChannel, since time.Time,
+	username string) []YTChannel {
+	ctx := context.Background()
+	newItemCounts := make(map[string]int64, len(ytChannels))
+	for _, ytChannel := range ytChannels {
+		newItemCounts[ytChannel.ChannelID] = min(max(ytChannel.NewItemCount, 1), maxAlertUploads)
+	}
+	playlists := make(map[string][]*youtube.PlaylistItem)
+	if svc != nil {
+		playlists = fetchChannelPlaylists(ytChannels, username, func(channelID string) ([]*youtube.PlaylistItem, error) {
+			return svc.GetPlaylistVideosSince(ctx, uploadsPlaylistID(channelID), since, newItemCounts[channelID])
+		})
+	}
+
+	uploads := make([]YTChannel, 0, len(ytChannels))
+	for _, ytChannel := range ytChannels {
+		playlistItems, ok := playlists[ytChannel.ChannelID]
+		if !ok {
+			if ytChannel.LatestVideoID != "" {
+				uploads = append(uploads, ytChannel)
+			}
+			continue
+		}
+		for _, playlistItem := range playlistItems {
+			upload := ytChannel
+			setLatestVideo(&upload, playlistItem)
+			uploads = append(uploads, upload)
+		}
+	}
+	return uploads
+}
+
+// return the matches of the alerts among the uploads, given as channels with the upload as latest video. An alert
+// matches the uploads published after alertSince whose title or description contains its query, case-insensitively
+func matchAlerts(alerts []database.Alert, uploads []YTChannel) []database.AlertMatch {
+	matches := make([]database.AlertMatch, 0)
+	for _, upload := range uploads {
+		publishedAt, err := time.Parse(time.RFC3339, upload.LatestVideoPublishedAt)
+		if upload.LatestVideoID == "" || err != nil {
+			continue
+		}
+		title, description := strings.ToLower(upload.LatestVideoTitle), strings.ToLower(upload.LatestVideoDescription)
+		for _, alert := range alerts {
+			if !publishedAt.After(alertSince(alert)) {
+				continue
+			}
+			query := strings.ToLower(alert.Query)
+			if !strings.Contains(title, query) && !strings.Contains(description, query) {
+				continue
+			}
+			matches = append(matches, database.AlertMatch{
+				AlertId:      alert.Id,
+				UserId:       alert.UserId,
+				Query:        alert.Query,
+				VideoID:      upload.LatestVideoID,
+				VideoTitle:   upload.LatestVideoTitle,
+				ChannelID:    upload.ChannelID,
+				ChannelTitle: upload.Title,
+				PublishedAt:  upload.LatestVideoPublishedAt,
+			})
+		}
+	}
+	return matches
+}
+
+// record in the inbox of the logged user the matches of their alerts among the uploads of the channels published
+// since the previous sync, returning the new ones. The uploads are retrieved with the given YouTube service, nil to
+// match the latest videos only. Failures are logged only, not to prevent the sync from going on
+func recordAlertMatches(r *http.Request, storage database.StorageInterface, svc clients.YoutubeClientInterface,
+	tokenInfo *auth.TokenInfo, ytChannels []YTChannel, now time.Time, funcName string) []database.AlertMatch {
+	alerts, err := storage.GetAlerts(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to retrieve alerts, skipping them: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		return nil
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	since := now
+	for _, alert := range alerts {
+		if alertSince(alert).Before(since) {
+			since = alertSince(alert)
+		}
+	}
+	added, err := storage.AddAlertMatches(r.Context(),
+		matchAlerts(alerts, newUploads(svc, ytChannels, since, tokenInfo.Username)))
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to record alert matches: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		return nil
+	}
+	// the uploads published during the sync are matched by the next one
+	if err = storage.MarkAlertsChecked(r.Context(), tokenInfo.AppUserId, now); err != nil {
+		slog.Warn(fmt.Sprintf("failed to mark alerts as checked: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+	}
+	for i, match := range added {
+		added[i].CreatedAt = now
+		slog.Info(fmt.Sprintf("video %s of channel %s matches alert %q", match.VideoID, match.ChannelTitle,
+			match.Query), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+	}
+	return added
+}
+
+// post the new alert matches to the webhook set by the logged user, if any. Failures are logged only, the matches
+// are listed in the inbox anyway
+func notifyAlertMatches(r *http.Request, webhook clients.WebhookClientInterface, preferences database.Preferences,
+	tokenInfo *auth.TokenInfo, matches []database.AlertMatch, funcName string) {
+	if preferences.AlertWebhookURL == "" || len(matches) == 0 {
+		return
+	}
+	notification := alertMatchesNotification{Matches: make([]alertMatchResponse, 0, len(matches))}
+	for _, match := range matches {
+		notification.Matches = append(notification.Matches, newAlertMatchResponse(match))
+	}
+	if err := webhook.Post(r.Context(), preferences.AlertWebhookURL, notification); err != nil {
+		slog.Warn(fmt.Sprintf("failed to notify %d alert matches: %s", len(matches), err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		return
+	}
+	slog.Info(fmt.Sprintf("%d alert matches notified", len(matches)), logging.FuncNameAttr(funcName),
+		logging.UserAttr(tokenInfo.Username))
+}
+
+// return an alert match as listed by the API
+func newAlertMatchResponse(match database.AlertMatch) alertMatchResponse {
+	return alertMatchResponse{
+		ID:           match.Id,
+		AlertID:      match.AlertId,
+		Query:        match.Query,
+		VideoID:      match.VideoID,
+		VideoTitle:   match.VideoTitle,
+		VideoURL:     fmt.Sprintf("%s/watch?v=%s", youTubeBasepath, match.VideoID),
+		ChannelID:    match.ChannelID,
+		ChannelTitle: match.ChannelTitle,
+		PublishedAt:  match.PublishedAt,
+		Read:         match.ReadAt != nil,
+		MatchedAt:    match.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// return the number of unread alert matches of the logged user, 0 when it can't be retrieved
+func unreadAlertMatches(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	funcName string) int {
+	count, err := storage.CountUnreadAlertMatches(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to count unread alert matches: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+	}
+	return count
+}
+
+// GetAlerts renders the alerts page, listing the alerts of the logged user and their latest matches
+func GetAlerts(storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "GetAlerts"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		alerts, err := storage.GetAlerts(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve alerts: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		matches, err := storage.GetAlertMatches(r.Context(), tokenInfo.AppUserId, alertInboxSize)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve alert matches: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := alertsTemplateResponse{
+			Username:       tokenInfo.Username,
+			Alerts:         alerts,
+			Matches:        matches,
+			Theme:          userPreferences(r, storage, tokenInfo, funcName).Theme,
+			ServerBasepath: serverBasepath,
+		}
+		for _, match := range matches {
+			if match.ReadAt == nil {
+				response.UnreadCount++
+			}
+		}
+
+		// render response as HTML using a template
+		tmpl, err := template.New("alertsTemplate.tmpl").Parse(htmlTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = tmpl.Execute(w, response)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// CreateAlert creates a new alert of the logged user from the alerts page
+func CreateAlert(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "CreateAlert"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		query, err := alertQuery(r.FormValue("query"))
+		if err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err = storage.CreateAlert(r.Context(), tokenInfo.AppUserId, query); err != nil {
+			slog.Error(fmt.Sprintf("failed to create alert: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("alert %q created", query), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/alerts", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// DeleteAlert removes an alert of the logged user, along with its matches, from the alerts page
+func DeleteAlert(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "DeleteAlert"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid alert id: %s", r.FormValue("id"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err = storage.DeleteAlert(r.Context(), tokenInfo.AppUserId, id); err != nil {
+			slog.Error(fmt.Sprintf("failed to delete alert: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("alert %d deleted", id), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/alerts", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// MarkAlertsRead marks all the alert matches of the logged user as read from the alerts page
+func MarkAlertsRead(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "MarkAlertsRead"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		if err := storage.MarkAlertMatchesRead(r.Context(), tokenInfo.AppUserId); err != nil {
+			slog.Error(fmt.Sprintf("failed to mark alert matches as read: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("%s/alerts", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// AlertsAPI lists the alerts of the user as JSON on GET, creates one on POST and deletes the one given by the id
+// query parameter on DELETE
+func AlertsAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "AlertsAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			alerts, err := storage.GetAlerts(r.Context(), tokenInfo.AppUserId)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve alerts: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			response := make([]alertResponse, 0, len(alerts))
+			for _, alert := range alerts {
+				response = append(response, alertResponse{
+					ID:        alert.Id,
+					Query:     alert.Query,
+					CreatedAt: alert.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+				})
+			}
+			writeJSON(w, response, funcName)
+		case http.MethodPost:
+			var req alertRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			query, err := alertQuery(req.Query)
+			if err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			id, err := storage.CreateAlert(r.Context(), tokenInfo.AppUserId, query)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to create alert: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("alert %q created", query), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(w, alertResponse{ID: id, Query: query}, funcName)
+		case http.MethodDelete:
+			id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+			if err != nil {
+				err = fmt.Errorf("invalid alert id: %s", r.URL.Query().Get("id"))
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err = storage.DeleteAlert(r.Context(), tokenInfo.AppUserId, id); err != nil {
+				slog.Error(fmt.Sprintf("failed to delete alert: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("alert %d deleted", id), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// AlertMatchesAPI lists the latest alert matches of the user as JSON on GET, and marks them all as read on POST
+func AlertMatchesAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "AlertMatchesAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			matches, err := storage.GetAlertMatches(r.Context(), tokenInfo.AppUserId, alertInboxSize)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve alert matches: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			unreadOnly := r.URL.Query().Get("unread") == "true"
+			response := make([]alertMatchResponse, 0, len(matches))
+			for _, match := range matches {
+				if unreadOnly && match.ReadAt != nil {
+					continue
+				}
+				response = append(response, newAlertMatchResponse(match))
+			}
+			writeJSON(w, response, funcName)
+		case http.MethodPost:
+			if err := storage.MarkAlertMatchesRead(r.Context(), tokenInfo.AppUserId); err != nil {
+				slog.Error(fmt.Sprintf("failed to mark alert matches as read: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
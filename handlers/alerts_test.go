@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"bytes"
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_matchAlerts(t *testing.T) {
+	createdAt, checkedAt := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)
+	alerts := []database.Alert{{Id: 1, UserId: 1, Query: "Go 1.24", CreatedAt: createdAt},
+		{Id: 2, UserId: 1, Query: "rust", CreatedAt: createdAt, CheckedAt: &checkedAt}}
+	uploads := []YTChannel{
+		{ChannelID: "channel1", Title: "Go", LatestVideoID: "video1", LatestVideoTitle: "What's new in go 1.24",
+			LatestVideoPublishedAt: "2025-02-11T00:00:00Z"},
+		{ChannelID: "channel2", Title: "Systems", LatestVideoID: "video2", LatestVideoTitle: "Weekly news",
+			LatestVideoDescription: "Rust and Go 1.24 releases", LatestVideoPublishedAt: "2025-02-12T00:00:00Z"},
+		{ChannelID: "channel2", Title: "Systems", LatestVideoID: "video3", LatestVideoTitle: "Rust before the check",
+			LatestVideoPublishedAt: "2025-02-05T00:00:00Z"},
+		{ChannelID: "channel3", Title: "Go", LatestVideoID: "video4", LatestVideoTitle: "Go 1.24 preview",
+			LatestVideoPublishedAt: "2025-01-20T00:00:00Z"},
+		{ChannelID: "channel4", Title: "Cooking", LatestVideoID: "video5", LatestVideoTitle: "Pasta",
+			LatestVideoPublishedAt: "2025-02-11T00:00:00Z"},
+		{ChannelID: "channel5", Title: "Unknown date", LatestVideoID: "video6", LatestVideoTitle: "Go 1.24"},
+		{ChannelID: "channel6", Title: "Empty"},
+	}
+
+	want := []database.AlertMatch{
+		{AlertId: 1, UserId: 1, Query: "Go 1.24", VideoID: "video1", VideoTitle: "What's new in go 1.24",
+			ChannelID: "channel1", ChannelTitle: "Go", PublishedAt: "2025-02-11T00:00:00Z"},
+		{AlertId: 1, UserId: 1, Query: "Go 1.24", VideoID: "video2", VideoTitle: "Weekly news",
+			ChannelID: "channel2", ChannelTitle: "Systems", PublishedAt: "2025-02-12T00:00:00Z"},
+		{AlertId: 2, UserId: 1, Query: "rust", VideoID: "video2", VideoTitle: "Weekly news",
+			ChannelID: "channel2", ChannelTitle: "Systems", PublishedAt: "2025-02-12T00:00:00Z"},
+	}
+	if diff := cmp.Diff(want, matchAlerts(alerts, uploads)); diff != "" {
+		t.Errorf("matchAlerts() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_newUploads(t *testing.T) {
+	since := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	playlistItem := func(videoID, publishedAt string) *youtube.PlaylistItem {
+		return &youtube.PlaylistItem{Snippet: &youtube.PlaylistItemSnippet{Title: videoID, PublishedAt: publishedAt,
+			ResourceId: &youtube.ResourceId{VideoId: videoID}}}
+	}
+	ytChannels := []YTChannel{
+		{ChannelID: "UCchannel1", Title: "channel1", LatestVideoID: "video2", NewItemCount: 2},
+		{ChannelID: "UCchannel2", Title: "channel2", LatestVideoID: "video4", NewItemCount: 100},
+		{ChannelID: "UCchannel3", Title: "channel3", LatestVideoID: "video5", NewItemCount: 1},
+	}
+	var mutex sync.Mutex
+	maxResults := make(map[string]int64)
+	svc := &youtubeClientMock{
+		getPlaylistVideosSinceStub: func(_ context.Context, playlistID string, s time.Time,
+			max int64) ([]*youtube.PlaylistItem, error) {
+			if !s.Equal(since) {
+				t.Errorf("newUploads() retrieved the uploads since %v, want %v", s, since)
+			}
+			mutex.Lock()
+			maxResults[playlistID] = max
+			mutex.Unlock()
+			switch playlistID {
+			case "UUchannel1":
+				return []*youtube.PlaylistItem{playlistItem("video2", "2025-02-03T00:00:00Z"),
+					playlistItem("video1", "2025-02-02T00:00:00Z")}, nil
+			case "UUchannel2":
+				return []*youtube.PlaylistItem{playlistItem("video4", "2025-02-03T00:00:00Z")}, nil
+			}
+			return nil, fmt.Errorf("test error")
+		},
+	}
+
+	tests := []struct {
+		name           string
+		svc            clients.YoutubeClientInterface
+		want           []string
+		wantMaxResults map[string]int64
+	}{
+		{
+			name:           "success case - new uploads of each channel",
+			svc:            svc,
+			want:           []string{"video2", "video1", "video4", "video5"},
+			wantMaxResults: map[string]int64{"UUchannel1": 2, "UUchannel2": 50, "UUchannel3": 1},
+		},
+		{
+			name:           "success case - latest videos without YouTube service",
+			want:           []string{"video2", "video4", "video5"},
+			wantMaxResults: map[string]int64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(maxResults)
+			videoIDs := make([]string, 0)
+			for _, upload := range newUploads(tt.svc, ytChannels, since, "user") {
+				videoIDs = append(videoIDs, upload.LatestVideoID)
+			}
+			if diff := cmp.Diff(tt.want, videoIDs); diff != "" {
+				t.Errorf("newUploads() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantMaxResults, maxResults); diff != "" {
+				t.Errorf("newUploads() max results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_notifyAlertMatches(t *testing.T) {
+	matchedAt := time.Date(2025, 2, 11, 8, 0, 0, 0, time.UTC)
+	matches := []database.AlertMatch{{AlertId: 1, Query: "go", VideoID: "video1", VideoTitle: "Go 1.24",
+		ChannelID: "channel1", ChannelTitle: "Go", PublishedAt: "2025-02-11T00:00:00Z", CreatedAt: matchedAt}}
+
+	tests := []struct {
+		name       string
+		webhookURL string
+		matches    []database.AlertMatch
+		postErr    error
+		wantPosted bool
+	}{
+		{
+			name:       "success case",
+			webhookURL: "https://example.com/hook",
+			matches:    matches,
+			wantPosted: true,
+		},
+		{
+			name:       "success case - no webhook",
+			matches:    matches,
+			wantPosted: false,
+		},
+		{
+			name:       "success case - no new match",
+			webhookURL: "https://example.com/hook",
+			matches:    []database.AlertMatch{},
+			wantPosted: false,
+		},
+		{
+			name:       "error case - webhook error",
+			webhookURL: "https://example.com/hook",
+			matches:    matches,
+			postErr:    fmt.Errorf("test error"),
+			wantPosted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posted := false
+			webhook := webhookClientMock{
+				postStub: func(_ context.Context, url string, payload any) error {
+					posted = true
+					want := alertMatchesNotification{Matches: []alertMatchResponse{{AlertID: 1, Query: "go",
+						VideoID: "video1", VideoTitle: "Go 1.24", VideoURL: "https://www.youtube.com/watch?v=video1",
+						ChannelID: "channel1", ChannelTitle: "Go", PublishedAt: "2025-02-11T00:00:00Z",
+						MatchedAt: "2025-02-11T08:00:00Z"}}}
+					if url != tt.webhookURL {
+						t.Errorf("notifyAlertMatches() posted to %s, want %s", url, tt.webhookURL)
+					}
+					if diff := cmp.Diff(want, payload); diff != "" {
+						t.Errorf("notifyAlertMatches() payload mismatch (-want +got):\n%s", diff)
+					}
+					return tt.postErr
+				},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/sync", nil)
+			preferences := database.DefaultPreferences()
+			preferences.AlertWebhookURL = tt.webhookURL
+			notifyAlertMatches(req, webhook, preferences, &auth.TokenInfo{Username: "user"}, tt.matches, "test")
+			if posted != tt.wantPosted {
+				t.Errorf("notifyAlertMatches() posted = %v, want %v", posted, tt.wantPosted)
+			}
+		})
+	}
+}
+
+func TestGetAlerts(t *testing.T) {
+	// mocks
+	const (
+		serverBasepath = "http://localhost:8900"
+		tokenNotFound  = "redirect case - token not found in context"
+	)
+	readAt := time.Now()
+
+	tests := []struct {
+		name    string
+		storage database.StorageInterface
+		want    int
+	}{
+		{
+			name: "success case",
+			storage: &test.StorageMock{
+				GetAlertsStub: func(_ context.Context, userId int64) ([]database.Alert, error) {
+					return []database.Alert{{Id: 1, Query: "go 1.24"}}, nil
+				},
+				GetAlertMatchesStub: func(_ context.Context, userId int64, limit int) ([]database.AlertMatch, error) {
+					return []database.AlertMatch{{Id: 1, AlertId: 1, Query: "go 1.24", VideoID: "video1"},
+						{Id: 2, AlertId: 1, Query: "go 1.24", VideoID: "video2", ReadAt: &readAt}}, nil
+				},
+				GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+					return database.DefaultPreferences(), nil
+				},
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "error case - alerts storage error",
+			storage: &test.StorageMock{
+				GetAlertsStub: func(_ context.Context, userId int64) ([]database.Alert, error) {
+					return nil, fmt.Errorf("test error")
+				},
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "error case - matches storage error",
+			storage: &test.StorageMock{
+				GetAlertsStub: func(_ context.Context, userId int64) ([]database.Alert, error) {
+					return []database.Alert{}, nil
+				},
+				GetAlertMatchesStub: func(_ context.Context, userId int64, limit int) ([]database.AlertMatch, error) {
+					return nil, fmt.Errorf("test error")
+				},
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name:    tokenNotFound,
+			storage: &test.StorageMock{},
+			want:    http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/alerts", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetAlerts(tt.storage, serverBasepath, "")
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("GetAlerts() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAlert(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		CreateAlertStub: func(_ context.Context, userId int64, query string) (int64, error) {
+			if query == "error" {
+				return 0, fmt.Errorf("test error")
+			}
+			return 1, nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		query  string
+		want   int
+	}{
+		{
+			name:   "success case",
+			method: http.MethodPost,
+			query:  " Go 1.24 ",
+			want:   http.StatusSeeOther,
+		},
+		{
+			name:   "failure case - missing query",
+			method: http.MethodPost,
+			query:  " ",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - query too long",
+			method: http.MethodPost,
+			query:  strings.Repeat("a", maxAlertQueryLength+1),
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - storage error",
+			method: http.MethodPost,
+			query:  "error",
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "failure case - method not allowed",
+			method: http.MethodGet,
+			query:  "Go 1.24",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"query": {tt.query}}
+			req, err := http.NewRequest(tt.method, "/create-alert", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := CreateAlert(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("CreateAlert() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertsAPI(t *testing.T) {
+	// mocks
+	const tokenNotFound = "error case - token not found in context"
+	storage := &test.StorageMock{
+		GetAlertsStub: func(_ context.Context, userId int64) ([]database.Alert, error) {
+			return []database.Alert{{Id: 1, Query: "go 1.24"}}, nil
+		},
+		CreateAlertStub: func(_ context.Context, userId int64, query string) (int64, error) {
+			return 2, nil
+		},
+		DeleteAlertStub: func(_ context.Context, userId, id int64) error {
+			if id != 1 {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{
+			name:   "success case - list",
+			method: http.MethodGet,
+			target: "/api/v1/alerts",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "success case - create",
+			method: http.MethodPost,
+			target: "/api/v1/alerts",
+			body:   `{"query": "rust"}`,
+			want:   http.StatusCreated,
+		},
+		{
+			name:   "error case - create missing query",
+			method: http.MethodPost,
+			target: "/api/v1/alerts",
+			body:   `{"query": ""}`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "success case - delete",
+			method: http.MethodDelete,
+			target: "/api/v1/alerts?id=1",
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "error case - delete invalid id",
+			method: http.MethodDelete,
+			target: "/api/v1/alerts?id=abc",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - delete storage error",
+			method: http.MethodDelete,
+			target: "/api/v1/alerts?id=2",
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   tokenNotFound,
+			method: http.MethodGet,
+			target: "/api/v1/alerts",
+			want:   http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := AlertsAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("AlertsAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertMatchesAPI(t *testing.T) {
+	// mocks
+	readAt := time.Now()
+	storage := &test.StorageMock{
+		GetAlertMatchesStub: func(_ context.Context, userId int64, limit int) ([]database.AlertMatch, error) {
+			return []database.AlertMatch{{Id: 1, AlertId: 1, Query: "go 1.24", VideoID: "video1"},
+				{Id: 2, AlertId: 1, Query: "go 1.24", VideoID: "video2", ReadAt: &readAt}}, nil
+		},
+		MarkAlertMatchesReadStub: func(_ context.Context, userId int64) error {
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		want      int
+		wantCount int
+	}{
+		{
+			name:      "success case - list",
+			method:    http.MethodGet,
+			target:    "/api/v1/alerts/matches",
+			want:      http.StatusOK,
+			wantCount: 2,
+		},
+		{
+			name:      "success case - list unread",
+			method:    http.MethodGet,
+			target:    "/api/v1/alerts/matches?unread=true",
+			want:      http.StatusOK,
+			wantCount: 1,
+		},
+		{
+			name:   "success case - mark as read",
+			method: http.MethodPost,
+			target: "/api/v1/alerts/matches",
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodDelete,
+			target: "/api/v1/alerts/matches",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := AlertMatchesAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("AlertMatchesAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				var response []alertMatchResponse
+				if err = json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+					t.Fatalf("AlertMatchesAPI() invalid JSON response: %v", err)
+				}
+				if len(response) != tt.wantCount {
+					t.Errorf("AlertMatchesAPI() got %d matches, want %d", len(response), tt.wantCount)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+type feedResponse struct {
+	YTChannels []YTChannel `json:"channels"`
+}
+
+type linkedAccountResponse struct {
+	AccountID   string `json:"account_id"`
+	DisplayName string `json:"display_name"`
+	LinkedAt    string `json:"linked_at"`
+}
+
+// GetFeed returns as JSON the channels with new videos of the Google accounts linked to the user, only the ones
+// of the channel group given by the group query parameter if set
+func GetFeed(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "GetFeed"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ytChannels, ok := apiFeedChannels(w, r, oauth2C, ytcf, storage, tokenInfo, nil, funcName)
+		if !ok {
+			return
+		}
+		writeJSON(w, feedResponse{YTChannels: ytChannels}, funcName)
+	}
+}
+
+// return the channels of the Google accounts linked to the user having new videos, all of them unless the filtered
+// query parameter is true, tagged with their channel groups and only the ones of the group query parameter and in
+// the range of the min_duration and max_duration ones if set. The response is written when they can't be retrieved
+func apiFeedChannels(w http.ResponseWriter, r *http.Request, oauth2C auth.Oauth2Config,
+	ytcf clients.YoutubeClientFactoryInterface, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	emit func([]YTChannel), funcName string) ([]YTChannel, bool) {
+	filtered := r.URL.Query().Get("filtered") == "true"
+	var groupID int64
+	group := r.URL.Query().Get("group")
+	if group != "" {
+		var err error
+		if groupID, err = strconv.ParseInt(group, 10, 64); err != nil {
+			err = fmt.Errorf("invalid group: %s", group)
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	minDuration, maxDuration, err := parseDurationRange(r.URL.Query())
+	if err != nil {
+		slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	videoRules := userVideoRules(r, storage, tokenInfo, funcName)
+	groups := userChannelGroups(r, storage, tokenInfo, funcName)
+	narrow := func(ytChannels []YTChannel) []YTChannel {
+		ytChannels = tagChannelGroups(videoRules.filter(ytChannels), groups)
+		return slices.DeleteFunc(ytChannels, func(ytChannel YTChannel) bool {
+			return (group != "" && !inChannelGroup(ytChannel, groupID)) ||
+				!inDurationRange(ytChannel.LatestVideoDurationSeconds, minDuration, maxDuration)
+		})
+	}
+	var emitNarrowed func([]YTChannel)
+	if emit != nil {
+		emitNarrowed = func(ytChannels []YTChannel) {
+			if ytChannels = narrow(ytChannels); len(ytChannels) > 0 {
+				emit(ytChannels)
+			}
+		}
+	}
+
+	// get YouTube subscriptions info of each linked account and of the followed channels
+	availability := newAvailabilityOptions(userPreferences(r, storage, tokenInfo, funcName))
+	ytChannels, _, err := getAccountsYTChannels(r.Context(), oauth2C, ytcf, tokenInfo,
+		contextAccounts(r, tokenInfo), userFollowedChannels(r, storage, tokenInfo, funcName), filtered,
+		userChannelRules(r, storage, tokenInfo, funcName), availability, emitNarrowed)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	return narrow(ytChannels), true
+}
+
+// LinkedAccountsAPI lists the Google accounts linked to the user as JSON on GET, and unlinks the one
+// given by the account_id query parameter on DELETE
+func LinkedAccountsAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "LinkedAccountsAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			linkedAccounts, err := storage.GetLinkedAccounts(r.Context(), tokenInfo.AppUserId)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve linked accounts: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			response := make([]linkedAccountResponse, 0, len(linkedAccounts))
+			for _, account := range linkedAccounts {
+				response = append(response, linkedAccountResponse{
+					AccountID:   account.AccountId,
+					DisplayName: account.DisplayName,
+					LinkedAt:    account.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+				})
+			}
+			writeJSON(w, response, funcName)
+		case http.MethodDelete:
+			accountID := r.URL.Query().Get("account_id")
+			if accountID == "" {
+				err := fmt.Errorf("missing account_id")
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err := storage.UnlinkAccount(r.Context(), tokenInfo.AppUserId, accountID); err != nil {
+				slog.Error(fmt.Sprintf("failed to unlink account: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("account %s unlinked", accountID), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any, funcName string) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error(fmt.Sprintf("failed to write JSON response: %s", err.Error()), logging.FuncNameAttr(funcName))
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetFeed(t *testing.T) {
+	// mocks
+	const tokenNotFound = "error case - token not found in context"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return &youtubeClientMock{
+				getAndProcessSubscriptionsStub: func(ctx context.Context,
+					f func(*youtube.SubscriptionListResponse) error) error {
+					return nil
+				},
+			}, nil
+		},
+	}
+
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+		GetChannelGroupsStub: func(_ context.Context, userId int64) ([]database.ChannelGroup, error) {
+			return []database.ChannelGroup{{Id: 1, Name: "Music", ChannelIDs: []string{"channel1"}}}, nil
+		},
+		GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+			return []database.ChannelRule{{ChannelID: "channel2", Kind: database.MuteRule}}, nil
+		},
+		GetFollowedChannelsStub: func(_ context.Context, userId int64) ([]database.FollowedChannel, error) {
+			return []database.FollowedChannel{}, nil
+		},
+		GetVideoRulesStub: func(_ context.Context, userId int64) ([]database.VideoRule, error) {
+			return []database.VideoRule{}, nil
+		},
+	}
+
+	type args struct {
+		method string
+		target string
+		ytcf   clients.YoutubeClientFactoryInterface
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "success case",
+			args: args{method: http.MethodGet, target: "/api/v1/feed", ytcf: ytcf},
+			want: http.StatusOK,
+		},
+		{
+			name: "success case - channel group",
+			args: args{method: http.MethodGet, target: "/api/v1/feed?group=1", ytcf: ytcf},
+			want: http.StatusOK,
+		},
+		{
+			name: "error case - invalid channel group",
+			args: args{method: http.MethodGet, target: "/api/v1/feed?group=music", ytcf: ytcf},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "error case - invalid duration range",
+			args: args{method: http.MethodGet, target: "/api/v1/feed?min_duration=15m&max_duration=5m", ytcf: ytcf},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "error case - error on creating youtube client",
+			args: args{
+				method: http.MethodGet,
+				target: "/api/v1/feed",
+				ytcf: &youtubeClientFactoryMock{
+					newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+						return nil, fmt.Errorf("testerror")
+					},
+				},
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: tokenNotFound,
+			args: args{method: http.MethodGet, target: "/api/v1/feed", ytcf: ytcf},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "error case - method not allowed",
+			args: args{method: http.MethodPost, target: "/api/v1/feed", ytcf: ytcf},
+			want: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.args.method, tt.args.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{Token: &oauth2.Token{}}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetFeed(oauth2C, tt.args.ytcf, storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("GetFeed() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				var response feedResponse
+				if err = json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+					t.Errorf("GetFeed() invalid JSON response: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLinkedAccountsAPI(t *testing.T) {
+	// mocks
+	storage := &test.StorageMock{
+		GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+			return []database.LinkedAccount{{AccountId: "1", DisplayName: "usertest"}}, nil
+		},
+		UnlinkAccountStub: func(_ context.Context, userId int64, accountId string) error {
+			if accountId == "unknown" {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{
+			name:   "success case - list",
+			method: http.MethodGet,
+			target: "/api/v1/accounts",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "success case - unlink",
+			method: http.MethodDelete,
+			target: "/api/v1/accounts?account_id=2",
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "error case - missing account id",
+			method: http.MethodDelete,
+			target: "/api/v1/accounts",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - storage error",
+			method: http.MethodDelete,
+			target: "/api/v1/accounts?account_id=unknown",
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodPost,
+			target: "/api/v1/accounts",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := LinkedAccountsAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("LinkedAccountsAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
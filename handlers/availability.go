@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"context"
+	"fmt"
+	"google.golang.org/api/youtube/v3"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// reasons of a video not being watchable
+const (
+	PrivateVideo       = "private"
+	DeletedVideo       = "deleted"
+	MembersOnlyVideo   = "members_only"
+	RegionBlockedVideo = "region_blocked"
+)
+
+// descriptions of the reasons of a video not being watchable shown in the feed
+var unavailableLabels = map[string]string{
+	PrivateVideo:       "private",
+	DeletedVideo:       "deleted",
+	MembersOnlyVideo:   "members only",
+	RegionBlockedVideo: "blocked in your region",
+}
+
+// max number of the latest uploads of a channel looked through for a watchable video, or a members-only one
+const maxSkippedVideos = 10
+
+// SkippedVideo is an unavailable video passed over to show an older watchable one
+type SkippedVideo struct {
+	VideoID string `json:"video_id"`
+	Title   string `json:"title"`
+	Reason  string `json:"reason"`
+}
+
+// availabilityOptions set how the unavailable latest videos are detected and handled
+type availabilityOptions struct {
+	// Region is the ISO 3166-1 alpha-2 code of the region restrictions checked, none when empty
+	Region string
+	// SkipUnavailable replaces the unavailable latest videos with the newest watchable ones
+	SkipUnavailable bool
+	// DetectMembersOnly calls the YouTube API for the members-only playlist of each channel
+	DetectMembersOnly bool
+}
+
+// return the availability options set by the preferences of the user
+func newAvailabilityOptions(preferences database.Preferences) availabilityOptions {
+	return availabilityOptions{
+		Region:            preferences.Region,
+		SkipUnavailable:   preferences.SkipUnavailableVideos,
+		DetectMembersOnly: preferences.DetectMembersOnlyVideos,
+	}
+}
+
+// return the id of the playlist of the members-only videos of a channel, obtained by changing the prefix of its id
+func membersOnlyPlaylistID(channelID string) string {
+	return "UUMO" + channelID[2:]
+}
+
+// return why a video can't be watched, empty when it can. The video is nil when not returned by the YouTube videos
+// API, the privacy status and title are the ones of its playlist item
+func unavailableReason(video *youtube.Video, privacyStatus, title string, options availabilityOptions,
+	membersOnly bool) string {
+	if video == nil {
+		// private videos keep a placeholder title in the playlists
+		if privacyStatus == "private" || title == "Private video" {
+			return PrivateVideo
+		}
+		return DeletedVideo
+	}
+	if video.Status != nil && video.Status.PrivacyStatus == "private" {
+		return PrivateVideo
+	}
+	if membersOnly {
+		return MembersOnlyVideo
+	}
+	if options.Region != "" && video.ContentDetails != nil && video.ContentDetails.RegionRestriction != nil {
+		restriction := video.ContentDetails.RegionRestriction
+		if slices.Contains(restriction.Blocked, options.Region) ||
+			(len(restriction.Allowed) > 0 && !slices.Contains(restriction.Allowed, options.Region)) {
+			return RegionBlockedVideo
+		}
+	}
+	return ""
+}
+
+// mark the latest videos that can't be watched with the reason and, when enabled, replace them with the newest
+// watchable video among the latest uploads of the channel. The given videos are the ones returned by the YouTube
+// videos API by id, the channels without a watchable video keep the unavailable one
+func resolveUnavailableVideos(ctx context.Context, svc clients.YoutubeClientInterface, response []YTChannel,
+	videos map[string]*youtube.Video, options availabilityOptions, username string) {
+	const funcName = "resolveUnavailableVideos"
+
+	membersOnly := make(map[string]bool)
+	if options.DetectMembersOnly {
+		playlists := channelPlaylists(ctx, svc, response, membersOnlyPlaylistID, maxSkippedVideos, username)
+		for _, playlistItems := range playlists {
+			for _, playlistItem := range playlistItems {
+				membersOnly[playlistItem.Snippet.ResourceId.VideoId] = true
+			}
+		}
+	}
+
+	unavailable := make([]YTChannel, 0)
+	for i, ytChannel := range response {
+		if ytChannel.LatestVideoID == "" {
+			continue
+		}
+		response[i].LatestVideoUnavailable = unavailableReason(videos[ytChannel.LatestVideoID],
+			ytChannel.LatestVideoPrivacyStatus, ytChannel.LatestVideoTitle, options,
+			membersOnly[ytChannel.LatestVideoID])
+		if response[i].LatestVideoUnavailable != "" {
+			unavailable = append(unavailable, response[i])
+		}
+	}
+	if !options.SkipUnavailable || len(unavailable) == 0 {
+		return
+	}
+
+	// look for the newest watchable video among the latest uploads, retrieving the ones not retrieved yet
+	uploads := channelPlaylists(ctx, svc, unavailable, uploadsPlaylistID, maxSkippedVideos, username)
+	videoIDs := make([]string, 0)
+	for _, playlistItems := range uploads {
+		for _, playlistItem := range playlistItems {
+			videoID := playlistItem.Snippet.ResourceId.VideoId
+			if _, ok := videos[videoID]; !ok && !slices.Contains(videoIDs, videoID) {
+				videoIDs = append(videoIDs, videoID)
+			}
+		}
+	}
+	olderVideos, err := getVideos(ctx, svc, videoIDs)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("error retrieving older videos, keeping the unavailable ones: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(username))
+		return
+	}
+	for videoID, video := range videos {
+		olderVideos[videoID] = video
+	}
+
+	for i, ytChannel := range response {
+		if ytChannel.LatestVideoUnavailable == "" {
+			continue
+		}
+		skipped := []SkippedVideo{{VideoID: ytChannel.LatestVideoID, Title: ytChannel.LatestVideoTitle,
+			Reason: ytChannel.LatestVideoUnavailable}}
+		for _, playlistItem := range uploads[ytChannel.ChannelID] {
+			videoID := playlistItem.Snippet.ResourceId.VideoId
+			if videoID == ytChannel.LatestVideoID {
+				continue
+			}
+			privacyStatus := ""
+			if playlistItem.Status != nil {
+				privacyStatus = playlistItem.Status.PrivacyStatus
+			}
+			reason := unavailableReason(olderVideos[videoID], privacyStatus, playlistItem.Snippet.Title, options,
+				membersOnly[videoID])
+			if reason != "" {
+				skipped = append(skipped, SkippedVideo{VideoID: videoID, Title: playlistItem.Snippet.Title,
+					Reason: reason})
+				continue
+			}
+
+			// the channel is shown with the watchable video in place of the unavailable one
+			watchable := YTChannel{
+				Title:          ytChannel.Title,
+				ChannelID:      ytChannel.ChannelID,
+				URL:            ytChannel.URL,
+				NewItemCount:   ytChannel.NewItemCount,
+				SourceAccounts: ytChannel.SourceAccounts,
+				SkippedVideos:  skipped,
+			}
+			setLatestVideo(&watchable, playlistItem)
+			addVideoDetails(&watchable, olderVideos[videoID], username)
+			response[i] = watchable
+			slog.Debug(fmt.Sprintf("skipped %d unavailable videos of channel %s", len(skipped), ytChannel.Title),
+				logging.FuncNameAttr(funcName), logging.UserAttr(username))
+			break
+		}
+	}
+}
+
+// sum up the skipped videos along with the reasons, e.g. "skipped: Live Q&A (members only), Trailer (private)",
+// empty when none was skipped
+func skippedVideosSummary(skippedVideos []SkippedVideo) string {
+	if len(skippedVideos) == 0 {
+		return ""
+	}
+	summaries := make([]string, 0, len(skippedVideos))
+	for _, skippedVideo := range skippedVideos {
+		summaries = append(summaries, fmt.Sprintf("%s (%s)", skippedVideo.Title,
+			unavailableLabels[skippedVideo.Reason]))
+	}
+	return "skipped: " + strings.Join(summaries, ", ")
+}
+
+// return up to maxResults first items of a playlist of each channel by channel id, calling the YouTube playlist
+// items API concurrently. The channels whose playlist can't be retrieved are left out
+func channelPlaylists(ctx context.Context, svc clients.YoutubeClientInterface, ytChannels []YTChannel,
+	playlistID func(string) string, maxResults int64, username string) map[string][]*youtube.PlaylistItem {
+	return fetchChannelPlaylists(ytChannels, username, func(channelID string) ([]*youtube.PlaylistItem, error) {
+		return svc.GetPlaylistVideos(ctx, playlistID(channelID), maxResults)
+	})
+}
+
+// return by channel id the playlist items retrieved with fetch for each channel, concurrently. The channels whose
+// items can't be retrieved are left out
+func fetchChannelPlaylists(ytChannels []YTChannel, username string,
+	fetch func(channelID string) ([]*youtube.PlaylistItem, error)) map[string][]*youtube.PlaylistItem {
+	const funcName = "fetchChannelPlaylists"
+	playlists := make(map[string][]*youtube.PlaylistItem)
+	wg := &sync.WaitGroup{}
+	mutex := sync.Mutex{}
+	for _, ytChannel := range ytChannels {
+		if len(ytChannel.ChannelID) < 2 {
+			continue
+		}
+		wg.Add(1)
+		go func(channelID string) {
+			defer wg.Done()
+			playlistItems, err := fetch(channelID)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("error retrieving playlist of channel %s: %s", channelID, err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(username))
+				return
+			}
+			// items without a video can't be told apart
+			playlistItems = slices.DeleteFunc(playlistItems, func(playlistItem *youtube.PlaylistItem) bool {
+				return playlistItem.Snippet == nil || playlistItem.Snippet.ResourceId == nil
+			})
+			mutex.Lock()
+			playlists[channelID] = playlistItems
+			mutex.Unlock()
+		}(ytChannel.ChannelID)
+	}
+	wg.Wait()
+	return playlists
+}
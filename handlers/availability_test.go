@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/youtube/v3"
+	"testing"
+)
+
+func Test_unavailableReason(t *testing.T) {
+	restricted := &youtube.Video{ContentDetails: &youtube.VideoContentDetails{
+		RegionRestriction: &youtube.VideoContentDetailsRegionRestriction{Blocked: []string{"IT"}},
+	}}
+	allowed := &youtube.Video{ContentDetails: &youtube.VideoContentDetails{
+		RegionRestriction: &youtube.VideoContentDetailsRegionRestriction{Allowed: []string{"US", "CA"}},
+	}}
+
+	type args struct {
+		video         *youtube.Video
+		privacyStatus string
+		title         string
+		region        string
+		membersOnly   bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "success case - watchable",
+			args: args{video: &youtube.Video{Status: &youtube.VideoStatus{PrivacyStatus: "public"}}, region: "IT"},
+		},
+		{
+			name: "success case - private playlist item",
+			args: args{privacyStatus: "private", title: "Some title"},
+			want: PrivateVideo,
+		},
+		{
+			name: "success case - private placeholder title",
+			args: args{title: "Private video"},
+			want: PrivateVideo,
+		},
+		{
+			name: "success case - deleted",
+			args: args{privacyStatus: "privacyStatusUnspecified", title: "Deleted video"},
+			want: DeletedVideo,
+		},
+		{
+			name: "success case - private video",
+			args: args{video: &youtube.Video{Status: &youtube.VideoStatus{PrivacyStatus: "private"}}},
+			want: PrivateVideo,
+		},
+		{
+			name: "success case - members only",
+			args: args{video: &youtube.Video{}, membersOnly: true},
+			want: MembersOnlyVideo,
+		},
+		{
+			name: "success case - blocked in the region",
+			args: args{video: restricted, region: "IT"},
+			want: RegionBlockedVideo,
+		},
+		{
+			name: "success case - not allowed in the region",
+			args: args{video: allowed, region: "IT"},
+			want: RegionBlockedVideo,
+		},
+		{
+			name: "success case - allowed in the region",
+			args: args{video: allowed, region: "US"},
+		},
+		{
+			name: "success case - region not set",
+			args: args{video: restricted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unavailableReason(tt.args.video, tt.args.privacyStatus, tt.args.title,
+				availabilityOptions{Region: tt.args.region}, tt.args.membersOnly)
+			if got != tt.want {
+				t.Errorf("unavailableReason() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveUnavailableVideos(t *testing.T) {
+	playlistItem := func(videoID, title string) *youtube.PlaylistItem {
+		return &youtube.PlaylistItem{
+			Snippet: &youtube.PlaylistItemSnippet{Title: title, ResourceId: &youtube.ResourceId{VideoId: videoID}},
+			Status:  &youtube.PlaylistItemStatus{PrivacyStatus: "public"},
+		}
+	}
+	// the latest video of channel UCtest-1 is deleted, the older ones are members-only, private and watchable
+	uploads := map[string][]*youtube.PlaylistItem{
+		"UUtest-1": {playlistItem("deleted", "Deleted video"), playlistItem("members", "Live Q&A"),
+			playlistItem("private", "Private video"), playlistItem("watchable", "Tutorial")},
+		"UUMOtest-1": {playlistItem("members", "Live Q&A")},
+	}
+	videos := map[string]*youtube.Video{
+		"video2":    {Id: "video2"},
+		"members":   {Id: "members"},
+		"watchable": {Id: "watchable", ContentDetails: &youtube.VideoContentDetails{Duration: "PT10M"}},
+	}
+	svc := &youtubeClientMock{
+		getPlaylistVideosStub: func(_ context.Context, playlistID string, _ int64) ([]*youtube.PlaylistItem, error) {
+			if playlistID == "UUMOtest-2" {
+				return nil, fmt.Errorf("test error")
+			}
+			return uploads[playlistID], nil
+		},
+		getVideosStub: func(_ context.Context, videoIDs []string,
+			processFunction func(*youtube.VideoListResponse) error) error {
+			response := &youtube.VideoListResponse{}
+			for _, videoID := range videoIDs {
+				if video, ok := videos[videoID]; ok {
+					response.Items = append(response.Items, video)
+				}
+			}
+			return processFunction(response)
+		},
+	}
+	latestVideos := func() []YTChannel {
+		return []YTChannel{
+			{Title: "channel 1", ChannelID: "UCtest-1", LatestVideoID: "deleted", LatestVideoTitle: "Deleted video"},
+			{Title: "channel 2", ChannelID: "UCtest-2", LatestVideoID: "video2", LatestVideoTitle: "Video 2"},
+		}
+	}
+	latestVideosDetails := map[string]*youtube.Video{"video2": videos["video2"]}
+
+	type args struct {
+		options availabilityOptions
+	}
+	tests := []struct {
+		name string
+		args args
+		want []YTChannel
+	}{
+		{
+			name: "success case - unavailable video kept",
+			args: args{options: availabilityOptions{DetectMembersOnly: true}},
+			want: []YTChannel{
+				{Title: "channel 1", ChannelID: "UCtest-1", LatestVideoID: "deleted", LatestVideoTitle: "Deleted video",
+					LatestVideoUnavailable: DeletedVideo},
+				{Title: "channel 2", ChannelID: "UCtest-2", LatestVideoID: "video2", LatestVideoTitle: "Video 2"},
+			},
+		},
+		{
+			name: "success case - unavailable videos skipped",
+			args: args{options: availabilityOptions{SkipUnavailable: true, DetectMembersOnly: true}},
+			want: []YTChannel{
+				{
+					Title:                      "channel 1",
+					ChannelID:                  "UCtest-1",
+					LatestVideoID:              "watchable",
+					LatestVideoURL:             "https://www.youtube.com/watch?v=watchable",
+					LatestVideoTitle:           "Tutorial",
+					LatestVideoDuration:        "10:00",
+					LatestVideoDurationSeconds: 600,
+					LatestVideoType:            RegularVideoType,
+					LatestVideoPrivacyStatus:   "public",
+					SkippedVideos: []SkippedVideo{
+						{VideoID: "deleted", Title: "Deleted video", Reason: DeletedVideo},
+						{VideoID: "members", Title: "Live Q&A", Reason: MembersOnlyVideo},
+						{VideoID: "private", Title: "Private video", Reason: PrivateVideo},
+					},
+				},
+				{Title: "channel 2", ChannelID: "UCtest-2", LatestVideoID: "video2", LatestVideoTitle: "Video 2"},
+			},
+		},
+		{
+			name: "success case - members-only videos not detected",
+			args: args{options: availabilityOptions{SkipUnavailable: true}},
+			want: []YTChannel{
+				{
+					Title:                    "channel 1",
+					ChannelID:                "UCtest-1",
+					LatestVideoID:            "members",
+					LatestVideoURL:           "https://www.youtube.com/watch?v=members",
+					LatestVideoTitle:         "Live Q&A",
+					LatestVideoType:          RegularVideoType,
+					LatestVideoPrivacyStatus: "public",
+					SkippedVideos:            []SkippedVideo{{VideoID: "deleted", Title: "Deleted video", Reason: DeletedVideo}},
+				},
+				{Title: "channel 2", ChannelID: "UCtest-2", LatestVideoID: "video2", LatestVideoTitle: "Video 2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := latestVideos()
+			resolveUnavailableVideos(context.Background(), svc, got, latestVideosDetails, tt.args.options, "")
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("resolveUnavailableVideos() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_skippedVideosSummary(t *testing.T) {
+	got := skippedVideosSummary([]SkippedVideo{{Title: "Live Q&A", Reason: MembersOnlyVideo},
+		{Title: "Trailer", Reason: RegionBlockedVideo}})
+	if want := "skipped: Live Q&A (members only), Trailer (blocked in your region)"; got != want {
+		t.Errorf("skippedVideosSummary() got = %v, want %v", got, want)
+	}
+	if got = skippedVideosSummary(nil); got != "" {
+		t.Errorf("skippedVideosSummary() got = %v, want empty", got)
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// length buckets the new videos of the backlog are counted in, in seconds, 0 for no upper bound
+var backlogBuckets = []struct {
+	label       string
+	minDuration int64
+	maxDuration int64
+}{
+	{label: "under 5 min", minDuration: 0, maxDuration: 5 * 60},
+	{label: "5-15 min", minDuration: 5 * 60, maxDuration: 15 * 60},
+	{label: "15-30 min", minDuration: 15 * 60, maxDuration: 30 * 60},
+	{label: "30-60 min", minDuration: 30 * 60, maxDuration: 60 * 60},
+	{label: "over 1 h", minDuration: 60 * 60},
+}
+
+// videos up to this duration, in seconds, are quick to watch, linked from the backlog summary
+const quickWatchDuration = 15 * 60
+
+// backlogSummary sums up the time needed to watch the new videos of the feed. Only the latest video of each channel
+// is retrieved, so its new videos are estimated to be like it: same type and same duration. The fields relying on
+// this are named as estimates, the counts of channels and new videos are exact
+type backlogSummary struct {
+	// Channels counts the channels having new videos, Videos their new videos
+	Channels          int    `json:"channels"`
+	Videos            int    `json:"videos"`
+	EstimatedSeconds  int64  `json:"estimated_seconds"`
+	EstimatedDuration string `json:"estimated_duration"`
+	// EstimatedByType counts the videos by the type of the latest video of their channel, the unknown ones left out
+	EstimatedByType  map[string]int  `json:"estimated_by_type"`
+	EstimatedBuckets []backlogBucket `json:"estimated_buckets"`
+	// EstimatedUnknownDuration counts the videos of the channels whose latest video has no duration, like the
+	// upcoming ones
+	EstimatedUnknownDuration int `json:"estimated_unknown_duration"`
+	DailyWatchMinutes        int `json:"daily_watch_minutes"`
+	// EstimatedDaysToCatchUp is the number of days needed to watch all the videos at the daily watch time
+	EstimatedDaysToCatchUp int `json:"estimated_days_to_catch_up"`
+}
+
+// backlogBucket counts the videos of the backlog whose channel's latest video duration is in a range, MaxDuration
+// excluded and 0 for no upper bound
+type backlogBucket struct {
+	Label            string `json:"label"`
+	MinDuration      int64  `json:"min_duration_seconds"`
+	MaxDuration      int64  `json:"max_duration_seconds"`
+	EstimatedVideos  int    `json:"estimated_videos"`
+	EstimatedSeconds int64  `json:"estimated_seconds"`
+}
+
+// return the backlog of the channels having new videos, each of their new videos counted as lasting as long as
+// their latest one
+func summarizeBacklog(ytChannels []YTChannel, dailyWatchMinutes int) backlogSummary {
+	summary := backlogSummary{
+		EstimatedByType:   map[string]int{},
+		EstimatedBuckets:  make([]backlogBucket, 0, len(backlogBuckets)),
+		DailyWatchMinutes: dailyWatchMinutes,
+	}
+	for _, bucket := range backlogBuckets {
+		summary.EstimatedBuckets = append(summary.EstimatedBuckets, backlogBucket{Label: bucket.label, MinDuration: bucket.minDuration,
+			MaxDuration: bucket.maxDuration})
+	}
+
+	for _, ytChannel := range ytChannels {
+		videos := int(ytChannel.NewItemCount)
+		if videos <= 0 {
+			continue
+		}
+		summary.Channels++
+		summary.Videos += videos
+		if ytChannel.LatestVideoType != "" {
+			summary.EstimatedByType[ytChannel.LatestVideoType] += videos
+		}
+		seconds := ytChannel.LatestVideoDurationSeconds
+		if seconds <= 0 {
+			summary.EstimatedUnknownDuration += videos
+			continue
+		}
+		summary.EstimatedSeconds += seconds * int64(videos)
+		for i, bucket := range summary.EstimatedBuckets {
+			if inDurationRange(seconds, bucket.MinDuration, bucket.MaxDuration) {
+				summary.EstimatedBuckets[i].EstimatedVideos += videos
+				summary.EstimatedBuckets[i].EstimatedSeconds += seconds * int64(videos)
+				break
+			}
+		}
+	}
+
+	summary.EstimatedDuration = formatWatchTime(summary.EstimatedSeconds)
+	if dailySeconds := int64(dailyWatchMinutes) * 60; dailySeconds > 0 {
+		summary.EstimatedDaysToCatchUp = int((summary.EstimatedSeconds + dailySeconds - 1) / dailySeconds)
+	}
+	return summary
+}
+
+// TypeSummary returns the videos by type and the ones of unknown duration, e.g. "3 video · 1 short"
+func (s backlogSummary) TypeSummary() string {
+	var counts []string
+	for _, videoType := range VideoTypes {
+		if count := s.EstimatedByType[videoType]; count > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count, videoType))
+		}
+	}
+	if s.EstimatedUnknownDuration > 0 {
+		counts = append(counts, fmt.Sprintf("%d of unknown length", s.EstimatedUnknownDuration))
+	}
+	return strings.Join(counts, " · ")
+}
+
+// format a watch time in seconds as hours and minutes, e.g. 3h 05m or 45m
+func formatWatchTime(seconds int64) string {
+	minutes := (seconds + 59) / 60
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+// BacklogAPI returns as JSON the backlog of the new videos of the feed, the same as the backlog panel of the main
+// page: the channels are narrowed down by the q and group query parameters, leaving out the video types hidden in
+// the preferences, and the new videos of every duration are summed up
+func BacklogAPI(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "BacklogAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		preferences := userPreferences(r, storage, tokenInfo, funcName)
+		groups := userChannelGroups(r, storage, tokenInfo, funcName)
+		options := resolveFeedOptions(r.URL.Query(), preferences, groups)
+		ytChannels, err := userFeedChannels(r, oauth2C, ytcf, storage, tokenInfo, contextAccounts(r, tokenInfo),
+			preferences, groups, options.Filtered, funcName)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, buildFeedPage(ytChannels, preferences, options).Backlog, funcName)
+	}
+}
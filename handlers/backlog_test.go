@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_summarizeBacklog(t *testing.T) {
+	ytChannels := []YTChannel{
+		{Title: "a", LatestVideoType: ShortVideoType, LatestVideoDurationSeconds: 45, NewItemCount: 3},
+		{Title: "b", LatestVideoType: RegularVideoType, LatestVideoDurationSeconds: 600, NewItemCount: 1},
+		{Title: "c", LatestVideoType: RegularVideoType, LatestVideoDurationSeconds: 1200, NewItemCount: 1},
+		{Title: "d", LatestVideoType: LiveVideoType, LatestVideoDurationSeconds: 7200, NewItemCount: 1},
+		{Title: "e", LatestVideoType: UpcomingVideoType, NewItemCount: 1},
+		{Title: "watched", LatestVideoType: RegularVideoType, LatestVideoDurationSeconds: 300},
+	}
+	want := backlogSummary{
+		Channels:          5,
+		Videos:            7,
+		EstimatedSeconds:  9135,
+		EstimatedDuration: "2h 33m",
+		EstimatedByType: map[string]int{ShortVideoType: 3, RegularVideoType: 2, LiveVideoType: 1,
+			UpcomingVideoType: 1},
+		EstimatedBuckets: []backlogBucket{
+			{Label: "under 5 min", MaxDuration: 300, EstimatedVideos: 3, EstimatedSeconds: 135},
+			{Label: "5-15 min", MinDuration: 300, MaxDuration: 900, EstimatedVideos: 1, EstimatedSeconds: 600},
+			{Label: "15-30 min", MinDuration: 900, MaxDuration: 1800, EstimatedVideos: 1, EstimatedSeconds: 1200},
+			{Label: "30-60 min", MinDuration: 1800, MaxDuration: 3600},
+			{Label: "over 1 h", MinDuration: 3600, EstimatedVideos: 1, EstimatedSeconds: 7200},
+		},
+		EstimatedUnknownDuration: 1,
+		DailyWatchMinutes:        60,
+		EstimatedDaysToCatchUp:   3,
+	}
+	got := summarizeBacklog(ytChannels, 60)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("summarizeBacklog() mismatch (-want +got):\n%s", diff)
+	}
+	if want := "2 video · 3 short · 1 live · 1 upcoming · 1 of unknown length"; got.TypeSummary() != want {
+		t.Errorf("TypeSummary() got = %v, want %v", got.TypeSummary(), want)
+	}
+}
+
+func Test_formatWatchTime(t *testing.T) {
+	tests := []struct {
+		seconds int64
+		want    string
+	}{
+		{seconds: 0, want: "0m"},
+		{seconds: 61, want: "2m"},
+		{seconds: 3600, want: "1h 00m"},
+		{seconds: 100000, want: "27h 47m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatWatchTime(tt.seconds); got != tt.want {
+				t.Errorf("formatWatchTime() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacklogAPI(t *testing.T) {
+	// mocks
+	const tokenNotFound = "error case - token not found in context"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return &youtubeClientMock{
+				getAndProcessSubscriptionsStub: func(ctx context.Context,
+					f func(*youtube.SubscriptionListResponse) error) error {
+					subscription := func(channelID string, newItems int64) *youtube.Subscription {
+						return &youtube.Subscription{
+							Snippet: &youtube.SubscriptionSnippet{Title: channelID,
+								ResourceId: &youtube.ResourceId{ChannelId: channelID}},
+							ContentDetails: &youtube.SubscriptionContentDetails{NewItemCount: newItems},
+						}
+					}
+					return f(&youtube.SubscriptionListResponse{Items: []*youtube.Subscription{
+						subscription("channel1", 3), subscription("channel2", 2)}})
+				},
+				getLatestVideoFromPlaylistStub: func(playlistID string) (*youtube.PlaylistItem, error) {
+					videoID := map[string]string{"cUannel1": "short1", "cUannel2": "video2"}[playlistID]
+					return &youtube.PlaylistItem{Snippet: &youtube.PlaylistItemSnippet{Title: videoID,
+						ResourceId: &youtube.ResourceId{VideoId: videoID}}}, nil
+				},
+				getVideosStub: func(ctx context.Context, videoIDs []string,
+					processFunction func(*youtube.VideoListResponse) error) error {
+					return processFunction(&youtube.VideoListResponse{Items: []*youtube.Video{
+						{Id: "short1", ContentDetails: &youtube.VideoContentDetails{Duration: "PT30S"}},
+						{Id: "video2", ContentDetails: &youtube.VideoContentDetails{Duration: "PT20M"}},
+					}})
+				},
+			}, nil
+		},
+	}
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			preferences := database.DefaultPreferences()
+			preferences.DailyWatchMinutes = 45
+			preferences.HiddenVideoTypes = []string{ShortVideoType}
+			return preferences, nil
+		},
+		GetChannelGroupsStub: func(_ context.Context, userId int64) ([]database.ChannelGroup, error) {
+			return []database.ChannelGroup{}, nil
+		},
+		GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+			return []database.ChannelRule{}, nil
+		},
+		GetFollowedChannelsStub: func(_ context.Context, userId int64) ([]database.FollowedChannel, error) {
+			return []database.FollowedChannel{}, nil
+		},
+		GetVideoRulesStub: func(_ context.Context, userId int64) ([]database.VideoRule, error) {
+			return []database.VideoRule{}, nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{
+			name:   "success case",
+			method: http.MethodGet,
+			target: "/api/v1/feed/backlog",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "success case - every duration summed up like the backlog panel",
+			method: http.MethodGet,
+			target: "/api/v1/feed/backlog?max_duration=15m",
+			want:   http.StatusOK,
+		},
+		{
+			name:   tokenNotFound,
+			method: http.MethodGet,
+			target: "/api/v1/feed/backlog",
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodPost,
+			target: "/api/v1/feed/backlog",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{Token: &oauth2.Token{}}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := BacklogAPI(oauth2C, ytcf, storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("BacklogAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				var response backlogSummary
+				if err = json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+					t.Errorf("BacklogAPI() invalid JSON response: %v", err)
+				}
+				// the shorts are hidden in the preferences
+				if response.Channels != 1 || response.Videos != 2 || response.EstimatedSeconds != 2400 {
+					t.Errorf("BacklogAPI() got %d videos of %d channels lasting %ds, want 2 videos of 1 channel "+
+						"lasting 2400s", response.Videos, response.Channels, response.EstimatedSeconds)
+				}
+				if response.DailyWatchMinutes != 45 || response.EstimatedDaysToCatchUp != 1 {
+					t.Errorf("BacklogAPI() daily watch minutes = %v, days to catch up = %v, want 45 and 1",
+						response.DailyWatchMinutes, response.EstimatedDaysToCatchUp)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"checkYoutube/database"
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feedOptions are how the feed page is shown: the user preferences, overridden by the query parameters
+type feedOptions struct {
+	Filtered      bool
+	SortColumn    string
+	SortDirection string
+	// Query is the keyword the channel or latest video title must contain, empty to show every channel
+	Query string
+	// Group is the id of the channel group to show, 0 to show every channel
+	Group int64
+	// MinDuration and MaxDuration bound the duration of the latest videos in seconds, MaxDuration excluded, 0 for
+	// no bound
+	MinDuration int64
+	MaxDuration int64
+	Page        int
+}
+
+// feedPage is a page of the channels of the feed
+type feedPage struct {
+	YTChannels []YTChannel
+	// ChannelIDs are the ids of the channels in all the pages
+	ChannelIDs []string
+	// TotalChannels is the number of channels in all the pages
+	TotalChannels int
+	Page          int
+	PageCount     int
+	// Backlog sums up the new videos of the channels shown, whatever their duration
+	Backlog backlogSummary
+}
+
+// feedRow is a channel shown in the feed page, along with the publish date of its latest video formatted in the
+// timezone of the user
+type feedRow struct {
+	YTChannel
+	PublishedAt string
+	Thumbnail   *Thumbnail
+	// Stats sums up the statistics, category and availability of the latest video
+	Stats string
+	// Unavailable is why the latest video can't be watched, Skipped lists the newer unavailable videos passed over
+	Unavailable string
+	Skipped     string
+}
+
+// return the feed options of the preferences, overridden by the filtered, sort, dir, q, group, min_duration,
+// max_duration and page query parameters holding valid values
+func resolveFeedOptions(query url.Values, preferences database.Preferences,
+	groups []database.ChannelGroup) feedOptions {
+	options := feedOptions{
+		Filtered:      preferences.DefaultView == database.FilteredView,
+		SortColumn:    preferences.SortColumn,
+		SortDirection: preferences.SortDirection,
+		Query:         strings.TrimSpace(query.Get("q")),
+		Page:          1,
+	}
+	if filtered, err := strconv.ParseBool(query.Get("filtered")); err == nil {
+		options.Filtered = filtered
+	}
+	if sortColumn := query.Get("sort"); slices.Contains(sortColumns, sortColumn) {
+		options.SortColumn = sortColumn
+	}
+	if sortDirection := query.Get("dir"); slices.Contains(sortDirections, sortDirection) {
+		options.SortDirection = sortDirection
+	}
+	if group, err := strconv.ParseInt(query.Get("group"), 10, 64); err == nil &&
+		slices.ContainsFunc(groups, func(g database.ChannelGroup) bool { return g.Id == group }) {
+		options.Group = group
+	}
+	if minDuration, maxDuration, err := parseDurationRange(query); err == nil {
+		options.MinDuration, options.MaxDuration = minDuration, maxDuration
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 0 {
+		options.Page = page
+	}
+	return options
+}
+
+// return the minimum and maximum duration in seconds set by the min_duration and max_duration query parameters,
+// given as seconds or like 15m, 0 when not set
+func parseDurationRange(query url.Values) (int64, int64, error) {
+	var bounds [2]int64
+	for i, name := range []string{"min_duration", "max_duration"} {
+		if value := query.Get(name); value != "" {
+			duration, err := parseRuleDuration(value)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			bounds[i] = int64(duration.Seconds())
+		}
+	}
+	if bounds[1] != 0 && bounds[1] <= bounds[0] {
+		return 0, 0, fmt.Errorf("max_duration must be greater than min_duration")
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// tell whether a duration in seconds is in the range, the videos of unknown duration being left out as soon as a
+// bound is set
+func inDurationRange(seconds, minDuration, maxDuration int64) bool {
+	if minDuration == 0 && maxDuration == 0 {
+		return true
+	}
+	return seconds > 0 && seconds >= minDuration && (maxDuration == 0 || seconds < maxDuration)
+}
+
+// shows tells whether the channel is shown in the feed whatever the duration of its latest video: its type isn't
+// hidden, it matches the keyword and it's in the group
+func (o feedOptions) shows(ytChannel YTChannel, preferences database.Preferences) bool {
+	if slices.Contains(preferences.HiddenVideoTypes, ytChannel.LatestVideoType) {
+		return false
+	}
+	if query := strings.ToLower(o.Query); query != "" && !strings.Contains(strings.ToLower(ytChannel.Title), query) &&
+		!strings.Contains(strings.ToLower(ytChannel.LatestVideoTitle), query) {
+		return false
+	}
+	return o.Group == 0 || inChannelGroup(ytChannel, o.Group)
+}
+
+// return the page of the feed to show: channels whose latest video type is hidden, not matching the keyword, not
+// in the group or out of the duration range are left out, the others are sorted and paged. The backlog sums up the
+// channels left regardless of the duration range, so that every range can be picked from it
+func buildFeedPage(ytChannels []YTChannel, preferences database.Preferences, options feedOptions) feedPage {
+	backlog := make([]YTChannel, 0, len(ytChannels))
+	visible := make([]YTChannel, 0, len(ytChannels))
+	for _, ytChannel := range ytChannels {
+		if !options.shows(ytChannel, preferences) {
+			continue
+		}
+		backlog = append(backlog, ytChannel)
+		if !inDurationRange(ytChannel.LatestVideoDurationSeconds, options.MinDuration, options.MaxDuration) {
+			continue
+		}
+		visible = append(visible, ytChannel)
+	}
+
+	slices.SortStableFunc(visible, func(a, b YTChannel) int {
+		result := compareYTChannels(a, b, options.SortColumn)
+		if result == 0 {
+			result = cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		}
+		if options.SortDirection == database.SortDescending {
+			return -result
+		}
+		return result
+	})
+
+	page := feedPage{YTChannels: visible, ChannelIDs: make([]string, 0, len(visible)), TotalChannels: len(visible),
+		Page: 1, PageCount: 1, Backlog: summarizeBacklog(backlog, preferences.DailyWatchMinutes)}
+	for _, ytChannel := range visible {
+		page.ChannelIDs = append(page.ChannelIDs, ytChannel.ChannelID)
+	}
+	if preferences.PageSize > 0 && len(visible) > preferences.PageSize {
+		page.PageCount = (len(visible) + preferences.PageSize - 1) / preferences.PageSize
+		page.Page = min(options.Page, page.PageCount)
+		start := (page.Page - 1) * preferences.PageSize
+		page.YTChannels = visible[start:min(start+preferences.PageSize, len(visible))]
+	}
+	return page
+}
+
+// compare two channels by the given sort column, 0 when sorted by channel name
+func compareYTChannels(a, b YTChannel, sortColumn string) int {
+	switch sortColumn {
+	case database.SortByPublishDate:
+		// RFC 3339 dates in UTC sort as strings
+		return cmp.Compare(a.LatestVideoPublishedAt, b.LatestVideoPublishedAt)
+	case database.SortByDuration:
+		return cmp.Compare(a.LatestVideoDurationSeconds, b.LatestVideoDurationSeconds)
+	case database.SortByNewItems:
+		return cmp.Compare(a.NewItemCount, b.NewItemCount)
+	}
+	return 0
+}
+
+// return the rows of the feed page, publish dates are formatted in the given timezone, UTC if empty or unknown, and
+// thumbnails picked in the given size
+func feedRows(ytChannels []YTChannel, timezone, thumbnailSize string) []feedRow {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	rows := make([]feedRow, 0, len(ytChannels))
+	for _, ytChannel := range ytChannels {
+		row := feedRow{YTChannel: ytChannel}
+		if publishedAt, err := time.Parse(time.RFC3339, ytChannel.LatestVideoPublishedAt); err == nil {
+			row.PublishedAt = publishedAt.In(location).Format("2006-01-02 15:04 MST")
+		}
+		if thumbnail, ok := selectThumbnail(ytChannel.LatestVideoThumbnails, thumbnailSize); ok {
+			row.Thumbnail = &thumbnail
+		}
+		row.Stats = videoStats(ytChannel)
+		row.Unavailable = unavailableLabels[ytChannel.LatestVideoUnavailable]
+		row.Skipped = skippedVideosSummary(ytChannel.SkippedVideos)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// return the statistics, category, captions and privacy status of the latest video of a channel, e.g.
+// "1.2K views · 30 likes · Music · captions", empty when the video details are unknown
+func videoStats(ytChannel YTChannel) string {
+	if ytChannel.LatestVideoType == "" {
+		return ""
+	}
+	stats := []string{formatCount(ytChannel.LatestVideoViewCount) + " views"}
+	if ytChannel.LatestVideoLikeCount > 0 {
+		stats = append(stats, formatCount(ytChannel.LatestVideoLikeCount)+" likes")
+	}
+	if ytChannel.LatestVideoCommentCount > 0 {
+		stats = append(stats, formatCount(ytChannel.LatestVideoCommentCount)+" comments")
+	}
+	if ytChannel.LatestVideoCategory != "" {
+		stats = append(stats, ytChannel.LatestVideoCategory)
+	}
+	if ytChannel.LatestVideoCaptions {
+		stats = append(stats, "captions")
+	}
+	if ytChannel.LatestVideoPrivacyStatus != "" && ytChannel.LatestVideoPrivacyStatus != "public" {
+		stats = append(stats, ytChannel.LatestVideoPrivacyStatus)
+	}
+	return strings.Join(stats, " · ")
+}
+
+// URL returns the URL of the feed page shown with the options
+func (o feedOptions) URL() string {
+	query := url.Values{}
+	query.Set("filtered", strconv.FormatBool(o.Filtered))
+	query.Set("sort", o.SortColumn)
+	query.Set("dir", o.SortDirection)
+	if o.Query != "" {
+		query.Set("q", o.Query)
+	}
+	if o.Group != 0 {
+		query.Set("group", strconv.FormatInt(o.Group, 10))
+	}
+	o.setDurationRange(query)
+	if o.Page > 1 {
+		query.Set("page", strconv.Itoa(o.Page))
+	}
+	return "/check-youtube?" + query.Encode()
+}
+
+// set the duration range of the options in the query, when bounded
+func (o feedOptions) setDurationRange(query url.Values) {
+	if o.MinDuration != 0 {
+		query.Set("min_duration", strconv.FormatInt(o.MinDuration, 10))
+	}
+	if o.MaxDuration != 0 {
+		query.Set("max_duration", strconv.FormatInt(o.MaxDuration, 10))
+	}
+}
+
+// DurationURL returns the URL of the first page showing the latest videos in the given duration range, in seconds
+func (o feedOptions) DurationURL(minDuration, maxDuration int64) string {
+	o.MinDuration, o.MaxDuration = minDuration, maxDuration
+	o.Page = 1
+	return o.URL()
+}
+
+// ExportURL returns the URL downloading the channels of the view, keyword, channel group and duration range in the
+// given format
+func (o feedOptions) ExportURL(format string) string {
+	query := url.Values{}
+	query.Set("format", format)
+	query.Set("filtered", strconv.FormatBool(o.Filtered))
+	if o.Query != "" {
+		query.Set("q", o.Query)
+	}
+	if o.Group != 0 {
+		query.Set("group", strconv.FormatInt(o.Group, 10))
+	}
+	o.setDurationRange(query)
+	return "/export-feed?" + query.Encode()
+}
+
+// ViewURL returns the URL of the first page of the given view
+func (o feedOptions) ViewURL(filtered bool) string {
+	o.Filtered = filtered
+	o.Page = 1
+	return o.URL()
+}
+
+// PageURL returns the URL of the given page
+func (o feedOptions) PageURL(page int) string {
+	o.Page = page
+	return o.URL()
+}
+
+// SortURL returns the URL of the first page sorted by the given column: the direction is toggled when already
+// sorted by it, otherwise channel names are sorted ascending and the other columns descending
+func (o feedOptions) SortURL(column string) string {
+	switch {
+	case o.SortColumn == column && o.SortDirection == database.SortAscending:
+		o.SortDirection = database.SortDescending
+	case o.SortColumn == column:
+		o.SortDirection = database.SortAscending
+	case column == database.SortByChannel:
+		o.SortDirection = database.SortAscending
+	default:
+		o.SortDirection = database.SortDescending
+	}
+	o.SortColumn = column
+	o.Page = 1
+	return o.URL()
+}
+
+// SortIndicator returns the arrow telling whether the page is sorted by the given column, and in which direction
+func (o feedOptions) SortIndicator(column string) string {
+	switch {
+	case o.SortColumn != column:
+		return "⇅"
+	case o.SortDirection == database.SortDescending:
+		return "↓"
+	default:
+		return "↑"
+	}
+}
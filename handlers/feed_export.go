@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formats of the feed downloads
+const (
+	csvFeedExport    = "csv"
+	ndjsonFeedExport = "ndjson"
+)
+
+// feedExportColumn is a field of the channels in the feed downloads, named after its JSON key in the API
+type feedExportColumn struct {
+	name  string
+	value func(YTChannel) any
+}
+
+// columns of the feed downloads, in the order they're written
+var feedExportColumns = []feedExportColumn{
+	{name: "title", value: func(c YTChannel) any { return c.Title }},
+	{name: "channel_id", value: func(c YTChannel) any { return c.ChannelID }},
+	{name: "url", value: func(c YTChannel) any { return c.URL }},
+	{name: "latest_video_id", value: func(c YTChannel) any { return c.LatestVideoID }},
+	{name: "latest_video_url", value: func(c YTChannel) any { return c.LatestVideoURL }},
+	{name: "latest_video_title", value: func(c YTChannel) any { return c.LatestVideoTitle }},
+	{name: "latest_video_published_at", value: func(c YTChannel) any { return c.LatestVideoPublishedAt }},
+	{name: "latest_video_duration", value: func(c YTChannel) any { return c.LatestVideoDuration }},
+	{name: "latest_video_duration_seconds", value: func(c YTChannel) any { return c.LatestVideoDurationSeconds }},
+	{name: "latest_video_type", value: func(c YTChannel) any { return c.LatestVideoType }},
+	{name: "latest_video_thumbnail_url", value: func(c YTChannel) any {
+		thumbnail, _ := selectThumbnail(c.LatestVideoThumbnails, database.MaxResThumbnail)
+		return thumbnail.URL
+	}},
+	{name: "latest_video_view_count", value: func(c YTChannel) any { return c.LatestVideoViewCount }},
+	{name: "latest_video_like_count", value: func(c YTChannel) any { return c.LatestVideoLikeCount }},
+	{name: "latest_video_comment_count", value: func(c YTChannel) any { return c.LatestVideoCommentCount }},
+	{name: "latest_video_tags", value: func(c YTChannel) any { return nonNil(c.LatestVideoTags) }},
+	{name: "latest_video_category_id", value: func(c YTChannel) any { return c.LatestVideoCategoryID }},
+	{name: "latest_video_category", value: func(c YTChannel) any { return c.LatestVideoCategory }},
+	{name: "latest_video_default_language", value: func(c YTChannel) any { return c.LatestVideoLanguage }},
+	{name: "latest_video_captions", value: func(c YTChannel) any { return c.LatestVideoCaptions }},
+	{name: "latest_video_privacy_status", value: func(c YTChannel) any { return c.LatestVideoPrivacyStatus }},
+	{name: "latest_video_unavailable", value: func(c YTChannel) any { return c.LatestVideoUnavailable }},
+	{name: "skipped_videos", value: func(c YTChannel) any { return nonNil(c.SkippedVideos) }},
+	{name: "new_item_count", value: func(c YTChannel) any { return c.NewItemCount }},
+	{name: "source_accounts", value: func(c YTChannel) any { return nonNil(c.SourceAccounts) }},
+	{name: "groups", value: func(c YTChannel) any { return nonNil(c.Groups) }},
+}
+
+// feedExportParams are the options of a feed download
+type feedExportParams struct {
+	Format  string
+	Columns []feedExportColumn
+	// From and To bound the publish date of the latest videos, as YYYY-MM-DD dates, both included
+	From string
+	To   string
+}
+
+// feedExportWriter writes the rows of a feed download as soon as the channels are checked
+type feedExportWriter struct {
+	w         io.Writer
+	flush     func()
+	params    feedExportParams
+	csvWriter *csv.Writer
+}
+
+// return a nil slice as an empty one, so that it's written as [] in JSON
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
+
+// ExportFeed downloads the channels of the feed given by the filtered, q, group, min_duration and max_duration query
+// parameters, leaving out the video types hidden by the preferences like the main page, as CSV or newline delimited
+// JSON according to the format query parameter. The columns query parameter selects the fields
+// written, all of them when empty, and from and to keep the channels whose latest video was published in the range
+func ExportFeed(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "ExportFeed"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		// the options are checked before retrieving the feed
+		params, err := parseFeedExportParams(r.URL.Query())
+		if err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		options := feedOptions{Query: r.URL.Query().Get("q")}
+		preferences := userPreferences(r, storage, tokenInfo, funcName)
+
+		// the rows are sent as the channels are checked, the headers being set on the first one so that the errors
+		// met before can still be answered with their status
+		var writer *feedExportWriter
+		start := func() {
+			contentType := "text/csv; charset=utf-8"
+			if params.Format == ndjsonFeedExport {
+				contentType = "application/x-ndjson"
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="feed.%s"`, params.Format))
+			flush := func() {}
+			if flusher, ok := w.(http.Flusher); ok {
+				flush = flusher.Flush
+			}
+			writer, err = newFeedExportWriter(w, flush, params)
+		}
+		emit := func(ytChannels []YTChannel) {
+			for _, ytChannel := range ytChannels {
+				if !options.shows(ytChannel, preferences) {
+					continue
+				}
+				if writer == nil {
+					start()
+				}
+				if err == nil {
+					err = writer.write(ytChannel)
+				}
+			}
+		}
+		if _, ok := apiFeedChannels(w, r, oauth2C, ytcf, storage, tokenInfo, emit, funcName); !ok {
+			return
+		}
+		if writer == nil {
+			start()
+		}
+		if err != nil {
+			// the status is already sent
+			slog.Error(fmt.Sprintf("failed to write feed export: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		}
+	}
+}
+
+// return the options of a feed download set by the format, columns, from and to query parameters
+func parseFeedExportParams(values url.Values) (feedExportParams, error) {
+	params := feedExportParams{
+		Format:  csvFeedExport,
+		Columns: feedExportColumns,
+		From:    values.Get("from"),
+		To:      values.Get("to"),
+	}
+	switch format := values.Get("format"); format {
+	case "":
+	case csvFeedExport, ndjsonFeedExport:
+		params.Format = format
+	default:
+		return params, fmt.Errorf("invalid format: %s, expected %s or %s", format, csvFeedExport, ndjsonFeedExport)
+	}
+	if columns := strings.TrimSpace(values.Get("columns")); columns != "" {
+		params.Columns = nil
+	columnsLoop:
+		for _, name := range strings.Split(columns, ",") {
+			name = strings.TrimSpace(name)
+			for _, column := range feedExportColumns {
+				if column.name == name {
+					params.Columns = append(params.Columns, column)
+					continue columnsLoop
+				}
+			}
+			return params, fmt.Errorf("invalid column: %s", name)
+		}
+	}
+	for _, date := range []string{params.From, params.To} {
+		if _, err := time.Parse(time.DateOnly, date); date != "" && err != nil {
+			return params, fmt.Errorf("invalid date: %s, expected YYYY-MM-DD", date)
+		}
+	}
+	return params, nil
+}
+
+// includes tells whether the latest video of the channel was published in the date range of the download, the
+// channels without a publish date being left out as soon as a bound is set
+func (p feedExportParams) includes(ytChannel YTChannel) bool {
+	if p.From == "" && p.To == "" {
+		return true
+	}
+	publishedAt, err := time.Parse(time.RFC3339, ytChannel.LatestVideoPublishedAt)
+	if err != nil {
+		return false
+	}
+	day := publishedAt.UTC().Format(time.DateOnly)
+	return (p.From == "" || day >= p.From) && (p.To == "" || day <= p.To)
+}
+
+// return a writer of a feed download, the CSV header being written and flushed right away
+func newFeedExportWriter(w io.Writer, flush func(), params feedExportParams) (*feedExportWriter, error) {
+	writer := &feedExportWriter{w: w, flush: flush, params: params}
+	if params.Format == ndjsonFeedExport {
+		return writer, nil
+	}
+
+	writer.csvWriter = csv.NewWriter(w)
+	header := make([]string, 0, len(params.Columns))
+	for _, column := range params.Columns {
+		header = append(header, column.name)
+	}
+	return writer, writer.writeRecord(header)
+}
+
+// write the row of the channel and flush it, the channels out of the date range of the download being left out
+func (fw *feedExportWriter) write(ytChannel YTChannel) error {
+	if !fw.params.includes(ytChannel) {
+		return nil
+	}
+	if fw.csvWriter == nil {
+		row, err := ndjsonFeedRow(fw.params.Columns, ytChannel)
+		if err != nil {
+			return err
+		}
+		if _, err = fw.w.Write(row); err != nil {
+			return err
+		}
+		fw.flush()
+		return nil
+	}
+
+	record := make([]string, 0, len(fw.params.Columns))
+	for _, column := range fw.params.Columns {
+		record = append(record, csvFeedValue(column.value(ytChannel)))
+	}
+	return fw.writeRecord(record)
+}
+
+// write a CSV record and flush it
+func (fw *feedExportWriter) writeRecord(record []string) error {
+	if err := fw.csvWriter.Write(record); err != nil {
+		return err
+	}
+	fw.csvWriter.Flush()
+	if err := fw.csvWriter.Error(); err != nil {
+		return err
+	}
+	fw.flush()
+	return nil
+}
+
+// return the selected columns of the channel as a JSON object on a single line, the keys being in column order
+func ndjsonFeedRow(columns []feedExportColumn, ytChannel YTChannel) ([]byte, error) {
+	row := []byte{'{'}
+	for i, column := range columns {
+		if i > 0 {
+			row = append(row, ',')
+		}
+		row = strconv.AppendQuote(row, column.name)
+		value, err := json.Marshal(column.value(ytChannel))
+		if err != nil {
+			return nil, err
+		}
+		row = append(append(row, ':'), value...)
+	}
+	return append(row, '}', '\n'), nil
+}
+
+// return a field of a channel as a CSV cell, lists being joined with | and referenced by id or name
+func csvFeedValue(value any) string {
+	var items []string
+	switch value := value.(type) {
+	case string:
+		return value
+	case []string:
+		items = value
+	case []SkippedVideo:
+		for _, video := range value {
+			items = append(items, video.VideoID)
+		}
+	case []SourceAccount:
+		for _, account := range value {
+			items = append(items, account.Name)
+		}
+	case []GroupTag:
+		for _, group := range value {
+			items = append(items, group.Name)
+		}
+	default:
+		return fmt.Sprint(value)
+	}
+	return strings.Join(items, "|")
+}
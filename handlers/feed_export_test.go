@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"bytes"
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestExportFeed(t *testing.T) {
+	// mocks
+	const tokenNotFound = "error case - token not found in context"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	newClientCalls := 0
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			newClientCalls++
+			return &youtubeClientMock{
+				getAndProcessSubscriptionsStub: func(ctx context.Context,
+					f func(*youtube.SubscriptionListResponse) error) error {
+					return nil
+				},
+			}, nil
+		},
+	}
+
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+		GetChannelGroupsStub: func(_ context.Context, userId int64) ([]database.ChannelGroup, error) {
+			return []database.ChannelGroup{}, nil
+		},
+		GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+			return []database.ChannelRule{}, nil
+		},
+		GetFollowedChannelsStub: func(_ context.Context, userId int64) ([]database.FollowedChannel, error) {
+			return []database.FollowedChannel{}, nil
+		},
+		GetVideoRulesStub: func(_ context.Context, userId int64) ([]database.VideoRule, error) {
+			return []database.VideoRule{}, nil
+		},
+	}
+
+	tests := []struct {
+		name            string
+		method          string
+		target          string
+		want            int
+		wantContentType string
+	}{
+		{
+			name:            "success case - csv",
+			method:          http.MethodGet,
+			target:          "/api/v1/feed/export?filtered=true&columns=title,url&from=2024-01-01",
+			want:            http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+		},
+		{
+			name:            "success case - ndjson",
+			method:          http.MethodGet,
+			target:          "/api/v1/feed/export?format=ndjson",
+			want:            http.StatusOK,
+			wantContentType: "application/x-ndjson",
+		},
+		{
+			name:   "error case - invalid format",
+			method: http.MethodGet,
+			target: "/api/v1/feed/export?format=xml",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - invalid group",
+			method: http.MethodGet,
+			target: "/api/v1/feed/export?group=music",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   tokenNotFound,
+			method: http.MethodGet,
+			target: "/api/v1/feed/export",
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodPost,
+			target: "/api/v1/feed/export",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{Token: &oauth2.Token{}}))
+			}
+			newClientCalls = 0
+			recorder := httptest.NewRecorder()
+			handlerFunction := ExportFeed(oauth2C, ytcf, storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("ExportFeed() = %v, want %v", recorder.Code, tt.want)
+			}
+			if got := recorder.Header().Get("Content-Type"); tt.wantContentType != "" && got != tt.wantContentType {
+				t.Errorf("ExportFeed() Content-Type = %v, want %v", got, tt.wantContentType)
+			}
+			// invalid options are rejected before calling YouTube
+			if tt.want == http.StatusBadRequest && newClientCalls > 0 {
+				t.Errorf("ExportFeed() called YouTube with invalid options")
+			}
+		})
+	}
+}
+
+func Test_parseFeedExportParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantFormat  string
+		wantColumns []string
+		wantErr     bool
+	}{
+		{
+			name:        "success case - defaults",
+			query:       "",
+			wantFormat:  csvFeedExport,
+			wantColumns: []string{"title", "channel_id"},
+		},
+		{
+			name:        "success case - columns selected",
+			query:       "format=ndjson&columns=latest_video_url, title&from=2024-01-01&to=2024-01-31",
+			wantFormat:  ndjsonFeedExport,
+			wantColumns: []string{"latest_video_url", "title"},
+		},
+		{
+			name:    "error case - unknown column",
+			query:   "columns=title,latest_video_description",
+			wantErr: true,
+		},
+		{
+			name:    "error case - invalid date",
+			query:   "from=01/02/2024",
+			wantErr: true,
+		},
+		{
+			name:    "error case - invalid format",
+			query:   "format=json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseFeedExportParams(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFeedExportParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Format != tt.wantFormat {
+				t.Errorf("parseFeedExportParams() format = %v, want %v", got.Format, tt.wantFormat)
+			}
+			// the default columns are checked by their first ones only
+			for i, name := range tt.wantColumns {
+				if got.Columns[i].name != name {
+					t.Errorf("parseFeedExportParams() column %d = %v, want %v", i, got.Columns[i].name, name)
+				}
+			}
+		})
+	}
+}
+
+func Test_feedExportWriter(t *testing.T) {
+	params, err := parseFeedExportParams(url.Values{
+		"columns": {"title,latest_video_published_at,latest_video_tags,skipped_videos,groups"},
+		"from":    {"2024-01-02"},
+		"to":      {"2024-01-03"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ytChannels := []YTChannel{
+		{Title: "before", LatestVideoPublishedAt: "2024-01-01T12:00:00Z"},
+		{Title: `Music, "live"`, LatestVideoPublishedAt: "2024-01-02T00:00:00Z", LatestVideoTags: []string{"a", "b"},
+			SkippedVideos: []SkippedVideo{{VideoID: "video1"}}, Groups: []GroupTag{{ID: 1, Name: "Music"}}},
+		{Title: "last day", LatestVideoPublishedAt: "2024-01-03T23:59:59Z"},
+		{Title: "no video"},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+		// wantFlushes counts the header and each row written
+		wantFlushes int
+	}{
+		{
+			name:   "success case - csv",
+			format: csvFeedExport,
+			want: "title,latest_video_published_at,latest_video_tags,skipped_videos,groups\n" +
+				`"Music, ""live""",2024-01-02T00:00:00Z,a|b,video1,Music` + "\n" +
+				"last day,2024-01-03T23:59:59Z,,,\n",
+			wantFlushes: 3,
+		},
+		{
+			name:   "success case - ndjson",
+			format: ndjsonFeedExport,
+			want: `{"title":"Music, \"live\"","latest_video_published_at":"2024-01-02T00:00:00Z",` +
+				`"latest_video_tags":["a","b"],"skipped_videos":[{"video_id":"video1","title":"","reason":""}],` +
+				`"groups":[{"id":1,"name":"Music"}]}` + "\n" +
+				`{"title":"last day","latest_video_published_at":"2024-01-03T23:59:59Z","latest_video_tags":[],` +
+				`"skipped_videos":[],"groups":[]}` + "\n",
+			wantFlushes: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params.Format = tt.format
+			var buffer bytes.Buffer
+			flushes := 0
+			writer, err := newFeedExportWriter(&buffer, func() { flushes++ }, params)
+			if err != nil {
+				t.Fatalf("newFeedExportWriter() error = %v", err)
+			}
+			for _, ytChannel := range ytChannels {
+				if err = writer.write(ytChannel); err != nil {
+					t.Fatalf("write() error = %v", err)
+				}
+			}
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("write() got = %v, want %v", got, tt.want)
+			}
+			if flushes != tt.wantFlushes {
+				t.Errorf("write() flushed %d times, want %d", flushes, tt.wantFlushes)
+			}
+		})
+	}
+}
+
+func Test_feedOptions_ExportURL(t *testing.T) {
+	options := feedOptions{Filtered: true, SortColumn: database.SortByChannel, Query: "music", Group: 2, Page: 3}
+	want := "/export-feed?filtered=true&format=ndjson&group=2&q=music"
+	if got := options.ExportURL(ndjsonFeedExport); got != want {
+		t.Errorf("ExportURL() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"checkYoutube/database"
+	"github.com/google/go-cmp/cmp"
+	"net/url"
+	"testing"
+)
+
+func Test_resolveFeedOptions(t *testing.T) {
+	preferences := database.DefaultPreferences()
+	groups := []database.ChannelGroup{{Id: 7, Name: "Music"}}
+	tests := []struct {
+		name  string
+		query url.Values
+		want  feedOptions
+	}{
+		{
+			name:  "success case - preferences",
+			query: url.Values{},
+			want: feedOptions{Filtered: true, SortColumn: database.SortByChannel,
+				SortDirection: database.SortAscending, Page: 1},
+		},
+		{
+			name: "success case - query parameters override",
+			query: url.Values{"filtered": {"false"}, "sort": {"new_items"}, "dir": {"desc"}, "q": {" music "},
+				"group": {"7"}, "min_duration": {"60"}, "max_duration": {"15m"}, "page": {"3"}},
+			want: feedOptions{Filtered: false, SortColumn: database.SortByNewItems,
+				SortDirection: database.SortDescending, Query: "music", Group: 7, MinDuration: 60, MaxDuration: 900,
+				Page: 3},
+		},
+		{
+			name: "success case - invalid query parameters ignored",
+			query: url.Values{"filtered": {"maybe"}, "sort": {"views"}, "dir": {"up"}, "group": {"8"},
+				"min_duration": {"20m"}, "max_duration": {"10m"}, "page": {"-1"}},
+			want: feedOptions{Filtered: true, SortColumn: database.SortByChannel,
+				SortDirection: database.SortAscending, Page: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveFeedOptions(tt.query, preferences, groups); got != tt.want {
+				t.Errorf("resolveFeedOptions() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildFeedPage(t *testing.T) {
+	ytChannels := []YTChannel{
+		{Title: "b", LatestVideoTitle: "Live music", LatestVideoPublishedAt: "2024-01-03T00:00:00Z",
+			LatestVideoDurationSeconds: 600, NewItemCount: 1, LatestVideoType: RegularVideoType,
+			Groups: []GroupTag{{ID: 1, Name: "Music"}}},
+		{Title: "a", LatestVideoPublishedAt: "2024-01-01T00:00:00Z", LatestVideoDurationSeconds: 30,
+			NewItemCount: 5, LatestVideoType: ShortVideoType},
+		{Title: "C Music", LatestVideoPublishedAt: "2024-01-02T00:00:00Z", LatestVideoDurationSeconds: 3600,
+			NewItemCount: 2, LatestVideoType: LiveVideoType, Groups: []GroupTag{{ID: 2, Name: "Live"}}},
+		{Title: "d", LatestVideoPublishedAt: "2024-01-04T00:00:00Z", NewItemCount: 1},
+	}
+	titles := func(ytChannels []YTChannel) []string {
+		result := make([]string, 0, len(ytChannels))
+		for _, ytChannel := range ytChannels {
+			result = append(result, ytChannel.Title)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name          string
+		preferences   database.Preferences
+		options       feedOptions
+		wantTitles    []string
+		wantTotal     int
+		wantPage      int
+		wantPageCount int
+		// wantBacklog is the number of new videos in the backlog
+		wantBacklog int
+	}{
+		{
+			name:          "success case - sorted by channel",
+			preferences:   database.DefaultPreferences(),
+			options:       feedOptions{SortColumn: database.SortByChannel, SortDirection: database.SortAscending},
+			wantTitles:    []string{"a", "b", "C Music", "d"},
+			wantTotal:     4,
+			wantPage:      1,
+			wantPageCount: 1,
+			wantBacklog:   9,
+		},
+		{
+			name:          "success case - sorted by publish date descending, hidden types left out",
+			preferences:   database.Preferences{HiddenVideoTypes: []string{ShortVideoType, LiveVideoType}},
+			options:       feedOptions{SortColumn: database.SortByPublishDate, SortDirection: database.SortDescending},
+			wantTitles:    []string{"d", "b"},
+			wantTotal:     2,
+			wantPage:      1,
+			wantPageCount: 1,
+			wantBacklog:   2,
+		},
+		{
+			name:          "success case - sorted by duration",
+			preferences:   database.DefaultPreferences(),
+			options:       feedOptions{SortColumn: database.SortByDuration, SortDirection: database.SortAscending},
+			wantTitles:    []string{"d", "a", "b", "C Music"},
+			wantTotal:     4,
+			wantPage:      1,
+			wantPageCount: 1,
+			wantBacklog:   9,
+		},
+		{
+			name:          "success case - sorted by new items descending, ties by channel",
+			preferences:   database.DefaultPreferences(),
+			options:       feedOptions{SortColumn: database.SortByNewItems, SortDirection: database.SortDescending},
+			wantTitles:    []string{"a", "C Music", "d", "b"},
+			wantTotal:     4,
+			wantPage:      1,
+			wantPageCount: 1,
+			wantBacklog:   9,
+		},
+		{
+			name:        "success case - keyword matching channel or video title",
+			preferences: database.DefaultPreferences(),
+			options: feedOptions{SortColumn: database.SortByChannel, SortDirection: database.SortAscending,
+				Query: "MUSIC"},
+			wantTitles:    []string{"b", "C Music"},
+			wantTotal:     2,
+			wantPage:      1,
+			wantPageCount: 1,
+			wantBacklog:   3,
+		},
+		{
+			name:        "success case - channel group",
+			preferences: database.DefaultPreferences(),
+			options: feedOptions{SortColumn: database.SortByChannel, SortDirection: database.SortAscending,
+				Group: 1},
+			wantTitles:    []string{"b"},
+			wantTotal:     1,
+			wantPage:      1,
+			wantPageCount: 1,
+			wantBacklog:   1,
+		},
+		{
+			name:        "success case - duration range, backlog regardless of it",
+			preferences: database.DefaultPreferences(),
+			options: feedOptions{SortColumn: database.SortByChannel, SortDirection: database.SortAscending,
+				MaxDuration: 900},
+			wantTitles:    []string{"a", "b"},
+			wantTotal:     2,
+			wantPage:      1,
+			wantPageCount: 1,
+			wantBacklog:   9,
+		},
+		{
+			name:        "success case - last page",
+			preferences: database.Preferences{PageSize: 3},
+			options: feedOptions{SortColumn: database.SortByChannel, SortDirection: database.SortAscending,
+				Page: 5},
+			wantTitles:    []string{"d"},
+			wantTotal:     4,
+			wantPage:      2,
+			wantPageCount: 2,
+			wantBacklog:   9,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildFeedPage(ytChannels, tt.preferences, tt.options)
+			if diff := cmp.Diff(tt.wantTitles, titles(got.YTChannels)); diff != "" {
+				t.Errorf("buildFeedPage() channels mismatch (-want +got):\n%s", diff)
+			}
+			if got.TotalChannels != tt.wantTotal || got.Page != tt.wantPage || got.PageCount != tt.wantPageCount {
+				t.Errorf("buildFeedPage() got total = %v, page %v of %v, want %v, page %v of %v",
+					got.TotalChannels, got.Page, got.PageCount, tt.wantTotal, tt.wantPage, tt.wantPageCount)
+			}
+			if got.Backlog.Videos != tt.wantBacklog {
+				t.Errorf("buildFeedPage() backlog videos = %v, want %v", got.Backlog.Videos, tt.wantBacklog)
+			}
+		})
+	}
+}
+
+func Test_feedRows(t *testing.T) {
+	ytChannels := []YTChannel{{Title: "a", LatestVideoPublishedAt: "2024-01-01T23:30:00Z"}, {Title: "b"}}
+	tests := []struct {
+		name     string
+		timezone string
+		want     []string
+	}{
+		{
+			name:     "success case - timezone of the preferences",
+			timezone: "Europe/Rome",
+			want:     []string{"2024-01-02 00:30 CET", ""},
+		},
+		{
+			name:     "success case - UTC by default",
+			timezone: "",
+			want:     []string{"2024-01-01 23:30 UTC", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, row := range feedRows(ytChannels, tt.timezone, database.DefaultThumbnail) {
+				got = append(got, row.PublishedAt)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("feedRows() publish dates mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_feedOptions_SortURL(t *testing.T) {
+	options := feedOptions{Filtered: true, SortColumn: database.SortByChannel, SortDirection: database.SortAscending,
+		Query: "music", Group: 2, Page: 3}
+	tests := []struct {
+		name    string
+		options feedOptions
+		column  string
+		want    string
+	}{
+		{
+			name:    "success case - direction toggled",
+			options: options,
+			column:  database.SortByChannel,
+			want:    "/check-youtube?dir=desc&filtered=true&group=2&q=music&sort=channel",
+		},
+		{
+			name:    "success case - other column sorted descending",
+			options: options,
+			column:  database.SortByPublishDate,
+			want:    "/check-youtube?dir=desc&filtered=true&group=2&q=music&sort=published",
+		},
+		{
+			name: "success case - channel sorted ascending",
+			options: feedOptions{SortColumn: database.SortByDuration, SortDirection: database.SortDescending,
+				Page: 1},
+			column: database.SortByChannel,
+			want:   "/check-youtube?dir=asc&filtered=false&sort=channel",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.SortURL(tt.column); got != tt.want {
+				t.Errorf("SortURL() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_feedOptions_DurationURL(t *testing.T) {
+	options := feedOptions{Filtered: true, SortColumn: database.SortByChannel, SortDirection: database.SortAscending,
+		MinDuration: 60, Page: 3}
+	tests := []struct {
+		name        string
+		minDuration int64
+		maxDuration int64
+		want        string
+	}{
+		{
+			name:        "success case - under 15 minutes",
+			maxDuration: 900,
+			want:        "/check-youtube?dir=asc&filtered=true&max_duration=900&sort=channel",
+		},
+		{
+			name: "success case - any duration",
+			want: "/check-youtube?dir=asc&filtered=true&sort=channel",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := options.DurationURL(tt.minDuration, tt.maxDuration); got != tt.want {
+				t.Errorf("DurationURL() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_feedOptions_shows(t *testing.T) {
+	ytChannel := YTChannel{Title: "Go channel", LatestVideoTitle: "Generics tutorial", LatestVideoType: ShortVideoType,
+		Groups: []GroupTag{{ID: 1, Name: "Programming"}}}
+	tests := []struct {
+		name        string
+		options     feedOptions
+		hiddenTypes []string
+		want        bool
+	}{
+		{
+			name:    "success case - keyword in the video title and group",
+			options: feedOptions{Query: "TUTORIAL", Group: 1},
+			want:    true,
+		},
+		{
+			name:        "success case - hidden video type",
+			hiddenTypes: []string{ShortVideoType},
+			want:        false,
+		},
+		{
+			name:    "success case - keyword not matching",
+			options: feedOptions{Query: "music"},
+			want:    false,
+		},
+		{
+			name:    "success case - other group",
+			options: feedOptions{Group: 2},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preferences := database.Preferences{HiddenVideoTypes: tt.hiddenTypes}
+			if got := tt.options.shows(ytChannel, preferences); got != tt.want {
+				t.Errorf("shows() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
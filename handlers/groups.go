@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// max length of the name of a channel group
+const maxChannelGroupNameLength = 255
+
+// actions of the bulk assignment of channels to a group
+const (
+	addToGroupAction      = "add"
+	removeFromGroupAction = "remove"
+)
+
+type channelGroupResponse struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	ChannelIDs []string `json:"channel_ids"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+type channelGroupRequest struct {
+	Name string `json:"name"`
+}
+
+type channelGroupChannelsRequest struct {
+	GroupID    int64    `json:"group_id"`
+	ChannelIDs []string `json:"channel_ids"`
+}
+
+// CreateChannelGroup creates a new channel group of the logged user from the settings page
+func CreateChannelGroup(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "CreateChannelGroup"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		name, err := channelGroupName(r.FormValue("name"))
+		if err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err = storage.CreateChannelGroup(r.Context(), tokenInfo.AppUserId, name); err != nil {
+			slog.Error(fmt.Sprintf("failed to create channel group: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("channel group %s created", name), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/settings", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// RenameChannelGroup changes the name of a channel group of the logged user from the settings page
+func RenameChannelGroup(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "RenameChannelGroup"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid channel group id: %s", r.FormValue("id"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name, err := channelGroupName(r.FormValue("name"))
+		if err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err = storage.RenameChannelGroup(r.Context(), tokenInfo.AppUserId, id, name); err != nil {
+			slog.Error(fmt.Sprintf("failed to rename channel group: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("channel group %d renamed to %s", id, name), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/settings", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// DeleteChannelGroup removes a channel group of the logged user from the settings page
+func DeleteChannelGroup(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "DeleteChannelGroup"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid channel group id: %s", r.FormValue("id"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err = storage.DeleteChannelGroup(r.Context(), tokenInfo.AppUserId, id); err != nil {
+			slog.Error(fmt.Sprintf("failed to delete channel group: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("channel group %d deleted", id), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/settings", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// AssignChannelGroup adds the channels selected in the feed page to a channel group of the logged user, or
+// removes them from it, then goes back to the feed page
+func AssignChannelGroup(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "AssignChannelGroup"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		// validate the form
+		if err := r.ParseForm(); err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		groupID, err := strconv.ParseInt(r.PostForm.Get("group_id"), 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid channel group id: %s", r.PostForm.Get("group_id"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		channelIDs := r.PostForm["channel_id"]
+		if len(channelIDs) == 0 {
+			err = fmt.Errorf("no channel selected")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch action := r.PostForm.Get("action"); action {
+		case addToGroupAction:
+			err = storage.AddChannelsToGroup(r.Context(), tokenInfo.AppUserId, groupID, channelIDs)
+		case removeFromGroupAction:
+			err = storage.RemoveChannelsFromGroup(r.Context(), tokenInfo.AppUserId, groupID, channelIDs)
+		default:
+			err = fmt.Errorf("unknown action: %s", action)
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to assign channels to group: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("%d channels assigned to group %d", len(channelIDs), groupID),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+
+		// go back to the feed page the form was sent from
+		http.Redirect(w, r, serverBasepath+returnPath(r.PostForm.Get("return_to"), "/check-youtube"),
+			http.StatusSeeOther)
+	}
+}
+
+// ChannelGroupsAPI lists the channel groups of the user as JSON on GET, creates one on POST, renames the one
+// given by the id query parameter on PUT and deletes it on DELETE
+func ChannelGroupsAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "ChannelGroupsAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			groups, err := storage.GetChannelGroups(r.Context(), tokenInfo.AppUserId)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve channel groups: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			response := make([]channelGroupResponse, 0, len(groups))
+			for _, group := range groups {
+				response = append(response, channelGroupResponse{
+					ID:         group.Id,
+					Name:       group.Name,
+					ChannelIDs: group.ChannelIDs,
+					CreatedAt:  group.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+				})
+			}
+			writeJSON(w, response, funcName)
+		case http.MethodPost:
+			var req channelGroupRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			name, err := channelGroupName(req.Name)
+			if err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			id, err := storage.CreateChannelGroup(r.Context(), tokenInfo.AppUserId, name)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to create channel group: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("channel group %s created", name), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(w, channelGroupResponse{ID: id, Name: name, ChannelIDs: []string{}}, funcName)
+		case http.MethodPut:
+			id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+			if err != nil {
+				err = fmt.Errorf("invalid channel group id: %s", r.URL.Query().Get("id"))
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var req channelGroupRequest
+			if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			name, err := channelGroupName(req.Name)
+			if err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err = storage.RenameChannelGroup(r.Context(), tokenInfo.AppUserId, id, name); err != nil {
+				slog.Error(fmt.Sprintf("failed to rename channel group: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("channel group %d renamed to %s", id, name), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+			if err != nil {
+				err = fmt.Errorf("invalid channel group id: %s", r.URL.Query().Get("id"))
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err = storage.DeleteChannelGroup(r.Context(), tokenInfo.AppUserId, id); err != nil {
+				slog.Error(fmt.Sprintf("failed to delete channel group: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("channel group %d deleted", id), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// ChannelGroupChannelsAPI adds the channels of the request body to a channel group of the user on POST, and
+// removes them from it on DELETE
+func ChannelGroupChannelsAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "ChannelGroupChannelsAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var req channelGroupChannelsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.ChannelIDs) == 0 {
+			err := fmt.Errorf("missing channel_ids")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		if r.Method == http.MethodPost {
+			err = storage.AddChannelsToGroup(r.Context(), tokenInfo.AppUserId, req.GroupID, req.ChannelIDs)
+		} else {
+			err = storage.RemoveChannelsFromGroup(r.Context(), tokenInfo.AppUserId, req.GroupID, req.ChannelIDs)
+		}
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to assign channels to group: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("%d channels assigned to group %d", len(req.ChannelIDs), req.GroupID),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// return the trimmed name of a channel group, an error if empty or too long
+func channelGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("missing name")
+	}
+	if utf8.RuneCountInString(name) > maxChannelGroupNameLength {
+		return "", fmt.Errorf("name longer than %d characters", maxChannelGroupNameLength)
+	}
+	return name, nil
+}
+
+// return the channel groups of the logged user, none when they can't be retrieved
+func userChannelGroups(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	funcName string) []database.ChannelGroup {
+	groups, err := storage.GetChannelGroups(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to retrieve channel groups, ignoring them: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		return []database.ChannelGroup{}
+	}
+	return groups
+}
+
+// set the groups each channel is in
+func tagChannelGroups(ytChannels []YTChannel, groups []database.ChannelGroup) []YTChannel {
+	for i := range ytChannels {
+		ytChannels[i].Groups = make([]GroupTag, 0)
+		for _, group := range groups {
+			if slices.Contains(group.ChannelIDs, ytChannels[i].ChannelID) {
+				ytChannels[i].Groups = append(ytChannels[i].Groups, GroupTag{ID: group.Id, Name: group.Name})
+			}
+		}
+	}
+	return ytChannels
+}
+
+// tell whether the channel is in the given group
+func inChannelGroup(ytChannel YTChannel, groupID int64) bool {
+	return slices.ContainsFunc(ytChannel.Groups, func(group GroupTag) bool { return group.ID == groupID })
+}
@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCreateChannelGroup(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		CreateChannelGroupStub: func(_ context.Context, userId int64, name string) (int64, error) {
+			if name == "Duplicated" {
+				return 0, fmt.Errorf("test error")
+			}
+			return 1, nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		form   url.Values
+		want   int
+	}{
+		{
+			name:   "success case",
+			method: http.MethodPost,
+			form:   url.Values{"name": {" Music "}},
+			want:   http.StatusSeeOther,
+		},
+		{
+			name:   "failure case - missing name",
+			method: http.MethodPost,
+			form:   url.Values{"name": {" "}},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - storage error",
+			method: http.MethodPost,
+			form:   url.Values{"name": {"Duplicated"}},
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "failure case - method not allowed",
+			method: http.MethodGet,
+			form:   url.Values{"name": {"Music"}},
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/create-channel-group", strings.NewReader(tt.form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := CreateChannelGroup(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("CreateChannelGroup() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteChannelGroup(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		DeleteChannelGroupStub: func(_ context.Context, userId, id int64) error {
+			if id != 1 {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		id     string
+		want   int
+	}{
+		{
+			name:   "success case",
+			method: http.MethodPost,
+			id:     "1",
+			want:   http.StatusSeeOther,
+		},
+		{
+			name:   "failure case - invalid id",
+			method: http.MethodPost,
+			id:     "abc",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - storage error",
+			method: http.MethodPost,
+			id:     "2",
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "failure case - method not allowed",
+			method: http.MethodGet,
+			id:     "1",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"id": {tt.id}}
+			req, err := http.NewRequest(tt.method, "/delete-channel-group", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := DeleteChannelGroup(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("DeleteChannelGroup() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignChannelGroup(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	var added, removed []string
+	storage := &test.StorageMock{
+		AddChannelsToGroupStub: func(_ context.Context, userId, groupId int64, channelIDs []string) error {
+			if groupId != 1 {
+				return fmt.Errorf("test error")
+			}
+			added = channelIDs
+			return nil
+		},
+		RemoveChannelsFromGroupStub: func(_ context.Context, userId, groupId int64, channelIDs []string) error {
+			removed = channelIDs
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		form         url.Values
+		want         int
+		wantLocation string
+	}{
+		{
+			name: "success case - add",
+			form: url.Values{"group_id": {"1"}, "channel_id": {"channel1", "channel2"}, "action": {"add"},
+				"return_to": {"/check-youtube?filtered=true&page=2"}},
+			want:         http.StatusSeeOther,
+			wantLocation: serverBasepath + "/check-youtube?filtered=true&page=2",
+		},
+		{
+			name: "success case - remove, not going back to another site",
+			form: url.Values{"group_id": {"1"}, "channel_id": {"channel3"}, "action": {"remove"},
+				"return_to": {"https://example.com"}},
+			want:         http.StatusSeeOther,
+			wantLocation: serverBasepath + "/check-youtube",
+		},
+		{
+			name: "failure case - no channel selected",
+			form: url.Values{"group_id": {"1"}, "action": {"add"}},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "failure case - unknown action",
+			form: url.Values{"group_id": {"1"}, "channel_id": {"channel1"}, "action": {"move"}},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "failure case - invalid group id",
+			form: url.Values{"group_id": {"music"}, "channel_id": {"channel1"}, "action": {"add"}},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "failure case - storage error",
+			form: url.Values{"group_id": {"2"}, "channel_id": {"channel1"}, "action": {"add"}},
+			want: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/assign-channel-group",
+				strings.NewReader(tt.form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := AssignChannelGroup(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("AssignChannelGroup() = %v, want %v", recorder.Code, tt.want)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("AssignChannelGroup() location = %v, want %v", location, tt.wantLocation)
+			}
+		})
+	}
+
+	if diff := cmp.Diff([]string{"channel1", "channel2"}, added); diff != "" {
+		t.Errorf("AssignChannelGroup() added channels mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"channel3"}, removed); diff != "" {
+		t.Errorf("AssignChannelGroup() removed channels mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestChannelGroupsAPI(t *testing.T) {
+	// mocks
+	storage := &test.StorageMock{
+		GetChannelGroupsStub: func(_ context.Context, userId int64) ([]database.ChannelGroup, error) {
+			return []database.ChannelGroup{{Id: 1, Name: "Music", ChannelIDs: []string{"channel1"}}}, nil
+		},
+		CreateChannelGroupStub: func(_ context.Context, userId int64, name string) (int64, error) {
+			return 2, nil
+		},
+		RenameChannelGroupStub: func(_ context.Context, userId, id int64, name string) error {
+			return nil
+		},
+		DeleteChannelGroupStub: func(_ context.Context, userId, id int64) error {
+			if id != 1 {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{
+			name:   "success case - list",
+			method: http.MethodGet,
+			target: "/api/v1/groups",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "success case - create",
+			method: http.MethodPost,
+			target: "/api/v1/groups",
+			body:   `{"name": "Tech talks"}`,
+			want:   http.StatusCreated,
+		},
+		{
+			name:   "success case - rename",
+			method: http.MethodPut,
+			target: "/api/v1/groups?id=1",
+			body:   `{"name": "Kids"}`,
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "success case - delete",
+			method: http.MethodDelete,
+			target: "/api/v1/groups?id=1",
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "error case - missing name",
+			method: http.MethodPost,
+			target: "/api/v1/groups",
+			body:   `{}`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - invalid id",
+			method: http.MethodPut,
+			target: "/api/v1/groups",
+			body:   `{"name": "Kids"}`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - storage error",
+			method: http.MethodDelete,
+			target: "/api/v1/groups?id=2",
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodPatch,
+			target: "/api/v1/groups",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := ChannelGroupsAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("ChannelGroupsAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelGroupChannelsAPI(t *testing.T) {
+	// mocks
+	storage := &test.StorageMock{
+		AddChannelsToGroupStub: func(_ context.Context, userId, groupId int64, channelIDs []string) error {
+			if groupId != 1 {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+		RemoveChannelsFromGroupStub: func(_ context.Context, userId, groupId int64, channelIDs []string) error {
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{
+			name:   "success case - add",
+			method: http.MethodPost,
+			body:   `{"group_id": 1, "channel_ids": ["channel1", "channel2"]}`,
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "success case - remove",
+			method: http.MethodDelete,
+			body:   `{"group_id": 1, "channel_ids": ["channel1"]}`,
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "error case - missing channels",
+			method: http.MethodPost,
+			body:   `{"group_id": 1}`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - storage error",
+			method: http.MethodPost,
+			body:   `{"group_id": 2, "channel_ids": ["channel1"]}`,
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodGet,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/api/v1/groups/channels", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := ChannelGroupChannelsAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("ChannelGroupChannelsAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tagChannelGroups(t *testing.T) {
+	groups := []database.ChannelGroup{
+		{Id: 1, Name: "Music", ChannelIDs: []string{"channel1", "channel2"}},
+		{Id: 2, Name: "Tech", ChannelIDs: []string{"channel1"}},
+	}
+	ytChannels := []YTChannel{{ChannelID: "channel1"}, {ChannelID: "channel2"}, {ChannelID: "channel3"}}
+
+	want := []YTChannel{
+		{ChannelID: "channel1", Groups: []GroupTag{{ID: 1, Name: "Music"}, {ID: 2, Name: "Tech"}}},
+		{ChannelID: "channel2", Groups: []GroupTag{{ID: 1, Name: "Music"}}},
+		{ChannelID: "channel3", Groups: []GroupTag{}},
+	}
+	if diff := cmp.Diff(want, tagChannelGroups(ytChannels, groups)); diff != "" {
+		t.Errorf("tagChannelGroups() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"checkYoutube/auth"
 	"checkYoutube/clients"
+	"checkYoutube/database"
 	"checkYoutube/datetime"
 	"checkYoutube/logging"
 	"cmp"
@@ -17,38 +18,112 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
+// YTChannel is a subscribed channel along with its latest video, LatestVideoDurationSeconds is 0 when the duration
+// is unknown. The like and comment counts are 0 when hidden or disabled. LatestVideoUnavailable is the reason the
+// latest video can't be watched, SkippedVideos are the newer unavailable videos passed over to show it
 type YTChannel struct {
-	Title                  string
-	ChannelID              string
-	URL                    string
-	LatestVideoID          string
-	LatestVideoURL         string
-	LatestVideoTitle       string
-	LatestVideoPublishedAt string
-	LatestVideoDuration    string
+	Title                      string               `json:"title"`
+	ChannelID                  string               `json:"channel_id"`
+	URL                        string               `json:"url"`
+	LatestVideoID              string               `json:"latest_video_id"`
+	LatestVideoURL             string               `json:"latest_video_url"`
+	LatestVideoTitle           string               `json:"latest_video_title"`
+	LatestVideoDescription     string               `json:"-"`
+	LatestVideoPublishedAt     string               `json:"latest_video_published_at"`
+	LatestVideoDuration        string               `json:"latest_video_duration"`
+	LatestVideoDurationSeconds int64                `json:"latest_video_duration_seconds"`
+	LatestVideoType            string               `json:"latest_video_type"`
+	LatestVideoThumbnails      map[string]Thumbnail `json:"latest_video_thumbnails"`
+	LatestVideoViewCount       uint64               `json:"latest_video_view_count"`
+	LatestVideoLikeCount       uint64               `json:"latest_video_like_count"`
+	LatestVideoCommentCount    uint64               `json:"latest_video_comment_count"`
+	LatestVideoTags            []string             `json:"latest_video_tags"`
+	LatestVideoCategoryID      string               `json:"latest_video_category_id"`
+	LatestVideoCategory        string               `json:"latest_video_category"`
+	LatestVideoLanguage        string               `json:"latest_video_default_language"`
+	LatestVideoCaptions        bool                 `json:"latest_video_captions"`
+	LatestVideoPrivacyStatus   string               `json:"latest_video_privacy_status"`
+	LatestVideoUnavailable     string               `json:"latest_video_unavailable"`
+	SkippedVideos              []SkippedVideo       `json:"skipped_videos"`
+	NewItemCount               int64                `json:"new_item_count"`
+	SourceAccounts             []SourceAccount      `json:"source_accounts"`
+	Groups                     []GroupTag           `json:"groups"`
+}
+
+// SourceAccount is a linked Google account subscribed to a channel
+type SourceAccount struct {
+	AccountID string `json:"account_id"`
+	Name      string `json:"name"`
+}
+
+// GroupTag is a channel group of the user a channel is in
+type GroupTag struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
 }
 
 type templateResponse struct {
-	YTChannels     []YTChannel
-	Username       string
-	ServerBasepath string
+	Rows             []feedRow
+	Username         string
+	ServerBasepath   string
+	MultipleAccounts bool
+	NoLinkedAccounts bool
+	Options          feedOptions
+	Groups           []database.ChannelGroup
+	// MarkAllChannelIDs are the ids of the channels in all the pages, space separated
+	MarkAllChannelIDs string
+	TotalChannels     int
+	Page              int
+	PageCount         int
+	PrevPageURL       string
+	NextPageURL       string
+	Timezone          string
+	Theme             string
+	UnreadAlerts      int
+	Backlog           backlogSummary
+	// QuickWatchURL shows the videos quick to watch of the backlog
+	QuickWatchURL string
 }
 
 type callUrlRequest struct {
 	ChannelsID []string `json:"channels_id"`
+	// GroupID adds the channels of the group to the ones to mark as viewed
+	GroupID int64 `json:"group_id"`
 }
 
 const youTubeBasepath = "https://www.youtube.com"
 
-// GetYoutubeChannelsVideos call YouTube API to check for new videos
+// types of the latest video of a channel
+const (
+	RegularVideoType  = "video"
+	ShortVideoType    = "short"
+	LiveVideoType     = "live"
+	UpcomingVideoType = "upcoming"
+)
+
+// VideoTypes are the video types users can hide from the feed
+var VideoTypes = []string{RegularVideoType, ShortVideoType, LiveVideoType, UpcomingVideoType}
+
+// YouTube doesn't tell shorts apart, videos up to the max duration of shorts are considered shorts. It's a
+// heuristic: regular videos as short are taken for shorts too
+const maxShortDuration = 3 * time.Minute
+
+// YouTube tracks the new videos of the subscriptions only, the uploads of the followed channels in the past days
+// are their new videos, up to a page of the YouTube playlist items API
+const (
+	followedNewVideosDays = 7
+	maxFollowedNewVideos  = 50
+)
+
+// GetYoutubeChannelsVideos call YouTube API to check for new videos, shown according to the user preferences
+// unless overridden by the query parameters
 func GetYoutubeChannelsVideos(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
-	serverBasepath, htmlTemplate string) http.HandlerFunc {
+	storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
 	const funcName = "GetYoutubeChannelsVideos"
 	return func(w http.ResponseWriter, r *http.Request) {
-		filtered := r.URL.Query().Get("filtered") == "true"
-
 		// get token from context
 		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
 		if !tokenOk {
@@ -58,8 +133,14 @@ func GetYoutubeChannelsVideos(oauth2C auth.Oauth2Config, ytcf clients.YoutubeCli
 			return
 		}
 
-		// create youtube service
-		youtubeSvc, err := ytcf.NewClient(oauth2C.CreateTokenSource(r.Context(), tokenInfo.Token))
+		preferences := userPreferences(r, storage, tokenInfo, funcName)
+		groups := userChannelGroups(r, storage, tokenInfo, funcName)
+		options := resolveFeedOptions(r.URL.Query(), preferences, groups)
+
+		// get YouTube subscriptions info of each linked account and of the followed channels
+		accounts := contextAccounts(r, tokenInfo)
+		ytChannels, err := userFeedChannels(r, oauth2C, ytcf, storage, tokenInfo, accounts, preferences, groups,
+			options.Filtered, funcName)
 		if err != nil {
 			slog.Warn(fmt.Sprintf("unable to create youtube service, redirecting user to login page: %s",
 				err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
@@ -67,13 +148,30 @@ func GetYoutubeChannelsVideos(oauth2C auth.Oauth2Config, ytcf clients.YoutubeCli
 			return
 		}
 
-		// get YouTube subscriptions info
-		ytChannels := checkYoutube(youtubeSvc, filtered, tokenInfo.Username)
-
+		page := buildFeedPage(ytChannels, preferences, options)
 		response := templateResponse{
-			YTChannels:     ytChannels,
-			Username:       tokenInfo.Username,
-			ServerBasepath: serverBasepath,
+			Rows:              feedRows(page.YTChannels, preferences.Timezone, preferences.ThumbnailSize),
+			Username:          tokenInfo.Username,
+			ServerBasepath:    serverBasepath,
+			MultipleAccounts:  len(accounts) > 1,
+			NoLinkedAccounts:  len(accounts) == 0,
+			Options:           options,
+			Groups:            groups,
+			MarkAllChannelIDs: strings.Join(page.ChannelIDs, " "),
+			TotalChannels:     page.TotalChannels,
+			Page:              page.Page,
+			PageCount:         page.PageCount,
+			Timezone:          preferences.Timezone,
+			Theme:             preferences.Theme,
+			UnreadAlerts:      unreadAlertMatches(r, storage, tokenInfo, funcName),
+			Backlog:           page.Backlog,
+			QuickWatchURL:     options.DurationURL(0, quickWatchDuration),
+		}
+		if page.Page > 1 {
+			response.PrevPageURL = options.PageURL(page.Page - 1)
+		}
+		if page.Page < page.PageCount {
+			response.NextPageURL = options.PageURL(page.Page + 1)
 		}
 
 		// render response as HTML using a template
@@ -88,26 +186,157 @@ func GetYoutubeChannelsVideos(oauth2C auth.Oauth2Config, ytcf clients.YoutubeCli
 	}
 }
 
-// call YouTube API to check for new videos
-func checkYoutube(svc clients.YoutubeClientInterface, filtered bool, username string) []YTChannel {
+// return the channels of the feed of the logged user: the subscriptions of the given accounts and the channels
+// followed, leaving out the ones hidden by the rules and tagging the others with their channel groups. An error is
+// returned when the YouTube service of the session account can't be created
+func userFeedChannels(r *http.Request, oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface, tokenInfo *auth.TokenInfo, accounts []*auth.TokenInfo,
+	preferences database.Preferences, groups []database.ChannelGroup, filtered bool,
+	funcName string) ([]YTChannel, error) {
+	ytChannels, _, err := getAccountsYTChannels(r.Context(), oauth2C, ytcf, tokenInfo, accounts,
+		userFollowedChannels(r, storage, tokenInfo, funcName), filtered,
+		userChannelRules(r, storage, tokenInfo, funcName), newAvailabilityOptions(preferences), nil)
+	if err != nil {
+		return nil, err
+	}
+	ytChannels = userVideoRules(r, storage, tokenInfo, funcName).filter(ytChannels)
+	return tagChannelGroups(ytChannels, groups), nil
+}
+
+// return the Google accounts linked to the user from the context, falling back to the session one. Users logged
+// in with a login-only provider have no accounts until they link one
+func contextAccounts(r *http.Request, tokenInfo *auth.TokenInfo) []*auth.TokenInfo {
+	accounts, accountsOk := r.Context().Value(auth.AccountsCtxKey{}).([]*auth.TokenInfo)
+	if !accountsOk || (len(accounts) == 0 && !tokenInfo.LoginOnly) {
+		accounts = []*auth.TokenInfo{tokenInfo}
+	}
+	return accounts
+}
+
+// get the YouTube subscriptions info of each account and the channels followed by the user, merged, along with the
+// subscriptions of the accounts whose list was retrieved entirely. The followed channels are checked with the
+// YouTube service of the first account available, not at all when no account is linked. When emit isn't nil, the
+// channels are also passed to it as soon as they're checked, one call at a time: a channel subscribed with several
+// accounts is passed once, with the account it's found with first. An error is returned when the YouTube service of
+// the session account can't be created, the other accounts failing are skipped
+func getAccountsYTChannels(ctx context.Context, oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	tokenInfo *auth.TokenInfo, accounts []*auth.TokenInfo, followed []database.FollowedChannel, filtered bool,
+	rules channelRules, availability availabilityOptions,
+	emit func([]YTChannel)) ([]YTChannel, []accountSubscriptions, error) {
+	const funcName = "getAccountsYTChannels"
+
+	// the channels already emitted are left out
+	emitted := make(map[string]bool)
+	emitMutex := sync.Mutex{}
+	emitFrom := func(account *auth.TokenInfo) func([]YTChannel) {
+		if emit == nil {
+			return nil
+		}
+		return func(ytChannels []YTChannel) {
+			emitMutex.Lock()
+			defer emitMutex.Unlock()
+			fresh := make([]YTChannel, 0, len(ytChannels))
+			for _, ytChannel := range ytChannels {
+				if !emitted[ytChannel.ChannelID] {
+					emitted[ytChannel.ChannelID] = true
+					fresh = append(fresh, ytChannel)
+				}
+			}
+			if account != nil {
+				fresh = tagSourceAccount(fresh, account)
+			}
+			if len(fresh) > 0 {
+				emit(fresh)
+			}
+		}
+	}
+
+	feeds := make([][]YTChannel, len(accounts))
+	synced := make([]accountSubscriptions, len(accounts))
+	var followedSvc clients.YoutubeClientInterface
+	wg := &sync.WaitGroup{}
+	for i, account := range accounts {
+		// create youtube service
+		youtubeSvc, err := ytcf.NewClient(oauth2C.CreateTokenSource(ctx, account.Token))
+		if err != nil {
+			if i == 0 && !tokenInfo.LoginOnly {
+				return nil, nil, err
+			}
+			slog.Warn(fmt.Sprintf("unable to create youtube service for linked account %s, skipping it: %s",
+				account.Username, err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			continue
+		}
+		if followedSvc == nil {
+			followedSvc = youtubeSvc
+		}
+
+		wg.Add(1)
+		go func(i int, account *auth.TokenInfo) {
+			defer wg.Done()
+			ytChannels, subscriptions := checkYoutube(youtubeSvc, filtered, account.Username, rules, availability,
+				emitFrom(account))
+			feeds[i] = tagSourceAccount(ytChannels, account)
+			synced[i] = accountSubscriptions{account: account, svc: youtubeSvc, subscriptions: subscriptions}
+		}(i, account)
+	}
+	wg.Wait()
+
+	synced = slices.DeleteFunc(synced, func(s accountSubscriptions) bool { return s.subscriptions == nil })
+	if len(followed) > 0 && followedSvc != nil {
+		subscribed := make(map[string]bool)
+		for _, s := range synced {
+			for _, subscription := range s.subscriptions {
+				subscribed[subscription.ChannelID] = true
+			}
+		}
+		followedChannels := checkFollowedChannels(followedSvc, followed, subscribed, filtered, tokenInfo.Username,
+			rules, availability, time.Now())
+		if emit != nil {
+			emitFrom(nil)(followedChannels)
+		}
+		feeds = append(feeds, followedChannels)
+	}
+	return mergeYTChannels(feeds...), synced, nil
+}
+
+// call YouTube API to check for new videos, leaving out the channels hidden by the rules of the user and handling
+// the unavailable latest videos as set by the availability options. The title match rules are applied to the video
+// shown, the watchable one replacing an unavailable latest video. The channels of each page of subscriptions are
+// passed to emit, when not nil, as soon as they're checked. All the subscriptions listed are returned too, none when
+// the list couldn't be retrieved entirely
+func checkYoutube(svc clients.YoutubeClientInterface, filtered bool, username string, rules channelRules,
+	availability availabilityOptions, emit func([]YTChannel)) ([]YTChannel, []subscriptionRow) {
 	const funcName = "checkYoutube"
 	response := make([]YTChannel, 0)
-	videoIDs := make([]string, 0)
+	subscriptions := make([]subscriptionRow, 0)
 	ctx := context.Background()
 
 	if svc == nil {
 		slog.Warn("uninitialized youtube service", logging.FuncNameAttr(funcName), logging.UserAttr(username))
-		return nil
+		return nil, nil
 	}
 
 	// get user's subscriptions list from the YouTube API
 	err := svc.GetAndProcessSubscriptions(ctx, func(subs *youtube.SubscriptionListResponse) error {
+		for _, item := range subs.Items {
+			subscriptions = append(subscriptions, subscriptionRow{SubscriptionID: item.Id,
+				ChannelID: item.Snippet.ResourceId.ChannelId, Title: item.Snippet.Title})
+		}
+
 		// collect channels having published new videos
+		page := make([]YTChannel, 0, len(subs.Items))
+		videoIDs := make([]string, 0, len(subs.Items))
+		// channels whose latest video couldn't be retrieved, kept whatever their title match rule
+		failed := make(map[string]bool)
 		wg := &sync.WaitGroup{}
 		mutex := sync.RWMutex{}
 		for _, item := range subs.Items {
 			newItems := item.ContentDetails.NewItemCount
 			if !filtered || newItems > 0 {
+				// muted and snoozed channels are left out without calling the YouTube API
+				if rules.skips(item.Snippet.ResourceId.ChannelId) {
+					continue
+				}
 				wg.Add(1)
 				go func(item *youtube.Subscription) {
 					defer wg.Done()
@@ -118,7 +347,10 @@ func checkYoutube(svc clients.YoutubeClientInterface, filtered bool, username st
 							logging.FuncNameAttr(funcName), logging.UserAttr(username))
 					}
 					mutex.Lock()
-					response = append(response, responseItem)
+					if err != nil {
+						failed[responseItem.ChannelID] = true
+					}
+					page = append(page, responseItem)
 					videoIDs = append(videoIDs, responseItem.LatestVideoID)
 					mutex.Unlock()
 				}(item)
@@ -127,21 +359,160 @@ func checkYoutube(svc clients.YoutubeClientInterface, filtered bool, username st
 			}
 		}
 		wg.Wait()
+		if len(page) == 0 {
+			return nil
+		}
+
+		page = detailYTChannels(ctx, svc, page, videoIDs, failed, rules, availability, username)
+		response = append(response, page...)
+		if emit != nil {
+			emit(page)
+		}
 		return nil
 	})
 	if err != nil {
 		slog.Error(fmt.Sprintf("error retrieving YouTube subscriptions list: %s", err.Error()),
 			logging.FuncNameAttr(funcName), logging.UserAttr(username))
-		return response
+		return response, nil
 	}
 
 	if len(response) == 0 {
 		slog.Info("no new video published by user's YouTube channels",
 			logging.FuncNameAttr(funcName), logging.UserAttr(username))
-		return response
+		return response, subscriptions
+	}
+
+	// sort results by title, the pages being sorted one by one by detailYTChannels
+	slices.SortFunc(response, func(a, b YTChannel) int {
+		return cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+	return response, subscriptions
+}
+
+// add the details and categories of the latest videos of the channels, handling the unavailable ones as set by the
+// availability options, then leave out the channels hidden by the title match rules, except the failed ones whose
+// latest video couldn't be retrieved, and sort the others by title
+func detailYTChannels(ctx context.Context, svc clients.YoutubeClientInterface, response []YTChannel,
+	videoIDs []string, failed map[string]bool, rules channelRules, availability availabilityOptions,
+	username string) []YTChannel {
+	const funcName = "detailYTChannels"
+	hidden := func(ytChannel YTChannel) bool {
+		return !failed[ytChannel.ChannelID] && rules.hides(ytChannel)
 	}
 
 	// retrieve additional videos info from YouTube videos API
+	if err := resolveLatestVideos(ctx, svc, response, videoIDs, availability, username); err != nil {
+		slog.Error(fmt.Sprintf("error retrieving videos: %s",
+			err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(username))
+		return slices.DeleteFunc(response, hidden)
+	}
+	response = slices.DeleteFunc(response, hidden)
+	addVideoCategories(ctx, svc, response, username)
+
+	// sort results by title
+	slices.SortFunc(response, func(a, b YTChannel) int {
+		return cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+
+	return response
+}
+
+// add the details of the latest videos of the channels, then handle the unavailable ones as set by the availability
+// options, replacing them with the newest watchable ones when enabled
+func resolveLatestVideos(ctx context.Context, svc clients.YoutubeClientInterface, response []YTChannel,
+	videoIDs []string, availability availabilityOptions, username string) error {
+	videos, err := addVideosDetails(ctx, svc, response, videoIDs, username)
+	if err != nil {
+		return err
+	}
+	resolveUnavailableVideos(ctx, svc, response, videos, availability, username)
+	return nil
+}
+
+// call YouTube API to check the channels followed by the user for new videos. YouTube tracks the new videos of the
+// subscriptions only, so the uploads of the past days are the new videos of a followed channel. The channels
+// subscribed to are left out, already checked along with the subscriptions, as are the ones hidden by the rules of
+// the user and, when filtered, the ones without new videos or whose uploads couldn't be retrieved
+func checkFollowedChannels(svc clients.YoutubeClientInterface, followed []database.FollowedChannel,
+	subscribed map[string]bool, filtered bool, username string, rules channelRules,
+	availability availabilityOptions, now time.Time) []YTChannel {
+	const funcName = "checkFollowedChannels"
+	ctx := context.Background()
+
+	ytChannels := make([]YTChannel, 0, len(followed))
+	for _, channel := range followed {
+		// muted and snoozed channels are left out without calling the YouTube API
+		if subscribed[channel.ChannelID] || rules.skips(channel.ChannelID) {
+			continue
+		}
+		ytChannels = append(ytChannels, YTChannel{
+			Title:     channel.ChannelTitle,
+			ChannelID: channel.ChannelID,
+			URL:       fmt.Sprintf("%s/channel/%s/videos", youTubeBasepath, channel.ChannelID),
+		})
+	}
+	since := now.Add(-followedNewVideosDays * 24 * time.Hour)
+	uploads := fetchChannelPlaylists(ytChannels, username, func(channelID string) ([]*youtube.PlaylistItem, error) {
+		return svc.GetPlaylistVideosSince(ctx, uploadsPlaylistID(channelID), since, maxFollowedNewVideos)
+	})
+
+	response := make([]YTChannel, 0, len(ytChannels))
+	videoIDs := make([]string, 0, len(ytChannels))
+	failed := make(map[string]bool)
+	for _, ytChannel := range ytChannels {
+		playlistItems, ok := uploads[ytChannel.ChannelID]
+		if !ok {
+			if !filtered {
+				failed[ytChannel.ChannelID] = true
+				response = append(response, ytChannel)
+			}
+			continue
+		}
+		for _, playlistItem := range playlistItems {
+			publishedAt, err := time.Parse(time.RFC3339, playlistItem.Snippet.PublishedAt)
+			if err == nil && !publishedAt.Before(since) {
+				ytChannel.NewItemCount++
+			}
+		}
+		if filtered && ytChannel.NewItemCount == 0 {
+			continue
+		}
+		if len(playlistItems) > 0 {
+			setLatestVideo(&ytChannel, playlistItems[0])
+			videoIDs = append(videoIDs, ytChannel.LatestVideoID)
+		}
+		response = append(response, ytChannel)
+	}
+
+	if len(response) == 0 {
+		slog.Info("no new video published by the channels followed by the user",
+			logging.FuncNameAttr(funcName), logging.UserAttr(username))
+		return response
+	}
+	return detailYTChannels(ctx, svc, response, videoIDs, failed, rules, availability, username)
+}
+
+// add the details of the given videos to the channels having them as latest video, calling the YouTube videos API:
+// duration, type, description, thumbnails, statistics, tags, language, captions and privacy status. The videos
+// returned by the API are returned by id, the ones missing aren't available
+func addVideosDetails(ctx context.Context, svc clients.YoutubeClientInterface, response []YTChannel,
+	videoIDs []string, username string) (map[string]*youtube.Video, error) {
+	videos, err := getVideos(ctx, svc, videoIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i, ytChannel := range response {
+		if video, ok := videos[ytChannel.LatestVideoID]; ok {
+			addVideoDetails(&response[i], video, username)
+		}
+	}
+	return videos, nil
+}
+
+// return the given videos by id calling the YouTube videos API, the unavailable ones are left out
+func getVideos(ctx context.Context, svc clients.YoutubeClientInterface,
+	videoIDs []string) (map[string]*youtube.Video, error) {
+	videos := make(map[string]*youtube.Video, len(videoIDs))
 	maxItems := 50 // YouTube API limit
 	for i := 0; i < len(videoIDs); i += maxItems {
 		end := i + maxItems
@@ -149,31 +520,133 @@ func checkYoutube(svc clients.YoutubeClientInterface, filtered bool, username st
 			end = len(videoIDs)
 		}
 		videoIDsChunk := videoIDs[i:end]
-		err = svc.GetVideos(ctx, videoIDsChunk, func(videos *youtube.VideoListResponse) error {
-			// add video duration to each response item
-			for _, item := range videos.Items {
-				dur := item.ContentDetails.Duration
-				if dur != "" {
-					for i, ytChannel := range response {
-						if ytChannel.LatestVideoID == item.Id {
-							formattedDur, err := datetime.FormatISO8601Duration(dur, username)
-							if err != nil {
-								slog.Warn(fmt.Sprintf("error formatting video datetime: %s", err.Error()),
-									logging.FuncNameAttr(funcName), logging.UserAttr(username))
-								continue
-							}
-							response[i].LatestVideoDuration = formattedDur
-							break
-						}
-					}
-				}
+		err := svc.GetVideos(ctx, videoIDsChunk, func(response *youtube.VideoListResponse) error {
+			for _, item := range response.Items {
+				videos[item.Id] = item
 			}
 			return nil
 		})
 		if err != nil {
-			slog.Error(fmt.Sprintf("error retrieving videos: %s",
-				err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(username))
-			return response
+			return nil, err
+		}
+	}
+	return videos, nil
+}
+
+// resolve the category names of the latest videos, the videos are shown without them on failure
+func addVideoCategories(ctx context.Context, svc clients.YoutubeClientInterface, response []YTChannel,
+	username string) {
+	const funcName = "addVideoCategories"
+	categoryIDs := make([]string, 0)
+	for _, ytChannel := range response {
+		if ytChannel.LatestVideoCategoryID != "" {
+			categoryIDs = append(categoryIDs, ytChannel.LatestVideoCategoryID)
+		}
+	}
+	if len(categoryIDs) == 0 {
+		return
+	}
+	categories, err := videoCategories.resolve(ctx, svc, categoryIDs)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("error retrieving video categories: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(username))
+	}
+	for i, ytChannel := range response {
+		response[i].LatestVideoCategory = categories[ytChannel.LatestVideoCategoryID]
+	}
+}
+
+// add the details of a video returned by the YouTube videos API to the channel having it as latest video
+func addVideoDetails(ytChannel *YTChannel, video *youtube.Video, username string) {
+	const funcName = "addVideoDetails"
+	ytChannel.LatestVideoType = videoType(video)
+	if video.ContentDetails != nil {
+		if dur := video.ContentDetails.Duration; dur != "" {
+			if duration, err := datetime.ParseISO8601Duration(dur); err == nil {
+				ytChannel.LatestVideoDurationSeconds = int64(duration.Seconds())
+			}
+			formattedDur, err := datetime.FormatISO8601Duration(dur, username)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("error formatting video datetime: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(username))
+			}
+			ytChannel.LatestVideoDuration = formattedDur
+		}
+		ytChannel.LatestVideoCaptions = video.ContentDetails.Caption == "true"
+	}
+	if video.Snippet != nil {
+		if video.Snippet.Description != "" {
+			ytChannel.LatestVideoDescription = video.Snippet.Description
+		}
+		ytChannel.LatestVideoThumbnails = videoThumbnails(video.Snippet.Thumbnails)
+		ytChannel.LatestVideoTags = video.Snippet.Tags
+		ytChannel.LatestVideoCategoryID = video.Snippet.CategoryId
+		ytChannel.LatestVideoLanguage = video.Snippet.DefaultLanguage
+		if ytChannel.LatestVideoLanguage == "" {
+			ytChannel.LatestVideoLanguage = video.Snippet.DefaultAudioLanguage
+		}
+	}
+	if video.Statistics != nil {
+		ytChannel.LatestVideoViewCount = video.Statistics.ViewCount
+		ytChannel.LatestVideoLikeCount = video.Statistics.LikeCount
+		ytChannel.LatestVideoCommentCount = video.Statistics.CommentCount
+	}
+	if video.Status != nil {
+		ytChannel.LatestVideoPrivacyStatus = video.Status.PrivacyStatus
+	}
+}
+
+// return the path to go back to after a form is sent, the fallback one when not a path of this server
+func returnPath(returnTo, fallback string) string {
+	if !auth.LocalPath(returnTo) {
+		return fallback
+	}
+	return returnTo
+}
+
+// return the type of a video: live streams and premieres, upcoming or already started, shorts and regular videos
+func videoType(video *youtube.Video) string {
+	if video.Snippet != nil {
+		switch video.Snippet.LiveBroadcastContent {
+		case "upcoming":
+			return UpcomingVideoType
+		case "live":
+			return LiveVideoType
+		}
+	}
+	if video.LiveStreamingDetails != nil {
+		return LiveVideoType
+	}
+	if video.ContentDetails != nil {
+		duration, err := datetime.ParseISO8601Duration(video.ContentDetails.Duration)
+		if err == nil && duration > 0 && duration <= maxShortDuration {
+			return ShortVideoType
+		}
+	}
+	return RegularVideoType
+}
+
+// set the given account as the source of each channel
+func tagSourceAccount(ytChannels []YTChannel, account *auth.TokenInfo) []YTChannel {
+	for i := range ytChannels {
+		ytChannels[i].SourceAccounts = []SourceAccount{{AccountID: account.UserId, Name: account.Username}}
+	}
+	return ytChannels
+}
+
+// merge the channels of several accounts, de-duplicating the ones subscribed by more than an account
+func mergeYTChannels(feeds ...[]YTChannel) []YTChannel {
+	response := make([]YTChannel, 0)
+	indexByChannelID := make(map[string]int)
+	for _, feed := range feeds {
+		for _, ytChannel := range feed {
+			if i, ok := indexByChannelID[ytChannel.ChannelID]; ok {
+				response[i].SourceAccounts = append(response[i].SourceAccounts, ytChannel.SourceAccounts...)
+				response[i].NewItemCount = max(response[i].NewItemCount, ytChannel.NewItemCount)
+				continue
+			}
+			indexByChannelID[ytChannel.ChannelID] = len(response)
+			response = append(response, ytChannel)
 		}
 	}
 
@@ -196,25 +669,22 @@ func processYouTubeChannel(svc clients.YoutubeClientInterface, item *youtube.Sub
 		ChannelID: channelID,
 		URL:       fmt.Sprintf("%s/channel/%s/videos", youTubeBasepath, channelID),
 	}
-
-	// the playlist ID can be obtained by changing the second letter of the channel ID
-	playlistIDRunes := []rune(channelID)
-	playlistIDRunes[1] = 'U'
-	playlistID := string(playlistIDRunes)
+	if item.ContentDetails != nil {
+		responseItem.NewItemCount = item.ContentDetails.NewItemCount
+	}
 
 	// get latest video info from the first playlist item
-	playlistItem, err := svc.GetLatestVideoFromPlaylist(playlistID)
+	playlistItem, err := svc.GetLatestVideoFromPlaylist(uploadsPlaylistID(channelID))
 	if err != nil {
 		slog.Error(fmt.Sprintf("error retrieving latest YouTube video from playlist: %s", err.Error()),
 			logging.FuncNameAttr(funcName), logging.UserAttr(username))
 		return responseItem, err
 	}
 	if playlistItem != nil {
-		responseItem.LatestVideoURL = fmt.Sprintf("%s/watch?v=%s", youTubeBasepath,
-			playlistItem.Snippet.ResourceId.VideoId)
-		responseItem.LatestVideoTitle = playlistItem.Snippet.Title
-		responseItem.LatestVideoPublishedAt = playlistItem.Snippet.PublishedAt
-		responseItem.LatestVideoID = playlistItem.Snippet.ResourceId.VideoId
+		setLatestVideo(&responseItem, playlistItem)
+		if responseItem.Title == "" {
+			responseItem.Title = playlistItem.Snippet.ChannelTitle
+		}
 		slog.Debug(fmt.Sprintf("found latest video for channel %s", channelTitle),
 			logging.FuncNameAttr(funcName), logging.UserAttr(username))
 	}
@@ -222,8 +692,29 @@ func processYouTubeChannel(svc clients.YoutubeClientInterface, item *youtube.Sub
 	return responseItem, nil
 }
 
-// MarkAsViewed visits subscription channels in the background to clear the notification of new videos
-func MarkAsViewed(oauth2C auth.Oauth2Config, serverBasepath string) http.HandlerFunc {
+// return the id of the playlist of the uploads of a channel, obtained by changing the second letter of its id
+func uploadsPlaylistID(channelID string) string {
+	playlistIDRunes := []rune(channelID)
+	playlistIDRunes[1] = 'U'
+	return string(playlistIDRunes)
+}
+
+// set the latest video of a channel from an item of its uploads playlist
+func setLatestVideo(ytChannel *YTChannel, playlistItem *youtube.PlaylistItem) {
+	ytChannel.LatestVideoID = playlistItem.Snippet.ResourceId.VideoId
+	ytChannel.LatestVideoURL = fmt.Sprintf("%s/watch?v=%s", youTubeBasepath, ytChannel.LatestVideoID)
+	ytChannel.LatestVideoTitle = playlistItem.Snippet.Title
+	ytChannel.LatestVideoDescription = playlistItem.Snippet.Description
+	ytChannel.LatestVideoPublishedAt = playlistItem.Snippet.PublishedAt
+	if playlistItem.Status != nil {
+		ytChannel.LatestVideoPrivacyStatus = playlistItem.Status.PrivacyStatus
+	}
+}
+
+// MarkAsViewed visits subscription channels in the background to clear the notification of new videos, either
+// the given ones or the ones of a channel group
+func MarkAsViewed(oauth2C auth.Oauth2Config, storage database.StorageInterface,
+	serverBasepath string) http.HandlerFunc {
 	const funcName = "MarkAsViewed"
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Body == nil {
@@ -242,7 +733,7 @@ func MarkAsViewed(oauth2C auth.Oauth2Config, serverBasepath string) http.Handler
 			return
 		}
 
-		if len(req.ChannelsID) == 0 {
+		if len(req.ChannelsID) == 0 && req.GroupID == 0 {
 			slog.Info("no channels ID found in request body", logging.FuncNameAttr(funcName))
 			return
 		}
@@ -256,41 +747,74 @@ func MarkAsViewed(oauth2C auth.Oauth2Config, serverBasepath string) http.Handler
 			return
 		}
 
-		// create HTTP client
-		client := oauth2C.CreateHTTPClient(r.Context(), tokenInfo.Token)
-		if client == nil {
-			slog.Warn("http client not initialized, redirecting user to login page",
-				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
-			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
-			return
+		// add the channels of the group
+		if req.GroupID != 0 {
+			groups, err := storage.GetChannelGroups(r.Context(), tokenInfo.AppUserId)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve channel groups: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			i := slices.IndexFunc(groups, func(group database.ChannelGroup) bool { return group.Id == req.GroupID })
+			if i < 0 {
+				err = fmt.Errorf("channel group %d not found", req.GroupID)
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, channelID := range groups[i].ChannelIDs {
+				if !slices.Contains(req.ChannelsID, channelID) {
+					req.ChannelsID = append(req.ChannelsID, channelID)
+				}
+			}
+		}
+
+		// visit each channel with the accounts subscribed to it
+		visits := make([]channelVisit, 0, len(req.ChannelsID))
+		for account, channelIDs := range channelsByAccount(r, storage, tokenInfo, req.ChannelsID, funcName) {
+			// create HTTP client
+			client := oauth2C.CreateHTTPClient(r.Context(), account.Token)
+			if client == nil {
+				slog.Warn("http client not initialized, redirecting user to login page",
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+				return
+			}
+			for _, channelID := range channelIDs {
+				visits = append(visits, channelVisit{client: client,
+					url: fmt.Sprintf("%s/channel/%s/videos", youTubeBasepath, channelID)})
+			}
 		}
 
 		// limit max concurrent goroutines that will perform the http call
 		maxConcurrentCalls := 5
 
-		// enqueue URLs to visit in a buffered channel
-		ch := make(chan string, len(req.ChannelsID))
-		errorsCh := make(chan error)
+		// enqueue URLs to visit in a buffered channel, the errors one is buffered too so that the visits left
+		// don't block once the first error is returned
+		ch := make(chan channelVisit, len(visits))
+		errorsCh := make(chan error, len(visits))
 		wg := &sync.WaitGroup{}
-		for _, channelID := range req.ChannelsID {
-			url := fmt.Sprintf("%s/channel/%s/videos", youTubeBasepath, channelID)
+		for _, visit := range visits {
 			wg.Add(1)
-			ch <- url
+			ch <- visit
 		}
 
 		// visit each url of the YouTube channels to mark them as viewed
 		for i := 0; i < maxConcurrentCalls; i++ {
 			go func() {
-				for url := range ch {
+				for visit := range ch {
 					func() {
 						defer wg.Done()
-						res, err := client.Get(url)
+						url := visit.url
+						res, err := visit.client.Get(url)
 						if err != nil {
 							slog.Error(fmt.Sprintf("markAsViewed get request failed, error: %s", err.Error()),
 								logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
 							errorsCh <- err
 							return
 						}
+						_ = res.Body.Close()
 
 						if res.StatusCode != http.StatusOK {
 							err = fmt.Errorf("call to youtube returned status: %d", res.StatusCode)
@@ -321,3 +845,47 @@ func MarkAsViewed(oauth2C auth.Oauth2Config, serverBasepath string) http.Handler
 		}
 	}
 }
+
+// a channel page to visit with the HTTP client of an account subscribed to the channel
+type channelVisit struct {
+	client *http.Client
+	url    string
+}
+
+// group the channels by the linked accounts subscribed to them, as told by the latest subscriptions snapshot of
+// each account. The channels no account is known to be subscribed to, followed ones included, are left to the
+// session account
+func channelsByAccount(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	channelIDs []string, funcName string) map[*auth.TokenInfo][]string {
+	accounts := contextAccounts(r, tokenInfo)
+	if len(accounts) == 0 {
+		accounts = []*auth.TokenInfo{tokenInfo}
+	}
+
+	byAccount := make(map[*auth.TokenInfo][]string)
+	assigned := make(map[string]bool, len(channelIDs))
+	for _, account := range accounts {
+		subscribed, err := storage.GetSubscribedChannels(r.Context(), tokenInfo.AppUserId, account.UserId)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to retrieve the subscriptions of account %s: %s", account.Username,
+				err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			continue
+		}
+		subscribedIDs := make(map[string]bool, len(subscribed))
+		for _, channel := range subscribed {
+			subscribedIDs[channel.ChannelID] = true
+		}
+		for _, channelID := range channelIDs {
+			if subscribedIDs[channelID] {
+				byAccount[account] = append(byAccount[account], channelID)
+				assigned[channelID] = true
+			}
+		}
+	}
+	for _, channelID := range channelIDs {
+		if !assigned[channelID] {
+			byAccount[accounts[0]] = append(byAccount[accounts[0]], channelID)
+		}
+	}
+	return byAccount
+}
@@ -4,16 +4,20 @@ import (
 	"bytes"
 	"checkYoutube/auth"
 	"checkYoutube/clients"
+	"checkYoutube/database"
 	"checkYoutube/test"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/youtube/v3"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 )
 
 // mocks
@@ -21,10 +25,19 @@ type youtubeClientMock struct {
 	getAndProcessSubscriptionsStub func(context.Context, func(*youtube.SubscriptionListResponse) error) error
 	getLatestVideoFromPlaylistStub func(string) (*youtube.PlaylistItem, error)
 	getVideosStub                  func(context.Context, []string, func(*youtube.VideoListResponse) error) error
+	getVideoCategoriesStub         func(context.Context, []string) ([]*youtube.VideoCategory, error)
+	getPlaylistVideosStub          func(context.Context, string, int64) ([]*youtube.PlaylistItem, error)
+	getPlaylistVideosSinceStub     func(context.Context, string, time.Time, int64) ([]*youtube.PlaylistItem, error)
+	getChannelsStub                func(context.Context, []string) ([]*youtube.Channel, error)
+	subscribeStub                  func(context.Context, string) (*youtube.Subscription, error)
+	unsubscribeStub                func(context.Context, string) error
 }
 type youtubeClientFactoryMock struct {
 	newClientStub func(oauth2.TokenSource) (clients.YoutubeClientInterface, error)
 }
+type webhookClientMock struct {
+	postStub func(context.Context, string, any) error
+}
 
 func (y youtubeClientMock) GetAndProcessSubscriptions(ctx context.Context,
 	processFunction func(*youtube.SubscriptionListResponse) error) error {
@@ -33,13 +46,37 @@ func (y youtubeClientMock) GetAndProcessSubscriptions(ctx context.Context,
 func (y youtubeClientMock) GetLatestVideoFromPlaylist(playlistID string) (*youtube.PlaylistItem, error) {
 	return y.getLatestVideoFromPlaylistStub(playlistID)
 }
+func (y youtubeClientMock) GetPlaylistVideos(ctx context.Context, playlistID string,
+	maxResults int64) ([]*youtube.PlaylistItem, error) {
+	return y.getPlaylistVideosStub(ctx, playlistID, maxResults)
+}
+func (y youtubeClientMock) GetPlaylistVideosSince(ctx context.Context, playlistID string, since time.Time,
+	maxResults int64) ([]*youtube.PlaylistItem, error) {
+	return y.getPlaylistVideosSinceStub(ctx, playlistID, since, maxResults)
+}
 func (y youtubeClientMock) GetVideos(ctx context.Context, videoIDs []string,
 	processFunction func(*youtube.VideoListResponse) error) error {
 	return y.getVideosStub(ctx, videoIDs, processFunction)
 }
+func (y youtubeClientMock) GetVideoCategories(ctx context.Context,
+	categoryIDs []string) ([]*youtube.VideoCategory, error) {
+	return y.getVideoCategoriesStub(ctx, categoryIDs)
+}
+func (y youtubeClientMock) GetChannels(ctx context.Context, channelIDs []string) ([]*youtube.Channel, error) {
+	return y.getChannelsStub(ctx, channelIDs)
+}
+func (y youtubeClientMock) Subscribe(ctx context.Context, channelID string) (*youtube.Subscription, error) {
+	return y.subscribeStub(ctx, channelID)
+}
+func (y youtubeClientMock) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	return y.unsubscribeStub(ctx, subscriptionID)
+}
 func (yf *youtubeClientFactoryMock) NewClient(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
 	return yf.newClientStub(ts)
 }
+func (w webhookClientMock) Post(ctx context.Context, url string, payload any) error {
+	return w.postStub(ctx, url, payload)
+}
 
 func TestGetYoutubeChannelsVideos(t *testing.T) {
 	// mocks
@@ -61,6 +98,26 @@ func TestGetYoutubeChannelsVideos(t *testing.T) {
 			}, nil
 		},
 	}
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.Preferences{}, fmt.Errorf("test error")
+		},
+		GetChannelGroupsStub: func(_ context.Context, userId int64) ([]database.ChannelGroup, error) {
+			return nil, fmt.Errorf("test error")
+		},
+		GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+			return nil, fmt.Errorf("test error")
+		},
+		GetFollowedChannelsStub: func(_ context.Context, userId int64) ([]database.FollowedChannel, error) {
+			return nil, fmt.Errorf("test error")
+		},
+		GetVideoRulesStub: func(_ context.Context, userId int64) ([]database.VideoRule, error) {
+			return nil, fmt.Errorf("test error")
+		},
+		CountUnreadAlertMatchesStub: func(_ context.Context, userId int64) (int, error) {
+			return 0, fmt.Errorf("test error")
+		},
+	}
 
 	type args struct {
 		oauth2C        auth.Oauth2Config
@@ -115,7 +172,7 @@ func TestGetYoutubeChannelsVideos(t *testing.T) {
 			} else {
 				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{Token: &oauth2.Token{}}))
 			}
-			handlerFunction := GetYoutubeChannelsVideos(tt.args.oauth2C, tt.args.ytcf,
+			handlerFunction := GetYoutubeChannelsVideos(tt.args.oauth2C, tt.args.ytcf, storage,
 				tt.args.serverBasepath, tt.args.htmlTemplate)
 			handlerFunction(recorder, req)
 			if recorder.Code != tt.want {
@@ -128,8 +185,16 @@ func TestGetYoutubeChannelsVideos(t *testing.T) {
 func TestMarkAsViewed(t *testing.T) {
 	// mocks
 	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
-	createMockRequest := func(emptyArray bool, channelID string) *http.Request {
-		var reqBody callUrlRequest
+	storage := &test.StorageMock{
+		GetChannelGroupsStub: func(_ context.Context, userId int64) ([]database.ChannelGroup, error) {
+			return []database.ChannelGroup{{Id: 1, Name: "Music", ChannelIDs: []string{}}}, nil
+		},
+		GetSubscribedChannelsStub: func(context.Context, int64, string) ([]database.SubscribedChannel, error) {
+			return []database.SubscribedChannel{}, nil
+		},
+	}
+	createMockRequest := func(emptyArray bool, channelID string, groupID int64) *http.Request {
+		reqBody := callUrlRequest{GroupID: groupID}
 		if !emptyArray {
 			reqBody.ChannelsID = []string{channelID}
 		}
@@ -156,7 +221,9 @@ func TestMarkAsViewed(t *testing.T) {
 		failureCaseMissingReqBody = "failure case - empty request body"
 		failureCaseBadRequest     = "failure case - bad request"
 		tokenNotFound             = "failure case - token not found in context"
+		groupNotFound             = "failure case - channel group not found"
 		okEmptyRequest            = "success case - empty array in request"
+		okEmptyGroup              = "success case - empty channel group"
 	)
 	type args struct {
 		oauth2C        auth.Oauth2Config
@@ -175,7 +242,7 @@ func TestMarkAsViewed(t *testing.T) {
 				oauth2C:        oauth2C,
 				serverBasepath: "http://localhost:8900",
 				recorder:       httptest.NewRecorder(),
-				request:        createMockRequest(false, "channelIdTest"),
+				request:        createMockRequest(false, "channelIdTest", 0),
 			},
 			want: http.StatusInternalServerError,
 		},
@@ -203,7 +270,7 @@ func TestMarkAsViewed(t *testing.T) {
 				oauth2C:        oauth2C,
 				serverBasepath: "http://localhost:8900",
 				recorder:       httptest.NewRecorder(),
-				request:        createMockRequest(false, "channelIdTest"),
+				request:        createMockRequest(false, "channelIdTest", 0),
 			},
 			want: http.StatusTemporaryRedirect,
 		},
@@ -213,16 +280,36 @@ func TestMarkAsViewed(t *testing.T) {
 				oauth2C:        oauth2C,
 				serverBasepath: "http://localhost:8900",
 				recorder:       httptest.NewRecorder(),
-				request:        createMockRequest(true, ""),
+				request:        createMockRequest(true, "", 0),
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: groupNotFound,
+			args: args{
+				oauth2C:        oauth2C,
+				serverBasepath: "http://localhost:8900",
+				recorder:       httptest.NewRecorder(),
+				request:        createMockRequest(true, "", 2),
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: okEmptyGroup,
+			args: args{
+				oauth2C:        oauth2C,
+				serverBasepath: "http://localhost:8900",
+				recorder:       httptest.NewRecorder(),
+				request:        createMockRequest(true, "", 1),
 			},
 			want: http.StatusOK,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handlerFunction := MarkAsViewed(tt.args.oauth2C, tt.args.serverBasepath)
+			handlerFunction := MarkAsViewed(tt.args.oauth2C, storage, tt.args.serverBasepath)
 			switch tt.name {
-			case failureCaseBadStatus, okEmptyRequest:
+			case failureCaseBadStatus, okEmptyRequest, groupNotFound, okEmptyGroup:
 				req := tt.args.request.WithContext(
 					addTokenInfoToContext(tt.args.request.Context(), &auth.TokenInfo{Token: &oauth2.Token{}}))
 				handlerFunction(tt.args.recorder, req)
@@ -259,6 +346,55 @@ func TestMarkAsViewed(t *testing.T) {
 	}
 }
 
+func Test_channelsByAccount(t *testing.T) {
+	session := &auth.TokenInfo{UserId: "account1", Username: "user1", AppUserId: 1}
+	work := &auth.TokenInfo{UserId: "account2", Username: "user2", AppUserId: 1}
+	snapshots := map[string][]database.SubscribedChannel{
+		"account1": {{ChannelID: "channel1"}, {ChannelID: "channel3"}},
+		"account2": {{ChannelID: "channel2"}, {ChannelID: "channel3"}},
+	}
+	tests := []struct {
+		name        string
+		accounts    []*auth.TokenInfo
+		snapshotErr error
+		want        map[*auth.TokenInfo][]string
+	}{
+		{
+			name:     "success case - channels visited with the accounts subscribed to them",
+			accounts: []*auth.TokenInfo{session, work},
+			want: map[*auth.TokenInfo][]string{
+				session: {"channel1", "channel3", "followed"},
+				work:    {"channel2", "channel3"},
+			},
+		},
+		{
+			name:        "success case - session account when the snapshots can't be retrieved",
+			accounts:    []*auth.TokenInfo{session, work},
+			snapshotErr: errors.New("database error"),
+			want:        map[*auth.TokenInfo][]string{session: {"channel1", "channel2", "channel3", "followed"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &test.StorageMock{
+				GetSubscribedChannelsStub: func(_ context.Context, _ int64,
+					accountId string) ([]database.SubscribedChannel, error) {
+					return snapshots[accountId], tt.snapshotErr
+				},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req = req.WithContext(context.WithValue(addTokenInfoToContext(req.Context(), session),
+				auth.AccountsCtxKey{}, tt.accounts))
+
+			got := channelsByAccount(req, storage, session, []string{"channel1", "channel2", "channel3", "followed"},
+				"test")
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("channelsByAccount() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func Test_checkYoutube(t *testing.T) {
 	const (
 		channelUrl = "https://www.youtube.com/channel/%s/videos"
@@ -307,19 +443,144 @@ func Test_checkYoutube(t *testing.T) {
 			},
 		},
 	}
+	videosOutput := &youtube.VideoListResponse{
+		Items: []*youtube.Video{{Id: "videoidtest"}},
+	}
+	privateItem := &youtube.PlaylistItem{
+		Snippet: &youtube.PlaylistItemSnippet{
+			Title: "Private video",
+			ResourceId: &youtube.ResourceId{
+				VideoId: "privateidtest",
+			},
+		},
+	}
+	tutorialItem := &youtube.PlaylistItem{
+		Snippet: &youtube.PlaylistItemSnippet{
+			Title: "Go tutorial",
+			ResourceId: &youtube.ResourceId{
+				VideoId: "tutorialidtest",
+			},
+		},
+	}
+	subscriptions := make([]subscriptionRow, 0, len(subsInput))
+	for _, item := range subsInput {
+		subscriptions = append(subscriptions, subscriptionRow{ChannelID: item.Snippet.ResourceId.ChannelId,
+			Title: item.Snippet.Title})
+	}
 
 	type args struct {
 		svc      clients.YoutubeClientInterface
 		filtered bool
 		username string
+		rules    channelRules
+		options  availabilityOptions
 	}
 	tests := []struct {
-		name string
-		args args
-		want []YTChannel
+		name              string
+		args              args
+		want              []YTChannel
+		wantSubscriptions []subscriptionRow
 	}{
 		{
-			name: "success case - filtered",
+			name:              "success case - channel rules",
+			wantSubscriptions: subscriptions,
+			args: args{
+				svc: &youtubeClientMock{
+					getAndProcessSubscriptionsStub: func(ctx context.Context,
+						processFunction func(*youtube.SubscriptionListResponse) error) error {
+						_ = processFunction(&youtube.SubscriptionListResponse{
+							Items: subsInput,
+						})
+						return nil
+					},
+					getLatestVideoFromPlaylistStub: func(playlistID string) (*youtube.PlaylistItem, error) {
+						// the muted channel must be skipped without calling the YouTube API
+						if playlistID == "cUannelidtest-1" {
+							return nil, fmt.Errorf("test error")
+						}
+						return playlistItemOuput, nil
+					},
+					getVideosStub: func(ctx context.Context, videoIDs []string,
+						processFunction func(*youtube.VideoListResponse) error) error {
+						_ = processFunction(videosOutput)
+						return nil
+					},
+				},
+				filtered: false,
+				rules: newChannelRules([]database.ChannelRule{
+					{ChannelID: "channelidtest-1", Kind: database.MuteRule},
+					{ChannelID: "channelidtest-2", Kind: database.TitleMatchRule, TitlePattern: "TITLE"},
+					{ChannelID: "channelidtest-3", Kind: database.TitleMatchRule, TitlePattern: "tutorial"},
+				}, time.Now()),
+			},
+			want: []YTChannel{
+				{
+					Title:            subsInput[1].Snippet.Title,
+					ChannelID:        subsInput[1].Snippet.ResourceId.ChannelId,
+					NewItemCount:     subsInput[1].ContentDetails.NewItemCount,
+					URL:              fmt.Sprintf(channelUrl, subsInput[1].Snippet.ResourceId.ChannelId),
+					LatestVideoID:    playlistItemOuput.Snippet.ResourceId.VideoId,
+					LatestVideoURL:   fmt.Sprintf(videoUrl, playlistItemOuput.Snippet.ResourceId.VideoId),
+					LatestVideoTitle: playlistItemOuput.Snippet.Title,
+					LatestVideoType:  RegularVideoType,
+				},
+			},
+		},
+		{
+			// the title match rules apply to the watchable videos replacing the private latest ones
+			name:              "success case - title match rules on skipped private videos",
+			wantSubscriptions: subscriptions,
+			args: args{
+				svc: &youtubeClientMock{
+					getAndProcessSubscriptionsStub: func(ctx context.Context,
+						processFunction func(*youtube.SubscriptionListResponse) error) error {
+						_ = processFunction(&youtube.SubscriptionListResponse{
+							Items: subsInput,
+						})
+						return nil
+					},
+					getLatestVideoFromPlaylistStub: func(string) (*youtube.PlaylistItem, error) {
+						return privateItem, nil
+					},
+					getPlaylistVideosStub: func(_ context.Context, playlistID string,
+						_ int64) ([]*youtube.PlaylistItem, error) {
+						if playlistID == "cUannelidtest-1" {
+							return []*youtube.PlaylistItem{privateItem, tutorialItem}, nil
+						}
+						return []*youtube.PlaylistItem{privateItem, playlistItemOuput}, nil
+					},
+					getVideosStub: func(ctx context.Context, videoIDs []string,
+						processFunction func(*youtube.VideoListResponse) error) error {
+						return processFunction(&youtube.VideoListResponse{
+							Items: []*youtube.Video{{Id: "videoidtest"}, {Id: "tutorialidtest"}},
+						})
+					},
+				},
+				filtered: true,
+				rules: newChannelRules([]database.ChannelRule{
+					{ChannelID: "channelidtest-1", Kind: database.TitleMatchRule, TitlePattern: "tutorial"},
+					{ChannelID: "channelidtest-2", Kind: database.TitleMatchRule, TitlePattern: "tutorial"},
+				}, time.Now()),
+				options: availabilityOptions{SkipUnavailable: true},
+			},
+			want: []YTChannel{
+				{
+					Title:            subsInput[0].Snippet.Title,
+					ChannelID:        subsInput[0].Snippet.ResourceId.ChannelId,
+					NewItemCount:     subsInput[0].ContentDetails.NewItemCount,
+					URL:              fmt.Sprintf(channelUrl, subsInput[0].Snippet.ResourceId.ChannelId),
+					LatestVideoID:    tutorialItem.Snippet.ResourceId.VideoId,
+					LatestVideoURL:   fmt.Sprintf(videoUrl, tutorialItem.Snippet.ResourceId.VideoId),
+					LatestVideoTitle: tutorialItem.Snippet.Title,
+					LatestVideoType:  RegularVideoType,
+					SkippedVideos: []SkippedVideo{{VideoID: privateItem.Snippet.ResourceId.VideoId,
+						Title: privateItem.Snippet.Title, Reason: PrivateVideo}},
+				},
+			},
+		},
+		{
+			name:              "success case - filtered",
+			wantSubscriptions: subscriptions,
 			args: args{
 				svc: &youtubeClientMock{
 					getAndProcessSubscriptionsStub: func(ctx context.Context,
@@ -334,7 +595,7 @@ func Test_checkYoutube(t *testing.T) {
 					},
 					getVideosStub: func(ctx context.Context, videoIDs []string,
 						processFunction func(*youtube.VideoListResponse) error) error {
-						_ = processFunction(&youtube.VideoListResponse{})
+						_ = processFunction(videosOutput)
 						return nil
 					},
 				},
@@ -344,23 +605,28 @@ func Test_checkYoutube(t *testing.T) {
 				{
 					Title:            subsInput[0].Snippet.Title,
 					ChannelID:        subsInput[0].Snippet.ResourceId.ChannelId,
+					NewItemCount:     subsInput[0].ContentDetails.NewItemCount,
 					URL:              fmt.Sprintf(channelUrl, subsInput[0].Snippet.ResourceId.ChannelId),
 					LatestVideoID:    playlistItemOuput.Snippet.ResourceId.VideoId,
 					LatestVideoURL:   fmt.Sprintf(videoUrl, playlistItemOuput.Snippet.ResourceId.VideoId),
 					LatestVideoTitle: playlistItemOuput.Snippet.Title,
+					LatestVideoType:  RegularVideoType,
 				},
 				{
 					Title:            subsInput[1].Snippet.Title,
 					ChannelID:        subsInput[1].Snippet.ResourceId.ChannelId,
+					NewItemCount:     subsInput[1].ContentDetails.NewItemCount,
 					URL:              fmt.Sprintf(channelUrl, subsInput[1].Snippet.ResourceId.ChannelId),
 					LatestVideoID:    playlistItemOuput.Snippet.ResourceId.VideoId,
 					LatestVideoURL:   fmt.Sprintf(videoUrl, playlistItemOuput.Snippet.ResourceId.VideoId),
 					LatestVideoTitle: playlistItemOuput.Snippet.Title,
+					LatestVideoType:  RegularVideoType,
 				},
 			},
 		},
 		{
-			name: "success case - all",
+			name:              "success case - all",
+			wantSubscriptions: subscriptions,
 			args: args{
 				svc: &youtubeClientMock{
 					getAndProcessSubscriptionsStub: func(ctx context.Context,
@@ -375,7 +641,7 @@ func Test_checkYoutube(t *testing.T) {
 					},
 					getVideosStub: func(ctx context.Context, videoIDs []string,
 						processFunction func(*youtube.VideoListResponse) error) error {
-						_ = processFunction(&youtube.VideoListResponse{})
+						_ = processFunction(videosOutput)
 						return nil
 					},
 				},
@@ -385,31 +651,38 @@ func Test_checkYoutube(t *testing.T) {
 				{
 					Title:            subsInput[0].Snippet.Title,
 					ChannelID:        subsInput[0].Snippet.ResourceId.ChannelId,
+					NewItemCount:     subsInput[0].ContentDetails.NewItemCount,
 					URL:              fmt.Sprintf(channelUrl, subsInput[0].Snippet.ResourceId.ChannelId),
 					LatestVideoID:    playlistItemOuput.Snippet.ResourceId.VideoId,
 					LatestVideoURL:   fmt.Sprintf(videoUrl, playlistItemOuput.Snippet.ResourceId.VideoId),
 					LatestVideoTitle: playlistItemOuput.Snippet.Title,
+					LatestVideoType:  RegularVideoType,
 				},
 				{
 					Title:            subsInput[1].Snippet.Title,
 					ChannelID:        subsInput[1].Snippet.ResourceId.ChannelId,
+					NewItemCount:     subsInput[1].ContentDetails.NewItemCount,
 					URL:              fmt.Sprintf(channelUrl, subsInput[1].Snippet.ResourceId.ChannelId),
 					LatestVideoID:    playlistItemOuput.Snippet.ResourceId.VideoId,
 					LatestVideoURL:   fmt.Sprintf(videoUrl, playlistItemOuput.Snippet.ResourceId.VideoId),
 					LatestVideoTitle: playlistItemOuput.Snippet.Title,
+					LatestVideoType:  RegularVideoType,
 				},
 				{
 					Title:            subsInput[2].Snippet.Title,
 					ChannelID:        subsInput[2].Snippet.ResourceId.ChannelId,
+					NewItemCount:     subsInput[2].ContentDetails.NewItemCount,
 					URL:              fmt.Sprintf(channelUrl, subsInput[2].Snippet.ResourceId.ChannelId),
 					LatestVideoID:    playlistItemOuput.Snippet.ResourceId.VideoId,
 					LatestVideoURL:   fmt.Sprintf(videoUrl, playlistItemOuput.Snippet.ResourceId.VideoId),
 					LatestVideoTitle: playlistItemOuput.Snippet.Title,
+					LatestVideoType:  RegularVideoType,
 				},
 			},
 		},
 		{
-			name: "success case - no new videos",
+			name:              "success case - no new videos",
+			wantSubscriptions: subscriptions[2:],
 			args: args{
 				svc: &youtubeClientMock{
 					getAndProcessSubscriptionsStub: func(ctx context.Context,
@@ -443,7 +716,8 @@ func Test_checkYoutube(t *testing.T) {
 			want: make([]YTChannel, 0),
 		},
 		{
-			name: "failure case - processYouTubeChannel error",
+			name:              "failure case - processYouTubeChannel error",
+			wantSubscriptions: subscriptions,
 			args: args{
 				svc: &youtubeClientMock{
 					getAndProcessSubscriptionsStub: func(ctx context.Context,
@@ -458,7 +732,7 @@ func Test_checkYoutube(t *testing.T) {
 					},
 					getVideosStub: func(ctx context.Context, videoIDs []string,
 						processFunction func(*youtube.VideoListResponse) error) error {
-						_ = processFunction(&youtube.VideoListResponse{})
+						_ = processFunction(videosOutput)
 						return nil
 					},
 				},
@@ -466,24 +740,158 @@ func Test_checkYoutube(t *testing.T) {
 			},
 			want: []YTChannel{
 				{
-					Title:     subsInput[0].Snippet.Title,
-					ChannelID: subsInput[0].Snippet.ResourceId.ChannelId,
-					URL:       fmt.Sprintf(channelUrl, subsInput[0].Snippet.ResourceId.ChannelId),
+					Title:        subsInput[0].Snippet.Title,
+					ChannelID:    subsInput[0].Snippet.ResourceId.ChannelId,
+					NewItemCount: subsInput[0].ContentDetails.NewItemCount,
+					URL:          fmt.Sprintf(channelUrl, subsInput[0].Snippet.ResourceId.ChannelId),
 				},
 				{
-					Title:     subsInput[1].Snippet.Title,
-					ChannelID: subsInput[1].Snippet.ResourceId.ChannelId,
-					URL:       fmt.Sprintf(channelUrl, subsInput[1].Snippet.ResourceId.ChannelId),
+					Title:        subsInput[1].Snippet.Title,
+					ChannelID:    subsInput[1].Snippet.ResourceId.ChannelId,
+					NewItemCount: subsInput[1].ContentDetails.NewItemCount,
+					URL:          fmt.Sprintf(channelUrl, subsInput[1].Snippet.ResourceId.ChannelId),
 				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := checkYoutube(tt.args.svc, tt.args.filtered, tt.args.username)
+			got, gotSubscriptions := checkYoutube(tt.args.svc, tt.args.filtered, tt.args.username, tt.args.rules,
+				tt.args.options, nil)
 			if diff := cmp.Diff(got, tt.want); diff != "" {
 				t.Errorf("checkYoutube() - diff: \n%v", diff)
 			}
+			if diff := cmp.Diff(gotSubscriptions, tt.wantSubscriptions); diff != "" {
+				t.Errorf("checkYoutube() subscriptions - diff: \n%v", diff)
+			}
+		})
+	}
+}
+
+func Test_checkFollowedChannels(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	playlistItem := func(videoID string, days int) *youtube.PlaylistItem {
+		return &youtube.PlaylistItem{Snippet: &youtube.PlaylistItemSnippet{Title: videoID,
+			PublishedAt: now.AddDate(0, 0, -days).Format(time.RFC3339),
+			ResourceId:  &youtube.ResourceId{VideoId: videoID}}}
+	}
+	// UCnew uploaded twice in the past week, UCold 10 days ago and the uploads of UCfailing can't be retrieved
+	uploads := map[string][]*youtube.PlaylistItem{
+		"UUnew": {playlistItem("new1", 1), playlistItem("new2", 3), playlistItem("new3", 30)},
+		"UUold": {playlistItem("old1", 10)},
+	}
+	requested := make(map[string]bool)
+	mutex := sync.Mutex{}
+	svc := &youtubeClientMock{
+		getPlaylistVideosSinceStub: func(_ context.Context, playlistID string, since time.Time,
+			_ int64) ([]*youtube.PlaylistItem, error) {
+			mutex.Lock()
+			requested[playlistID] = true
+			mutex.Unlock()
+			if playlistID == "UUfailing" {
+				return nil, fmt.Errorf("test error")
+			}
+			return uploads[playlistID], nil
+		},
+		getVideosStub: func(_ context.Context, videoIDs []string,
+			processFunction func(*youtube.VideoListResponse) error) error {
+			response := &youtube.VideoListResponse{}
+			for _, videoID := range videoIDs {
+				response.Items = append(response.Items, &youtube.Video{Id: videoID})
+			}
+			return processFunction(response)
+		},
+	}
+	followed := []database.FollowedChannel{
+		{ChannelID: "UCnew", ChannelTitle: "New"},
+		{ChannelID: "UCold", ChannelTitle: "Old"},
+		{ChannelID: "UCfailing", ChannelTitle: "Failing"},
+		{ChannelID: "UCsubscribed", ChannelTitle: "Subscribed"},
+		{ChannelID: "UCmuted", ChannelTitle: "Muted"},
+	}
+	subscribed := map[string]bool{"UCsubscribed": true}
+	rules := newChannelRules([]database.ChannelRule{{ChannelID: "UCmuted", Kind: database.MuteRule}}, now)
+	newChannel := YTChannel{Title: "New", ChannelID: "UCnew", URL: "https://www.youtube.com/channel/UCnew/videos",
+		NewItemCount: 2, LatestVideoID: "new1", LatestVideoURL: "https://www.youtube.com/watch?v=new1",
+		LatestVideoTitle: "new1", LatestVideoPublishedAt: "2026-10-17T12:00:00Z", LatestVideoType: RegularVideoType}
+
+	tests := []struct {
+		name     string
+		filtered bool
+		want     []YTChannel
+	}{
+		{
+			name:     "success case - filtered",
+			filtered: true,
+			want:     []YTChannel{newChannel},
+		},
+		{
+			name: "success case - all",
+			want: []YTChannel{
+				{Title: "Failing", ChannelID: "UCfailing", URL: "https://www.youtube.com/channel/UCfailing/videos"},
+				newChannel,
+				{Title: "Old", ChannelID: "UCold", URL: "https://www.youtube.com/channel/UCold/videos",
+					LatestVideoID: "old1", LatestVideoURL: "https://www.youtube.com/watch?v=old1",
+					LatestVideoTitle: "old1", LatestVideoPublishedAt: "2026-10-08T12:00:00Z",
+					LatestVideoType: RegularVideoType},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(requested)
+			got := checkFollowedChannels(svc, followed, subscribed, tt.filtered, "", rules, availabilityOptions{},
+				now)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("checkFollowedChannels() mismatch (-want +got):\n%s", diff)
+			}
+			// the channels subscribed to and the muted ones aren't retrieved
+			if requested["UUsubscribed"] || requested["UUmuted"] {
+				t.Errorf("checkFollowedChannels() retrieved the uploads of %v", requested)
+			}
+		})
+	}
+}
+
+func Test_mergeYTChannels(t *testing.T) {
+	personal := SourceAccount{AccountID: "1", Name: "personal"}
+	work := SourceAccount{AccountID: "2", Name: "work"}
+
+	tests := []struct {
+		name  string
+		feeds [][]YTChannel
+		want  []YTChannel
+	}{
+		{
+			name: "success case - channels de-duplicated and sorted by title",
+			feeds: [][]YTChannel{
+				{
+					{Title: "b-channel", ChannelID: "b", SourceAccounts: []SourceAccount{personal}},
+					{Title: "C-channel", ChannelID: "c", SourceAccounts: []SourceAccount{personal}},
+				},
+				{
+					{Title: "a-channel", ChannelID: "a", SourceAccounts: []SourceAccount{work}},
+					{Title: "b-channel", ChannelID: "b", SourceAccounts: []SourceAccount{work}},
+				},
+			},
+			want: []YTChannel{
+				{Title: "a-channel", ChannelID: "a", SourceAccounts: []SourceAccount{work}},
+				{Title: "b-channel", ChannelID: "b", SourceAccounts: []SourceAccount{personal, work}},
+				{Title: "C-channel", ChannelID: "c", SourceAccounts: []SourceAccount{personal}},
+			},
+		},
+		{
+			name:  "success case - no feeds",
+			feeds: nil,
+			want:  make([]YTChannel, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeYTChannels(tt.feeds...)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("mergeYTChannels() - diff: \n%v", diff)
+			}
 		})
 	}
 }
@@ -571,3 +979,83 @@ func Test_processYouTubeChannel(t *testing.T) {
 func addTokenInfoToContext(ctx context.Context, value *auth.TokenInfo) context.Context {
 	return context.WithValue(ctx, auth.TokenCtxKey{}, value)
 }
+
+func Test_videoType(t *testing.T) {
+	tests := []struct {
+		name  string
+		video *youtube.Video
+		want  string
+	}{
+		{
+			name: "success case - regular video",
+			video: &youtube.Video{ContentDetails: &youtube.VideoContentDetails{Duration: "PT10M"},
+				Snippet: &youtube.VideoSnippet{LiveBroadcastContent: "none"}},
+			want: RegularVideoType,
+		},
+		{
+			name: "success case - short",
+			video: &youtube.Video{ContentDetails: &youtube.VideoContentDetails{Duration: "PT45S"},
+				Snippet: &youtube.VideoSnippet{LiveBroadcastContent: "none"}},
+			want: ShortVideoType,
+		},
+		{
+			name: "success case - short up to 3 minutes",
+			video: &youtube.Video{ContentDetails: &youtube.VideoContentDetails{Duration: "PT2M59S"},
+				Snippet: &youtube.VideoSnippet{LiveBroadcastContent: "none"}},
+			want: ShortVideoType,
+		},
+		{
+			name: "success case - regular video longer than shorts",
+			video: &youtube.Video{ContentDetails: &youtube.VideoContentDetails{Duration: "PT3M1S"},
+				Snippet: &youtube.VideoSnippet{LiveBroadcastContent: "none"}},
+			want: RegularVideoType,
+		},
+		{
+			name: "success case - live stream",
+			video: &youtube.Video{ContentDetails: &youtube.VideoContentDetails{Duration: "P0D"},
+				Snippet: &youtube.VideoSnippet{LiveBroadcastContent: "live"}},
+			want: LiveVideoType,
+		},
+		{
+			name: "success case - past live stream",
+			video: &youtube.Video{ContentDetails: &youtube.VideoContentDetails{Duration: "PT50S"},
+				LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{}},
+			want: LiveVideoType,
+		},
+		{
+			name: "success case - upcoming premiere",
+			video: &youtube.Video{ContentDetails: &youtube.VideoContentDetails{Duration: "P0D"},
+				Snippet: &youtube.VideoSnippet{LiveBroadcastContent: "upcoming"}},
+			want: UpcomingVideoType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := videoType(tt.video); got != tt.want {
+				t.Errorf("videoType() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_returnPath(t *testing.T) {
+	const fallback = "/settings"
+	tests := []struct {
+		name     string
+		returnTo string
+		want     string
+	}{
+		{name: "success case - path of this server", returnTo: "/check-youtube?group=1", want: "/check-youtube?group=1"},
+		{name: "redirect case - empty", returnTo: "", want: fallback},
+		{name: "redirect case - absolute URL", returnTo: "https://evil.com", want: fallback},
+		{name: "redirect case - protocol-relative URL", returnTo: "//evil.com", want: fallback},
+		{name: "redirect case - backslash URL", returnTo: `/\evil.com`, want: fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := returnPath(tt.returnTo, fallback); got != tt.want {
+				t.Errorf("returnPath() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"google.golang.org/api/youtube/v3"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// windows of the upload frequency in the inactive channels report, in days
+const (
+	recentUploadsDays = 90
+	yearUploadsDays   = 365
+)
+
+// max number of the latest uploads of a channel counted in the inactive channels report, a single page of the
+// YouTube playlist items API so that the report costs one call per channel. Only the channels uploading more often
+// over the past 90 or 365 days are capped
+const maxCountedUploads = 50
+
+// time the inactive channels report of an account is kept before calling YouTube again
+const inactiveReportTTL = time.Hour
+
+// inactiveReportKey identifies a cached report, built for an account with the inactivity threshold of the user
+type inactiveReportKey struct {
+	accountID     string
+	thresholdDays int
+}
+
+// inactiveReport is a cached report along with the time it expires
+type inactiveReport struct {
+	channels  []inactiveChannel
+	expiresAt time.Time
+}
+
+// inactiveReportCache holds the inactive channels reports built lately, by account
+type inactiveReportCache struct {
+	mutex   sync.Mutex
+	reports map[inactiveReportKey]inactiveReport
+}
+
+// inactive channels reports built so far, shared by all the users
+var inactiveReports = &inactiveReportCache{reports: make(map[inactiveReportKey]inactiveReport)}
+
+// inactiveChannel is a subscription in the inactive channels report
+type inactiveChannel struct {
+	SubscriptionID string
+	ChannelID      string
+	Title          string
+	// LastUploadAt is zero when the channel never uploaded a video
+	LastUploadAt time.Time
+	// DaysSinceUpload is -1 when the channel never uploaded a video
+	DaysSinceUpload int
+	RecentUploads   int
+	YearUploads     int
+	// Capped is set when the channel uploaded more videos in the past year than counted
+	Capped bool
+	// Dead is set when the channel didn't upload for more days than the threshold of the user
+	Dead bool
+	// Unknown is set when the uploads of the channel couldn't be retrieved
+	Unknown bool
+}
+
+type inactiveChannelsTemplateResponse struct {
+	Username      string
+	Channels      []inactiveChannel
+	DeadCount     int
+	ThresholdDays int
+	// MaxCountedUploads is the max number of uploads counted per channel
+	MaxCountedUploads int
+	WriteAccess       bool
+	GrantURL          string
+	LoginOnly         bool
+	Theme             string
+	ServerBasepath    string
+}
+
+// GetInactiveChannels renders the inactive channels report, listing the subscriptions of the logged account by last
+// upload date with their upload frequency, or returns it as CSV when the format query parameter is csv
+func GetInactiveChannels(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "GetInactiveChannels"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+		csvFormat := r.URL.Query().Get("format") == "csv"
+		preferences := userPreferences(r, storage, tokenInfo, funcName)
+
+		response := inactiveChannelsTemplateResponse{
+			Username:          tokenInfo.Username,
+			Channels:          []inactiveChannel{},
+			ThresholdDays:     preferences.InactiveChannelDays,
+			MaxCountedUploads: maxCountedUploads,
+			WriteAccess:       tokenInfo.WriteAccess,
+			GrantURL:          grantWriteAccessURL(serverBasepath, "/inactive-channels"),
+			LoginOnly:         tokenInfo.LoginOnly,
+			Theme:             preferences.Theme,
+			ServerBasepath:    serverBasepath,
+		}
+
+		// the token of users logged in with a login-only provider can't access YouTube
+		if tokenInfo.LoginOnly && csvFormat {
+			err := fmt.Errorf("users logged in with a login-only provider have no subscriptions")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the report is built again once the cached one expired or the subscriptions changed
+		channels, cached := inactiveReports.get(tokenInfo.UserId, preferences.InactiveChannelDays, time.Now())
+		if cached {
+			response.Channels = channels
+		}
+		if !tokenInfo.LoginOnly && !cached {
+			youtubeSvc, err := ytcf.NewClient(oauth2C.CreateTokenSource(r.Context(), tokenInfo.Token))
+			if err != nil {
+				slog.Error(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			subscriptions, err := getSubscriptionRows(r, youtubeSvc)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve subscriptions: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			now := time.Now()
+			response.Channels = inactiveChannels(r.Context(), youtubeSvc, subscriptions,
+				preferences.InactiveChannelDays, now, tokenInfo.Username)
+			inactiveReports.put(tokenInfo.UserId, preferences.InactiveChannelDays, response.Channels, now)
+		}
+		for _, channel := range response.Channels {
+			if channel.Dead {
+				response.DeadCount++
+			}
+		}
+
+		if csvFormat {
+			writeInactiveChannelsCSV(w, response.Channels, funcName)
+			return
+		}
+
+		// render response as HTML using a template
+		tmpl, err := template.New("inactiveChannelsTemplate.tmpl").Parse(htmlTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = tmpl.Execute(w, response)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// return the report of the given subscriptions, built from the latest uploads of each channel: the channels that
+// never uploaded first, then by last upload date, the ones whose uploads can't be retrieved last
+func inactiveChannels(ctx context.Context, svc clients.YoutubeClientInterface, subscriptions []subscriptionRow,
+	thresholdDays int, now time.Time, username string) []inactiveChannel {
+	ytChannels := make([]YTChannel, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		ytChannels = append(ytChannels, YTChannel{ChannelID: subscription.ChannelID})
+	}
+	since := now.Add(-yearUploadsDays * 24 * time.Hour)
+	uploads := fetchChannelPlaylists(ytChannels, username, func(channelID string) ([]*youtube.PlaylistItem, error) {
+		return svc.GetPlaylistVideosSince(ctx, uploadsPlaylistID(channelID), since, maxCountedUploads)
+	})
+
+	channels := make([]inactiveChannel, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		channel := inactiveChannel{
+			SubscriptionID:  subscription.SubscriptionID,
+			ChannelID:       subscription.ChannelID,
+			Title:           subscription.Title,
+			DaysSinceUpload: -1,
+		}
+		playlistItems, ok := uploads[subscription.ChannelID]
+		if !ok {
+			channel.Unknown = true
+			channels = append(channels, channel)
+			continue
+		}
+		for _, playlistItem := range playlistItems {
+			publishedAt, err := time.Parse(time.RFC3339, playlistItem.Snippet.PublishedAt)
+			if err != nil {
+				continue
+			}
+			if publishedAt.After(channel.LastUploadAt) {
+				channel.LastUploadAt = publishedAt
+			}
+			if now.Sub(publishedAt) <= recentUploadsDays*24*time.Hour {
+				channel.RecentUploads++
+			}
+			if now.Sub(publishedAt) <= yearUploadsDays*24*time.Hour {
+				channel.YearUploads++
+			}
+		}
+		channel.Capped = channel.YearUploads >= maxCountedUploads
+		if !channel.LastUploadAt.IsZero() {
+			channel.DaysSinceUpload = int(now.Sub(channel.LastUploadAt).Hours() / 24)
+		}
+		channel.Dead = channel.DaysSinceUpload < 0 || channel.DaysSinceUpload > thresholdDays
+		channels = append(channels, channel)
+	}
+
+	slices.SortStableFunc(channels, func(a, b inactiveChannel) int {
+		if a.Unknown != b.Unknown {
+			if a.Unknown {
+				return 1
+			}
+			return -1
+		}
+		if c := a.LastUploadAt.Compare(b.LastUploadAt); c != 0 {
+			return c
+		}
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+	return channels
+}
+
+// return the cached report of the account built with the given threshold, unless it expired
+func (c *inactiveReportCache) get(accountID string, thresholdDays int, now time.Time) ([]inactiveChannel, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	report, ok := c.reports[inactiveReportKey{accountID: accountID, thresholdDays: thresholdDays}]
+	if !ok || !now.Before(report.expiresAt) {
+		return nil, false
+	}
+	return report.channels, true
+}
+
+// cache the report of the account, unless the uploads of some channels couldn't be retrieved so that they're
+// retried on the next visit. The expired reports are dropped along the way
+func (c *inactiveReportCache) put(accountID string, thresholdDays int, channels []inactiveChannel, now time.Time) {
+	if slices.ContainsFunc(channels, func(channel inactiveChannel) bool { return channel.Unknown }) {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, report := range c.reports {
+		if !now.Before(report.expiresAt) {
+			delete(c.reports, key)
+		}
+	}
+	c.reports[inactiveReportKey{accountID: accountID, thresholdDays: thresholdDays}] = inactiveReport{
+		channels: channels, expiresAt: now.Add(inactiveReportTTL)}
+}
+
+// drop the reports of the account, whatever their threshold, once its subscriptions changed
+func (c *inactiveReportCache) forget(accountID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key := range c.reports {
+		if key.accountID == accountID {
+			delete(c.reports, key)
+		}
+	}
+}
+
+// UploadsLabel returns the given upload count of the channel, followed by + when it's capped, e.g. 500+
+func (c inactiveChannel) UploadsLabel(count int) string {
+	if c.Capped && count >= maxCountedUploads {
+		return strconv.Itoa(count) + "+"
+	}
+	return strconv.Itoa(count)
+}
+
+// write the report as a CSV file, leaving the upload columns empty for the channels whose uploads are unknown
+func writeInactiveChannelsCSV(w http.ResponseWriter, channels []inactiveChannel, funcName string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="inactive-channels.csv"`)
+	writer := csv.NewWriter(w)
+	records := [][]string{{"channel_id", "title", "subscription_id", "last_upload_at", "days_since_upload",
+		"uploads_90_days", "uploads_365_days", "dead"}}
+	for _, channel := range channels {
+		record := []string{channel.ChannelID, channel.Title, channel.SubscriptionID, "", "", "", "", ""}
+		if !channel.Unknown {
+			if !channel.LastUploadAt.IsZero() {
+				record[3] = channel.LastUploadAt.UTC().Format(time.RFC3339)
+				record[4] = strconv.Itoa(channel.DaysSinceUpload)
+			}
+			record[5] = channel.UploadsLabel(channel.RecentUploads)
+			record[6] = channel.UploadsLabel(channel.YearUploads)
+			record[7] = strconv.FormatBool(channel.Dead)
+		}
+		records = append(records, record)
+	}
+	if err := writer.WriteAll(records); err != nil {
+		slog.Error(fmt.Sprintf("failed to write the CSV response: %s", err.Error()), logging.FuncNameAttr(funcName))
+	}
+}
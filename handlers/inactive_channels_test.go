@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"checkYoutube/web"
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// return a YouTube client mock whose uploads playlists hold videos published the given days before now, newest
+// first, the playlist of channel UCfailing failing
+func inactiveChannelsClientMock(now time.Time, uploadDays map[string][]int) *youtubeClientMock {
+	return &youtubeClientMock{
+		getAndProcessSubscriptionsStub: func(ctx context.Context,
+			f func(*youtube.SubscriptionListResponse) error) error {
+			return f(&youtube.SubscriptionListResponse{Items: []*youtube.Subscription{
+				{Id: "sub1", Snippet: &youtube.SubscriptionSnippet{Title: "Active",
+					ResourceId: &youtube.ResourceId{ChannelId: "UCactive"}}},
+				{Id: "sub2", Snippet: &youtube.SubscriptionSnippet{Title: "Dead",
+					ResourceId: &youtube.ResourceId{ChannelId: "UCdead"}}},
+			}})
+		},
+		getPlaylistVideosSinceStub: func(_ context.Context, playlistID string, since time.Time,
+			maxResults int64) ([]*youtube.PlaylistItem, error) {
+			if playlistID == "UUfailing" {
+				return nil, fmt.Errorf("test error")
+			}
+			playlistItems := make([]*youtube.PlaylistItem, 0)
+			for _, days := range uploadDays[playlistID] {
+				publishedAt := now.AddDate(0, 0, -days)
+				playlistItems = append(playlistItems, &youtube.PlaylistItem{Snippet: &youtube.PlaylistItemSnippet{
+					PublishedAt: publishedAt.Format(time.RFC3339),
+					ResourceId:  &youtube.ResourceId{VideoId: fmt.Sprintf("%s-%d", playlistID, days)},
+				}})
+				if int64(len(playlistItems)) >= maxResults || publishedAt.Before(since) {
+					break
+				}
+			}
+			return playlistItems, nil
+		},
+	}
+}
+
+func Test_inactiveChannels(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	// channel UCregular uploaded a video every 10 days for more than a year, more than the uploads counted but
+	// spanning the year, and channel UCbusy uploaded more videos yesterday than the uploads counted
+	regularUploads := make([]int, 0, 2*maxCountedUploads)
+	busyUploads := make([]int, 0, 2*maxCountedUploads)
+	for i := range 2 * maxCountedUploads {
+		regularUploads = append(regularUploads, 10*i)
+		busyUploads = append(busyUploads, 1)
+	}
+	svc := inactiveChannelsClientMock(now, map[string][]int{
+		"UUactive":  {2, 30, 100, 400},
+		"UUdead":    {500, 800},
+		"UUregular": regularUploads,
+		"UUbusy":    busyUploads,
+	})
+	subscriptions := []subscriptionRow{
+		{SubscriptionID: "sub1", ChannelID: "UCactive", Title: "Active"},
+		{SubscriptionID: "sub5", ChannelID: "UCregular", Title: "Regular"},
+		{SubscriptionID: "sub6", ChannelID: "UCbusy", Title: "Busy"},
+		{SubscriptionID: "sub2", ChannelID: "UCdead", Title: "Dead"},
+		{SubscriptionID: "sub3", ChannelID: "UCempty", Title: "Empty"},
+		{SubscriptionID: "sub4", ChannelID: "UCfailing", Title: "Failing"},
+	}
+
+	got := inactiveChannels(context.Background(), svc, subscriptions, 365, now, "")
+	want := []inactiveChannel{
+		{SubscriptionID: "sub3", ChannelID: "UCempty", Title: "Empty", DaysSinceUpload: -1, Dead: true},
+		{SubscriptionID: "sub2", ChannelID: "UCdead", Title: "Dead", LastUploadAt: now.AddDate(0, 0, -500),
+			DaysSinceUpload: 500, Dead: true},
+		{SubscriptionID: "sub1", ChannelID: "UCactive", Title: "Active", LastUploadAt: now.AddDate(0, 0, -2),
+			DaysSinceUpload: 2, RecentUploads: 2, YearUploads: 3},
+		{SubscriptionID: "sub6", ChannelID: "UCbusy", Title: "Busy", LastUploadAt: now.AddDate(0, 0, -1),
+			DaysSinceUpload: 1, RecentUploads: maxCountedUploads, YearUploads: maxCountedUploads, Capped: true},
+		{SubscriptionID: "sub5", ChannelID: "UCregular", Title: "Regular", LastUploadAt: now, RecentUploads: 10,
+			YearUploads: 37},
+		{SubscriptionID: "sub4", ChannelID: "UCfailing", Title: "Failing", DaysSinceUpload: -1, Unknown: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("inactiveChannels() mismatch (-want +got):\n%s", diff)
+	}
+	for _, channel := range got {
+		if wantLabel := "50+"; channel.Capped && channel.UploadsLabel(channel.YearUploads) != wantLabel {
+			t.Errorf("UploadsLabel() got = %v, want %v", channel.UploadsLabel(channel.YearUploads), wantLabel)
+		}
+	}
+}
+
+func Test_inactiveReportCache(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	report := []inactiveChannel{{ChannelID: "UCactive", DaysSinceUpload: 2}}
+	cache := &inactiveReportCache{reports: make(map[inactiveReportKey]inactiveReport)}
+	cache.put("account1", 365, report, now)
+	cache.put("account2", 365, []inactiveChannel{{ChannelID: "UCfailing", Unknown: true}}, now)
+
+	tests := []struct {
+		name          string
+		accountID     string
+		thresholdDays int
+		at            time.Time
+		forget        string
+		want          bool
+	}{
+		{name: "success case - cached", accountID: "account1", thresholdDays: 365, at: now, want: true},
+		{name: "success case - another threshold", accountID: "account1", thresholdDays: 30, at: now},
+		{name: "success case - report with unknown uploads not cached", accountID: "account2", thresholdDays: 365,
+			at: now},
+		{name: "success case - expired", accountID: "account1", thresholdDays: 365, at: now.Add(inactiveReportTTL)},
+		{name: "success case - subscriptions changed", accountID: "account1", thresholdDays: 365, at: now,
+			forget: "account1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.forget != "" {
+				cache.forget(tt.forget)
+			}
+			got, ok := cache.get(tt.accountID, tt.thresholdDays, tt.at)
+			if ok != tt.want {
+				t.Fatalf("get() ok = %v, want %v", ok, tt.want)
+			}
+			if diff := cmp.Diff(report, got); ok && diff != "" {
+				t.Errorf("get() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetInactiveChannels(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+	}
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return inactiveChannelsClientMock(time.Now(), map[string][]int{"UUactive": {2}, "UUdead": {500}}), nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		tokenInfo    *auth.TokenInfo
+		want         int
+		wantContains []string
+	}{
+		{
+			name:      "success case - report",
+			target:    "/inactive-channels",
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}, WriteAccess: true},
+			want:      http.StatusOK,
+			wantContains: []string{"1 of 2 channels didn't upload for more than 365 days",
+				`value="sub2" checked`, `name="return_to" value="/inactive-channels"`},
+		},
+		{
+			name:         "success case - write access not granted",
+			target:       "/inactive-channels",
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:         http.StatusOK,
+			wantContains: []string{"grant-write-access?return_to=%2Finactive-channels"},
+		},
+		{
+			name:      "success case - CSV",
+			target:    "/inactive-channels?format=csv",
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:      http.StatusOK,
+			wantContains: []string{"channel_id,title,subscription_id,last_upload_at,days_since_upload," +
+				"uploads_90_days,uploads_365_days,dead\n", ",500,0,0,true\n", ",2,1,1,false\n"},
+		},
+		{
+			name:      "error case - CSV of a login-only user",
+			target:    "/inactive-channels?format=csv",
+			tokenInfo: &auth.TokenInfo{LoginOnly: true},
+			want:      http.StatusBadRequest,
+		},
+		{
+			name:   "redirect case - token not found in context",
+			target: "/inactive-channels",
+			want:   http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tokenInfo != nil {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), tt.tokenInfo))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetInactiveChannels(oauth2C, ytcf, storage, serverBasepath,
+				string(web.InactiveChannelsTemplate))
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("GetInactiveChannels() = %v, want %v", recorder.Code, tt.want)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(recorder.Body.String(), want) {
+					t.Errorf("GetInactiveChannels() body doesn't contain %s, got %s", want, recorder.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// max number of channels in a page of the feed
+const maxPageSize = 500
+
+// max number of days without uploads after which a channel is reported as dead
+const maxInactiveChannelDays = 3650
+
+// max time spent watching videos each day, in minutes
+const maxDailyWatchMinutes = 24 * 60
+
+// max length of the URL the alert matches are posted to
+const maxAlertWebhookURLLength = 2048
+
+// ISO 3166-1 alpha-2 country codes, as used by the region restrictions of the videos
+var regionRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// values of the preferences
+var (
+	feedViews      = []string{database.FilteredView, database.AllView}
+	sortDirections = []string{database.SortAscending, database.SortDescending}
+	themes         = []string{database.DarkTheme, database.LightTheme}
+	sortColumns    = []string{database.SortByChannel, database.SortByPublishDate, database.SortByDuration,
+		database.SortByNewItems}
+)
+
+type preferencesResponse struct {
+	DefaultView             string   `json:"default_view"`
+	SortColumn              string   `json:"sort_column"`
+	SortDirection           string   `json:"sort_direction"`
+	Timezone                string   `json:"timezone"`
+	HiddenVideoTypes        []string `json:"hidden_video_types"`
+	PageSize                int      `json:"page_size"`
+	Theme                   string   `json:"theme"`
+	ThumbnailSize           string   `json:"thumbnail_size"`
+	Region                  string   `json:"region"`
+	SkipUnavailableVideos   bool     `json:"skip_unavailable_videos"`
+	DetectMembersOnlyVideos bool     `json:"detect_members_only_videos"`
+	InactiveChannelDays     int      `json:"inactive_channel_days"`
+	DailyWatchMinutes       int      `json:"daily_watch_minutes"`
+	AlertWebhookURL         string   `json:"alert_webhook_url"`
+}
+
+// UpdatePreferences stores the preferences of the logged user submitted from the settings page
+func UpdatePreferences(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "UpdatePreferences"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		// validate the form
+		if err := r.ParseForm(); err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pageSize, err := strconv.Atoi(r.PostForm.Get("page_size"))
+		if err != nil {
+			err = fmt.Errorf("invalid page_size: %s", r.PostForm.Get("page_size"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		inactiveChannelDays, err := strconv.Atoi(r.PostForm.Get("inactive_channel_days"))
+		if err != nil {
+			err = fmt.Errorf("invalid inactive_channel_days: %s", r.PostForm.Get("inactive_channel_days"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		dailyWatchMinutes, err := strconv.Atoi(r.PostForm.Get("daily_watch_minutes"))
+		if err != nil {
+			err = fmt.Errorf("invalid daily_watch_minutes: %s", r.PostForm.Get("daily_watch_minutes"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		preferences := database.Preferences{
+			DefaultView:      r.PostForm.Get("default_view"),
+			SortColumn:       r.PostForm.Get("sort_column"),
+			SortDirection:    r.PostForm.Get("sort_direction"),
+			Timezone:         strings.TrimSpace(r.PostForm.Get("timezone")),
+			HiddenVideoTypes: r.PostForm["hidden_video_type"],
+			PageSize:         pageSize,
+			Theme:            r.PostForm.Get("theme"),
+			ThumbnailSize:    r.PostForm.Get("thumbnail_size"),
+			Region:           strings.ToUpper(strings.TrimSpace(r.PostForm.Get("region"))),
+			// unchecked checkboxes aren't sent
+			SkipUnavailableVideos:   r.PostForm.Get("skip_unavailable_videos") == "true",
+			DetectMembersOnlyVideos: r.PostForm.Get("detect_members_only_videos") == "true",
+			InactiveChannelDays:     inactiveChannelDays,
+			DailyWatchMinutes:       dailyWatchMinutes,
+			AlertWebhookURL:         strings.TrimSpace(r.PostForm.Get("alert_webhook_url")),
+		}
+		if preferences.HiddenVideoTypes == nil {
+			preferences.HiddenVideoTypes = []string{}
+		}
+		if err = validatePreferences(preferences); err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err = storage.UpsertPreferences(r.Context(), tokenInfo.AppUserId, preferences); err != nil {
+			slog.Error(fmt.Sprintf("failed to store preferences: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("preferences updated", logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/settings", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// PreferencesAPI returns the preferences of the user as JSON on GET, and updates them on PUT: the fields left out
+// of the request body keep their value
+func PreferencesAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "PreferencesAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPut {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		preferences, err := storage.GetPreferences(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve preferences: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response := preferencesResponse(preferences)
+
+		if r.Method == http.MethodPut {
+			if err = json.NewDecoder(r.Body).Decode(&response); err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if response.HiddenVideoTypes == nil {
+				response.HiddenVideoTypes = []string{}
+			}
+			preferences = database.Preferences(response)
+			if err = validatePreferences(preferences); err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err = storage.UpsertPreferences(r.Context(), tokenInfo.AppUserId, preferences); err != nil {
+				slog.Error(fmt.Sprintf("failed to store preferences: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			slog.Info("preferences updated", logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		}
+
+		writeJSON(w, response, funcName)
+	}
+}
+
+// check that the preferences hold known values
+func validatePreferences(preferences database.Preferences) error {
+	if !slices.Contains(feedViews, preferences.DefaultView) {
+		return fmt.Errorf("unknown default view: %s", preferences.DefaultView)
+	}
+	if !slices.Contains(sortColumns, preferences.SortColumn) {
+		return fmt.Errorf("unknown sort column: %s", preferences.SortColumn)
+	}
+	if !slices.Contains(sortDirections, preferences.SortDirection) {
+		return fmt.Errorf("unknown sort direction: %s", preferences.SortDirection)
+	}
+	if preferences.Timezone != "" {
+		if _, err := time.LoadLocation(preferences.Timezone); err != nil || preferences.Timezone == "Local" {
+			return fmt.Errorf("unknown timezone: %s", preferences.Timezone)
+		}
+	}
+	for _, hiddenVideoType := range preferences.HiddenVideoTypes {
+		if !slices.Contains(VideoTypes, hiddenVideoType) {
+			return fmt.Errorf("unknown video type: %s", hiddenVideoType)
+		}
+	}
+	if preferences.PageSize < 0 || preferences.PageSize > maxPageSize {
+		return fmt.Errorf("page size must be between 0 and %d, got %d", maxPageSize, preferences.PageSize)
+	}
+	if !slices.Contains(themes, preferences.Theme) {
+		return fmt.Errorf("unknown theme: %s", preferences.Theme)
+	}
+	if preferences.ThumbnailSize != database.NoThumbnail && !slices.Contains(ThumbnailSizes, preferences.ThumbnailSize) {
+		return fmt.Errorf("unknown thumbnail size: %s", preferences.ThumbnailSize)
+	}
+	if preferences.Region != "" && !regionRegex.MatchString(preferences.Region) {
+		return fmt.Errorf("invalid region: %s, expected an ISO 3166-1 alpha-2 code like US", preferences.Region)
+	}
+	if preferences.InactiveChannelDays < 1 || preferences.InactiveChannelDays > maxInactiveChannelDays {
+		return fmt.Errorf("inactive channel days must be between 1 and %d, got %d", maxInactiveChannelDays,
+			preferences.InactiveChannelDays)
+	}
+	if preferences.DailyWatchMinutes < 1 || preferences.DailyWatchMinutes > maxDailyWatchMinutes {
+		return fmt.Errorf("daily watch minutes must be between 1 and %d, got %d", maxDailyWatchMinutes,
+			preferences.DailyWatchMinutes)
+	}
+	if preferences.AlertWebhookURL != "" {
+		webhookURL, err := url.Parse(preferences.AlertWebhookURL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" ||
+			len(preferences.AlertWebhookURL) > maxAlertWebhookURLLength {
+			return fmt.Errorf("invalid alert webhook URL: %s, expected an http or https URL", preferences.AlertWebhookURL)
+		}
+	}
+	return nil
+}
+
+// return the preferences of the logged user, falling back to the default ones when they can't be retrieved
+func userPreferences(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	funcName string) database.Preferences {
+	preferences, err := storage.GetPreferences(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to retrieve preferences, using the default ones: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		return database.DefaultPreferences()
+	}
+	return preferences
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUpdatePreferences(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	var stored database.Preferences
+	storage := &test.StorageMock{
+		UpsertPreferencesStub: func(_ context.Context, userId int64, preferences database.Preferences) error {
+			if preferences.Timezone == "Europe/London" {
+				return fmt.Errorf("test error")
+			}
+			stored = preferences
+			return nil
+		},
+	}
+	validForm := func() url.Values {
+		return url.Values{"default_view": {"all"}, "sort_column": {"published"}, "sort_direction": {"desc"},
+			"timezone": {"Europe/Rome"}, "hidden_video_type": {ShortVideoType, UpcomingVideoType},
+			"page_size": {"50"}, "theme": {"light"}, "thumbnail_size": {"medium"},
+			"region": {" it "}, "skip_unavailable_videos": {"true"}, "inactive_channel_days": {"180"},
+			"daily_watch_minutes": {"90"}, "alert_webhook_url": {" https://example.com/hook "}}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		form   func() url.Values
+		want   int
+	}{
+		{
+			name:   "success case",
+			method: http.MethodPost,
+			form:   validForm,
+			want:   http.StatusSeeOther,
+		},
+		{
+			name:   "error case - unknown video type",
+			method: http.MethodPost,
+			form: func() url.Values {
+				form := validForm()
+				form["hidden_video_type"] = []string{"unknown"}
+				return form
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:   "error case - unknown thumbnail size",
+			method: http.MethodPost,
+			form: func() url.Values {
+				form := validForm()
+				form.Set("thumbnail_size", "huge")
+				return form
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:   "error case - invalid region",
+			method: http.MethodPost,
+			form: func() url.Values {
+				form := validForm()
+				form.Set("region", "ITA")
+				return form
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:   "error case - invalid inactive channel days",
+			method: http.MethodPost,
+			form: func() url.Values {
+				form := validForm()
+				form.Set("inactive_channel_days", "0")
+				return form
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:   "error case - invalid daily watch minutes",
+			method: http.MethodPost,
+			form: func() url.Values {
+				form := validForm()
+				form.Set("daily_watch_minutes", "1441")
+				return form
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:   "error case - alert webhook URL not http",
+			method: http.MethodPost,
+			form: func() url.Values {
+				form := validForm()
+				form.Set("alert_webhook_url", "file:///etc/passwd")
+				return form
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:   "error case - invalid page size",
+			method: http.MethodPost,
+			form: func() url.Values {
+				form := validForm()
+				form.Set("page_size", "ten")
+				return form
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:   "error case - storage error",
+			method: http.MethodPost,
+			form: func() url.Values {
+				form := validForm()
+				form.Set("timezone", "Europe/London")
+				return form
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodGet,
+			form:   validForm,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/update-preferences", strings.NewReader(tt.form().Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := UpdatePreferences(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("UpdatePreferences() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+
+	want := database.Preferences{DefaultView: database.AllView, SortColumn: database.SortByPublishDate,
+		SortDirection: database.SortDescending, Timezone: "Europe/Rome",
+		HiddenVideoTypes: []string{ShortVideoType, UpcomingVideoType}, PageSize: 50, Theme: database.LightTheme,
+		ThumbnailSize: database.MediumThumbnail, Region: "IT", SkipUnavailableVideos: true, InactiveChannelDays: 180,
+		DailyWatchMinutes: 90, AlertWebhookURL: "https://example.com/hook"}
+	if diff := cmp.Diff(want, stored); diff != "" {
+		t.Errorf("UpdatePreferences() stored preferences mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPreferencesAPI(t *testing.T) {
+	// mocks
+	var stored database.Preferences
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+		UpsertPreferencesStub: func(_ context.Context, userId int64, preferences database.Preferences) error {
+			stored = preferences
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{
+			name:   "success case - get",
+			method: http.MethodGet,
+			want:   http.StatusOK,
+		},
+		{
+			name:   "success case - partial update",
+			method: http.MethodPut,
+			body:   `{"page_size": 25, "hidden_video_types": ["live"]}`,
+			want:   http.StatusOK,
+		},
+		{
+			name:   "error case - invalid preferences",
+			method: http.MethodPut,
+			body:   `{"timezone": "Mars/Olympus_Mons"}`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - invalid body",
+			method: http.MethodPut,
+			body:   `{invalid_json}`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodDelete,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/api/v1/preferences", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := PreferencesAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("PreferencesAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+
+	want := database.DefaultPreferences()
+	want.PageSize = 25
+	want.HiddenVideoTypes = []string{LiveVideoType}
+	if diff := cmp.Diff(want, stored); diff != "" {
+		t.Errorf("PreferencesAPI() stored preferences mismatch (-want +got):\n%s", diff)
+	}
+}
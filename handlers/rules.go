@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"fmt"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// max length of the title pattern of a rule
+const maxTitlePatternLength = 255
+
+// max number of days a channel can be snoozed for
+const maxSnoozeDays = 365
+
+// RuleKinds are the kinds of channel rules users can create
+var RuleKinds = []string{database.MuteRule, database.SnoozeRule, database.TitleMatchRule}
+
+// channelRules are the channel rules of a user applying now, by channel id
+type channelRules struct {
+	// skipped are the muted and snoozed channels
+	skipped map[string]bool
+	// titlePatterns are the lowercase patterns the latest video title of a channel must contain
+	titlePatterns map[string]string
+}
+
+type rulesTemplateResponse struct {
+	Username           string
+	Rules              []database.ChannelRule
+	RuleKinds          []string
+	VideoRules         []videoRuleRow
+	VideoRuleFields    []string
+	VideoRuleOperators []string
+	Theme              string
+	ServerBasepath     string
+}
+
+// return the channel rules applying at the given time
+func newChannelRules(rules []database.ChannelRule, now time.Time) channelRules {
+	result := channelRules{skipped: make(map[string]bool), titlePatterns: make(map[string]string)}
+	for _, rule := range rules {
+		if !rule.Active(now) {
+			continue
+		}
+		if rule.Kind == database.TitleMatchRule {
+			result.titlePatterns[rule.ChannelID] = strings.ToLower(rule.TitlePattern)
+		} else {
+			result.skipped[rule.ChannelID] = true
+		}
+	}
+	return result
+}
+
+// tell whether the channel is muted or snoozed, so its latest video doesn't need to be retrieved
+func (c channelRules) skips(channelID string) bool {
+	return c.skipped[channelID]
+}
+
+// tell whether the latest video title of the channel doesn't contain the pattern of its title match rule
+func (c channelRules) hides(ytChannel YTChannel) bool {
+	pattern, ok := c.titlePatterns[ytChannel.ChannelID]
+	return ok && !strings.Contains(strings.ToLower(ytChannel.LatestVideoTitle), pattern)
+}
+
+// return the channel rules of the logged user applying now, none when they can't be retrieved
+func userChannelRules(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	funcName string) channelRules {
+	rules, err := storage.GetChannelRules(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to retrieve channel rules, ignoring them: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+	}
+	return newChannelRules(rules, time.Now())
+}
+
+// GetRules renders the rules page, listing the active channel rules and the video rules of the logged user
+func GetRules(storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "GetRules"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		rules, err := storage.GetChannelRules(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve channel rules: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		rules = slices.DeleteFunc(rules, func(rule database.ChannelRule) bool { return !rule.Active(now) })
+		videoRules, err := storage.GetVideoRules(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve video rules: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		videoRuleRows := make([]videoRuleRow, 0, len(videoRules))
+		for _, rule := range videoRules {
+			videoRuleRows = append(videoRuleRows, videoRuleRow{Rule: rule, Description: describeVideoRule(rule)})
+		}
+
+		response := rulesTemplateResponse{
+			Username:           tokenInfo.Username,
+			Rules:              rules,
+			RuleKinds:          RuleKinds,
+			VideoRules:         videoRuleRows,
+			VideoRuleFields:    VideoRuleFields,
+			VideoRuleOperators: VideoRuleOperators,
+			Theme:              userPreferences(r, storage, tokenInfo, funcName).Theme,
+			ServerBasepath:     serverBasepath,
+		}
+
+		// render response as HTML using a template
+		tmpl, err := template.New("rulesTemplate.tmpl").Parse(htmlTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = tmpl.Execute(w, response)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// AddChannelRules stores a rule of the given kind for each of the channels sent, from the feed or the rules page.
+// Snooze rules last until the snoozed_until date or for snooze_days days, title match rules need a title_pattern
+func AddChannelRules(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "AddChannelRules"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		// validate the form
+		if err := r.ParseForm(); err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule, err := channelRuleFromForm(r, time.Now().UTC())
+		if err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		channelIDs := slices.DeleteFunc(r.PostForm["channel_id"], func(channelID string) bool {
+			return strings.TrimSpace(channelID) == ""
+		})
+		if len(channelIDs) == 0 {
+			err = fmt.Errorf("no channel selected")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rule.UserId = tokenInfo.AppUserId
+		err = storage.WithTx(r.Context(), func(tx database.StorageInterface) error {
+			for _, channelID := range channelIDs {
+				rule.ChannelID = strings.TrimSpace(channelID)
+				// the feed page sends the title of each channel, the rules page only the one of the channel typed
+				rule.ChannelTitle = r.PostForm.Get("title_" + rule.ChannelID)
+				if rule.ChannelTitle == "" {
+					rule.ChannelTitle = strings.TrimSpace(r.PostForm.Get("channel_title"))
+				}
+				if err := tx.UpsertChannelRule(r.Context(), rule); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to store channel rules: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("%s rule added to %d channels", rule.Kind, len(channelIDs)),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, serverBasepath+returnPath(r.PostForm.Get("return_to"), "/rules"), http.StatusSeeOther)
+	}
+}
+
+// DeleteChannelRule removes a channel rule of the logged user
+func DeleteChannelRule(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "DeleteChannelRule"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid channel rule id: %s", r.FormValue("id"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err = storage.DeleteChannelRule(r.Context(), tokenInfo.AppUserId, id); err != nil {
+			slog.Error(fmt.Sprintf("failed to delete channel rule: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("channel rule %d deleted", id), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/rules", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// return the rule described by the kind, snoozed_until, snooze_days and title_pattern fields of the form, without
+// user and channel
+func channelRuleFromForm(r *http.Request, now time.Time) (database.ChannelRule, error) {
+	rule := database.ChannelRule{Kind: r.PostForm.Get("kind")}
+	switch rule.Kind {
+	case database.MuteRule:
+	case database.SnoozeRule:
+		var snoozedUntil time.Time
+		if date := r.PostForm.Get("snoozed_until"); date != "" {
+			var err error
+			if snoozedUntil, err = time.Parse(time.DateOnly, date); err != nil {
+				return rule, fmt.Errorf("invalid snoozed_until: %s", date)
+			}
+		} else {
+			days, err := strconv.Atoi(r.PostForm.Get("snooze_days"))
+			if err != nil || days <= 0 || days > maxSnoozeDays {
+				return rule, fmt.Errorf("snooze_days must be between 1 and %d, got %s", maxSnoozeDays,
+					r.PostForm.Get("snooze_days"))
+			}
+			snoozedUntil = now.AddDate(0, 0, days)
+		}
+		if !snoozedUntil.After(now) || snoozedUntil.After(now.AddDate(0, 0, maxSnoozeDays)) {
+			return rule, fmt.Errorf("a channel can be snoozed for up to %d days", maxSnoozeDays)
+		}
+		rule.SnoozedUntil = &snoozedUntil
+	case database.TitleMatchRule:
+		rule.TitlePattern = strings.TrimSpace(r.PostForm.Get("title_pattern"))
+		if rule.TitlePattern == "" {
+			return rule, fmt.Errorf("missing title_pattern")
+		}
+		if utf8.RuneCountInString(rule.TitlePattern) > maxTitlePatternLength {
+			return rule, fmt.Errorf("title_pattern longer than %d characters", maxTitlePatternLength)
+		}
+	default:
+		return rule, fmt.Errorf("unknown rule kind: %s", rule.Kind)
+	}
+	return rule, nil
+}
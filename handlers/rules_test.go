@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetRules(t *testing.T) {
+	// mocks
+	const (
+		serverBasepath = "http://localhost:8900"
+		tokenNotFound  = "redirect case - token not found in context"
+	)
+	snoozedUntil := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		storage database.StorageInterface
+		want    int
+	}{
+		{
+			name: "success case",
+			storage: &test.StorageMock{
+				GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+					return []database.ChannelRule{
+						{Id: 1, ChannelID: "channel1", Kind: database.MuteRule},
+						{Id: 2, ChannelID: "channel2", Kind: database.SnoozeRule, SnoozedUntil: &snoozedUntil},
+					}, nil
+				},
+				GetVideoRulesStub: func(_ context.Context, userId int64) ([]database.VideoRule, error) {
+					return []database.VideoRule{{Id: 1, Action: database.ExcludeAction, Field: titleField,
+						Operator: containsOperator, Value: "#shorts"}}, nil
+				},
+				GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+					return database.DefaultPreferences(), nil
+				},
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "error case - storage error",
+			storage: &test.StorageMock{
+				GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+					return nil, fmt.Errorf("test error")
+				},
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "error case - video rules storage error",
+			storage: &test.StorageMock{
+				GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+					return []database.ChannelRule{}, nil
+				},
+				GetVideoRulesStub: func(_ context.Context, userId int64) ([]database.VideoRule, error) {
+					return nil, fmt.Errorf("test error")
+				},
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name:    tokenNotFound,
+			storage: &test.StorageMock{},
+			want:    http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/rules", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetRules(tt.storage, serverBasepath, "")
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("GetRules() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddChannelRules(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	var stored []database.ChannelRule
+	storage := &test.StorageMock{
+		UpsertChannelRuleStub: func(_ context.Context, rule database.ChannelRule) error {
+			if rule.ChannelID == "unknown" {
+				return fmt.Errorf("test error")
+			}
+			stored = append(stored, rule)
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		form         url.Values
+		want         int
+		wantLocation string
+	}{
+		{
+			name:   "success case - mute from the feed page",
+			method: http.MethodPost,
+			form: url.Values{"kind": {"mute"}, "channel_id": {"channel1", "channel2"}, "title_channel1": {"a"},
+				"title_channel2": {"b"}, "return_to": {"/check-youtube?page=2"}},
+			want:         http.StatusSeeOther,
+			wantLocation: serverBasepath + "/check-youtube?page=2",
+		},
+		{
+			name:   "success case - title match from the rules page",
+			method: http.MethodPost,
+			form: url.Values{"kind": {"title_match"}, "channel_id": {" channel3 "}, "channel_title": {"c"},
+				"title_pattern": {" tutorial "}},
+			want:         http.StatusSeeOther,
+			wantLocation: serverBasepath + "/rules",
+		},
+		{
+			name:   "failure case - snooze in the past",
+			method: http.MethodPost,
+			form:   url.Values{"kind": {"snooze"}, "channel_id": {"channel1"}, "snoozed_until": {"2000-01-01"}},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - missing title pattern",
+			method: http.MethodPost,
+			form:   url.Values{"kind": {"title_match"}, "channel_id": {"channel1"}},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - no channel selected",
+			method: http.MethodPost,
+			form:   url.Values{"kind": {"mute"}, "channel_id": {""}},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - storage error",
+			method: http.MethodPost,
+			form:   url.Values{"kind": {"mute"}, "channel_id": {"unknown"}},
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "failure case - method not allowed",
+			method: http.MethodGet,
+			form:   url.Values{"kind": {"mute"}, "channel_id": {"channel1"}},
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/add-channel-rules", strings.NewReader(tt.form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := AddChannelRules(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("AddChannelRules() = %v, want %v", recorder.Code, tt.want)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("AddChannelRules() location = %v, want %v", location, tt.wantLocation)
+			}
+		})
+	}
+
+	want := []database.ChannelRule{
+		{UserId: 1, ChannelID: "channel1", ChannelTitle: "a", Kind: database.MuteRule},
+		{UserId: 1, ChannelID: "channel2", ChannelTitle: "b", Kind: database.MuteRule},
+		{UserId: 1, ChannelID: "channel3", ChannelTitle: "c", Kind: database.TitleMatchRule, TitlePattern: "tutorial"},
+	}
+	if diff := cmp.Diff(want, stored); diff != "" {
+		t.Errorf("AddChannelRules() stored rules mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDeleteChannelRule(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		DeleteChannelRuleStub: func(_ context.Context, userId, id int64) error {
+			if id != 1 {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		id     string
+		want   int
+	}{
+		{
+			name:   "success case",
+			method: http.MethodPost,
+			id:     "1",
+			want:   http.StatusSeeOther,
+		},
+		{
+			name:   "failure case - invalid id",
+			method: http.MethodPost,
+			id:     "abc",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - storage error",
+			method: http.MethodPost,
+			id:     "2",
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "failure case - method not allowed",
+			method: http.MethodGet,
+			id:     "1",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"id": {tt.id}}
+			req, err := http.NewRequest(tt.method, "/delete-channel-rule", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := DeleteChannelRule(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("DeleteChannelRule() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func Test_channelRules(t *testing.T) {
+	now := time.Now()
+	expired, snoozed := now.Add(-time.Hour), now.Add(time.Hour)
+	rules := newChannelRules([]database.ChannelRule{
+		{ChannelID: "muted", Kind: database.MuteRule},
+		{ChannelID: "snoozed", Kind: database.SnoozeRule, SnoozedUntil: &snoozed},
+		{ChannelID: "expired", Kind: database.SnoozeRule, SnoozedUntil: &expired},
+		{ChannelID: "matching", Kind: database.TitleMatchRule, TitlePattern: "Tutorial"},
+	}, now)
+
+	tests := []struct {
+		name      string
+		ytChannel YTChannel
+		wantSkips bool
+		wantHides bool
+	}{
+		{
+			name:      "success case - muted",
+			ytChannel: YTChannel{ChannelID: "muted"},
+			wantSkips: true,
+		},
+		{
+			name:      "success case - snoozed",
+			ytChannel: YTChannel{ChannelID: "snoozed"},
+			wantSkips: true,
+		},
+		{
+			name:      "success case - snooze expired",
+			ytChannel: YTChannel{ChannelID: "expired"},
+		},
+		{
+			name:      "success case - title matching",
+			ytChannel: YTChannel{ChannelID: "matching", LatestVideoTitle: "Go TUTORIAL part 2"},
+		},
+		{
+			name:      "success case - title not matching",
+			ytChannel: YTChannel{ChannelID: "matching", LatestVideoTitle: "Vlog"},
+			wantHides: true,
+		},
+		{
+			name:      "success case - no rules",
+			ytChannel: YTChannel{ChannelID: "other", LatestVideoTitle: "Vlog"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.skips(tt.ytChannel.ChannelID); got != tt.wantSkips {
+				t.Errorf("skips() got = %v, want %v", got, tt.wantSkips)
+			}
+			if got := rules.hides(tt.ytChannel); got != tt.wantHides {
+				t.Errorf("hides() got = %v, want %v", got, tt.wantHides)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// max length of a video search query
+const maxSearchQueryLength = 255
+
+// number of videos per page of search results
+const searchPageSize = 25
+
+type searchTemplateResponse struct {
+	Username       string
+	Query          string
+	ChannelID      string
+	From           string
+	To             string
+	Channels       []database.VideoChannel
+	Rows           []searchRow
+	Page           int
+	PrevPageURL    string
+	NextPageURL    string
+	Theme          string
+	ServerBasepath string
+}
+
+// searchRow is a video search result as shown on the search page
+type searchRow struct {
+	database.VideoSearchResult
+	Duration string
+	Snippet  template.HTML
+}
+
+type videoSearchResponse struct {
+	Page        int                         `json:"page"`
+	HasNextPage bool                        `json:"has_next_page"`
+	Results     []videoSearchResultResponse `json:"results"`
+}
+
+type videoSearchResultResponse struct {
+	VideoID         string `json:"video_id"`
+	VideoURL        string `json:"video_url"`
+	Title           string `json:"title"`
+	ChannelID       string `json:"channel_id"`
+	ChannelTitle    string `json:"channel_title"`
+	PublishedAt     string `json:"published_at"`
+	DurationSeconds int64  `json:"duration_seconds"`
+	Type            string `json:"type"`
+	// Snippet is HTML, the matching terms being between <mark> tags
+	Snippet string `json:"snippet"`
+}
+
+// videoSearchParams are the parameters of a video search request: q, channel_id, from and to as inclusive
+// YYYY-MM-DD dates, and page starting at 1
+type videoSearchParams struct {
+	Query     string
+	ChannelID string
+	From      string
+	To        string
+	Page      int
+}
+
+// return the parameters of a video search request, or an error when they're not valid
+func parseVideoSearchParams(values url.Values) (videoSearchParams, error) {
+	params := videoSearchParams{
+		Query:     strings.TrimSpace(values.Get("q")),
+		ChannelID: strings.TrimSpace(values.Get("channel_id")),
+		From:      values.Get("from"),
+		To:        values.Get("to"),
+		Page:      1,
+	}
+	if utf8.RuneCountInString(params.Query) > maxSearchQueryLength {
+		return params, fmt.Errorf("search query longer than %d characters", maxSearchQueryLength)
+	}
+	for _, date := range []string{params.From, params.To} {
+		if _, err := time.Parse(time.DateOnly, date); date != "" && err != nil {
+			return params, fmt.Errorf("invalid date: %s, expected YYYY-MM-DD", date)
+		}
+	}
+	if page := values.Get("page"); page != "" {
+		var err error
+		if params.Page, err = strconv.Atoi(page); err != nil || params.Page < 1 {
+			return params, fmt.Errorf("invalid page: %s", page)
+		}
+	}
+	return params, nil
+}
+
+// return the storage search of the parameters for the given user, fetching one more video than the page size to
+// know whether there's a next page
+func (p videoSearchParams) search(userId int64) database.VideoSearch {
+	search := database.VideoSearch{
+		UserId:    userId,
+		Query:     p.Query,
+		ChannelID: p.ChannelID,
+		Limit:     searchPageSize + 1,
+		Offset:    (p.Page - 1) * searchPageSize,
+	}
+	if from, err := time.Parse(time.DateOnly, p.From); err == nil {
+		search.PublishedFrom = &from
+	}
+	if to, err := time.Parse(time.DateOnly, p.To); err == nil {
+		to = to.AddDate(0, 0, 1)
+		search.PublishedTo = &to
+	}
+	return search
+}
+
+// return the query string of the search page of the parameters at the given page
+func (p videoSearchParams) pageQuery(page int) string {
+	values := url.Values{}
+	for key, value := range map[string]string{"q": p.Query, "channel_id": p.ChannelID, "from": p.From, "to": p.To} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	values.Set("page", strconv.Itoa(page))
+	return values.Encode()
+}
+
+// return the HTML of a search result snippet, the matching terms between <mark> tags
+func highlightSnippet(snippet string) template.HTML {
+	return template.HTML(strings.NewReplacer(database.SnippetMatchStart, "<mark>", database.SnippetMatchEnd,
+		"</mark>").Replace(html.EscapeString(snippet)))
+}
+
+// format a duration in seconds like the durations of the feed, e.g. 04:05 or 01:02:03
+func formatDurationSeconds(seconds int64) string {
+	if seconds >= 3600 {
+		return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// archive the latest videos of the channels in the videos searchable by the logged user. Failures are logged only,
+// not to prevent the feed from being shown
+func archiveVideos(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	ytChannels []YTChannel, funcName string) {
+	videos := make([]database.Video, 0, len(ytChannels))
+	for _, ytChannel := range ytChannels {
+		// unavailable videos can't be watched from the archive either
+		if ytChannel.LatestVideoID == "" || ytChannel.LatestVideoUnavailable != "" {
+			continue
+		}
+		video := database.Video{
+			VideoID:         ytChannel.LatestVideoID,
+			ChannelID:       ytChannel.ChannelID,
+			ChannelTitle:    ytChannel.Title,
+			Title:           ytChannel.LatestVideoTitle,
+			Description:     ytChannel.LatestVideoDescription,
+			DurationSeconds: ytChannel.LatestVideoDurationSeconds,
+			Type:            ytChannel.LatestVideoType,
+		}
+		if publishedAt, err := time.Parse(time.RFC3339, ytChannel.LatestVideoPublishedAt); err == nil {
+			video.PublishedAt = &publishedAt
+		} else {
+			slog.Warn(fmt.Sprintf("invalid publication date of video %s: %s", video.VideoID,
+				ytChannel.LatestVideoPublishedAt), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		}
+		videos = append(videos, video)
+	}
+	if len(videos) == 0 {
+		return
+	}
+
+	if err := storage.UpsertVideos(r.Context(), tokenInfo.AppUserId, videos); err != nil {
+		slog.Warn(fmt.Sprintf("failed to archive videos: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+	}
+}
+
+// GetSearch renders the search page, searching the videos discovered by the logged user
+func GetSearch(storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "GetSearch"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		params, err := parseVideoSearchParams(r.URL.Query())
+		if err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results, err := storage.SearchVideos(r.Context(), params.search(tokenInfo.AppUserId))
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to search videos: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		channels, err := storage.GetVideoChannels(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve video channels: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := searchTemplateResponse{
+			Username:       tokenInfo.Username,
+			Query:          params.Query,
+			ChannelID:      params.ChannelID,
+			From:           params.From,
+			To:             params.To,
+			Channels:       channels,
+			Page:           params.Page,
+			Theme:          userPreferences(r, storage, tokenInfo, funcName).Theme,
+			ServerBasepath: serverBasepath,
+		}
+		if len(results) > searchPageSize {
+			results = results[:searchPageSize]
+			response.NextPageURL = "/search?" + params.pageQuery(params.Page+1)
+		}
+		if params.Page > 1 {
+			response.PrevPageURL = "/search?" + params.pageQuery(params.Page-1)
+		}
+		for _, result := range results {
+			response.Rows = append(response.Rows, searchRow{
+				VideoSearchResult: result,
+				Duration:          formatDurationSeconds(result.DurationSeconds),
+				Snippet:           highlightSnippet(result.Snippet),
+			})
+		}
+
+		// render response as HTML using a template
+		tmpl, err := template.New("searchTemplate.tmpl").Parse(htmlTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = tmpl.Execute(w, response)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// SearchVideosAPI searches the videos discovered by the user, returning a page of results as JSON
+func SearchVideosAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "SearchVideosAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		params, err := parseVideoSearchParams(r.URL.Query())
+		if err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results, err := storage.SearchVideos(r.Context(), params.search(tokenInfo.AppUserId))
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to search videos: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := videoSearchResponse{
+			Page:        params.Page,
+			HasNextPage: len(results) > searchPageSize,
+			Results:     make([]videoSearchResultResponse, 0, len(results)),
+		}
+		if response.HasNextPage {
+			results = results[:searchPageSize]
+		}
+		for _, result := range results {
+			item := videoSearchResultResponse{
+				VideoID:         result.VideoID,
+				VideoURL:        fmt.Sprintf("%s/watch?v=%s", youTubeBasepath, result.VideoID),
+				Title:           result.Title,
+				ChannelID:       result.ChannelID,
+				ChannelTitle:    result.ChannelTitle,
+				DurationSeconds: result.DurationSeconds,
+				Type:            result.Type,
+				Snippet:         string(highlightSnippet(result.Snippet)),
+			}
+			if result.PublishedAt != nil {
+				item.PublishedAt = result.PublishedAt.UTC().Format("2006-01-02T15:04:05Z")
+			}
+			response.Results = append(response.Results, item)
+		}
+		writeJSON(w, response, funcName)
+	}
+}
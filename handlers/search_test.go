@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_parseVideoSearchParams(t *testing.T) {
+	from := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)
+	// the to date is inclusive
+	to := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    database.VideoSearch
+		wantErr bool
+	}{
+		{
+			name:  "success case",
+			query: "q=+gener*+&channel_id=channel1&from=2025-02-01&to=2025-02-28&page=3",
+			want: database.VideoSearch{UserId: 1, Query: "gener*", ChannelID: "channel1", PublishedFrom: &from,
+				PublishedTo: &to, Limit: searchPageSize + 1, Offset: 2 * searchPageSize},
+		},
+		{
+			name:  "success case - no parameters",
+			query: "",
+			want:  database.VideoSearch{UserId: 1, Limit: searchPageSize + 1},
+		},
+		{
+			name:    "error case - invalid date",
+			query:   "from=01/02/2025",
+			wantErr: true,
+		},
+		{
+			name:    "error case - invalid page",
+			query:   "page=0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params, err := parseVideoSearchParams(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVideoSearchParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, params.search(1)); diff != "" {
+				t.Errorf("search() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_highlightSnippet(t *testing.T) {
+	snippet := "<b>" + database.SnippetMatchStart + "Go" + database.SnippetMatchEnd + " & generics"
+	want := "&lt;b&gt;<mark>Go</mark> &amp; generics"
+	if got := highlightSnippet(snippet); string(got) != want {
+		t.Errorf("highlightSnippet() got = %v, want %v", got, want)
+	}
+}
+
+func TestGetSearch(t *testing.T) {
+	// mocks
+	const (
+		serverBasepath = "http://localhost:8900"
+		tokenNotFound  = "redirect case - token not found in context"
+	)
+	publishedAt := time.Date(2025, time.February, 11, 0, 0, 0, 0, time.UTC)
+	results := []database.VideoSearchResult{{Video: database.Video{VideoID: "video1", ChannelID: "channel1",
+		ChannelTitle: "Go", Title: "Go 1.24 released", PublishedAt: &publishedAt, DurationSeconds: 3723},
+		Snippet: database.SnippetMatchStart + "Go" + database.SnippetMatchEnd + " 1.24 released"}}
+
+	tests := []struct {
+		name    string
+		target  string
+		storage database.StorageInterface
+		want    int
+	}{
+		{
+			name:   "success case",
+			target: "/search?q=go&page=2",
+			storage: &test.StorageMock{
+				SearchVideosStub: func(_ context.Context,
+					search database.VideoSearch) ([]database.VideoSearchResult, error) {
+					return results, nil
+				},
+				GetVideoChannelsStub: func(_ context.Context, userId int64) ([]database.VideoChannel, error) {
+					return []database.VideoChannel{{ChannelID: "channel1", ChannelTitle: "Go"}}, nil
+				},
+				GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+					return database.DefaultPreferences(), nil
+				},
+			},
+			want: http.StatusOK,
+		},
+		{
+			name:    "error case - invalid parameters",
+			target:  "/search?to=tomorrow",
+			storage: &test.StorageMock{},
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:   "error case - search storage error",
+			target: "/search?q=go",
+			storage: &test.StorageMock{
+				SearchVideosStub: func(_ context.Context,
+					search database.VideoSearch) ([]database.VideoSearchResult, error) {
+					return nil, fmt.Errorf("test error")
+				},
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name:    tokenNotFound,
+			target:  "/search",
+			storage: &test.StorageMock{},
+			want:    http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetSearch(tt.storage, serverBasepath, "{{ range .Rows }}{{ .Snippet }}{{ end }}")
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("GetSearch() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchVideosAPI(t *testing.T) {
+	// mocks
+	const tokenNotFound = "error case - token not found in context"
+	publishedAt := time.Date(2025, time.February, 11, 0, 0, 0, 0, time.UTC)
+	storage := &test.StorageMock{
+		SearchVideosStub: func(_ context.Context, search database.VideoSearch) ([]database.VideoSearchResult, error) {
+			results := make([]database.VideoSearchResult, 0)
+			for i := range search.Limit {
+				results = append(results, database.VideoSearchResult{Video: database.Video{
+					VideoID: fmt.Sprintf("video%d", i), PublishedAt: &publishedAt}})
+			}
+			return results, nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{
+			name:   "success case",
+			method: http.MethodGet,
+			target: "/api/v1/videos/search?q=go",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "error case - invalid page",
+			method: http.MethodGet,
+			target: "/api/v1/videos/search?page=abc",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodPost,
+			target: "/api/v1/videos/search",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   tokenNotFound,
+			method: http.MethodGet,
+			target: "/api/v1/videos/search",
+			want:   http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := SearchVideosAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("SearchVideosAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				var response videoSearchResponse
+				if err = json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+					t.Fatalf("SearchVideosAPI() invalid JSON response: %v", err)
+				}
+				if len(response.Results) != searchPageSize || !response.HasNextPage {
+					t.Errorf("SearchVideosAPI() got %d results, has next page %v, want %d and true",
+						len(response.Results), response.HasNextPage, searchPageSize)
+				}
+			}
+		})
+	}
+}
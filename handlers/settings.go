@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"fmt"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type settingsTemplateResponse struct {
+	Username          string
+	CurrentAccountID  string
+	LinkedAccounts    []database.LinkedAccount
+	AccessTokens      []database.AccessToken
+	AccessTokenScopes []string
+	// NewAccessToken is the personal access token just created, shown only once
+	NewAccessToken string
+	Preferences    database.Preferences
+	VideoTypes     []string
+	ChannelGroups  []database.ChannelGroup
+	ServerBasepath string
+}
+
+// GetSettings renders the settings page, listing the Google accounts linked to the logged user,
+// its personal access tokens, preferences and channel groups
+func GetSettings(storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "GetSettings"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		renderSettings(w, r, storage, tokenInfo, "", serverBasepath, htmlTemplate, funcName)
+	}
+}
+
+// render the settings page of the user
+func renderSettings(w http.ResponseWriter, r *http.Request, storage database.StorageInterface,
+	tokenInfo *auth.TokenInfo, newAccessToken, serverBasepath, htmlTemplate, funcName string) {
+	linkedAccounts, err := storage.GetLinkedAccounts(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to retrieve linked accounts: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessTokens, err := storage.GetAccessTokens(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to retrieve access tokens: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	preferences, err := storage.GetPreferences(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to retrieve preferences: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	channelGroups, err := storage.GetChannelGroups(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to retrieve channel groups: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := settingsTemplateResponse{
+		Username:          tokenInfo.Username,
+		CurrentAccountID:  tokenInfo.UserId,
+		LinkedAccounts:    linkedAccounts,
+		AccessTokens:      accessTokens,
+		AccessTokenScopes: auth.AccessTokenScopes,
+		NewAccessToken:    newAccessToken,
+		Preferences:       preferences,
+		VideoTypes:        VideoTypes,
+		ChannelGroups:     channelGroups,
+		ServerBasepath:    serverBasepath,
+	}
+
+	// render response as HTML using a template
+	tmpl, err := template.New("settingsTemplate.tmpl").Parse(htmlTemplate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = tmpl.Execute(w, response)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// UnlinkAccount removes a linked Google account from the logged user
+func UnlinkAccount(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "UnlinkAccount"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		accountID := r.FormValue("account_id")
+		if accountID == "" {
+			err := fmt.Errorf("missing account_id")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the account used to log in can't be unlinked, as it identifies the user of the session
+		if accountID == tokenInfo.UserId {
+			err := fmt.Errorf("the account currently logged in can't be unlinked")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := storage.UnlinkAccount(r.Context(), tokenInfo.AppUserId, accountID)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to unlink account: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("account %s unlinked", accountID), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/settings", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// CreateAccessToken creates a new personal access token of the logged user, rendering the settings page
+// that shows it once
+func CreateAccessToken(storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "CreateAccessToken"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		// validate the form
+		if err := r.ParseForm(); err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSpace(r.PostForm.Get("name"))
+		if name == "" {
+			err := fmt.Errorf("missing name")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scopes := r.PostForm["scope"]
+		if len(scopes) == 0 {
+			err := fmt.Errorf("at least a scope must be granted")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(auth.AccessTokenScopes, scope) {
+				err := fmt.Errorf("unknown scope: %s", scope)
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		var expiresAt *time.Time
+		if days := r.PostForm.Get("expires_in_days"); days != "" && days != "0" {
+			expiresInDays, err := strconv.Atoi(days)
+			if err != nil || expiresInDays < 0 {
+				err = fmt.Errorf("invalid expires_in_days: %s", days)
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			expiry := time.Now().UTC().AddDate(0, 0, expiresInDays)
+			expiresAt = &expiry
+		}
+
+		// generate the token, only its hash is stored
+		accessToken, tokenHash, err := auth.GenerateAccessToken()
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to generate access token: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err = storage.CreateAccessToken(r.Context(), tokenInfo.AppUserId, name, tokenHash, scopes,
+			expiresAt); err != nil {
+			slog.Error(fmt.Sprintf("failed to store access token: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("access token %s created", name), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		renderSettings(w, r, storage, tokenInfo, accessToken, serverBasepath, htmlTemplate, funcName)
+	}
+}
+
+// DeleteAccessToken revokes a personal access token of the logged user
+func DeleteAccessToken(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "DeleteAccessToken"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid access token id: %s", r.FormValue("id"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err = storage.DeleteAccessToken(r.Context(), tokenInfo.AppUserId, id); err != nil {
+			slog.Error(fmt.Sprintf("failed to delete access token: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("access token %d deleted", id), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/settings", serverBasepath), http.StatusSeeOther)
+	}
+}
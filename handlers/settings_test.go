@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"fmt"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetSettings(t *testing.T) {
+	// mocks
+	const (
+		serverBasepath = "http://localhost:8900"
+		tokenNotFound  = "redirect case - token not found in context"
+	)
+
+	type args struct {
+		storage        database.StorageInterface
+		serverBasepath string
+		htmlTemplate   string
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "success case",
+			args: args{
+				storage: &test.StorageMock{
+					GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+						return []database.LinkedAccount{{AccountId: "1", DisplayName: "usertest"}}, nil
+					},
+					GetAccessTokensStub: func(_ context.Context, userId int64) ([]database.AccessToken, error) {
+						return []database.AccessToken{{Id: 1, Name: "cron", Scopes: []string{auth.ReadFeedScope}}}, nil
+					},
+					GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+						return database.DefaultPreferences(), nil
+					},
+					GetChannelGroupsStub: func(_ context.Context, userId int64) ([]database.ChannelGroup, error) {
+						return []database.ChannelGroup{{Id: 1, Name: "Music", ChannelIDs: []string{"channel1"}}}, nil
+					},
+				},
+				serverBasepath: serverBasepath,
+				htmlTemplate:   "",
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "error case - storage error",
+			args: args{
+				storage: &test.StorageMock{
+					GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+						return nil, fmt.Errorf("test error")
+					},
+				},
+				serverBasepath: serverBasepath,
+				htmlTemplate:   "",
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: tokenNotFound,
+			args: args{
+				storage:        &test.StorageMock{},
+				serverBasepath: serverBasepath,
+				htmlTemplate:   "",
+			},
+			want: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/settings", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(),
+					&auth.TokenInfo{Token: &oauth2.Token{}, UserId: "1", AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetSettings(tt.args.storage, tt.args.serverBasepath, tt.args.htmlTemplate)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("GetSettings() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnlinkAccount(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		UnlinkAccountStub: func(_ context.Context, userId int64, accountId string) error {
+			if accountId == "unknown" {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+	}
+	createMockRequest := func(method, accountID string) *http.Request {
+		form := url.Values{}
+		form.Add("account_id", accountID)
+		req, err := http.NewRequest(method, "/unlink-account", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req.WithContext(context.WithValue(req.Context(), auth.TokenCtxKey{},
+			&auth.TokenInfo{Token: &oauth2.Token{}, UserId: "1", AppUserId: 1}))
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    int
+	}{
+		{
+			name:    "success case",
+			request: createMockRequest(http.MethodPost, "2"),
+			want:    http.StatusSeeOther,
+		},
+		{
+			name:    "failure case - logged in account",
+			request: createMockRequest(http.MethodPost, "1"),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "failure case - missing account id",
+			request: createMockRequest(http.MethodPost, ""),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "failure case - storage error",
+			request: createMockRequest(http.MethodPost, "unknown"),
+			want:    http.StatusInternalServerError,
+		},
+		{
+			name:    "failure case - method not allowed",
+			request: createMockRequest(http.MethodGet, "2"),
+			want:    http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handlerFunction := UnlinkAccount(storage, serverBasepath)
+			handlerFunction(recorder, tt.request)
+			if recorder.Code != tt.want {
+				t.Errorf("UnlinkAccount() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAccessToken(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	var storedHash string
+	storage := &test.StorageMock{
+		CreateAccessTokenStub: func(_ context.Context, userId int64, name, tokenHash string, scopes []string,
+			expiresAt *time.Time) (int64, error) {
+			if name == "failing" {
+				return 0, fmt.Errorf("test error")
+			}
+			storedHash = tokenHash
+			return 1, nil
+		},
+		GetLinkedAccountsStub: func(_ context.Context, userId int64) ([]database.LinkedAccount, error) {
+			return []database.LinkedAccount{}, nil
+		},
+		GetAccessTokensStub: func(_ context.Context, userId int64) ([]database.AccessToken, error) {
+			return []database.AccessToken{}, nil
+		},
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+		GetChannelGroupsStub: func(_ context.Context, userId int64) ([]database.ChannelGroup, error) {
+			return []database.ChannelGroup{}, nil
+		},
+	}
+	createMockRequest := func(method string, form url.Values) *http.Request {
+		req, err := http.NewRequest(method, "/create-access-token", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req.WithContext(context.WithValue(req.Context(), auth.TokenCtxKey{},
+			&auth.TokenInfo{Token: &oauth2.Token{}, UserId: "1", AppUserId: 1}))
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    int
+	}{
+		{
+			name: "success case",
+			request: createMockRequest(http.MethodPost, url.Values{"name": {"cron"},
+				"scope": {auth.ReadFeedScope, auth.MarkViewedScope}, "expires_in_days": {"30"}}),
+			want: http.StatusOK,
+		},
+		{
+			name:    "failure case - missing name",
+			request: createMockRequest(http.MethodPost, url.Values{"scope": {auth.ReadFeedScope}}),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "failure case - unknown scope",
+			request: createMockRequest(http.MethodPost, url.Values{"name": {"cron"}, "scope": {"unknown"}}),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name: "failure case - invalid expiry",
+			request: createMockRequest(http.MethodPost, url.Values{"name": {"cron"},
+				"scope": {auth.ReadFeedScope}, "expires_in_days": {"-1"}}),
+			want: http.StatusBadRequest,
+		},
+		{
+			name:    "failure case - storage error",
+			request: createMockRequest(http.MethodPost, url.Values{"name": {"failing"}, "scope": {auth.ReadFeedScope}}),
+			want:    http.StatusInternalServerError,
+		},
+		{
+			name:    "failure case - method not allowed",
+			request: createMockRequest(http.MethodGet, url.Values{}),
+			want:    http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storedHash = ""
+			recorder := httptest.NewRecorder()
+			handlerFunction := CreateAccessToken(storage, serverBasepath, "{{ .NewAccessToken }}")
+			handlerFunction(recorder, tt.request)
+			if recorder.Code != tt.want {
+				t.Errorf("CreateAccessToken() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && auth.HashAccessToken(recorder.Body.String()) != storedHash {
+				t.Errorf("CreateAccessToken() shown token does not match the stored hash")
+			}
+		})
+	}
+}
+
+func TestDeleteAccessToken(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		DeleteAccessTokenStub: func(_ context.Context, userId, id int64) error {
+			if id != 1 {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+	}
+	createMockRequest := func(method, id string) *http.Request {
+		form := url.Values{}
+		form.Add("id", id)
+		req, err := http.NewRequest(method, "/delete-access-token", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req.WithContext(context.WithValue(req.Context(), auth.TokenCtxKey{},
+			&auth.TokenInfo{Token: &oauth2.Token{}, UserId: "1", AppUserId: 1}))
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    int
+	}{
+		{
+			name:    "success case",
+			request: createMockRequest(http.MethodPost, "1"),
+			want:    http.StatusSeeOther,
+		},
+		{
+			name:    "failure case - invalid id",
+			request: createMockRequest(http.MethodPost, "abc"),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "failure case - storage error",
+			request: createMockRequest(http.MethodPost, "2"),
+			want:    http.StatusInternalServerError,
+		},
+		{
+			name:    "failure case - method not allowed",
+			request: createMockRequest(http.MethodGet, "1"),
+			want:    http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handlerFunction := DeleteAccessToken(storage, serverBasepath)
+			handlerFunction(recorder, tt.request)
+			if recorder.Code != tt.want {
+				t.Errorf("DeleteAccessToken() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+)
+
+// max number of subscription changes shown in the subscription history
+const subscriptionHistorySize = 200
+
+// accountSubscriptions are the subscriptions of a Google account listed during a sync
+type accountSubscriptions struct {
+	account       *auth.TokenInfo
+	svc           clients.YoutubeClientInterface
+	subscriptions []subscriptionRow
+}
+
+// subscriptionChangeRow is a subscription change in the subscription history, along with the name of its account
+type subscriptionChangeRow struct {
+	database.SubscriptionChange
+	Account string
+}
+
+type subscriptionHistoryTemplateResponse struct {
+	Username         string
+	Changes          []subscriptionChangeRow
+	MultipleAccounts bool
+	Theme            string
+	ServerBasepath   string
+}
+
+type subscriptionChangeResponse struct {
+	ID            int64  `json:"id"`
+	AccountID     string `json:"account_id"`
+	ChannelID     string `json:"channel_id"`
+	ChannelTitle  string `json:"channel_title"`
+	PreviousTitle string `json:"previous_title,omitempty"`
+	Kind          string `json:"kind"`
+	DetectedAt    string `json:"detected_at"`
+}
+
+// GetSubscriptionHistory renders the latest changes of the subscriptions of the accounts linked to the logged user
+func GetSubscriptionHistory(storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "GetSubscriptionHistory"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		changes, err := storage.GetSubscriptionChanges(r.Context(), tokenInfo.AppUserId, subscriptionHistorySize)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve subscription changes: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		accounts := contextAccounts(r, tokenInfo)
+		accountNames := make(map[string]string, len(accounts))
+		for _, account := range accounts {
+			accountNames[account.UserId] = account.Username
+		}
+		response := subscriptionHistoryTemplateResponse{
+			Username:         tokenInfo.Username,
+			Changes:          make([]subscriptionChangeRow, 0, len(changes)),
+			MultipleAccounts: len(accounts) > 1,
+			Theme:            userPreferences(r, storage, tokenInfo, funcName).Theme,
+			ServerBasepath:   serverBasepath,
+		}
+		for _, change := range changes {
+			// accounts unlinked since the change are shown by id
+			account, ok := accountNames[change.AccountId]
+			if !ok {
+				account = change.AccountId
+			}
+			response.Changes = append(response.Changes, subscriptionChangeRow{SubscriptionChange: change,
+				Account: account})
+		}
+
+		// render response as HTML using a template
+		tmpl, err := template.New("subscriptionHistoryTemplate.tmpl").Parse(htmlTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = tmpl.Execute(w, response)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// SubscriptionChangesAPI lists the latest changes of the subscriptions of the user as JSON
+func SubscriptionChangesAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "SubscriptionChangesAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		changes, err := storage.GetSubscriptionChanges(r.Context(), tokenInfo.AppUserId, subscriptionHistorySize)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve subscription changes: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]subscriptionChangeResponse, 0, len(changes))
+		for _, change := range changes {
+			response = append(response, subscriptionChangeResponse{
+				ID:            change.Id,
+				AccountID:     change.AccountId,
+				ChannelID:     change.ChannelID,
+				ChannelTitle:  change.ChannelTitle,
+				PreviousTitle: change.PreviousTitle,
+				Kind:          change.Kind,
+				DetectedAt:    change.DetectedAt.UTC().Format("2006-01-02T15:04:05Z"),
+			})
+		}
+		writeJSON(w, response, funcName)
+	}
+}
+
+// snapshot the subscriptions of each synced account and record how they changed since the previous sync. The
+// previous snapshot is read, compared and replaced in a single transaction holding the lock of the account, so that
+// concurrent syncs don't record the same changes twice. YouTube is asked whether the channels of the removed
+// subscriptions still exist before the transaction, not to hold the lock meanwhile. Failures are logged only, not to
+// prevent the feed from being shown
+func recordSubscriptionChanges(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	synced []accountSubscriptions, funcName string) {
+	// users logged in with a login-only provider and no linked account have nowhere to store the snapshot
+	if tokenInfo.AppUserId == 0 {
+		return
+	}
+
+	for _, s := range synced {
+		channels := make([]database.SubscribedChannel, 0, len(s.subscriptions))
+		for _, subscription := range s.subscriptions {
+			channels = append(channels, database.SubscribedChannel{ChannelID: subscription.ChannelID,
+				ChannelTitle: subscription.Title})
+		}
+		previous, err := storage.GetSubscribedChannels(r.Context(), tokenInfo.AppUserId, s.account.UserId)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("failed to retrieve the subscriptions snapshot of account %s: %s",
+				s.account.Username, err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			continue
+		}
+		existing := existingChannels(r.Context(), s.svc, removedChannelIDs(previous, s.subscriptions),
+			tokenInfo.Username)
+
+		err = storage.WithTx(r.Context(), func(tx database.StorageInterface) error {
+			err := tx.LockSubscribedChannels(r.Context(), tokenInfo.AppUserId, s.account.UserId)
+			if err != nil {
+				return err
+			}
+			// the snapshot is read again holding the lock, a concurrent sync may have replaced it
+			snapshotTaken, err := tx.HasSubscriptionSnapshot(r.Context(), tokenInfo.AppUserId, s.account.UserId)
+			if err != nil {
+				return err
+			}
+			previous, err := tx.GetSubscribedChannels(r.Context(), tokenInfo.AppUserId, s.account.UserId)
+			if err != nil {
+				return err
+			}
+			changes := diffSubscriptions(previous, s.subscriptions, snapshotTaken)
+			markTerminatedChannels(changes, existing)
+			for i := range changes {
+				changes[i].UserId = tokenInfo.AppUserId
+				changes[i].AccountId = s.account.UserId
+			}
+
+			if err = tx.ReplaceSubscribedChannels(r.Context(), tokenInfo.AppUserId, s.account.UserId,
+				channels); err != nil {
+				return err
+			}
+			return tx.AddSubscriptionChanges(r.Context(), changes)
+		})
+		if err != nil {
+			slog.Warn(fmt.Sprintf("failed to record the subscription changes of account %s: %s",
+				s.account.Username, err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		}
+	}
+}
+
+// return the changes between the previous snapshot of the subscriptions of an account and the current ones: the
+// channels added, the ones removed and the ones renamed. The first snapshot is a baseline, recording no change,
+// while the subscriptions added to an account snapshotted without any are recorded
+func diffSubscriptions(previous []database.SubscribedChannel, current []subscriptionRow,
+	snapshotTaken bool) []database.SubscriptionChange {
+	changes := make([]database.SubscriptionChange, 0)
+	if !snapshotTaken {
+		return changes
+	}
+
+	previousTitles := make(map[string]string, len(previous))
+	for _, channel := range previous {
+		previousTitles[channel.ChannelID] = channel.ChannelTitle
+	}
+	currentIDs := make(map[string]bool, len(current))
+	for _, subscription := range current {
+		currentIDs[subscription.ChannelID] = true
+		previousTitle, ok := previousTitles[subscription.ChannelID]
+		switch {
+		case !ok:
+			changes = append(changes, database.SubscriptionChange{ChannelID: subscription.ChannelID,
+				ChannelTitle: subscription.Title, Kind: database.SubscriptionAdded})
+		case previousTitle != subscription.Title:
+			changes = append(changes, database.SubscriptionChange{ChannelID: subscription.ChannelID,
+				ChannelTitle: subscription.Title, PreviousTitle: previousTitle, Kind: database.ChannelRenamed})
+		}
+	}
+	for _, channel := range previous {
+		if !currentIDs[channel.ChannelID] {
+			changes = append(changes, database.SubscriptionChange{ChannelID: channel.ChannelID,
+				ChannelTitle: channel.ChannelTitle, Kind: database.SubscriptionRemoved})
+		}
+	}
+	return changes
+}
+
+// return the ids of the channels of the previous snapshot the account is no longer subscribed to
+func removedChannelIDs(previous []database.SubscribedChannel, current []subscriptionRow) []string {
+	currentIDs := make(map[string]bool, len(current))
+	for _, subscription := range current {
+		currentIDs[subscription.ChannelID] = true
+	}
+	channelIDs := make([]string, 0)
+	for _, channel := range previous {
+		if !currentIDs[channel.ChannelID] {
+			channelIDs = append(channelIDs, channel.ChannelID)
+		}
+	}
+	return channelIDs
+}
+
+// tell whether each of the given channels still exists, calling the YouTube channels API. None is returned when the
+// channels can't be retrieved
+func existingChannels(ctx context.Context, svc clients.YoutubeClientInterface, channelIDs []string,
+	username string) map[string]bool {
+	const funcName = "existingChannels"
+	found := make(map[string]bool, len(channelIDs))
+	maxItems := 50 // YouTube API limit
+	for i := 0; i < len(channelIDs); i += maxItems {
+		end := i + maxItems
+		if end > len(channelIDs) {
+			end = len(channelIDs)
+		}
+		channels, err := svc.GetChannels(ctx, channelIDs[i:end])
+		if err != nil {
+			slog.Warn(fmt.Sprintf("failed to retrieve the channels of the removed subscriptions: %s",
+				err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(username))
+			return nil
+		}
+		for _, channel := range channels {
+			found[channel.Id] = true
+		}
+	}
+
+	existing := make(map[string]bool, len(channelIDs))
+	for _, channelID := range channelIDs {
+		existing[channelID] = found[channelID]
+	}
+	return existing
+}
+
+// mark the removed subscriptions whose channel no longer exists as terminated, the ones whose channel wasn't looked
+// up are left as removed
+func markTerminatedChannels(changes []database.SubscriptionChange, existing map[string]bool) {
+	for i, change := range changes {
+		if exists, ok := existing[change.ChannelID]; ok && !exists && change.Kind == database.SubscriptionRemoved {
+			changes[i].Kind = database.ChannelTerminated
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"checkYoutube/web"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_diffSubscriptions(t *testing.T) {
+	previous := []database.SubscribedChannel{
+		{ChannelID: "UCkept", ChannelTitle: "Kept"},
+		{ChannelID: "UCrenamed", ChannelTitle: "Old name"},
+		{ChannelID: "UCremoved", ChannelTitle: "Removed"},
+	}
+	current := []subscriptionRow{
+		{ChannelID: "UCkept", Title: "Kept"},
+		{ChannelID: "UCrenamed", Title: "New name"},
+		{ChannelID: "UCadded", Title: "Added"},
+	}
+
+	tests := []struct {
+		name          string
+		previous      []database.SubscribedChannel
+		snapshotTaken bool
+		want          []database.SubscriptionChange
+	}{
+		{
+			name:          "success case - changes",
+			previous:      previous,
+			snapshotTaken: true,
+			want: []database.SubscriptionChange{
+				{ChannelID: "UCrenamed", ChannelTitle: "New name", PreviousTitle: "Old name",
+					Kind: database.ChannelRenamed},
+				{ChannelID: "UCadded", ChannelTitle: "Added", Kind: database.SubscriptionAdded},
+				{ChannelID: "UCremoved", ChannelTitle: "Removed", Kind: database.SubscriptionRemoved},
+			},
+		},
+		{
+			name: "success case - first snapshot",
+			want: []database.SubscriptionChange{},
+		},
+		{
+			name:          "success case - account snapshotted without subscriptions",
+			snapshotTaken: true,
+			want: []database.SubscriptionChange{
+				{ChannelID: "UCkept", ChannelTitle: "Kept", Kind: database.SubscriptionAdded},
+				{ChannelID: "UCrenamed", ChannelTitle: "New name", Kind: database.SubscriptionAdded},
+				{ChannelID: "UCadded", ChannelTitle: "Added", Kind: database.SubscriptionAdded},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffSubscriptions(tt.previous, current, tt.snapshotTaken)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diffSubscriptions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_existingChannels(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want map[string]bool
+	}{
+		{
+			name: "success case",
+			want: map[string]bool{"UCremoved": true, "UCterminated": false},
+		},
+		{
+			name: "error case - channels not retrieved",
+			err:  fmt.Errorf("test error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &youtubeClientMock{
+				getChannelsStub: func(_ context.Context, channelIDs []string) ([]*youtube.Channel, error) {
+					if diff := cmp.Diff([]string{"UCremoved", "UCterminated"}, channelIDs); diff != "" {
+						t.Errorf("GetChannels() channel ids mismatch (-want +got):\n%s", diff)
+					}
+					return []*youtube.Channel{{Id: "UCremoved"}}, tt.err
+				},
+			}
+
+			got := existingChannels(context.Background(), svc, []string{"UCremoved", "UCterminated"}, "")
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("existingChannels() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_markTerminatedChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string]bool
+		want     []string
+	}{
+		{
+			name:     "success case",
+			existing: map[string]bool{"UCremoved": true, "UCterminated": false},
+			want:     []string{database.SubscriptionAdded, database.SubscriptionRemoved, database.ChannelTerminated},
+		},
+		{
+			name: "success case - channels not looked up",
+			want: []string{database.SubscriptionAdded, database.SubscriptionRemoved, database.SubscriptionRemoved},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := []database.SubscriptionChange{
+				{ChannelID: "UCadded", Kind: database.SubscriptionAdded},
+				{ChannelID: "UCremoved", Kind: database.SubscriptionRemoved},
+				{ChannelID: "UCterminated", Kind: database.SubscriptionRemoved},
+			}
+
+			markTerminatedChannels(changes, tt.existing)
+			got := make([]string, 0, len(changes))
+			for _, change := range changes {
+				got = append(got, change.Kind)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("markTerminatedChannels() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_recordSubscriptionChanges(t *testing.T) {
+	var gotChannels []database.SubscribedChannel
+	var gotChanges []database.SubscriptionChange
+	locked := false
+	storage := &test.StorageMock{
+		LockSubscribedChannelsStub: func(_ context.Context, userId int64, accountId string) error {
+			locked = userId == 1 && accountId == "account1"
+			return nil
+		},
+		HasSubscriptionSnapshotStub: func(_ context.Context, userId int64, accountId string) (bool, error) {
+			// the previous snapshot is read holding the lock of the account
+			if !locked {
+				return false, fmt.Errorf("account %d/%s not locked", userId, accountId)
+			}
+			return true, nil
+		},
+		GetSubscribedChannelsStub: func(_ context.Context, userId int64,
+			accountId string) ([]database.SubscribedChannel, error) {
+			if userId != 1 || accountId != "account1" {
+				return nil, fmt.Errorf("unexpected account %d/%s", userId, accountId)
+			}
+			return []database.SubscribedChannel{{ChannelID: "UCkept", ChannelTitle: "Kept"},
+				{ChannelID: "UCterminated", ChannelTitle: "Terminated"}}, nil
+		},
+		ReplaceSubscribedChannelsStub: func(_ context.Context, userId int64, accountId string,
+			channels []database.SubscribedChannel) error {
+			gotChannels = channels
+			return nil
+		},
+		AddSubscriptionChangesStub: func(_ context.Context, changes []database.SubscriptionChange) error {
+			gotChanges = changes
+			return nil
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, "/check-youtube", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := &auth.TokenInfo{UserId: "account1", AppUserId: 1}
+	svc := &youtubeClientMock{
+		getChannelsStub: func(context.Context, []string) ([]*youtube.Channel, error) {
+			// YouTube isn't called holding the lock of the account
+			if locked {
+				t.Errorf("GetChannels() called holding the lock")
+			}
+			return []*youtube.Channel{}, nil
+		},
+	}
+	synced := []accountSubscriptions{{account: account, svc: svc,
+		subscriptions: []subscriptionRow{{ChannelID: "UCkept", Title: "Kept"}, {ChannelID: "UCadded", Title: "Added"}}}}
+
+	recordSubscriptionChanges(req, storage, account, synced, "")
+	wantChannels := []database.SubscribedChannel{{ChannelID: "UCkept", ChannelTitle: "Kept"},
+		{ChannelID: "UCadded", ChannelTitle: "Added"}}
+	if diff := cmp.Diff(wantChannels, gotChannels); diff != "" {
+		t.Errorf("recordSubscriptionChanges() channels mismatch (-want +got):\n%s", diff)
+	}
+	wantChanges := []database.SubscriptionChange{
+		{UserId: 1, AccountId: "account1", ChannelID: "UCadded", ChannelTitle: "Added",
+			Kind: database.SubscriptionAdded},
+		{UserId: 1, AccountId: "account1", ChannelID: "UCterminated", ChannelTitle: "Terminated",
+			Kind: database.ChannelTerminated},
+	}
+	if diff := cmp.Diff(wantChanges, gotChanges); diff != "" {
+		t.Errorf("recordSubscriptionChanges() changes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetSubscriptionHistory(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		GetSubscriptionChangesStub: func(_ context.Context, userId int64,
+			limit int) ([]database.SubscriptionChange, error) {
+			if userId != 1 {
+				return nil, fmt.Errorf("test error")
+			}
+			return []database.SubscriptionChange{
+				{Id: 2, AccountId: "account1", ChannelID: "UCrenamed", ChannelTitle: "New name",
+					PreviousTitle: "Old name", Kind: database.ChannelRenamed, DetectedAt: time.Now()},
+				{Id: 1, AccountId: "account1", ChannelID: "UCterminated", ChannelTitle: "Terminated",
+					Kind: database.ChannelTerminated, DetectedAt: time.Now()},
+			}, nil
+		},
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		tokenInfo    *auth.TokenInfo
+		want         int
+		wantContains []string
+	}{
+		{
+			name:         "success case",
+			tokenInfo:    &auth.TokenInfo{UserId: "account1", AppUserId: 1},
+			want:         http.StatusOK,
+			wantContains: []string{"renamed from Old name", "channel terminated"},
+		},
+		{
+			name:      "error case - storage error",
+			tokenInfo: &auth.TokenInfo{AppUserId: 2},
+			want:      http.StatusInternalServerError,
+		},
+		{
+			name: "redirect case - token not found in context",
+			want: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/subscription-history", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tokenInfo != nil {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), tt.tokenInfo))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetSubscriptionHistory(storage, serverBasepath, string(web.SubscriptionHistoryTemplate))
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("GetSubscriptionHistory() = %v, want %v", recorder.Code, tt.want)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(recorder.Body.String(), want) {
+					t.Errorf("GetSubscriptionHistory() body doesn't contain %s, got %s", want,
+						recorder.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestSubscriptionChangesAPI(t *testing.T) {
+	// mocks
+	storage := &test.StorageMock{
+		GetSubscriptionChangesStub: func(_ context.Context, userId int64,
+			limit int) ([]database.SubscriptionChange, error) {
+			return []database.SubscriptionChange{{Id: 1, AccountId: "account1", ChannelID: "UCadded",
+				ChannelTitle: "Added", Kind: database.SubscriptionAdded}}, nil
+		},
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		want      int
+		wantCount int
+	}{
+		{
+			name:      "success case",
+			method:    http.MethodGet,
+			want:      http.StatusOK,
+			wantCount: 1,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodPost,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/api/v1/subscriptions/changes", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := SubscriptionChangesAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("SubscriptionChangesAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				var got []subscriptionChangeResponse
+				if err = json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != tt.wantCount {
+					t.Errorf("SubscriptionChangesAPI() returned %d changes, want %d", len(got), tt.wantCount)
+				}
+			}
+		})
+	}
+}
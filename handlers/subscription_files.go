@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/errors"
+	"checkYoutube/logging"
+	"checkYoutube/subscriptionio"
+	errors2 "errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// max size of an imported subscriptions file
+const maxImportSize = 1 << 20
+
+// format of the subscriptions files when none is given
+const defaultSubscriptionsFormat = "opml"
+
+// targets of an import: local follows or YouTube subscriptions of the logged account
+const (
+	followImportTarget    = "follow"
+	subscribeImportTarget = "subscribe"
+)
+
+// statuses of an imported channel in the import preview
+const (
+	newImportStatus        = "new"
+	subscribedImportStatus = "subscribed"
+	followedImportStatus   = "followed"
+)
+
+// importedChannel is a channel read from an imported file
+type importedChannel struct {
+	ChannelID string
+	Title     string
+	// Status tells whether the channel is new or already subscribed or followed
+	Status string
+}
+
+// importPreview lists the channels of an imported file for the user to confirm the import
+type importPreview struct {
+	Format   string
+	Target   string
+	Channels []importedChannel
+	NewCount int
+	// Invalid is the number of entries of the file that aren't YouTube channels
+	Invalid int
+	// Duplicates is the number of entries of the file listing a channel already listed
+	Duplicates int
+}
+
+// ExportSubscriptions downloads the subscriptions of the logged account and the channels followed by the user as a
+// file of the format query parameter, OPML by default
+func ExportSubscriptions(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "ExportSubscriptions"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		format, ok := subscriptionsFormat(r.URL.Query().Get("format"))
+		if !ok {
+			err := fmt.Errorf("unknown format: %s", r.URL.Query().Get("format"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		channels := make([]subscriptionio.Channel, 0)
+		// the token of users logged in with a login-only provider can't access YouTube
+		if !tokenInfo.LoginOnly {
+			youtubeSvc, err := ytcf.NewClient(oauth2C.CreateTokenSource(r.Context(), tokenInfo.Token))
+			if err != nil {
+				slog.Error(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			subscriptions, err := getSubscriptionRows(r, youtubeSvc)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve subscriptions: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			for _, subscription := range subscriptions {
+				channels = append(channels, subscriptionio.Channel{ID: subscription.ChannelID,
+					Title: subscription.Title})
+			}
+		}
+		followed, err := storage.GetFollowedChannels(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve followed channels: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, channel := range followed {
+			channels = append(channels, subscriptionio.Channel{ID: channel.ChannelID, Title: channel.ChannelTitle})
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, format.Filename()))
+		if err = subscriptionio.Export(format, w, channels); err != nil {
+			slog.Error(fmt.Sprintf("failed to write the %s response: %s", format.Name(), err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		}
+	}
+}
+
+// ImportSubscriptions imports the channels of the file uploaded, of the format form value, as channels followed by
+// the user or, when the target form value is subscribe, as YouTube subscriptions of the logged account. The
+// channels are listed for the user to confirm first, they are imported only when the confirm form value is true
+func ImportSubscriptions(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "ImportSubscriptions"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		// validate the form
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+		if err := r.ParseMultipartForm(maxImportSize); err != nil && !errors2.Is(err, http.ErrNotMultipart) {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		target := r.FormValue("target")
+		if target != followImportTarget && target != subscribeImportTarget {
+			err := fmt.Errorf("invalid target: %s", target)
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// subscribing needs the write scope, asked before the file is read
+		var youtubeSvc clients.YoutubeClientInterface
+		if target == subscribeImportTarget {
+			var ok bool
+			youtubeSvc, tokenInfo, ok = subscriptionsClient(w, r, oauth2C, ytcf, serverBasepath, "/subscriptions",
+				funcName)
+			if !ok {
+				return
+			}
+		}
+
+		if r.FormValue("confirm") != "true" {
+			format, ok := subscriptionsFormat(r.FormValue("format"))
+			if !ok {
+				err := fmt.Errorf("unknown format: %s", r.FormValue("format"))
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			parsed, err := subscriptionio.Parse(format, file)
+			if err != nil {
+				err = fmt.Errorf("invalid %s file: %w", format.Label(), err)
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			renderImportPreview(w, r, oauth2C, ytcf, storage, tokenInfo, format, target, parsed, serverBasepath,
+				htmlTemplate, funcName)
+			return
+		}
+
+		channels := confirmedImportChannels(r)
+		switch target {
+		case followImportTarget:
+			followed := make([]database.FollowedChannel, 0, len(channels))
+			for _, channel := range channels {
+				followed = append(followed, database.FollowedChannel{ChannelID: channel.ID,
+					ChannelTitle: channel.Title})
+			}
+			added, err := storage.AddFollowedChannels(r.Context(), tokenInfo.AppUserId, followed)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to follow channels: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			slog.Info(fmt.Sprintf("%d channels followed", added), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+		case subscribeImportTarget:
+			// the channels that can't be subscribed to are skipped, the user finds them missing
+			for _, channel := range channels {
+				_, err := youtubeSvc.Subscribe(r.Context(), channel.ID)
+				if errors2.As(err, &errors.InsufficientScopeErr{}) {
+					redirectToGrantWriteAccess(w, r, tokenInfo, serverBasepath, "/subscriptions", funcName)
+					return
+				}
+				if err != nil {
+					slog.Error(fmt.Sprintf("failed to subscribe to channel %s: %s", channel.ID, err.Error()),
+						logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+					continue
+				}
+				slog.Info(fmt.Sprintf("subscribed to channel %s", channel.ID), logging.FuncNameAttr(funcName),
+					logging.UserAttr(tokenInfo.Username))
+			}
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("%s/subscriptions", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// Unfollow makes the user stop following the channel of the channel_id form value
+func Unfollow(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "Unfollow"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		channelID := strings.TrimSpace(r.FormValue("channel_id"))
+		if err := storage.DeleteFollowedChannel(r.Context(), tokenInfo.AppUserId, channelID); err != nil {
+			slog.Warn(fmt.Sprintf("failed to unfollow channel: %s", err.Error()), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info(fmt.Sprintf("channel %s unfollowed", channelID), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+
+		http.Redirect(w, r, fmt.Sprintf("%s/subscriptions", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// return the channels followed by the logged user, none when they can't be retrieved
+func userFollowedChannels(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	funcName string) []database.FollowedChannel {
+	followed, err := storage.GetFollowedChannels(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to retrieve followed channels, ignoring them: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		return []database.FollowedChannel{}
+	}
+	return followed
+}
+
+// return the subscriptions file format of the given name, the default one if empty
+func subscriptionsFormat(name string) (subscriptionio.Format, bool) {
+	if name == "" {
+		name = defaultSubscriptionsFormat
+	}
+	return subscriptionio.Lookup(name)
+}
+
+// render the channels to import, telling the ones already subscribed to by the logged account or followed by the
+// user apart from the new ones
+func renderImportPreview(w http.ResponseWriter, r *http.Request, oauth2C auth.Oauth2Config,
+	ytcf clients.YoutubeClientFactoryInterface, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	format subscriptionio.Format, target string, parsed *subscriptionio.Parsed, serverBasepath, htmlTemplate,
+	funcName string) {
+	statuses := make(map[string]string)
+	followed, err := storage.GetFollowedChannels(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to retrieve followed channels: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, channel := range followed {
+		statuses[channel.ChannelID] = followedImportStatus
+	}
+	if !tokenInfo.LoginOnly {
+		youtubeSvc, err := ytcf.NewClient(oauth2C.CreateTokenSource(r.Context(), tokenInfo.Token))
+		if err != nil {
+			slog.Error(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		subscriptions, err := getSubscriptionRows(r, youtubeSvc)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve subscriptions: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for _, subscription := range subscriptions {
+			statuses[subscription.ChannelID] = subscribedImportStatus
+		}
+	}
+
+	preview := &importPreview{
+		Format:     format.Label(),
+		Target:     target,
+		Channels:   make([]importedChannel, 0, len(parsed.Channels)),
+		Invalid:    parsed.Invalid,
+		Duplicates: parsed.Duplicates,
+	}
+	for _, channel := range parsed.Channels {
+		status, ok := statuses[channel.ID]
+		if !ok {
+			status = newImportStatus
+			preview.NewCount++
+		}
+		preview.Channels = append(preview.Channels, importedChannel{ChannelID: channel.ID, Title: channel.Title,
+			Status: status})
+	}
+	renderSubscriptions(w, subscriptionsTemplateResponse{
+		Username:       tokenInfo.Username,
+		Import:         preview,
+		WriteAccess:    tokenInfo.WriteAccess,
+		LoginOnly:      tokenInfo.LoginOnly,
+		Theme:          userPreferences(r, storage, tokenInfo, funcName).Theme,
+		ServerBasepath: serverBasepath,
+	}, htmlTemplate)
+}
+
+// return the channels of the channel_id form values confirmed in the import preview, with the titles sent along.
+// The values that aren't channel ids are left out
+func confirmedImportChannels(r *http.Request) []subscriptionio.Channel {
+	channels := make([]subscriptionio.Channel, 0)
+	for _, channelID := range r.Form["channel_id"] {
+		channelID = strings.TrimSpace(channelID)
+		if !subscriptionio.ValidChannelID(channelID) {
+			continue
+		}
+		channels = append(channels, subscriptionio.Channel{ID: channelID, Title: r.FormValue("title_" + channelID)})
+	}
+	return channels
+}
@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"bytes"
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"checkYoutube/web"
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const (
+	importChannel1 = "UC1234567890123456789012"
+	importChannel2 = "UCabcdefghijklmnopqrstuv"
+)
+
+func TestExportSubscriptions(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	storage := &test.StorageMock{
+		GetFollowedChannelsStub: func(_ context.Context, userId int64) ([]database.FollowedChannel, error) {
+			return []database.FollowedChannel{{ChannelID: importChannel2, ChannelTitle: "Rust"}}, nil
+		},
+	}
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return &youtubeClientMock{
+				getAndProcessSubscriptionsStub: func(ctx context.Context,
+					f func(*youtube.SubscriptionListResponse) error) error {
+					return f(&youtube.SubscriptionListResponse{Items: []*youtube.Subscription{{Id: "sub1",
+						Snippet: &youtube.SubscriptionSnippet{Title: "Go",
+							ResourceId: &youtube.ResourceId{ChannelId: importChannel1}}}}})
+				},
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		tokenInfo    *auth.TokenInfo
+		want         int
+		wantContains []string
+	}{
+		{
+			name:         "success case - OPML by default",
+			target:       "/export-subscriptions",
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:         http.StatusOK,
+			wantContains: []string{`title="Go"`, `title="Rust"`},
+		},
+		{
+			name:         "success case - Google Takeout",
+			target:       "/export-subscriptions?format=takeout",
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:         http.StatusOK,
+			wantContains: []string{"Channel Id,Channel Url,Channel Title\n", importChannel1 + ",", ",Rust\n"},
+		},
+		{
+			name:         "success case - login-only user",
+			target:       "/export-subscriptions",
+			tokenInfo:    &auth.TokenInfo{LoginOnly: true},
+			want:         http.StatusOK,
+			wantContains: []string{`title="Rust"`},
+		},
+		{
+			name:      "error case - unknown format",
+			target:    "/export-subscriptions?format=rss",
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:      http.StatusBadRequest,
+		},
+		{
+			name:   "redirect case - token not found in context",
+			target: "/export-subscriptions",
+			want:   http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tokenInfo != nil {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), tt.tokenInfo))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := ExportSubscriptions(oauth2C, ytcf, storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("ExportSubscriptions() = %v, want %v", recorder.Code, tt.want)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(recorder.Body.String(), want) {
+					t.Errorf("ExportSubscriptions() body doesn't contain %s, got %s", want, recorder.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// return a request uploading the given subscriptions file along with the given form values
+func uploadRequest(t *testing.T, file string, values map[string]string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range values {
+		if err := writer.WriteField(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", "subscriptions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = part.Write([]byte(file)); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, "/import-subscriptions", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestImportSubscriptions(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	var followed []database.FollowedChannel
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+		GetFollowedChannelsStub: func(_ context.Context, userId int64) ([]database.FollowedChannel, error) {
+			return []database.FollowedChannel{}, nil
+		},
+		AddFollowedChannelsStub: func(_ context.Context, userId int64,
+			channels []database.FollowedChannel) (int, error) {
+			followed = channels
+			return len(channels), nil
+		},
+	}
+	var subscribed []string
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return &youtubeClientMock{
+				getAndProcessSubscriptionsStub: func(ctx context.Context,
+					f func(*youtube.SubscriptionListResponse) error) error {
+					return f(&youtube.SubscriptionListResponse{Items: []*youtube.Subscription{{Id: "sub1",
+						Snippet: &youtube.SubscriptionSnippet{Title: "Go",
+							ResourceId: &youtube.ResourceId{ChannelId: importChannel1}}}}})
+				},
+				subscribeStub: func(_ context.Context, channelID string) (*youtube.Subscription, error) {
+					subscribed = append(subscribed, channelID)
+					return &youtube.Subscription{}, nil
+				},
+			}, nil
+		},
+	}
+	opml := `<opml version="1.1"><body>
+		<outline text="Go" xmlUrl="https://www.youtube.com/feeds/videos.xml?channel_id=` + importChannel1 + `"/>
+		<outline text="Rust" xmlUrl="https://www.youtube.com/feeds/videos.xml?channel_id=` + importChannel2 + `"/>
+		</body></opml>`
+	confirmForm := func(target string) *http.Request {
+		form := url.Values{"target": {target}, "confirm": {"true"}, "channel_id": {importChannel2, "invalid"},
+			"title_" + importChannel2: {"Rust"}}
+		req, err := http.NewRequest(http.MethodPost, "/import-subscriptions", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	tests := []struct {
+		name           string
+		req            *http.Request
+		tokenInfo      *auth.TokenInfo
+		want           int
+		wantContains   []string
+		wantFollowed   []database.FollowedChannel
+		wantSubscribed []string
+	}{
+		{
+			name:      "success case - preview",
+			req:       uploadRequest(t, opml, map[string]string{"target": followImportTarget}),
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:      http.StatusOK,
+			wantContains: []string{"1 of 2 channels of the OPML (feed readers) file are new", "already subscribed",
+				`value="` + importChannel2 + `" checked`},
+		},
+		{
+			name: "success case - preview of a Google Takeout file",
+			req: uploadRequest(t, "Channel Id,Channel Url,Channel Title\n"+importChannel2+",,Rust\n"+
+				importChannel2+",,Rust\n", map[string]string{"target": followImportTarget, "format": "takeout"}),
+			tokenInfo:    &auth.TokenInfo{LoginOnly: true},
+			want:         http.StatusOK,
+			wantContains: []string{"1 of 1 channels", "1 duplicated entries are skipped"},
+		},
+		{
+			name:         "success case - follow",
+			req:          confirmForm(followImportTarget),
+			tokenInfo:    &auth.TokenInfo{LoginOnly: true},
+			want:         http.StatusSeeOther,
+			wantFollowed: []database.FollowedChannel{{ChannelID: importChannel2, ChannelTitle: "Rust"}},
+		},
+		{
+			name:           "success case - subscribe",
+			req:            confirmForm(subscribeImportTarget),
+			tokenInfo:      &auth.TokenInfo{Token: &oauth2.Token{}, WriteAccess: true},
+			want:           http.StatusSeeOther,
+			wantSubscribed: []string{importChannel2},
+		},
+		{
+			name:      "error case - invalid target",
+			req:       uploadRequest(t, opml, map[string]string{"target": "watch"}),
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:      http.StatusBadRequest,
+		},
+		{
+			name:      "error case - unknown format",
+			req:       uploadRequest(t, opml, map[string]string{"target": followImportTarget, "format": "rss"}),
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:      http.StatusBadRequest,
+		},
+		{
+			name:      "error case - invalid file",
+			req:       uploadRequest(t, "not xml", map[string]string{"target": followImportTarget}),
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:      http.StatusBadRequest,
+		},
+		{
+			name:      "redirect case - write access not granted",
+			req:       uploadRequest(t, opml, map[string]string{"target": subscribeImportTarget}),
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}},
+			want:      http.StatusSeeOther,
+		},
+		{
+			name: "redirect case - token not found in context",
+			req:  uploadRequest(t, opml, map[string]string{"target": followImportTarget}),
+			want: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			followed, subscribed = nil, nil
+			req := tt.req
+			if tt.tokenInfo != nil {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), tt.tokenInfo))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := ImportSubscriptions(oauth2C, ytcf, storage, serverBasepath,
+				string(web.SubscriptionsTemplate))
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("ImportSubscriptions() = %v, want %v", recorder.Code, tt.want)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(recorder.Body.String(), want) {
+					t.Errorf("ImportSubscriptions() body doesn't contain %s, got %s", want, recorder.Body.String())
+				}
+			}
+			if diff := cmp.Diff(tt.wantFollowed, followed); diff != "" {
+				t.Errorf("ImportSubscriptions() followed mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantSubscribed, subscribed); diff != "" {
+				t.Errorf("ImportSubscriptions() subscribed mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnfollow(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		DeleteFollowedChannelStub: func(_ context.Context, userId int64, channelId string) error {
+			if channelId != importChannel1 {
+				return fmt.Errorf("channel %s not followed", channelId)
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name      string
+		channelID string
+		tokenInfo *auth.TokenInfo
+		want      int
+	}{
+		{
+			name:      "success case",
+			channelID: importChannel1,
+			tokenInfo: &auth.TokenInfo{},
+			want:      http.StatusSeeOther,
+		},
+		{
+			name:      "error case - channel not followed",
+			channelID: importChannel2,
+			tokenInfo: &auth.TokenInfo{},
+			want:      http.StatusBadRequest,
+		},
+		{
+			name: "redirect case - token not found in context",
+			want: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"channel_id": {tt.channelID}}
+			req, err := http.NewRequest(http.MethodPost, "/unfollow", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.tokenInfo != nil {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), tt.tokenInfo))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := Unfollow(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("Unfollow() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/errors"
+	"checkYoutube/logging"
+	"checkYoutube/subscriptionio"
+	errors2 "errors"
+	"fmt"
+	"google.golang.org/api/youtube/v3"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// subscriptionRow is a subscription of the logged user listed in the subscriptions page
+type subscriptionRow struct {
+	SubscriptionID string
+	ChannelID      string
+	Title          string
+}
+
+type subscriptionsTemplateResponse struct {
+	Username      string
+	Subscriptions []subscriptionRow
+	Followed      []database.FollowedChannel
+	// Confirm are the subscriptions selected to be deleted, waiting for the user to confirm
+	Confirm []subscriptionRow
+	// ReturnTo is the page the user goes back to after confirming or canceling
+	ReturnTo string
+	// Import are the channels of an imported file, waiting for the user to confirm
+	Import *importPreview
+	// Formats are the formats of the subscriptions files imported and exported
+	Formats []subscriptionio.Format
+	// WriteAccess is set when the user allowed the app to change its subscriptions
+	WriteAccess    bool
+	GrantURL       string
+	LoginOnly      bool
+	Theme          string
+	ServerBasepath string
+}
+
+// GetSubscriptions renders the subscriptions page, listing the subscriptions of the logged account and the channels
+// followed by the user sorted by title
+func GetSubscriptions(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "GetSubscriptions"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		response := subscriptionsTemplateResponse{
+			Username:       tokenInfo.Username,
+			Subscriptions:  []subscriptionRow{},
+			WriteAccess:    tokenInfo.WriteAccess,
+			GrantURL:       grantWriteAccessURL(serverBasepath, "/subscriptions"),
+			Formats:        subscriptionio.Formats(),
+			LoginOnly:      tokenInfo.LoginOnly,
+			Theme:          userPreferences(r, storage, tokenInfo, funcName).Theme,
+			ServerBasepath: serverBasepath,
+		}
+
+		// the token of users logged in with a login-only provider can't access YouTube
+		if !tokenInfo.LoginOnly {
+			youtubeSvc, err := ytcf.NewClient(oauth2C.CreateTokenSource(r.Context(), tokenInfo.Token))
+			if err != nil {
+				slog.Error(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			response.Subscriptions, err = getSubscriptionRows(r, youtubeSvc)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve subscriptions: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+		followed, err := storage.GetFollowedChannels(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve followed channels: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.Followed = followed
+
+		renderSubscriptions(w, response, htmlTemplate)
+	}
+}
+
+// Subscribe subscribes the logged account to the channel of the channel_id form value. Users are asked to grant
+// the write scope first when they haven't yet
+func Subscribe(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	serverBasepath string) http.HandlerFunc {
+	const funcName = "Subscribe"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		youtubeSvc, tokenInfo, ok := subscriptionsClient(w, r, oauth2C, ytcf, serverBasepath, "/subscriptions",
+			funcName)
+		if !ok {
+			return
+		}
+
+		// validate the form
+		if err := r.ParseForm(); err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		channelID := strings.TrimSpace(r.PostForm.Get("channel_id"))
+		if !subscriptionio.ValidChannelID(channelID) {
+			err := fmt.Errorf("invalid channel_id: %s", channelID)
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := youtubeSvc.Subscribe(r.Context(), channelID); err != nil {
+			if errors2.As(err, &errors.InsufficientScopeErr{}) {
+				redirectToGrantWriteAccess(w, r, tokenInfo, serverBasepath, "/subscriptions", funcName)
+				return
+			}
+			slog.Error(fmt.Sprintf("failed to subscribe to channel %s: %s", channelID, err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		slog.Info(fmt.Sprintf("subscribed to channel %s", channelID), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		inactiveReports.forget(tokenInfo.UserId)
+
+		http.Redirect(w, r, fmt.Sprintf("%s/subscriptions", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// Unsubscribe deletes the subscriptions of the subscription_id form values. The subscriptions are listed for the
+// user to confirm first, they are deleted only when the confirm form value is true. The user is then sent back to
+// the return_to form value, the subscriptions page by default
+func Unsubscribe(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface, serverBasepath, htmlTemplate string) http.HandlerFunc {
+	const funcName = "Unsubscribe"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		returnTo := returnPath(r.FormValue("return_to"), "/subscriptions")
+		youtubeSvc, tokenInfo, ok := subscriptionsClient(w, r, oauth2C, ytcf, serverBasepath, returnTo, funcName)
+		if !ok {
+			return
+		}
+
+		// validate the form
+		if err := r.ParseForm(); err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		subscriptionIDs := slices.DeleteFunc(r.PostForm["subscription_id"], func(subscriptionID string) bool {
+			return strings.TrimSpace(subscriptionID) == ""
+		})
+		if len(subscriptionIDs) == 0 {
+			err := fmt.Errorf("no subscription selected")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// list the selected subscriptions, with the titles sent by the subscriptions page, for the user to confirm
+		if r.PostForm.Get("confirm") != "true" {
+			confirm := make([]subscriptionRow, 0, len(subscriptionIDs))
+			for _, subscriptionID := range subscriptionIDs {
+				subscriptionID = strings.TrimSpace(subscriptionID)
+				confirm = append(confirm, subscriptionRow{SubscriptionID: subscriptionID,
+					Title: r.PostForm.Get("title_" + subscriptionID)})
+			}
+			renderSubscriptions(w, subscriptionsTemplateResponse{
+				Username:       tokenInfo.Username,
+				Confirm:        confirm,
+				ReturnTo:       returnTo,
+				WriteAccess:    tokenInfo.WriteAccess,
+				Theme:          userPreferences(r, storage, tokenInfo, funcName).Theme,
+				ServerBasepath: serverBasepath,
+			}, htmlTemplate)
+			return
+		}
+
+		// the subscriptions that can't be deleted are skipped, the user finds them still listed
+		for _, subscriptionID := range subscriptionIDs {
+			subscriptionID = strings.TrimSpace(subscriptionID)
+			err := youtubeSvc.Unsubscribe(r.Context(), subscriptionID)
+			if errors2.As(err, &errors.InsufficientScopeErr{}) {
+				redirectToGrantWriteAccess(w, r, tokenInfo, serverBasepath, returnTo, funcName)
+				return
+			}
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to delete subscription %s: %s", subscriptionID, err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				continue
+			}
+			slog.Info(fmt.Sprintf("deleted subscription %s", subscriptionID), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+		}
+		inactiveReports.forget(tokenInfo.UserId)
+
+		http.Redirect(w, r, serverBasepath+returnTo, http.StatusSeeOther)
+	}
+}
+
+// return the YouTube client of the logged account for changing its subscriptions, writing the response when it
+// can't be created or the user has to grant the write scope first, coming back to the returnTo path
+func subscriptionsClient(w http.ResponseWriter, r *http.Request, oauth2C auth.Oauth2Config,
+	ytcf clients.YoutubeClientFactoryInterface, serverBasepath, returnTo,
+	funcName string) (clients.YoutubeClientInterface, *auth.TokenInfo, bool) {
+	// get token from context
+	tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+	if !tokenOk {
+		slog.Warn("token not found in context, redirecting user to login page",
+			logging.FuncNameAttr(funcName))
+		http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+		return nil, nil, false
+	}
+	if tokenInfo.LoginOnly {
+		err := fmt.Errorf("users logged in with a login-only provider can't change their subscriptions")
+		slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if !tokenInfo.WriteAccess {
+		redirectToGrantWriteAccess(w, r, tokenInfo, serverBasepath, returnTo, funcName)
+		return nil, nil, false
+	}
+
+	youtubeSvc, err := ytcf.NewClient(oauth2C.CreateTokenSource(r.Context(), tokenInfo.Token))
+	if err != nil {
+		slog.Error(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return youtubeSvc, tokenInfo, true
+}
+
+// send the user to grant the write scope, coming back to the returnTo path afterward
+func redirectToGrantWriteAccess(w http.ResponseWriter, r *http.Request, tokenInfo *auth.TokenInfo,
+	serverBasepath, returnTo, funcName string) {
+	slog.Info("write scope not granted, redirecting user to grant it", logging.FuncNameAttr(funcName),
+		logging.UserAttr(tokenInfo.Username))
+	http.Redirect(w, r, grantWriteAccessURL(serverBasepath, returnTo), http.StatusSeeOther)
+}
+
+// return the url asking the user for the write scope, coming back to the returnTo path
+func grantWriteAccessURL(serverBasepath, returnTo string) string {
+	return fmt.Sprintf("%s/grant-write-access?return_to=%s", serverBasepath, url.QueryEscape(returnTo))
+}
+
+// return the subscriptions of the account of the YouTube client sorted by title
+func getSubscriptionRows(r *http.Request, svc clients.YoutubeClientInterface) ([]subscriptionRow, error) {
+	rows := make([]subscriptionRow, 0)
+	err := svc.GetAndProcessSubscriptions(r.Context(), func(subs *youtube.SubscriptionListResponse) error {
+		for _, item := range subs.Items {
+			if item.Snippet == nil || item.Snippet.ResourceId == nil {
+				continue
+			}
+			rows = append(rows, subscriptionRow{SubscriptionID: item.Id, ChannelID: item.Snippet.ResourceId.ChannelId,
+				Title: item.Snippet.Title})
+		}
+		return nil
+	})
+	slices.SortFunc(rows, func(a, b subscriptionRow) int {
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+	return rows, err
+}
+
+// render the subscriptions page, or the confirmation of the subscriptions to delete or the channels to import
+func renderSubscriptions(w http.ResponseWriter, response subscriptionsTemplateResponse, htmlTemplate string) {
+	tmpl, err := template.New("subscriptionsTemplate.tmpl").Parse(htmlTemplate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = tmpl.Execute(w, response)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/errors"
+	"checkYoutube/test"
+	"checkYoutube/web"
+	"context"
+	"fmt"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGetSubscriptions(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+		GetFollowedChannelsStub: func(_ context.Context, userId int64) ([]database.FollowedChannel, error) {
+			return []database.FollowedChannel{{ChannelID: "channel3", ChannelTitle: "Birds"}}, nil
+		},
+	}
+	subscription := func(id, channelID, title string) *youtube.Subscription {
+		return &youtube.Subscription{Id: id, Snippet: &youtube.SubscriptionSnippet{Title: title,
+			ResourceId: &youtube.ResourceId{ChannelId: channelID}}}
+	}
+	ytcf := func(err error) *youtubeClientFactoryMock {
+		return &youtubeClientFactoryMock{
+			newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+				return &youtubeClientMock{
+					getAndProcessSubscriptionsStub: func(ctx context.Context,
+						f func(*youtube.SubscriptionListResponse) error) error {
+						if err != nil {
+							return err
+						}
+						return f(&youtube.SubscriptionListResponse{Items: []*youtube.Subscription{
+							subscription("sub2", "channel2", "cooking"), subscription("sub1", "channel1", "Cats"),
+						}})
+					},
+				}, nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		tokenInfo    *auth.TokenInfo
+		ytcf         *youtubeClientFactoryMock
+		want         int
+		wantContains []string
+	}{
+		{
+			name:         "success case - write access granted",
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}, WriteAccess: true},
+			ytcf:         ytcf(nil),
+			want:         http.StatusOK,
+			wantContains: []string{`value="sub1"`, "Unsubscribe selected", `action="/subscribe"`},
+		},
+		{
+			name:         "success case - write access not granted",
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}},
+			ytcf:         ytcf(nil),
+			want:         http.StatusOK,
+			wantContains: []string{"grant-write-access?return_to=%2Fsubscriptions", "cooking"},
+		},
+		{
+			name:      "success case - login-only user",
+			tokenInfo: &auth.TokenInfo{LoginOnly: true},
+			ytcf:      ytcf(fmt.Errorf("test error")),
+			want:      http.StatusOK,
+			wantContains: []string{"login-only provider", "Birds", `action="/unfollow"`,
+				`<option value="newpipe">NewPipe (JSON)</option>`},
+		},
+		{
+			name:      "error case - subscriptions not retrieved",
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}},
+			ytcf:      ytcf(fmt.Errorf("test error")),
+			want:      http.StatusBadGateway,
+		},
+		{
+			name: "redirect case - token not found in context",
+			ytcf: ytcf(nil),
+			want: http.StatusTemporaryRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/subscriptions", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tokenInfo != nil {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), tt.tokenInfo))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := GetSubscriptions(oauth2C, tt.ytcf, storage, serverBasepath,
+				string(web.SubscriptionsTemplate))
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("GetSubscriptions() = %v, want %v", recorder.Code, tt.want)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(recorder.Body.String(), want) {
+					t.Errorf("GetSubscriptions() body doesn't contain %s", want)
+				}
+			}
+		})
+	}
+}
+
+func Test_getSubscriptionRows(t *testing.T) {
+	svc := &youtubeClientMock{
+		getAndProcessSubscriptionsStub: func(ctx context.Context,
+			f func(*youtube.SubscriptionListResponse) error) error {
+			return f(&youtube.SubscriptionListResponse{Items: []*youtube.Subscription{
+				{Id: "sub2", Snippet: &youtube.SubscriptionSnippet{Title: "cooking",
+					ResourceId: &youtube.ResourceId{ChannelId: "channel2"}}},
+				{Id: "sub3", Snippet: &youtube.SubscriptionSnippet{Title: "no channel"}},
+				{Id: "sub1", Snippet: &youtube.SubscriptionSnippet{Title: "Cats",
+					ResourceId: &youtube.ResourceId{ChannelId: "channel1"}}},
+			}})
+		},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	got, err := getSubscriptionRows(req, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []subscriptionRow{{SubscriptionID: "sub1", ChannelID: "channel1", Title: "Cats"},
+		{SubscriptionID: "sub2", ChannelID: "channel2", Title: "cooking"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("getSubscriptionRows() got = %v, want %v", got, want)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	// mocks
+	const (
+		serverBasepath = "http://localhost:8900"
+		channelID      = "UCabcdefghijklmnopqrstuv"
+		grantLocation  = serverBasepath + "/grant-write-access?return_to=%2Fsubscriptions"
+	)
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return &youtubeClientMock{
+				subscribeStub: func(_ context.Context, channelID string) (*youtube.Subscription, error) {
+					switch channelID {
+					case "UCinsufficientscopeXXXXX":
+						return nil, errors.InsufficientScopeErr{Err: fmt.Errorf("test error")}
+					case "UCfailingchannelXXXXXXXX":
+						return nil, fmt.Errorf("test error")
+					}
+					return &youtube.Subscription{Id: "sub1"}, nil
+				},
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		tokenInfo    *auth.TokenInfo
+		channelID    string
+		want         int
+		wantLocation string
+	}{
+		{
+			name:         "success case",
+			method:       http.MethodPost,
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}, WriteAccess: true},
+			channelID:    channelID,
+			want:         http.StatusSeeOther,
+			wantLocation: serverBasepath + "/subscriptions",
+		},
+		{
+			name:         "redirect case - write access not granted",
+			method:       http.MethodPost,
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}},
+			channelID:    channelID,
+			want:         http.StatusSeeOther,
+			wantLocation: grantLocation,
+		},
+		{
+			name:         "redirect case - write scope revoked",
+			method:       http.MethodPost,
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}, WriteAccess: true},
+			channelID:    "UCinsufficientscopeXXXXX",
+			want:         http.StatusSeeOther,
+			wantLocation: grantLocation,
+		},
+		{
+			name:      "error case - invalid channel id",
+			method:    http.MethodPost,
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}, WriteAccess: true},
+			channelID: "channel1",
+			want:      http.StatusBadRequest,
+		},
+		{
+			name:      "error case - login-only user",
+			method:    http.MethodPost,
+			tokenInfo: &auth.TokenInfo{LoginOnly: true},
+			channelID: channelID,
+			want:      http.StatusBadRequest,
+		},
+		{
+			name:      "error case - YouTube error",
+			method:    http.MethodPost,
+			tokenInfo: &auth.TokenInfo{Token: &oauth2.Token{}, WriteAccess: true},
+			channelID: "UCfailingchannelXXXXXXXX",
+			want:      http.StatusBadGateway,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodGet,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"channel_id": {tt.channelID}}
+			req, err := http.NewRequest(tt.method, "/subscribe", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.tokenInfo != nil {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), tt.tokenInfo))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := Subscribe(oauth2C, ytcf, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("Subscribe() = %v, want %v", recorder.Code, tt.want)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("Subscribe() location = %v, want %v", location, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	storage := &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+	}
+	var deleted []string
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return &youtubeClientMock{
+				unsubscribeStub: func(_ context.Context, subscriptionID string) error {
+					if subscriptionID == "failing" {
+						return fmt.Errorf("test error")
+					}
+					deleted = append(deleted, subscriptionID)
+					return nil
+				},
+			}, nil
+		},
+	}
+	writeAccess := &auth.TokenInfo{Token: &oauth2.Token{}, WriteAccess: true}
+
+	tests := []struct {
+		name         string
+		tokenInfo    *auth.TokenInfo
+		form         url.Values
+		want         int
+		wantContains string
+		wantLocation string
+		wantDeleted  []string
+	}{
+		{
+			name:         "success case - confirmation asked",
+			tokenInfo:    writeAccess,
+			form:         url.Values{"subscription_id": {"sub1", "sub2"}, "title_sub1": {"Cats"}},
+			want:         http.StatusOK,
+			wantContains: "Unsubscribe from 2 channels",
+		},
+		{
+			name:         "success case - confirmed",
+			tokenInfo:    writeAccess,
+			form:         url.Values{"subscription_id": {"sub1", "failing", "sub2"}, "confirm": {"true"}},
+			want:         http.StatusSeeOther,
+			wantLocation: serverBasepath + "/subscriptions",
+			wantDeleted:  []string{"sub1", "sub2"},
+		},
+		{
+			name:      "success case - confirmed from the inactive channels report",
+			tokenInfo: writeAccess,
+			form: url.Values{"subscription_id": {"sub1"}, "confirm": {"true"},
+				"return_to": {"/inactive-channels"}},
+			want:         http.StatusSeeOther,
+			wantLocation: serverBasepath + "/inactive-channels",
+			wantDeleted:  []string{"sub1"},
+		},
+		{
+			name:         "redirect case - write access not granted",
+			tokenInfo:    &auth.TokenInfo{Token: &oauth2.Token{}},
+			form:         url.Values{"subscription_id": {"sub1"}, "confirm": {"true"}},
+			want:         http.StatusSeeOther,
+			wantLocation: serverBasepath + "/grant-write-access?return_to=%2Fsubscriptions",
+		},
+		{
+			name:      "error case - no subscription selected",
+			tokenInfo: writeAccess,
+			form:      url.Values{"subscription_id": {" "}, "confirm": {"true"}},
+			want:      http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted = nil
+			req, err := http.NewRequest(http.MethodPost, "/unsubscribe", strings.NewReader(tt.form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), tt.tokenInfo))
+			recorder := httptest.NewRecorder()
+			handlerFunction := Unsubscribe(oauth2C, ytcf, storage, serverBasepath, string(web.SubscriptionsTemplate))
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("Unsubscribe() = %v, want %v", recorder.Code, tt.want)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantContains) {
+				t.Errorf("Unsubscribe() body doesn't contain %s", tt.wantContains)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("Unsubscribe() location = %v, want %v", location, tt.wantLocation)
+			}
+			if !slices.Equal(deleted, tt.wantDeleted) {
+				t.Errorf("Unsubscribe() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// syncResponse tells what a sync of the feed went through
+type syncResponse struct {
+	// Channels are the channels with new videos checked, Accounts the linked accounts whose subscriptions were saved
+	Channels     int `json:"channels"`
+	Accounts     int `json:"accounts"`
+	AlertMatches int `json:"alert_matches"`
+}
+
+// check the channels with new videos of the logged user and record what changed since the previous sync: the new
+// matches of the alerts, posted to the alert webhook of the user, the latest videos saved for the search and the
+// subscriptions of each linked account. The feed pages and API only read, so that these are recorded once per sync.
+// An error is returned when the YouTube service of the session account can't be created, the other failures are
+// logged only
+func syncFeed(r *http.Request, oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	webhook clients.WebhookClientInterface, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	funcName string) (syncResponse, error) {
+	now := time.Now()
+	preferences := userPreferences(r, storage, tokenInfo, funcName)
+	ytChannels, synced, err := getAccountsYTChannels(r.Context(), oauth2C, ytcf, tokenInfo,
+		contextAccounts(r, tokenInfo), userFollowedChannels(r, storage, tokenInfo, funcName), true,
+		userChannelRules(r, storage, tokenInfo, funcName), newAvailabilityOptions(preferences), nil)
+	if err != nil {
+		return syncResponse{}, err
+	}
+
+	// alerts are matched against all the new uploads, the ones hidden by the video rules included
+	var svc clients.YoutubeClientInterface
+	if len(synced) > 0 {
+		svc = synced[0].svc
+	}
+	matches := recordAlertMatches(r, storage, svc, tokenInfo, ytChannels, now, funcName)
+	notifyAlertMatches(r, webhook, preferences, tokenInfo, matches, funcName)
+	archiveVideos(r, storage, tokenInfo, ytChannels, funcName)
+	recordSubscriptionChanges(r, storage, tokenInfo, synced, funcName)
+	slog.Info(fmt.Sprintf("feed synced, %d channels with new videos", len(ytChannels)),
+		logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+	return syncResponse{Channels: len(ytChannels), Accounts: len(synced), AlertMatches: len(matches)}, nil
+}
+
+// SyncFeed syncs the feed of the logged user from the sync button of the main page, then goes back to it
+func SyncFeed(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	webhook clients.WebhookClientInterface, storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "SyncFeed"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		if _, err := syncFeed(r, oauth2C, ytcf, webhook, storage, tokenInfo, funcName); err != nil {
+			slog.Warn(fmt.Sprintf("unable to create youtube service, redirecting user to login page: %s",
+				err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		http.Redirect(w, r, serverBasepath+returnPath(r.PostFormValue("return_to"), "/check-youtube"),
+			http.StatusSeeOther)
+	}
+}
+
+// SyncFeedAPI syncs the feed of the user, telling what it went through as JSON
+func SyncFeedAPI(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	webhook clients.WebhookClientInterface, storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "SyncFeedAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		response, err := syncFeed(r, oauth2C, ytcf, webhook, storage, tokenInfo, funcName)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, response, funcName)
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// return a storage mock recording the accounts whose subscriptions were saved
+func newSyncStorageMock(snapshots *[]string) *test.StorageMock {
+	return &test.StorageMock{
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			return database.DefaultPreferences(), nil
+		},
+		GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+			return []database.ChannelRule{}, nil
+		},
+		GetFollowedChannelsStub: func(_ context.Context, userId int64) ([]database.FollowedChannel, error) {
+			return []database.FollowedChannel{}, nil
+		},
+		GetAlertsStub: func(_ context.Context, userId int64) ([]database.Alert, error) {
+			return []database.Alert{{Id: 1, UserId: userId, Query: "go"}}, nil
+		},
+		AddAlertMatchesStub: func(_ context.Context, matches []database.AlertMatch) ([]database.AlertMatch, error) {
+			return matches, nil
+		},
+		MarkAlertsCheckedStub: func(_ context.Context, userId int64, checkedAt time.Time) error {
+			return nil
+		},
+		LockSubscribedChannelsStub: func(_ context.Context, userId int64, accountId string) error {
+			return nil
+		},
+		HasSubscriptionSnapshotStub: func(_ context.Context, userId int64, accountId string) (bool, error) {
+			return true, nil
+		},
+		GetSubscribedChannelsStub: func(_ context.Context, userId int64,
+			accountId string) ([]database.SubscribedChannel, error) {
+			return []database.SubscribedChannel{}, nil
+		},
+		ReplaceSubscribedChannelsStub: func(_ context.Context, userId int64, accountId string,
+			channels []database.SubscribedChannel) error {
+			*snapshots = append(*snapshots, accountId)
+			return nil
+		},
+		AddSubscriptionChangesStub: func(_ context.Context, changes []database.SubscriptionChange) error {
+			return nil
+		},
+	}
+}
+
+// return a YouTube client factory mock listing a subscription without new videos
+func newSyncYoutubeClientFactoryMock() *youtubeClientFactoryMock {
+	return &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return &youtubeClientMock{
+				getAndProcessSubscriptionsStub: func(ctx context.Context,
+					f func(*youtube.SubscriptionListResponse) error) error {
+					return f(&youtube.SubscriptionListResponse{Items: []*youtube.Subscription{{
+						Id: "subscription1",
+						Snippet: &youtube.SubscriptionSnippet{Title: "channel1",
+							ResourceId: &youtube.ResourceId{ChannelId: "channel1"}},
+						ContentDetails: &youtube.SubscriptionContentDetails{NewItemCount: 0},
+					}}})
+				},
+			}, nil
+		},
+	}
+}
+
+func TestSyncFeed(t *testing.T) {
+	// mocks
+	const (
+		serverBasepath = "http://localhost:8900"
+		tokenNotFound  = "redirect case - token not found in context"
+	)
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+
+	tests := []struct {
+		name          string
+		method        string
+		returnTo      string
+		want          int
+		wantLocation  string
+		wantSnapshots []string
+	}{
+		{
+			name:          "success case",
+			method:        http.MethodPost,
+			returnTo:      "/check-youtube?filtered=true",
+			want:          http.StatusSeeOther,
+			wantLocation:  serverBasepath + "/check-youtube?filtered=true",
+			wantSnapshots: []string{"account1"},
+		},
+		{
+			name:          "success case - return path of another server",
+			method:        http.MethodPost,
+			returnTo:      "https://example.com",
+			want:          http.StatusSeeOther,
+			wantLocation:  serverBasepath + "/check-youtube",
+			wantSnapshots: []string{"account1"},
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodGet,
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:         tokenNotFound,
+			method:       http.MethodPost,
+			want:         http.StatusTemporaryRedirect,
+			wantLocation: serverBasepath + "/login",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var snapshots []string
+			storage := newSyncStorageMock(&snapshots)
+			form := url.Values{"return_to": {tt.returnTo}}
+			req := httptest.NewRequest(tt.method, "/sync", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(),
+					&auth.TokenInfo{Token: &oauth2.Token{}, UserId: "account1", AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			SyncFeed(oauth2C, newSyncYoutubeClientFactoryMock(), webhookClientMock{}, storage, serverBasepath)(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("SyncFeed() = %v, want %v", recorder.Code, tt.want)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("SyncFeed() location = %v, want %v", location, tt.wantLocation)
+			}
+			if fmt.Sprint(snapshots) != fmt.Sprint(tt.wantSnapshots) {
+				t.Errorf("SyncFeed() saved the subscriptions of %v, want %v", snapshots, tt.wantSnapshots)
+			}
+		})
+	}
+}
+
+func TestSyncFeedAPI(t *testing.T) {
+	// mocks
+	const tokenNotFound = "error case - token not found in context"
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+
+	type args struct {
+		method string
+		ytcf   clients.YoutubeClientFactoryInterface
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+		// wantResponse is the JSON response on success
+		wantResponse syncResponse
+	}{
+		{
+			name:         "success case",
+			args:         args{method: http.MethodPost, ytcf: newSyncYoutubeClientFactoryMock()},
+			want:         http.StatusOK,
+			wantResponse: syncResponse{Channels: 0, Accounts: 1},
+		},
+		{
+			name: "error case - error on creating youtube client",
+			args: args{
+				method: http.MethodPost,
+				ytcf: &youtubeClientFactoryMock{
+					newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+						return nil, fmt.Errorf("testerror")
+					},
+				},
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: tokenNotFound,
+			args: args{method: http.MethodPost, ytcf: newSyncYoutubeClientFactoryMock()},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "error case - method not allowed",
+			args: args{method: http.MethodGet, ytcf: newSyncYoutubeClientFactoryMock()},
+			want: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var snapshots []string
+			storage := newSyncStorageMock(&snapshots)
+			req := httptest.NewRequest(tt.args.method, "/api/v1/feed/sync", nil)
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(),
+					&auth.TokenInfo{Token: &oauth2.Token{}, UserId: "account1", AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			SyncFeedAPI(oauth2C, tt.args.ytcf, webhookClientMock{}, storage)(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("SyncFeedAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				var response syncResponse
+				if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+					t.Fatalf("SyncFeedAPI() invalid JSON response: %v", err)
+				}
+				if response != tt.wantResponse {
+					t.Errorf("SyncFeedAPI() got = %+v, want %+v", response, tt.wantResponse)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"context"
+	"fmt"
+	"google.golang.org/api/youtube/v3"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// ThumbnailSizes are the sizes of the thumbnails of a video, from the smallest to the largest
+var ThumbnailSizes = []string{database.DefaultThumbnail, database.MediumThumbnail, database.HighThumbnail,
+	database.StandardThumbnail, database.MaxResThumbnail}
+
+// Thumbnail is a thumbnail image of a video
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int64  `json:"width"`
+	Height int64  `json:"height"`
+}
+
+// videoCategoryCache holds the names of the YouTube video categories, which hardly ever change, by id
+type videoCategoryCache struct {
+	mutex sync.RWMutex
+	names map[string]string
+}
+
+// video categories resolved so far, shared by all the users
+var videoCategories = &videoCategoryCache{names: make(map[string]string)}
+
+// return the names of the given categories, calling the YouTube video categories API for the ones not cached
+func (c *videoCategoryCache) resolve(ctx context.Context, svc clients.YoutubeClientInterface,
+	categoryIDs []string) (map[string]string, error) {
+	names := make(map[string]string, len(categoryIDs))
+	missing := make([]string, 0)
+	c.mutex.RLock()
+	for _, categoryID := range categoryIDs {
+		if name, ok := c.names[categoryID]; ok {
+			names[categoryID] = name
+		} else if !slices.Contains(missing, categoryID) {
+			missing = append(missing, categoryID)
+		}
+	}
+	c.mutex.RUnlock()
+	if len(missing) == 0 {
+		return names, nil
+	}
+
+	categories, err := svc.GetVideoCategories(ctx, missing)
+	if err != nil {
+		return names, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, category := range categories {
+		if category.Snippet == nil {
+			continue
+		}
+		c.names[category.Id] = category.Snippet.Title
+		names[category.Id] = category.Snippet.Title
+	}
+	return names, nil
+}
+
+// return the thumbnails of a video by size
+func videoThumbnails(details *youtube.ThumbnailDetails) map[string]Thumbnail {
+	thumbnails := make(map[string]Thumbnail)
+	if details == nil {
+		return thumbnails
+	}
+	for size, thumbnail := range map[string]*youtube.Thumbnail{
+		database.DefaultThumbnail:  details.Default,
+		database.MediumThumbnail:   details.Medium,
+		database.HighThumbnail:     details.High,
+		database.StandardThumbnail: details.Standard,
+		database.MaxResThumbnail:   details.Maxres,
+	} {
+		if thumbnail != nil && thumbnail.Url != "" {
+			thumbnails[size] = Thumbnail{URL: thumbnail.Url, Width: thumbnail.Width, Height: thumbnail.Height}
+		}
+	}
+	return thumbnails
+}
+
+// return the thumbnail of the given size, falling back to the closest smaller one, then to the closest larger one.
+// No thumbnail is returned for the none size
+func selectThumbnail(thumbnails map[string]Thumbnail, size string) (Thumbnail, bool) {
+	i := slices.Index(ThumbnailSizes, size)
+	if i < 0 {
+		return Thumbnail{}, false
+	}
+	for j := i; j >= 0; j-- {
+		if thumbnail, ok := thumbnails[ThumbnailSizes[j]]; ok {
+			return thumbnail, true
+		}
+	}
+	for _, larger := range ThumbnailSizes[i+1:] {
+		if thumbnail, ok := thumbnails[larger]; ok {
+			return thumbnail, true
+		}
+	}
+	return Thumbnail{}, false
+}
+
+// format a count of views, likes or comments in a short form, e.g. 950, 12K or 3.4M
+func formatCount(count uint64) string {
+	switch {
+	case count >= 1_000_000_000:
+		return shortCount(count, 1_000_000_000) + "B"
+	case count >= 1_000_000:
+		return shortCount(count, 1_000_000) + "M"
+	case count >= 1_000:
+		return shortCount(count, 1_000) + "K"
+	}
+	return strconv.FormatUint(count, 10)
+}
+
+// return the count in the given unit, with a decimal below 10 units, rounded down
+func shortCount(count, unit uint64) string {
+	if count < 10*unit {
+		if tenths := count * 10 / unit % 10; tenths > 0 {
+			return fmt.Sprintf("%d.%d", count/unit, tenths)
+		}
+	}
+	return strconv.FormatUint(count/unit, 10)
+}
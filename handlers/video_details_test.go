@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"checkYoutube/database"
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/youtube/v3"
+	"testing"
+)
+
+func Test_selectThumbnail(t *testing.T) {
+	thumbnails := map[string]Thumbnail{
+		database.MediumThumbnail: {URL: "medium.jpg", Width: 320, Height: 180},
+		database.HighThumbnail:   {URL: "high.jpg", Width: 480, Height: 360},
+	}
+
+	tests := []struct {
+		name   string
+		size   string
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "success case - size available",
+			size:   database.HighThumbnail,
+			want:   "high.jpg",
+			wantOk: true,
+		},
+		{
+			name:   "success case - smaller size",
+			size:   database.MaxResThumbnail,
+			want:   "high.jpg",
+			wantOk: true,
+		},
+		{
+			name:   "success case - larger size",
+			size:   database.DefaultThumbnail,
+			want:   "medium.jpg",
+			wantOk: true,
+		},
+		{
+			name: "success case - hidden thumbnails",
+			size: database.NoThumbnail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := selectThumbnail(thumbnails, tt.size)
+			if got.URL != tt.want || ok != tt.wantOk {
+				t.Errorf("selectThumbnail() got = %v, %v, want %v, %v", got.URL, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_formatCount(t *testing.T) {
+	for count, want := range map[uint64]string{0: "0", 950: "950", 1000: "1K", 1290: "1.2K", 12_900: "12K",
+		3_456_789: "3.4M", 2_000_000_000: "2B"} {
+		if got := formatCount(count); got != want {
+			t.Errorf("formatCount(%d) got = %v, want %v", count, got, want)
+		}
+	}
+}
+
+func Test_addVideoDetails(t *testing.T) {
+	video := &youtube.Video{
+		Id:             "video1",
+		ContentDetails: &youtube.VideoContentDetails{Duration: "PT4M30S", Caption: "true"},
+		Snippet: &youtube.VideoSnippet{
+			Description:          "full description",
+			Thumbnails:           &youtube.ThumbnailDetails{Default: &youtube.Thumbnail{Url: "default.jpg", Width: 120}},
+			Tags:                 []string{"go", "generics"},
+			CategoryId:           "28",
+			DefaultAudioLanguage: "en",
+		},
+		Statistics: &youtube.VideoStatistics{ViewCount: 1200, LikeCount: 30},
+		Status:     &youtube.VideoStatus{PrivacyStatus: "unlisted"},
+	}
+
+	var got YTChannel
+	addVideoDetails(&got, video, "")
+	want := YTChannel{
+		LatestVideoDescription:     "full description",
+		LatestVideoDuration:        "04:30",
+		LatestVideoDurationSeconds: 270,
+		LatestVideoType:            RegularVideoType,
+		LatestVideoThumbnails:      map[string]Thumbnail{database.DefaultThumbnail: {URL: "default.jpg", Width: 120}},
+		LatestVideoViewCount:       1200,
+		LatestVideoLikeCount:       30,
+		LatestVideoTags:            []string{"go", "generics"},
+		LatestVideoCategoryID:      "28",
+		LatestVideoLanguage:        "en",
+		LatestVideoCaptions:        true,
+		LatestVideoPrivacyStatus:   "unlisted",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("addVideoDetails() mismatch (-want +got):\n%s", diff)
+	}
+	if stats := videoStats(got); stats != "1.2K views · 30 likes · captions · unlisted" {
+		t.Errorf("videoStats() got = %v", stats)
+	}
+}
+
+func Test_videoCategoryCache_resolve(t *testing.T) {
+	calls := make([][]string, 0)
+	svc := &youtubeClientMock{
+		getVideoCategoriesStub: func(_ context.Context, categoryIDs []string) ([]*youtube.VideoCategory, error) {
+			calls = append(calls, categoryIDs)
+			if categoryIDs[0] == "error" {
+				return nil, fmt.Errorf("test error")
+			}
+			categories := make([]*youtube.VideoCategory, 0)
+			for _, categoryID := range categoryIDs {
+				categories = append(categories, &youtube.VideoCategory{Id: categoryID,
+					Snippet: &youtube.VideoCategorySnippet{Title: "category " + categoryID}})
+			}
+			return categories, nil
+		},
+	}
+	cache := &videoCategoryCache{names: make(map[string]string)}
+
+	got, err := cache.resolve(context.Background(), svc, []string{"10", "28", "10"})
+	want := map[string]string{"10": "category 10", "28": "category 28"}
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("resolve() got = %v, error = %v, want %v", got, err, want)
+	}
+	// cached categories aren't requested again
+	got, err = cache.resolve(context.Background(), svc, []string{"28", "20"})
+	want = map[string]string{"20": "category 20", "28": "category 28"}
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("resolve() got = %v, error = %v, want %v", got, err, want)
+	}
+	if _, err = cache.resolve(context.Background(), svc, []string{"error"}); err == nil {
+		t.Errorf("resolve() expected an error")
+	}
+	if diff := cmp.Diff([][]string{{"10", "28"}, {"20"}, {"error"}}, calls); diff != "" {
+		t.Errorf("resolve() API calls mismatch (-want +got):\n%s", diff)
+	}
+}
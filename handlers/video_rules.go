@@ -0,0 +1,627 @@
+package handlers
+
+import (
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/logging"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/youtube/v3"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// max length of the value of a video rule
+const maxVideoRuleValueLength = 255
+
+// fields of the latest video of a channel the video rules are evaluated against
+const (
+	titleField       = "title"
+	descriptionField = "description"
+	durationField    = "duration"
+	// channelField is the title or the id of the channel
+	channelField = "channel"
+	typeField    = "type"
+)
+
+// operators of the video rules, text ones are case-insensitive
+const (
+	containsOperator    = "contains"
+	equalsOperator      = "equals"
+	matchesOperator     = "matches"
+	shorterThanOperator = "shorter_than"
+	longerThanOperator  = "longer_than"
+)
+
+// VideoRuleFields are the fields the video rules can check
+var VideoRuleFields = []string{titleField, descriptionField, durationField, channelField, typeField}
+
+// VideoRuleOperators are the operators of the video rules
+var VideoRuleOperators = []string{containsOperator, equalsOperator, matchesOperator, shorterThanOperator,
+	longerThanOperator}
+
+// operators available for each field
+var fieldOperators = map[string][]string{
+	titleField:       {containsOperator, equalsOperator, matchesOperator},
+	descriptionField: {containsOperator, equalsOperator, matchesOperator},
+	durationField:    {shorterThanOperator, longerThanOperator},
+	channelField:     {containsOperator, equalsOperator, matchesOperator},
+	typeField:        {equalsOperator},
+}
+
+type videoRuleRequest struct {
+	Action    string `json:"action"`
+	ChannelID string `json:"channel_id"`
+	Field     string `json:"field"`
+	Operator  string `json:"operator"`
+	Value     string `json:"value"`
+}
+
+type videoRuleResponse struct {
+	ID          int64  `json:"id"`
+	Action      string `json:"action"`
+	ChannelID   string `json:"channel_id"`
+	Field       string `json:"field"`
+	Operator    string `json:"operator"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type explanationResponse struct {
+	ChannelID string     `json:"channel_id"`
+	Hidden    bool       `json:"hidden"`
+	Reasons   []string   `json:"reasons"`
+	Video     *YTChannel `json:"video,omitempty"`
+}
+
+// videoRuleRow is a video rule as shown in the rules page
+type videoRuleRow struct {
+	Rule        database.VideoRule
+	Description string
+}
+
+// videoRule is a stored video rule ready to be evaluated
+type videoRule struct {
+	database.VideoRule
+	regexp   *regexp.Regexp
+	duration time.Duration
+}
+
+// videoRules are the video rules of a user
+type videoRules []videoRule
+
+// videoVerdict tells whether the latest video of a channel is hidden by the video rules, and why
+type videoVerdict struct {
+	Hidden  bool
+	Reasons []string
+}
+
+// return the video rule with trimmed fields, or an error when it's not valid
+func validateVideoRule(rule database.VideoRule) (database.VideoRule, error) {
+	rule.ChannelID = strings.TrimSpace(rule.ChannelID)
+	rule.Value = strings.TrimSpace(rule.Value)
+	if rule.Action != database.IncludeAction && rule.Action != database.ExcludeAction {
+		return rule, fmt.Errorf("unknown video rule action: %s", rule.Action)
+	}
+	operators, ok := fieldOperators[rule.Field]
+	if !ok {
+		return rule, fmt.Errorf("unknown video rule field: %s", rule.Field)
+	}
+	if !slices.Contains(operators, rule.Operator) {
+		return rule, fmt.Errorf("operator %s can't be used with field %s", rule.Operator, rule.Field)
+	}
+	if rule.Value == "" {
+		return rule, fmt.Errorf("missing video rule value")
+	}
+	if utf8.RuneCountInString(rule.Value) > maxVideoRuleValueLength {
+		return rule, fmt.Errorf("video rule value longer than %d characters", maxVideoRuleValueLength)
+	}
+	if _, err := compileVideoRule(rule); err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+// return the video rule ready to be evaluated, or an error when its value can't be parsed
+func compileVideoRule(rule database.VideoRule) (videoRule, error) {
+	compiled := videoRule{VideoRule: rule}
+	switch {
+	case rule.Operator == matchesOperator:
+		re, err := regexp.Compile("(?i)" + rule.Value)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid regular expression %s: %s", rule.Value, err.Error())
+		}
+		compiled.regexp = re
+	case rule.Field == durationField:
+		duration, err := parseRuleDuration(rule.Value)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.duration = duration
+	case rule.Field == typeField:
+		if !slices.Contains(VideoTypes, rule.Value) {
+			return compiled, fmt.Errorf("unknown video type %s, expected one of %s", rule.Value,
+				strings.Join(VideoTypes, ", "))
+		}
+	}
+	return compiled, nil
+}
+
+// parse a duration given either as seconds or like 1h30m
+func parseRuleDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid duration %s, expected seconds or a duration like 10m or 1h30m", value)
+	}
+	return duration, nil
+}
+
+// return a human-readable description of the video rule
+func describeVideoRule(rule database.VideoRule) string {
+	scope := "all channels"
+	if rule.ChannelID != "" {
+		scope = "channel " + rule.ChannelID
+	}
+	verb := "show only"
+	if rule.Action == database.ExcludeAction {
+		verb = "hide"
+	}
+	operator := strings.ReplaceAll(rule.Operator, "_", " ")
+	if rule.Field == durationField {
+		operator = "is " + operator
+	}
+	return fmt.Sprintf("%s videos whose %s %s %q (%s)", verb, rule.Field, operator, rule.Value, scope)
+}
+
+// return the video rules ready to be evaluated, leaving out the ones that can't be parsed
+func newVideoRules(rules []database.VideoRule) videoRules {
+	result := make(videoRules, 0, len(rules))
+	for _, rule := range rules {
+		compiled, err := compileVideoRule(rule)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("skipping invalid video rule %d: %s", rule.Id, err.Error()),
+				logging.FuncNameAttr("newVideoRules"))
+			continue
+		}
+		result = append(result, compiled)
+	}
+	return result
+}
+
+// tell whether the rule applies to the channel and matches its latest video
+func (v videoRule) matches(ytChannel YTChannel) bool {
+	if v.ChannelID != "" && v.ChannelID != ytChannel.ChannelID {
+		return false
+	}
+	switch v.Field {
+	case titleField:
+		return v.matchesText(ytChannel.LatestVideoTitle)
+	case descriptionField:
+		return v.matchesText(ytChannel.LatestVideoDescription)
+	case channelField:
+		return v.matchesText(ytChannel.Title) || (v.Operator == equalsOperator && v.Value == ytChannel.ChannelID)
+	case typeField:
+		return ytChannel.LatestVideoType == v.Value
+	case durationField:
+		// the duration of some videos, like upcoming ones, is unknown
+		if ytChannel.LatestVideoDurationSeconds == 0 {
+			return false
+		}
+		duration := time.Duration(ytChannel.LatestVideoDurationSeconds) * time.Second
+		if v.Operator == shorterThanOperator {
+			return duration < v.duration
+		}
+		return duration > v.duration
+	}
+	return false
+}
+
+func (v videoRule) matchesText(text string) bool {
+	switch v.Operator {
+	case containsOperator:
+		return strings.Contains(strings.ToLower(text), strings.ToLower(v.Value))
+	case equalsOperator:
+		return strings.EqualFold(text, v.Value)
+	case matchesOperator:
+		return v.regexp.MatchString(text)
+	}
+	return false
+}
+
+// tell whether the latest video of the channel is hidden: it is when it matches an exclude rule, or when include
+// rules apply to its channel and it matches none of them. Channels without videos are never hidden
+func (v videoRules) explain(ytChannel YTChannel) videoVerdict {
+	var verdict videoVerdict
+	if ytChannel.LatestVideoID == "" {
+		return verdict
+	}
+
+	var includes []string
+	included := false
+	for _, rule := range v {
+		if rule.ChannelID != "" && rule.ChannelID != ytChannel.ChannelID {
+			continue
+		}
+		switch rule.Action {
+		case database.ExcludeAction:
+			if rule.matches(ytChannel) {
+				verdict.Hidden = true
+				verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("matches rule %d: %s", rule.Id,
+					describeVideoRule(rule.VideoRule)))
+			}
+		case database.IncludeAction:
+			includes = append(includes, fmt.Sprintf("%d", rule.Id))
+			if rule.matches(ytChannel) {
+				included = true
+				verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("included by rule %d: %s", rule.Id,
+					describeVideoRule(rule.VideoRule)))
+			}
+		}
+	}
+	if len(includes) > 0 && !included {
+		verdict.Hidden = true
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("matches none of the include rules %s",
+			strings.Join(includes, ", ")))
+	}
+	return verdict
+}
+
+// leave out the channels whose latest video is hidden by the rules
+func (v videoRules) filter(ytChannels []YTChannel) []YTChannel {
+	if len(v) == 0 {
+		return ytChannels
+	}
+	return slices.DeleteFunc(ytChannels, func(ytChannel YTChannel) bool {
+		return v.explain(ytChannel).Hidden
+	})
+}
+
+// return the video rules of the logged user, none when they can't be retrieved
+func userVideoRules(r *http.Request, storage database.StorageInterface, tokenInfo *auth.TokenInfo,
+	funcName string) videoRules {
+	rules, err := storage.GetVideoRules(r.Context(), tokenInfo.AppUserId)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to retrieve video rules, ignoring them: %s", err.Error()),
+			logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+	}
+	return newVideoRules(rules)
+}
+
+// AddVideoRule stores a new video rule of the logged user from the rules page
+func AddVideoRule(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "AddVideoRule"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		rule, err := validateVideoRule(database.VideoRule{
+			UserId:    tokenInfo.AppUserId,
+			Action:    r.FormValue("action"),
+			ChannelID: r.FormValue("channel_id"),
+			Field:     r.FormValue("field"),
+			Operator:  r.FormValue("operator"),
+			Value:     r.FormValue("value"),
+		})
+		if err != nil {
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err = storage.CreateVideoRule(r.Context(), rule); err != nil {
+			slog.Error(fmt.Sprintf("failed to create video rule: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("video rule created: %s", describeVideoRule(rule)), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/rules", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// DeleteVideoRule removes a video rule of the logged user from the rules page
+func DeleteVideoRule(storage database.StorageInterface, serverBasepath string) http.HandlerFunc {
+	const funcName = "DeleteVideoRule"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			slog.Warn("token not found in context, redirecting user to login page",
+				logging.FuncNameAttr(funcName))
+			http.Redirect(w, r, fmt.Sprintf("%s/login", serverBasepath), http.StatusTemporaryRedirect)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid video rule id: %s", r.FormValue("id"))
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err = storage.DeleteVideoRule(r.Context(), tokenInfo.AppUserId, id); err != nil {
+			slog.Error(fmt.Sprintf("failed to delete video rule: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(fmt.Sprintf("video rule %d deleted", id), logging.FuncNameAttr(funcName),
+			logging.UserAttr(tokenInfo.Username))
+		http.Redirect(w, r, fmt.Sprintf("%s/rules", serverBasepath), http.StatusSeeOther)
+	}
+}
+
+// VideoRulesAPI lists the video rules of the user as JSON on GET, creates one on POST and deletes the one given by
+// the id query parameter on DELETE
+func VideoRulesAPI(storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "VideoRulesAPI"
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			rules, err := storage.GetVideoRules(r.Context(), tokenInfo.AppUserId)
+			if err != nil {
+				slog.Error(fmt.Sprintf("failed to retrieve video rules: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			response := make([]videoRuleResponse, 0, len(rules))
+			for _, rule := range rules {
+				response = append(response, newVideoRuleResponse(rule))
+			}
+			writeJSON(w, response, funcName)
+		case http.MethodPost:
+			var req videoRuleRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rule, err := validateVideoRule(database.VideoRule{
+				UserId:    tokenInfo.AppUserId,
+				Action:    req.Action,
+				ChannelID: req.ChannelID,
+				Field:     req.Field,
+				Operator:  req.Operator,
+				Value:     req.Value,
+			})
+			if err != nil {
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if rule.Id, err = storage.CreateVideoRule(r.Context(), rule); err != nil {
+				slog.Error(fmt.Sprintf("failed to create video rule: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("video rule created: %s", describeVideoRule(rule)),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			w.WriteHeader(http.StatusCreated)
+			writeJSON(w, newVideoRuleResponse(rule), funcName)
+		case http.MethodDelete:
+			id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+			if err != nil {
+				err = fmt.Errorf("invalid video rule id: %s", r.URL.Query().Get("id"))
+				slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err = storage.DeleteVideoRule(r.Context(), tokenInfo.AppUserId, id); err != nil {
+				slog.Error(fmt.Sprintf("failed to delete video rule: %s", err.Error()),
+					logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			slog.Info(fmt.Sprintf("video rule %d deleted", id), logging.FuncNameAttr(funcName),
+				logging.UserAttr(tokenInfo.Username))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func newVideoRuleResponse(rule database.VideoRule) videoRuleResponse {
+	response := videoRuleResponse{
+		ID:          rule.Id,
+		Action:      rule.Action,
+		ChannelID:   rule.ChannelID,
+		Field:       rule.Field,
+		Operator:    rule.Operator,
+		Value:       rule.Value,
+		Description: describeVideoRule(rule),
+	}
+	if !rule.CreatedAt.IsZero() {
+		response.CreatedAt = rule.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return response
+}
+
+// ExplainChannel tells as JSON whether the channel given by the channel_id query parameter is hidden from the feed
+// and which rules hide it. The latest video of the channel is retrieved with the first Google account of the user,
+// unless the channel is muted or snoozed
+func ExplainChannel(oauth2C auth.Oauth2Config, ytcf clients.YoutubeClientFactoryInterface,
+	storage database.StorageInterface) http.HandlerFunc {
+	const funcName = "ExplainChannel"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// get token from context
+		tokenInfo, tokenOk := r.Context().Value(auth.TokenCtxKey{}).(*auth.TokenInfo)
+		if !tokenOk {
+			err := fmt.Errorf("token not found in context")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		channelID := strings.TrimSpace(r.URL.Query().Get("channel_id"))
+		if channelID == "" {
+			err := fmt.Errorf("missing channel_id")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response := explanationResponse{ChannelID: channelID, Reasons: []string{}}
+
+		// muted and snoozed channels are hidden whatever their latest video is
+		channelRules, err := storage.GetChannelRules(r.Context(), tokenInfo.AppUserId)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve channel rules: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		var titlePattern string
+		for _, rule := range channelRules {
+			if rule.ChannelID != channelID || !rule.Active(now) {
+				continue
+			}
+			switch rule.Kind {
+			case database.MuteRule:
+				response.Reasons = append(response.Reasons, "channel muted")
+			case database.SnoozeRule:
+				response.Reasons = append(response.Reasons, fmt.Sprintf("channel snoozed until %s",
+					rule.SnoozedUntil.UTC().Format(time.RFC3339)))
+			case database.TitleMatchRule:
+				titlePattern = rule.TitlePattern
+			}
+		}
+		if len(response.Reasons) > 0 {
+			response.Hidden = true
+			writeJSON(w, response, funcName)
+			return
+		}
+
+		// get the latest video of the channel
+		accounts := contextAccounts(r, tokenInfo)
+		if len(accounts) == 0 {
+			err = fmt.Errorf("no Google account linked")
+			slog.Warn(err.Error(), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		youtubeSvc, err := ytcf.NewClient(oauth2C.CreateTokenSource(r.Context(), accounts[0].Token))
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to create youtube service: %s", err.Error()),
+				logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		preferences := userPreferences(r, storage, tokenInfo, funcName)
+		video, err := latestChannelVideo(r, youtubeSvc, channelID, newAvailabilityOptions(preferences),
+			tokenInfo.Username)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to retrieve the latest video of channel %s: %s", channelID,
+				err.Error()), logging.FuncNameAttr(funcName), logging.UserAttr(tokenInfo.Username))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		response.Video = &video
+
+		verdict := explainYTChannel(video, newChannelRules(channelRules, now), titlePattern,
+			userVideoRules(r, storage, tokenInfo, funcName), preferences)
+		response.Hidden = verdict.Hidden
+		response.Reasons = append(response.Reasons, verdict.Reasons...)
+
+		writeJSON(w, response, funcName)
+	}
+}
+
+// tell whether the channel is hidden from the feed by its latest video, going through the steps of the feed: the
+// title match rule of the channel, as in detailYTChannels, the video rules, then the video types hidden in the
+// preferences, as in buildFeedPage
+func explainYTChannel(ytChannel YTChannel, rules channelRules, titlePattern string, videoRules videoRules,
+	preferences database.Preferences) videoVerdict {
+	var verdict videoVerdict
+	if rules.hides(ytChannel) {
+		verdict.Hidden = true
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("title doesn't contain %q", titlePattern))
+	}
+	rulesVerdict := videoRules.explain(ytChannel)
+	verdict.Hidden = verdict.Hidden || rulesVerdict.Hidden
+	verdict.Reasons = append(verdict.Reasons, rulesVerdict.Reasons...)
+	if ytChannel.LatestVideoID != "" && !(feedOptions{}).shows(ytChannel, preferences) {
+		verdict.Hidden = true
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("videos of type %s hidden in the settings",
+			ytChannel.LatestVideoType))
+	}
+	return verdict
+}
+
+// return the latest video of a channel, with its duration and type, handling an unavailable one as set by the
+// availability options like the feed does
+func latestChannelVideo(r *http.Request, svc clients.YoutubeClientInterface, channelID string,
+	availability availabilityOptions, username string) (YTChannel, error) {
+	if len(channelID) < 2 {
+		return YTChannel{}, fmt.Errorf("invalid channel id: %s", channelID)
+	}
+	subscription := &youtube.Subscription{Snippet: &youtube.SubscriptionSnippet{
+		ResourceId: &youtube.ResourceId{ChannelId: channelID},
+	}}
+	video, err := processYouTubeChannel(svc, subscription, username)
+	if err != nil {
+		return video, err
+	}
+	if video.LatestVideoID == "" {
+		return video, nil
+	}
+	videos := []YTChannel{video}
+	err = resolveLatestVideos(r.Context(), svc, videos, []string{video.LatestVideoID}, availability, username)
+	if err != nil {
+		return videos[0], err
+	}
+	addVideoCategories(r.Context(), svc, videos, username)
+	return videos[0], nil
+}
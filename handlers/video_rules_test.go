@@ -0,0 +1,470 @@
+package handlers
+
+import (
+	"bytes"
+	"checkYoutube/auth"
+	"checkYoutube/clients"
+	"checkYoutube/database"
+	"checkYoutube/test"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_validateVideoRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    database.VideoRule
+		want    database.VideoRule
+		wantErr bool
+	}{
+		{
+			name: "success case",
+			rule: database.VideoRule{Action: database.ExcludeAction, ChannelID: " channel1 ", Field: titleField,
+				Operator: containsOperator, Value: " LIVE "},
+			want: database.VideoRule{Action: database.ExcludeAction, ChannelID: "channel1", Field: titleField,
+				Operator: containsOperator, Value: "LIVE"},
+		},
+		{
+			name: "success case - duration",
+			rule: database.VideoRule{Action: database.IncludeAction, Field: durationField,
+				Operator: longerThanOperator, Value: "1h30m"},
+			want: database.VideoRule{Action: database.IncludeAction, Field: durationField,
+				Operator: longerThanOperator, Value: "1h30m"},
+		},
+		{
+			name:    "error case - unknown action",
+			rule:    database.VideoRule{Action: "drop", Field: titleField, Operator: containsOperator, Value: "a"},
+			wantErr: true,
+		},
+		{
+			name: "error case - unknown field",
+			rule: database.VideoRule{Action: database.ExcludeAction, Field: "tags", Operator: containsOperator,
+				Value: "a"},
+			wantErr: true,
+		},
+		{
+			name: "error case - operator not available for the field",
+			rule: database.VideoRule{Action: database.ExcludeAction, Field: typeField, Operator: containsOperator,
+				Value: ShortVideoType},
+			wantErr: true,
+		},
+		{
+			name: "error case - missing value",
+			rule: database.VideoRule{Action: database.ExcludeAction, Field: titleField, Operator: containsOperator,
+				Value: " "},
+			wantErr: true,
+		},
+		{
+			name: "error case - invalid regular expression",
+			rule: database.VideoRule{Action: database.ExcludeAction, Field: titleField, Operator: matchesOperator,
+				Value: "(live"},
+			wantErr: true,
+		},
+		{
+			name: "error case - invalid duration",
+			rule: database.VideoRule{Action: database.ExcludeAction, Field: durationField,
+				Operator: shorterThanOperator, Value: "-10m"},
+			wantErr: true,
+		},
+		{
+			name: "error case - unknown video type",
+			rule: database.VideoRule{Action: database.ExcludeAction, Field: typeField, Operator: equalsOperator,
+				Value: "podcast"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateVideoRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateVideoRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("validateVideoRule() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_videoRules_explain(t *testing.T) {
+	rules := newVideoRules([]database.VideoRule{
+		{Id: 1, Action: database.ExcludeAction, Field: titleField, Operator: matchesOperator, Value: `live|#shorts`},
+		{Id: 2, Action: database.ExcludeAction, Field: typeField, Operator: equalsOperator, Value: UpcomingVideoType},
+		{Id: 3, Action: database.IncludeAction, ChannelID: "channel1", Field: titleField, Operator: containsOperator,
+			Value: "tutorial"},
+		{Id: 4, Action: database.IncludeAction, ChannelID: "channel1", Field: durationField,
+			Operator: longerThanOperator, Value: "3600"},
+		{Id: 5, Action: database.ExcludeAction, Field: descriptionField, Operator: containsOperator, Value: "#ad"},
+		{Id: 6, Action: database.ExcludeAction, Field: channelField, Operator: equalsOperator, Value: "channel3"},
+	})
+
+	tests := []struct {
+		name      string
+		ytChannel YTChannel
+		want      videoVerdict
+	}{
+		{
+			name: "success case - no rule matching",
+			ytChannel: YTChannel{ChannelID: "channel2", LatestVideoID: "video", LatestVideoTitle: "Vlog",
+				LatestVideoType: RegularVideoType},
+		},
+		{
+			name: "success case - excluded by title",
+			ytChannel: YTChannel{ChannelID: "channel2", LatestVideoID: "video", LatestVideoTitle: "Going LIVE",
+				LatestVideoType: LiveVideoType},
+			want: videoVerdict{Hidden: true,
+				Reasons: []string{`matches rule 1: hide videos whose title matches "live|#shorts" (all channels)`}},
+		},
+		{
+			name: "success case - excluded by type and description",
+			ytChannel: YTChannel{ChannelID: "channel2", LatestVideoID: "video", LatestVideoTitle: "Premiere",
+				LatestVideoDescription: "Sponsored #AD", LatestVideoType: UpcomingVideoType},
+			want: videoVerdict{Hidden: true, Reasons: []string{
+				`matches rule 2: hide videos whose type equals "upcoming" (all channels)`,
+				`matches rule 5: hide videos whose description contains "#ad" (all channels)`,
+			}},
+		},
+		{
+			name:      "success case - excluded by channel",
+			ytChannel: YTChannel{ChannelID: "channel3", Title: "Channel 3", LatestVideoID: "video"},
+			want: videoVerdict{Hidden: true,
+				Reasons: []string{`matches rule 6: hide videos whose channel equals "channel3" (all channels)`}},
+		},
+		{
+			name: "success case - included by title",
+			ytChannel: YTChannel{ChannelID: "channel1", LatestVideoID: "video", LatestVideoTitle: "Go Tutorial",
+				LatestVideoDurationSeconds: 600},
+			want: videoVerdict{Reasons: []string{
+				`included by rule 3: show only videos whose title contains "tutorial" (channel channel1)`,
+			}},
+		},
+		{
+			name: "success case - matching no include rule",
+			ytChannel: YTChannel{ChannelID: "channel1", LatestVideoID: "video", LatestVideoTitle: "Vlog",
+				LatestVideoDurationSeconds: 600},
+			want: videoVerdict{Hidden: true, Reasons: []string{"matches none of the include rules 3, 4"}},
+		},
+		{
+			name:      "success case - no latest video",
+			ytChannel: YTChannel{ChannelID: "channel1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, rules.explain(tt.ytChannel)); diff != "" {
+				t.Errorf("explain() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	ytChannels := []YTChannel{tests[0].ytChannel, tests[1].ytChannel, tests[4].ytChannel, tests[5].ytChannel}
+	got := rules.filter(ytChannels)
+	if len(got) != 2 || got[0].ChannelID != "channel2" || got[1].LatestVideoTitle != "Go Tutorial" {
+		t.Errorf("filter() got = %+v", got)
+	}
+}
+
+func TestAddVideoRule(t *testing.T) {
+	// mocks
+	const serverBasepath = "http://localhost:8900"
+	storage := &test.StorageMock{
+		CreateVideoRuleStub: func(_ context.Context, rule database.VideoRule) (int64, error) {
+			if rule.Value == "error" {
+				return 0, fmt.Errorf("test error")
+			}
+			return 1, nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		form   url.Values
+		want   int
+	}{
+		{
+			name:   "success case",
+			method: http.MethodPost,
+			form: url.Values{"action": {"exclude"}, "field": {"title"}, "operator": {"contains"},
+				"value": {"#shorts"}},
+			want: http.StatusSeeOther,
+		},
+		{
+			name:   "failure case - invalid rule",
+			method: http.MethodPost,
+			form:   url.Values{"action": {"exclude"}, "field": {"title"}, "operator": {"longer_than"}, "value": {"1"}},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failure case - storage error",
+			method: http.MethodPost,
+			form: url.Values{"action": {"exclude"}, "field": {"title"}, "operator": {"contains"},
+				"value": {"error"}},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name:   "failure case - method not allowed",
+			method: http.MethodGet,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/add-video-rule", strings.NewReader(tt.form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := AddVideoRule(storage, serverBasepath)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("AddVideoRule() = %v, want %v", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestVideoRulesAPI(t *testing.T) {
+	// mocks
+	const tokenNotFound = "error case - token not found in context"
+	storage := &test.StorageMock{
+		GetVideoRulesStub: func(_ context.Context, userId int64) ([]database.VideoRule, error) {
+			return []database.VideoRule{{Id: 1, Action: database.ExcludeAction, Field: titleField,
+				Operator: containsOperator, Value: "#shorts"}}, nil
+		},
+		CreateVideoRuleStub: func(_ context.Context, rule database.VideoRule) (int64, error) {
+			return 2, nil
+		},
+		DeleteVideoRuleStub: func(_ context.Context, userId, id int64) error {
+			if id != 1 {
+				return fmt.Errorf("test error")
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{
+			name:   "success case - list",
+			method: http.MethodGet,
+			target: "/api/v1/video-rules",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "success case - create",
+			method: http.MethodPost,
+			target: "/api/v1/video-rules",
+			body: `{"action": "include", "channel_id": "channel1", "field": "title", "operator": "contains", ` +
+				`"value": "tutorial"}`,
+			want: http.StatusCreated,
+		},
+		{
+			name:   "error case - create invalid rule",
+			method: http.MethodPost,
+			target: "/api/v1/video-rules",
+			body:   `{"action": "include", "field": "duration", "operator": "longer_than", "value": "long"}`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - create invalid JSON",
+			method: http.MethodPost,
+			target: "/api/v1/video-rules",
+			body:   `{"action"`,
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "success case - delete",
+			method: http.MethodDelete,
+			target: "/api/v1/video-rules?id=1",
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "error case - delete storage error",
+			method: http.MethodDelete,
+			target: "/api/v1/video-rules?id=2",
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "error case - method not allowed",
+			method: http.MethodPut,
+			target: "/api/v1/video-rules",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   tokenNotFound,
+			method: http.MethodGet,
+			target: "/api/v1/video-rules",
+			want:   http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != tokenNotFound {
+				req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{AppUserId: 1}))
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunction := VideoRulesAPI(storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("VideoRulesAPI() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusCreated {
+				var response videoRuleResponse
+				if err = json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+					t.Fatalf("VideoRulesAPI() invalid JSON response: %v", err)
+				}
+				if response.ID != 2 || response.Description == "" {
+					t.Errorf("VideoRulesAPI() got = %+v", response)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainChannel(t *testing.T) {
+	// mocks
+	oauth2C := auth.Oauth2Config{Oauth2ConfigProvider: &test.Oauth2Mock{}}
+	ytcf := &youtubeClientFactoryMock{
+		newClientStub: func(ts oauth2.TokenSource) (clients.YoutubeClientInterface, error) {
+			return &youtubeClientMock{
+				getLatestVideoFromPlaylistStub: func(playlistID string) (*youtube.PlaylistItem, error) {
+					switch playlistID {
+					case "cUannel3":
+						return nil, fmt.Errorf("test error")
+					case "cUannel5":
+						return &youtube.PlaylistItem{Snippet: &youtube.PlaylistItemSnippet{Title: "Private video",
+							ResourceId: &youtube.ResourceId{VideoId: "video5"}}}, nil
+					}
+					return &youtube.PlaylistItem{Snippet: &youtube.PlaylistItemSnippet{
+						Title:        "#shorts funny cat",
+						ChannelTitle: "Cats",
+						ResourceId:   &youtube.ResourceId{VideoId: "video1"},
+					}}, nil
+				},
+				getVideosStub: func(ctx context.Context, videoIDs []string,
+					processFunction func(*youtube.VideoListResponse) error) error {
+					return processFunction(&youtube.VideoListResponse{Items: []*youtube.Video{
+						{Id: "video1", ContentDetails: &youtube.VideoContentDetails{Duration: "PT30S"}},
+						{Id: "video6", ContentDetails: &youtube.VideoContentDetails{Duration: "PT30S"}},
+					}})
+				},
+				getPlaylistVideosStub: func(ctx context.Context, playlistID string,
+					maxResults int64) ([]*youtube.PlaylistItem, error) {
+					return []*youtube.PlaylistItem{
+						{Snippet: &youtube.PlaylistItemSnippet{Title: "Private video",
+							ResourceId: &youtube.ResourceId{VideoId: "video5"}}},
+						{Snippet: &youtube.PlaylistItemSnippet{Title: "Cat documentary",
+							ResourceId: &youtube.ResourceId{VideoId: "video6"}}},
+					}, nil
+				},
+			}, nil
+		},
+	}
+	storage := &test.StorageMock{
+		GetChannelRulesStub: func(_ context.Context, userId int64) ([]database.ChannelRule, error) {
+			return []database.ChannelRule{{ChannelID: "channel1", Kind: database.MuteRule},
+				{ChannelID: "channel5", Kind: database.TitleMatchRule, TitlePattern: "documentary"}}, nil
+		},
+		GetVideoRulesStub: func(_ context.Context, userId int64) ([]database.VideoRule, error) {
+			return []database.VideoRule{{Id: 1, Action: database.ExcludeAction, ChannelID: "channel2",
+				Field: typeField, Operator: equalsOperator, Value: ShortVideoType}}, nil
+		},
+		GetPreferencesStub: func(_ context.Context, userId int64) (database.Preferences, error) {
+			preferences := database.DefaultPreferences()
+			preferences.HiddenVideoTypes = []string{ShortVideoType}
+			return preferences, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		want        int
+		wantReasons []string
+	}{
+		{
+			name:        "success case - muted channel",
+			target:      "/api/v1/feed/explain?channel_id=channel1",
+			want:        http.StatusOK,
+			wantReasons: []string{"channel muted"},
+		},
+		{
+			name:   "success case - hidden by a video rule",
+			target: "/api/v1/feed/explain?channel_id=channel2",
+			want:   http.StatusOK,
+			wantReasons: []string{`matches rule 1: hide videos whose type equals "short" (channel channel2)`,
+				"videos of type short hidden in the settings"},
+		},
+		{
+			name:        "success case - video type hidden in the settings",
+			target:      "/api/v1/feed/explain?channel_id=channel4",
+			want:        http.StatusOK,
+			wantReasons: []string{"videos of type short hidden in the settings"},
+		},
+		{
+			name:        "success case - unavailable latest video replaced by the newest watchable one",
+			target:      "/api/v1/feed/explain?channel_id=channel5",
+			want:        http.StatusOK,
+			wantReasons: []string{"videos of type short hidden in the settings"},
+		},
+		{
+			name:   "error case - missing channel id",
+			target: "/api/v1/feed/explain",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "error case - latest video not retrieved",
+			target: "/api/v1/feed/explain?channel_id=channel3",
+			want:   http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(addTokenInfoToContext(req.Context(), &auth.TokenInfo{Token: &oauth2.Token{}}))
+			recorder := httptest.NewRecorder()
+			handlerFunction := ExplainChannel(oauth2C, ytcf, storage)
+			handlerFunction(recorder, req)
+			if recorder.Code != tt.want {
+				t.Fatalf("ExplainChannel() = %v, want %v", recorder.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var response explanationResponse
+			if err = json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("ExplainChannel() invalid JSON response: %v", err)
+			}
+			if !response.Hidden {
+				t.Errorf("ExplainChannel() expected the channel to be hidden")
+			}
+			if diff := cmp.Diff(tt.wantReasons, response.Reasons); diff != "" {
+				t.Errorf("ExplainChannel() reasons mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
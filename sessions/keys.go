@@ -0,0 +1,130 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/gorilla/sessions"
+	"math"
+	"strings"
+)
+
+const (
+	// MinAuthKeyLength is the minimum length in bytes of the keys authenticating the session cookies
+	MinAuthKeyLength = 32
+	// minKeyEntropy is the minimum Shannon entropy, in bits per byte, of the authentication keys: low enough
+	// to accept hex encoded random keys, high enough to reject repeated patterns
+	minKeyEntropy = 3.0
+	// generated keys length, the encryption key selects AES-256
+	authKeyLength       = 64
+	encryptionKeyLength = 32
+)
+
+// KeyPair is a key authenticating the session cookies along with the optional key encrypting them
+type KeyPair struct {
+	AuthKey       []byte
+	EncryptionKey []byte
+}
+
+// ParseKeyPairs parses a comma-separated list of key pairs, the current one first followed by the previous ones.
+// Each pair is a base64 authentication key, optionally followed by a colon and a base64 encryption key
+func ParseKeyPairs(value string) ([]KeyPair, error) {
+	keyPairs := make([]KeyPair, 0)
+	for i, encodedPair := range strings.Split(value, ",") {
+		encodedPair = strings.TrimSpace(encodedPair)
+		if encodedPair == "" {
+			continue
+		}
+
+		encodedAuthKey, encodedEncryptionKey, _ := strings.Cut(encodedPair, ":")
+		authKey, err := decodeKey(encodedAuthKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authentication key of pair %d: %w", i+1, err)
+		}
+		keyPair := KeyPair{AuthKey: authKey}
+		if encodedEncryptionKey != "" {
+			if keyPair.EncryptionKey, err = decodeKey(encodedEncryptionKey); err != nil {
+				return nil, fmt.Errorf("invalid encryption key of pair %d: %w", i+1, err)
+			}
+		}
+		if err = keyPair.Validate(); err != nil {
+			return nil, fmt.Errorf("key pair %d: %w", i+1, err)
+		}
+		keyPairs = append(keyPairs, keyPair)
+	}
+
+	if len(keyPairs) == 0 {
+		return nil, fmt.Errorf("no session key found")
+	}
+	return keyPairs, nil
+}
+
+// Validate checks the authentication key is long and random enough, and the encryption key
+// has a valid AES key length
+func (k KeyPair) Validate() error {
+	if len(k.AuthKey) < MinAuthKeyLength {
+		return fmt.Errorf("authentication key must be at least %d bytes long, got %d", MinAuthKeyLength,
+			len(k.AuthKey))
+	}
+	if entropy := shannonEntropy(k.AuthKey); entropy < minKeyEntropy {
+		return fmt.Errorf("authentication key is not random enough: %.2f bits per byte, want at least %.2f",
+			entropy, minKeyEntropy)
+	}
+	switch len(k.EncryptionKey) {
+	case 0, 16, 24, 32:
+	default:
+		return fmt.Errorf("encryption key must be 16, 24 or 32 bytes long, got %d", len(k.EncryptionKey))
+	}
+	return nil
+}
+
+// NewCookieStore creates a cookie store signing and encrypting new cookies with the first key pair,
+// while still accepting cookies created with the previous ones
+func NewCookieStore(keyPairs []KeyPair) *sessions.CookieStore {
+	keys := make([][]byte, 0, len(keyPairs)*2)
+	for _, keyPair := range keyPairs {
+		keys = append(keys, keyPair.AuthKey, keyPair.EncryptionKey)
+	}
+	return sessions.NewCookieStore(keys...)
+}
+
+// GenerateKeyPair returns a new random key pair, encoded as expected by ParseKeyPairs
+func GenerateKeyPair() (string, error) {
+	authKey := make([]byte, authKeyLength)
+	if _, err := rand.Read(authKey); err != nil {
+		return "", err
+	}
+	encryptionKey := make([]byte, encryptionKeyLength)
+	if _, err := rand.Read(encryptionKey); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(authKey) + ":" + base64.RawURLEncoding.EncodeToString(encryptionKey),
+		nil
+}
+
+// keys are accepted both in standard and URL base64 encoding, padded or not
+func decodeKey(encodedKey string) ([]byte, error) {
+	encodedKey = strings.TrimRight(strings.TrimSpace(encodedKey), "=")
+	if strings.ContainsAny(encodedKey, "+/") {
+		return base64.RawStdEncoding.DecodeString(encodedKey)
+	}
+	return base64.RawURLEncoding.DecodeString(encodedKey)
+}
+
+// return the Shannon entropy of the key bytes, in bits per byte
+func shannonEntropy(key []byte) float64 {
+	var counts [256]int
+	for _, b := range key {
+		counts[b]++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(len(key))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,144 @@
+package sessions
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyPairs(t *testing.T) {
+	// mocks
+	newKeyPair := func(t *testing.T) string {
+		keyPair, err := GenerateKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keyPair
+	}
+	current, previous := newKeyPair(t), newKeyPair(t)
+	authKeyOnly, _, _ := strings.Cut(previous, ":")
+	weakKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("ab", 32)))
+	shortKey := base64.StdEncoding.EncodeToString([]byte("tooshort"))
+
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "success case - current and previous key pairs",
+			value:   current + ", " + previous,
+			want:    2,
+			wantErr: false,
+		},
+		{
+			name:    "success case - authentication key only",
+			value:   authKeyOnly,
+			want:    1,
+			wantErr: false,
+		},
+		{
+			name:    "error case - empty value",
+			value:   " , ",
+			wantErr: true,
+		},
+		{
+			name:    "error case - short key",
+			value:   shortKey,
+			wantErr: true,
+		},
+		{
+			name:    "error case - low entropy key",
+			value:   weakKey,
+			wantErr: true,
+		},
+		{
+			name:    "error case - invalid encryption key length",
+			value:   authKeyOnly + ":" + shortKey,
+			wantErr: true,
+		},
+		{
+			name:    "error case - invalid base64",
+			value:   "not*base64",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeyPairs(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseKeyPairs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("ParseKeyPairs() got %v key pairs, want %v", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCookieStore_rotation(t *testing.T) {
+	// mocks
+	parse := func(t *testing.T, value string) []KeyPair {
+		keyPairs, err := ParseKeyPairs(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keyPairs
+	}
+	oldKeyPair, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKeyPair, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create a cookie with the old keys
+	oldStore := NewCookieStore(parse(t, oldKeyPair))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+	session, err := oldStore.Get(req, Oauth2SessionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values[VerifierKey] = "verifier"
+	if err = session.Save(req, recorder); err != nil {
+		t.Fatal(err)
+	}
+	cookies := recorder.Result().Cookies()
+
+	tests := []struct {
+		name      string
+		keyPairs  string
+		wantValue bool
+	}{
+		{
+			name:      "success case - rotated keys accept old cookies",
+			keyPairs:  newKeyPair + "," + oldKeyPair,
+			wantValue: true,
+		},
+		{
+			name:      "success case - dropped keys reject old cookies",
+			keyPairs:  newKeyPair,
+			wantValue: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			value, err := GetValueFromSession[string](NewCookieStore(parse(t, tt.keyPairs)), req,
+				Oauth2SessionName, VerifierKey)
+			if gotValue := err == nil && value == "verifier"; gotValue != tt.wantValue {
+				t.Errorf("GetValueFromSession() value = %v, err = %v, want value %v", value, err, tt.wantValue)
+			}
+		})
+	}
+}
@@ -13,6 +13,9 @@ const (
 	Oauth2SessionName = "oauth2_session"
 	VerifierKey       = "verifier"
 	TokenKey          = "token"
+	LinkAccountKey    = "link_account"
+	NonceKey          = "nonce"
+	ReturnToKey       = "return_to"
 )
 
 // GetValueFromSession returns the data having the given key from the session store
@@ -0,0 +1,110 @@
+package subscriptionio
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+)
+
+func init() {
+	Register(freeTubeFormat{})
+}
+
+// freeTubeFormat is the profiles .db file of FreeTube, a NeDB database holding a JSON document per line, one for
+// each profile along with its subscriptions
+type freeTubeFormat struct{}
+
+// id of the profile of FreeTube holding all the subscriptions
+const freeTubeAllChannelsProfile = "allChannels"
+
+type freeTubeProfile struct {
+	Name          string                 `json:"name"`
+	BgColor       string                 `json:"bgColor"`
+	TextColor     string                 `json:"textColor"`
+	Subscriptions []freeTubeSubscription `json:"subscriptions"`
+	ID            string                 `json:"_id"`
+}
+
+// freeTubeLine is a line of the NeDB log: a profile, the deletion of one or the creation of an index. Profiles
+// updated are appended again with the same id, superseding the previous lines
+type freeTubeLine struct {
+	freeTubeProfile
+	Deleted      bool            `json:"$$deleted"`
+	IndexCreated json.RawMessage `json:"$$indexCreated"`
+}
+
+type freeTubeSubscription struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Thumbnail string `json:"thumbnail"`
+}
+
+func (freeTubeFormat) Name() string        { return "freetube" }
+func (freeTubeFormat) Label() string       { return "FreeTube (.db)" }
+func (freeTubeFormat) Filename() string    { return "freetube-subscriptions.db" }
+func (freeTubeFormat) ContentType() string { return "application/octet-stream" }
+
+// Read returns the subscriptions of every profile, the channels of several profiles being listed several times.
+// Only the last line of each profile is read, the deleted profiles are left out
+func (freeTubeFormat) Read(r io.Reader) ([]Channel, error) {
+	decoder := json.NewDecoder(r)
+	profiles := map[string]freeTubeProfile{}
+	// ids of the profiles in the order they were first written
+	var ids []string
+	documents := 0
+	for {
+		var line freeTubeLine
+		err := decoder.Decode(&line)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line.IndexCreated != nil {
+			continue
+		}
+		documents++
+		if line.Deleted {
+			delete(profiles, line.ID)
+			continue
+		}
+		if !slices.Contains(ids, line.ID) {
+			ids = append(ids, line.ID)
+		}
+		profiles[line.ID] = line.freeTubeProfile
+	}
+	if documents == 0 {
+		return nil, errors.New("missing profiles")
+	}
+
+	channels := make([]Channel, 0)
+	for _, id := range ids {
+		profile, ok := profiles[id]
+		if !ok {
+			continue
+		}
+		for _, subscription := range profile.Subscriptions {
+			channels = append(channels, Channel{ID: subscription.ID, Title: subscription.Name})
+		}
+	}
+	return channels, nil
+}
+
+// Write lists the channels in the profile holding all the subscriptions, in the colors of FreeTube
+func (freeTubeFormat) Write(w io.Writer, channels []Channel) error {
+	profile := freeTubeProfile{
+		Name:          "All Channels",
+		BgColor:       "#000000",
+		TextColor:     "#FFFFFF",
+		Subscriptions: make([]freeTubeSubscription, 0, len(channels)),
+		ID:            freeTubeAllChannelsProfile,
+	}
+	for _, channel := range channels {
+		profile.Subscriptions = append(profile.Subscriptions, freeTubeSubscription{ID: channel.ID,
+			Name: channel.Title})
+	}
+	// NeDB documents are single lines
+	return json.NewEncoder(w).Encode(profile)
+}
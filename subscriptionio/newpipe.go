@@ -0,0 +1,80 @@
+package subscriptionio
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+func init() {
+	Register(newPipeFormat{})
+}
+
+// newPipeFormat is the subscriptions JSON file of NewPipe, listing the channels of every streaming service
+// supported by the app
+type newPipeFormat struct{}
+
+// id of YouTube among the streaming services of NewPipe
+const newPipeYouTubeServiceID = 0
+
+// version of NewPipe whose export format is written
+const (
+	newPipeAppVersion    = "0.24.1"
+	newPipeAppVersionInt = 990
+)
+
+type newPipeDocument struct {
+	AppVersion    string                `json:"app_version"`
+	AppVersionInt int                   `json:"app_version_int"`
+	Subscriptions []newPipeSubscription `json:"subscriptions"`
+}
+
+type newPipeSubscription struct {
+	ServiceID int    `json:"service_id"`
+	URL       string `json:"url"`
+	Name      string `json:"name"`
+}
+
+func (newPipeFormat) Name() string        { return "newpipe" }
+func (newPipeFormat) Label() string       { return "NewPipe (JSON)" }
+func (newPipeFormat) Filename() string    { return "newpipe_subscriptions.json" }
+func (newPipeFormat) ContentType() string { return "application/json" }
+
+// Read returns the channels of the other streaming services without id
+func (newPipeFormat) Read(r io.Reader) ([]Channel, error) {
+	var document newPipeDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+	if document.Subscriptions == nil {
+		return nil, errors.New("missing subscriptions")
+	}
+
+	channels := make([]Channel, 0, len(document.Subscriptions))
+	for _, subscription := range document.Subscriptions {
+		channel := Channel{Title: subscription.Name}
+		if subscription.ServiceID == newPipeYouTubeServiceID {
+			channel.ID = channelIDFromURL(subscription.URL)
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+func (newPipeFormat) Write(w io.Writer, channels []Channel) error {
+	document := newPipeDocument{
+		AppVersion:    newPipeAppVersion,
+		AppVersionInt: newPipeAppVersionInt,
+		Subscriptions: make([]newPipeSubscription, 0, len(channels)),
+	}
+	for _, channel := range channels {
+		document.Subscriptions = append(document.Subscriptions, newPipeSubscription{
+			ServiceID: newPipeYouTubeServiceID,
+			URL:       channelURL(channel.ID),
+			Name:      channel.Title,
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
@@ -0,0 +1,93 @@
+package subscriptionio
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+func init() {
+	Register(opmlFormat{})
+}
+
+// opmlFormat is the OPML file of feed readers, listing the RSS feed of each channel
+type opmlFormat struct{}
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+func (opmlFormat) Name() string        { return "opml" }
+func (opmlFormat) Label() string       { return "OPML (feed readers)" }
+func (opmlFormat) Filename() string    { return "subscriptions.opml" }
+func (opmlFormat) ContentType() string { return "text/x-opml; charset=utf-8" }
+
+// Read returns the feeds of the file, the ones grouped in folders included. Channels are recognized by the
+// channel_id of their RSS feed or by their YouTube page
+func (opmlFormat) Read(r io.Reader) ([]Channel, error) {
+	var document opmlDocument
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	channels := make([]Channel, 0)
+	var walk func(outlines []opmlOutline)
+	walk = func(outlines []opmlOutline) {
+		for _, outline := range outlines {
+			if len(outline.Outlines) > 0 {
+				walk(outline.Outlines)
+				continue
+			}
+			channelID := channelIDFromURL(outline.XMLURL)
+			if channelID == "" {
+				channelID = channelIDFromURL(outline.HTMLURL)
+			}
+			title := outline.Title
+			if title == "" {
+				title = outline.Text
+			}
+			channels = append(channels, Channel{ID: channelID, Title: title})
+		}
+	}
+	walk(document.Body)
+	return channels, nil
+}
+
+// Write groups the channels in a single outline, as YouTube used to export them
+func (opmlFormat) Write(w io.Writer, channels []Channel) error {
+	group := opmlOutline{Text: "YouTube Subscriptions", Title: "YouTube Subscriptions",
+		Outlines: make([]opmlOutline, 0, len(channels))}
+	for _, channel := range channels {
+		group.Outlines = append(group.Outlines, opmlOutline{
+			Text:    channel.Title,
+			Title:   channel.Title,
+			Type:    "rss",
+			XMLURL:  fmt.Sprintf("%s/feeds/videos.xml?channel_id=%s", youTubeBasepath, channel.ID),
+			HTMLURL: channelURL(channel.ID),
+		})
+	}
+	document := opmlDocument{Version: "1.1", Title: "CheckYoutube subscriptions", Body: []opmlOutline{group}}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
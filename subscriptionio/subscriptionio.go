@@ -0,0 +1,139 @@
+package subscriptionio
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// channel ids are "UC" followed by 22 characters
+var channelIDRegex = regexp.MustCompile(`^UC[\w-]{22}$`)
+
+const youTubeBasepath = "https://www.youtube.com"
+
+// Channel is a YouTube channel listed in a subscriptions file
+type Channel struct {
+	// ID is empty when the entry of the file isn't a YouTube channel
+	ID    string
+	Title string
+}
+
+// Format reads and writes the subscriptions files of an app
+type Format interface {
+	// Name identifies the format in forms and urls
+	Name() string
+	// Label describes the format to users
+	Label() string
+	// Filename is the name of the exported files
+	Filename() string
+	ContentType() string
+	// Read returns the entries of a file as they are listed, the invalid and duplicated ones included
+	Read(r io.Reader) ([]Channel, error)
+	Write(w io.Writer, channels []Channel) error
+}
+
+// Parsed are the channels of an imported file, once each
+type Parsed struct {
+	Channels []Channel
+	// Invalid is the number of entries that aren't YouTube channels
+	Invalid int
+	// Duplicates is the number of entries listing a channel already listed
+	Duplicates int
+}
+
+var formats = make(map[string]Format)
+
+// Register makes a format available to imports and exports, panicking when its name is already taken
+func Register(format Format) {
+	if _, ok := formats[format.Name()]; ok {
+		panic(fmt.Sprintf("subscriptionio: format %s registered twice", format.Name()))
+	}
+	formats[format.Name()] = format
+}
+
+// Lookup returns the format registered with the given name
+func Lookup(name string) (Format, bool) {
+	format, ok := formats[name]
+	return format, ok
+}
+
+// Formats returns the registered formats sorted by name
+func Formats() []Format {
+	sorted := make([]Format, 0, len(formats))
+	for _, format := range formats {
+		sorted = append(sorted, format)
+	}
+	slices.SortFunc(sorted, func(a, b Format) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return sorted
+}
+
+// Parse reads a file of the given format, leaving out the entries that aren't YouTube channels and the duplicated
+// ones
+func Parse(format Format, r io.Reader) (*Parsed, error) {
+	entries, err := format.Read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &Parsed{Channels: make([]Channel, 0, len(entries))}
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		entry.ID = strings.TrimSpace(entry.ID)
+		switch {
+		case !ValidChannelID(entry.ID):
+			parsed.Invalid++
+		case seen[entry.ID]:
+			parsed.Duplicates++
+		default:
+			seen[entry.ID] = true
+			entry.Title = strings.TrimSpace(entry.Title)
+			parsed.Channels = append(parsed.Channels, entry)
+		}
+	}
+	return parsed, nil
+}
+
+// Export writes the given channels in the given format, once each
+func Export(format Format, w io.Writer, channels []Channel) error {
+	unique := make([]Channel, 0, len(channels))
+	seen := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		if !seen[channel.ID] {
+			seen[channel.ID] = true
+			unique = append(unique, channel)
+		}
+	}
+	return format.Write(w, unique)
+}
+
+// ValidChannelID tells whether the given string is a YouTube channel id
+func ValidChannelID(channelID string) bool {
+	return channelIDRegex.MatchString(channelID)
+}
+
+// return the id of the YouTube channel of a channel page url like https://www.youtube.com/channel/UC..., or of a
+// feed url like https://www.youtube.com/feeds/videos.xml?channel_id=UC..., empty if it isn't one
+func channelIDFromURL(rawURL string) string {
+	parsedURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	if channelID := parsedURL.Query().Get("channel_id"); ValidChannelID(channelID) {
+		return channelID
+	}
+	segments := strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	if i := slices.Index(segments, "channel"); i >= 0 && i+1 < len(segments) && ValidChannelID(segments[i+1]) {
+		return segments[i+1]
+	}
+	return ""
+}
+
+// return the url of the page of a YouTube channel
+func channelURL(channelID string) string {
+	return fmt.Sprintf("%s/channel/%s", youTubeBasepath, channelID)
+}
@@ -0,0 +1,149 @@
+package subscriptionio
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// channels listed in every fixture file, along with an invalid entry and a duplicated one
+var fixtureChannels = []Channel{
+	{ID: "UCBR8-60-B28hp2BmDPdntcQ", Title: "YouTube"},
+	{ID: "UC_x5XG1OV2P6uZZ5FSM9Ttw", Title: `Google for Developers, "GDG"`},
+	{ID: "UCsXVk37bltHxD1rDPwtNM8Q", Title: "Kurzgesagt – In a Nutshell"},
+}
+
+func TestFormats_roundTrip(t *testing.T) {
+	tests := []struct {
+		format  string
+		fixture string
+	}{
+		{format: "opml", fixture: "subscriptions.opml"},
+		{format: "takeout", fixture: "subscriptions.csv"},
+		{format: "newpipe", fixture: "newpipe.json"},
+		{format: "freetube", fixture: "freetube.db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			format, ok := Lookup(tt.format)
+			if !ok {
+				t.Fatalf("Lookup() format %s not registered", tt.format)
+			}
+			file, err := os.Open(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			parsed, err := Parse(format, file)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			want := &Parsed{Channels: fixtureChannels, Invalid: 1, Duplicates: 1}
+			if diff := cmp.Diff(want, parsed); diff != "" {
+				t.Errorf("Parse() mismatch (-want +got):\n%s", diff)
+			}
+
+			// the exported file is read back as the channels exported
+			var buffer bytes.Buffer
+			if err = Export(format, &buffer, append(parsed.Channels, parsed.Channels[0])); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			reparsed, err := Parse(format, &buffer)
+			if err != nil {
+				t.Fatalf("Parse() of the exported file error = %v", err)
+			}
+			want = &Parsed{Channels: fixtureChannels}
+			if diff := cmp.Diff(want, reparsed); diff != "" {
+				t.Errorf("Export() round trip mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFreeTubeFormat_Read_superseded(t *testing.T) {
+	// the channel unsubscribed from in an updated profile and the ones of a deleted profile are left out
+	file, err := os.Open(filepath.Join("testdata", "freetube-superseded.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	got, err := freeTubeFormat{}.Read(file)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := []Channel{
+		{ID: "UCBR8-60-B28hp2BmDPdntcQ", Title: "YouTube"},
+		{ID: "UCsXVk37bltHxD1rDPwtNM8Q", Title: "Kurzgesagt – In a Nutshell"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParse_invalidFiles(t *testing.T) {
+	tests := []struct {
+		format string
+		file   string
+	}{
+		{format: "opml", file: `{"subscriptions": []}`},
+		{format: "takeout", file: ""},
+		{format: "takeout", file: "Channel Id,Channel Url,Channel Title\n\"unterminated"},
+		{format: "newpipe", file: `{"app_version": "0.24.1"}`},
+		{format: "newpipe", file: "<opml/>"},
+		{format: "freetube", file: ""},
+		{format: "freetube", file: `{"name": "All Channels"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			format, _ := Lookup(tt.format)
+			if _, err := Parse(format, strings.NewReader(tt.file)); err == nil {
+				t.Errorf("Parse() of %q error = nil, want error", tt.file)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	var got []string
+	for _, format := range Formats() {
+		got = append(got, format.Name())
+	}
+	want := []string{"freetube", "newpipe", "opml", "takeout"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Formats() mismatch (-want +got):\n%s", diff)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register() of a format already registered didn't panic")
+		}
+	}()
+	Register(opmlFormat{})
+}
+
+func Test_channelIDFromURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://www.youtube.com/channel/UCBR8-60-B28hp2BmDPdntcQ", want: "UCBR8-60-B28hp2BmDPdntcQ"},
+		{url: "http://youtube.com/channel/UCBR8-60-B28hp2BmDPdntcQ/videos", want: "UCBR8-60-B28hp2BmDPdntcQ"},
+		{url: "https://www.youtube.com/feeds/videos.xml?channel_id=UCBR8-60-B28hp2BmDPdntcQ",
+			want: "UCBR8-60-B28hp2BmDPdntcQ"},
+		{url: "https://www.youtube.com/@YouTube"},
+		{url: "https://www.youtube.com/channel/UCshort"},
+		{url: "::"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := channelIDFromURL(tt.url); got != tt.want {
+				t.Errorf("channelIDFromURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package subscriptionio
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+)
+
+func init() {
+	Register(takeoutFormat{})
+}
+
+// takeoutFormat is the subscriptions.csv file of Google Takeout, listing the id, the url and the title of each
+// channel under a header localized in the language of the account
+type takeoutFormat struct{}
+
+var takeoutHeader = []string{"Channel Id", "Channel Url", "Channel Title"}
+
+func (takeoutFormat) Name() string        { return "takeout" }
+func (takeoutFormat) Label() string       { return "Google Takeout (subscriptions.csv)" }
+func (takeoutFormat) Filename() string    { return "subscriptions.csv" }
+func (takeoutFormat) ContentType() string { return "text/csv; charset=utf-8" }
+
+// Read skips the header, whatever its language. The channels without id are recognized by their url
+func (takeoutFormat) Read(r io.Reader) ([]Channel, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) < len(takeoutHeader) {
+		return nil, errors.New("missing header")
+	}
+
+	channels := make([]Channel, 0, len(records)-1)
+	for _, record := range records[1:] {
+		var channel Channel
+		if len(record) >= len(takeoutHeader) {
+			channel = Channel{ID: record[0], Title: record[2]}
+			if !ValidChannelID(strings.TrimSpace(channel.ID)) {
+				channel.ID = channelIDFromURL(record[1])
+			}
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+func (takeoutFormat) Write(w io.Writer, channels []Channel) error {
+	records := make([][]string, 0, len(channels)+1)
+	records = append(records, takeoutHeader)
+	for _, channel := range channels {
+		records = append(records, []string{channel.ID, channelURL(channel.ID), channel.Title})
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
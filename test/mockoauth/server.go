@@ -0,0 +1,271 @@
+package mockoauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSubject is the subject of the user logged in when no login_hint is sent to the authorize endpoint
+	DefaultSubject = "mock-user"
+	keyID          = "mock-key"
+	tokenLifetime  = time.Hour
+)
+
+// Server is a local oauth2 and OpenID Connect provider stand-in, approving every authorization request.
+// It is meant to run the login flow in development and tests without reaching a real identity provider
+type Server struct {
+	// Issuer is the base URL the server is reachable at, used as the ID tokens issuer
+	Issuer string
+
+	key       *rsa.PrivateKey
+	mutex     sync.Mutex
+	codes     map[string]authorization
+	tokens    map[string]string // access or refresh token -> subject
+	clientIDs map[string]string // refresh token -> client id
+}
+
+type authorization struct {
+	clientID      string
+	subject       string
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+}
+
+// NewServer creates a new Server, issuer must be set before serving requests
+func NewServer() (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		key:       key,
+		codes:     make(map[string]authorization),
+		tokens:    make(map[string]string),
+		clientIDs: make(map[string]string),
+	}, nil
+}
+
+// ServeHTTP routes requests to the oauth2 and OpenID Connect endpoints
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		s.discovery(w)
+	case "/authorize":
+		s.authorize(w, r)
+	case "/token":
+		s.token(w, r)
+	case "/userinfo":
+		s.userinfo(w, r)
+	case "/jwks":
+		s.jwks(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// SignIDToken returns an RS256 ID token having the given claims, signed with the server key
+func (s *Server) SignIDToken(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": keyID, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 s.Issuer,
+		"authorization_endpoint": s.Issuer + "/authorize",
+		"token_endpoint":         s.Issuer + "/token",
+		"userinfo_endpoint":      s.Issuer + "/userinfo",
+		"jwks_uri":               s.Issuer + "/jwks",
+	})
+}
+
+// approve the request and redirect back to the client with an authorization code
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.String() == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	subject := query.Get("login_hint")
+	if subject == "" {
+		subject = DefaultSubject
+	}
+
+	code := randomString()
+	s.mutex.Lock()
+	s.codes[code] = authorization{
+		clientID:      query.Get("client_id"),
+		subject:       subject,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   redirectURI.String(),
+	}
+	s.mutex.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, "invalid_request", err.Error())
+		return
+	}
+	clientID, _, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		s.mutex.Lock()
+		auth, found := s.codes[r.PostForm.Get("code")]
+		delete(s.codes, r.PostForm.Get("code"))
+		s.mutex.Unlock()
+		if !found || auth.clientID != clientID || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+			writeTokenError(w, "invalid_grant", "unknown authorization code")
+			return
+		}
+		if auth.codeChallenge != "" {
+			digest := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if base64.RawURLEncoding.EncodeToString(digest[:]) != auth.codeChallenge {
+				writeTokenError(w, "invalid_grant", "code verifier mismatch")
+				return
+			}
+		}
+		s.writeToken(w, clientID, auth.subject, auth.nonce, true)
+	case "refresh_token":
+		s.mutex.Lock()
+		subject, found := s.tokens[r.PostForm.Get("refresh_token")]
+		issuedTo := s.clientIDs[r.PostForm.Get("refresh_token")]
+		s.mutex.Unlock()
+		if !found || issuedTo != clientID {
+			writeTokenError(w, "invalid_grant", "unknown refresh token")
+			return
+		}
+		s.writeToken(w, clientID, subject, "", false)
+	default:
+		writeTokenError(w, "unsupported_grant_type", r.PostForm.Get("grant_type"))
+	}
+}
+
+func (s *Server) writeToken(w http.ResponseWriter, clientID, subject, nonce string, withRefreshToken bool) {
+	now := time.Now()
+	claims := map[string]any{
+		"iss":  s.Issuer,
+		"sub":  subject,
+		"aud":  clientID,
+		"iat":  now.Unix(),
+		"exp":  now.Add(tokenLifetime).Unix(),
+		"name": subject,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	idToken, err := s.SignIDToken(claims)
+	if err != nil {
+		writeTokenError(w, "server_error", err.Error())
+		return
+	}
+
+	accessToken := randomString()
+	response := map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenLifetime.Seconds()),
+		"id_token":     idToken,
+	}
+	s.mutex.Lock()
+	s.tokens[accessToken] = subject
+	if withRefreshToken {
+		refreshToken := randomString()
+		s.tokens[refreshToken] = subject
+		s.clientIDs[refreshToken] = clientID
+		response["refresh_token"] = refreshToken
+	}
+	s.mutex.Unlock()
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	accessToken, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mutex.Lock()
+	subject, known := s.tokens[accessToken]
+	s.mutex.Unlock()
+	if !found || !known {
+		http.Error(w, "invalid access token", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"sub":   subject,
+		"name":  subject,
+		"email": fmt.Sprintf("%s@example.com", subject),
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": keyID,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+			},
+		},
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
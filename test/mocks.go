@@ -1,9 +1,11 @@
 package test
 
 import (
+	"checkYoutube/database"
 	"context"
 	"golang.org/x/oauth2"
 	"net/http"
+	"time"
 )
 
 // TokenSourceMock mocks an oauth2 token source implementation
@@ -19,11 +21,14 @@ type Oauth2Mock struct{}
 func (o *Oauth2Mock) GenerateVerifier() string {
 	return "mockVerifier"
 }
-func (o *Oauth2Mock) GenerateAuthURL(string, string, bool, bool) string {
+func (o *Oauth2Mock) GenerateAuthURL(string, string, string, bool, bool) string {
 	return "mockURL"
 }
+func (o *Oauth2Mock) GenerateWriteAuthURL(string, string, string) string {
+	return "mockWriteURL"
+}
 func (o *Oauth2Mock) ExchangeCodeWithToken(context.Context, string, ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
-	return &oauth2.Token{}, nil
+	return (&oauth2.Token{}).WithExtra(map[string]interface{}{"id_token": "mockIDToken"}), nil
 }
 func (o *Oauth2Mock) CreateHTTPClient(context.Context, *oauth2.Token) *http.Client {
 	return &http.Client{}
@@ -31,3 +36,238 @@ func (o *Oauth2Mock) CreateHTTPClient(context.Context, *oauth2.Token) *http.Clie
 func (o *Oauth2Mock) CreateTokenSource(context.Context, *oauth2.Token) oauth2.TokenSource {
 	return &TokenSourceMock{}
 }
+
+// StorageMock mocks a database storage implementation
+type StorageMock struct {
+	RunMigrationsStub               func(ctx context.Context) error
+	GetRefreshTokenByUserIdStub     func(ctx context.Context, userId string) (string, error)
+	UpsertRefreshTokenStub          func(ctx context.Context, userId, refreshToken string) error
+	GetUserIdByAccountIdStub        func(ctx context.Context, accountId string) (int64, error)
+	CreateUserWithAccountStub       func(ctx context.Context, accountId, displayName string) (int64, error)
+	LinkAccountStub                 func(ctx context.Context, userId int64, accountId, displayName string) error
+	GetLinkedAccountsStub           func(ctx context.Context, userId int64) ([]database.LinkedAccount, error)
+	UnlinkAccountStub               func(ctx context.Context, userId int64, accountId string) error
+	GetUserIdByLoginIdentityStub    func(ctx context.Context, provider, subject string) (int64, error)
+	CreateUserWithLoginIdentityStub func(ctx context.Context, provider, subject, displayName string) (int64, error)
+	CreateAccessTokenStub           func(ctx context.Context, userId int64, name, tokenHash string, scopes []string,
+		expiresAt *time.Time) (int64, error)
+	GetAccessTokenByHashStub      func(ctx context.Context, tokenHash string) (*database.AccessToken, error)
+	GetAccessTokensStub           func(ctx context.Context, userId int64) ([]database.AccessToken, error)
+	UpdateAccessTokenLastUsedStub func(ctx context.Context, id int64) error
+	DeleteAccessTokenStub         func(ctx context.Context, userId, id int64) error
+	GetUserProfileStub            func(ctx context.Context, userId int64) (*database.UserProfile, error)
+	RecordUserLoginStub           func(ctx context.Context, profile database.UserProfile) error
+	AddLoginEventStub             func(ctx context.Context, event database.LoginEvent) error
+	GetLoginHistoryStub           func(ctx context.Context, userId int64, limit int) ([]database.LoginEvent, error)
+	GetPreferencesStub            func(ctx context.Context, userId int64) (database.Preferences, error)
+	UpsertPreferencesStub         func(ctx context.Context, userId int64, preferences database.Preferences) error
+	CreateChannelGroupStub        func(ctx context.Context, userId int64, name string) (int64, error)
+	GetChannelGroupsStub          func(ctx context.Context, userId int64) ([]database.ChannelGroup, error)
+	RenameChannelGroupStub        func(ctx context.Context, userId, id int64, name string) error
+	DeleteChannelGroupStub        func(ctx context.Context, userId, id int64) error
+	AddChannelsToGroupStub        func(ctx context.Context, userId, groupId int64, channelIDs []string) error
+	RemoveChannelsFromGroupStub   func(ctx context.Context, userId, groupId int64, channelIDs []string) error
+	UpsertChannelRuleStub         func(ctx context.Context, rule database.ChannelRule) error
+	GetChannelRulesStub           func(ctx context.Context, userId int64) ([]database.ChannelRule, error)
+	DeleteChannelRuleStub         func(ctx context.Context, userId, id int64) error
+	CreateVideoRuleStub           func(ctx context.Context, rule database.VideoRule) (int64, error)
+	GetVideoRulesStub             func(ctx context.Context, userId int64) ([]database.VideoRule, error)
+	DeleteVideoRuleStub           func(ctx context.Context, userId, id int64) error
+	CreateAlertStub               func(ctx context.Context, userId int64, query string) (int64, error)
+	GetAlertsStub                 func(ctx context.Context, userId int64) ([]database.Alert, error)
+	MarkAlertsCheckedStub         func(ctx context.Context, userId int64, checkedAt time.Time) error
+	DeleteAlertStub               func(ctx context.Context, userId, id int64) error
+	AddAlertMatchesStub           func(ctx context.Context, matches []database.AlertMatch) ([]database.AlertMatch, error)
+	GetAlertMatchesStub           func(ctx context.Context, userId int64, limit int) ([]database.AlertMatch, error)
+	CountUnreadAlertMatchesStub   func(ctx context.Context, userId int64) (int, error)
+	MarkAlertMatchesReadStub      func(ctx context.Context, userId int64) error
+	UpsertVideosStub              func(ctx context.Context, userId int64, videos []database.Video) error
+	GetVideoChannelsStub          func(ctx context.Context, userId int64) ([]database.VideoChannel, error)
+	SearchVideosStub              func(ctx context.Context,
+		search database.VideoSearch) ([]database.VideoSearchResult, error)
+	LockSubscribedChannelsStub func(ctx context.Context, userId int64, accountId string) error
+	GetSubscribedChannelsStub  func(ctx context.Context, userId int64,
+		accountId string) ([]database.SubscribedChannel, error)
+	HasSubscriptionSnapshotStub   func(ctx context.Context, userId int64, accountId string) (bool, error)
+	ReplaceSubscribedChannelsStub func(ctx context.Context, userId int64, accountId string,
+		channels []database.SubscribedChannel) error
+	AddSubscriptionChangesStub func(ctx context.Context, changes []database.SubscriptionChange) error
+	GetSubscriptionChangesStub func(ctx context.Context, userId int64,
+		limit int) ([]database.SubscriptionChange, error)
+	GetFollowedChannelsStub   func(ctx context.Context, userId int64) ([]database.FollowedChannel, error)
+	AddFollowedChannelsStub   func(ctx context.Context, userId int64, channels []database.FollowedChannel) (int, error)
+	DeleteFollowedChannelStub func(ctx context.Context, userId int64, channelId string) error
+}
+
+func (s *StorageMock) RunMigrations(ctx context.Context) error {
+	return s.RunMigrationsStub(ctx)
+}
+
+// WithTx runs f with the mock itself, as if in a transaction
+func (s *StorageMock) WithTx(_ context.Context, f func(tx database.StorageInterface) error) error {
+	return f(s)
+}
+func (s *StorageMock) GetRefreshTokenByUserId(ctx context.Context, userId string) (string, error) {
+	return s.GetRefreshTokenByUserIdStub(ctx, userId)
+}
+func (s *StorageMock) UpsertRefreshToken(ctx context.Context, userId, refreshToken string) error {
+	return s.UpsertRefreshTokenStub(ctx, userId, refreshToken)
+}
+func (s *StorageMock) GetUserIdByAccountId(ctx context.Context, accountId string) (int64, error) {
+	return s.GetUserIdByAccountIdStub(ctx, accountId)
+}
+func (s *StorageMock) CreateUserWithAccount(ctx context.Context, accountId, displayName string) (int64, error) {
+	return s.CreateUserWithAccountStub(ctx, accountId, displayName)
+}
+func (s *StorageMock) LinkAccount(ctx context.Context, userId int64, accountId, displayName string) error {
+	return s.LinkAccountStub(ctx, userId, accountId, displayName)
+}
+func (s *StorageMock) GetLinkedAccounts(ctx context.Context, userId int64) ([]database.LinkedAccount, error) {
+	return s.GetLinkedAccountsStub(ctx, userId)
+}
+func (s *StorageMock) UnlinkAccount(ctx context.Context, userId int64, accountId string) error {
+	return s.UnlinkAccountStub(ctx, userId, accountId)
+}
+func (s *StorageMock) GetUserIdByLoginIdentity(ctx context.Context, provider, subject string) (int64, error) {
+	return s.GetUserIdByLoginIdentityStub(ctx, provider, subject)
+}
+func (s *StorageMock) CreateUserWithLoginIdentity(ctx context.Context, provider, subject,
+	displayName string) (int64, error) {
+	return s.CreateUserWithLoginIdentityStub(ctx, provider, subject, displayName)
+}
+func (s *StorageMock) CreateAccessToken(ctx context.Context, userId int64, name, tokenHash string, scopes []string,
+	expiresAt *time.Time) (int64, error) {
+	return s.CreateAccessTokenStub(ctx, userId, name, tokenHash, scopes, expiresAt)
+}
+func (s *StorageMock) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*database.AccessToken, error) {
+	return s.GetAccessTokenByHashStub(ctx, tokenHash)
+}
+func (s *StorageMock) GetAccessTokens(ctx context.Context, userId int64) ([]database.AccessToken, error) {
+	return s.GetAccessTokensStub(ctx, userId)
+}
+func (s *StorageMock) UpdateAccessTokenLastUsed(ctx context.Context, id int64) error {
+	return s.UpdateAccessTokenLastUsedStub(ctx, id)
+}
+func (s *StorageMock) DeleteAccessToken(ctx context.Context, userId, id int64) error {
+	return s.DeleteAccessTokenStub(ctx, userId, id)
+}
+func (s *StorageMock) GetUserProfile(ctx context.Context, userId int64) (*database.UserProfile, error) {
+	return s.GetUserProfileStub(ctx, userId)
+}
+func (s *StorageMock) RecordUserLogin(ctx context.Context, profile database.UserProfile) error {
+	return s.RecordUserLoginStub(ctx, profile)
+}
+func (s *StorageMock) AddLoginEvent(ctx context.Context, event database.LoginEvent) error {
+	return s.AddLoginEventStub(ctx, event)
+}
+func (s *StorageMock) GetLoginHistory(ctx context.Context, userId int64, limit int) ([]database.LoginEvent, error) {
+	return s.GetLoginHistoryStub(ctx, userId, limit)
+}
+func (s *StorageMock) GetPreferences(ctx context.Context, userId int64) (database.Preferences, error) {
+	return s.GetPreferencesStub(ctx, userId)
+}
+func (s *StorageMock) UpsertPreferences(ctx context.Context, userId int64, preferences database.Preferences) error {
+	return s.UpsertPreferencesStub(ctx, userId, preferences)
+}
+func (s *StorageMock) CreateChannelGroup(ctx context.Context, userId int64, name string) (int64, error) {
+	return s.CreateChannelGroupStub(ctx, userId, name)
+}
+func (s *StorageMock) GetChannelGroups(ctx context.Context, userId int64) ([]database.ChannelGroup, error) {
+	return s.GetChannelGroupsStub(ctx, userId)
+}
+func (s *StorageMock) RenameChannelGroup(ctx context.Context, userId, id int64, name string) error {
+	return s.RenameChannelGroupStub(ctx, userId, id, name)
+}
+func (s *StorageMock) DeleteChannelGroup(ctx context.Context, userId, id int64) error {
+	return s.DeleteChannelGroupStub(ctx, userId, id)
+}
+func (s *StorageMock) AddChannelsToGroup(ctx context.Context, userId, groupId int64, channelIDs []string) error {
+	return s.AddChannelsToGroupStub(ctx, userId, groupId, channelIDs)
+}
+func (s *StorageMock) RemoveChannelsFromGroup(ctx context.Context, userId, groupId int64, channelIDs []string) error {
+	return s.RemoveChannelsFromGroupStub(ctx, userId, groupId, channelIDs)
+}
+func (s *StorageMock) UpsertChannelRule(ctx context.Context, rule database.ChannelRule) error {
+	return s.UpsertChannelRuleStub(ctx, rule)
+}
+func (s *StorageMock) GetChannelRules(ctx context.Context, userId int64) ([]database.ChannelRule, error) {
+	return s.GetChannelRulesStub(ctx, userId)
+}
+func (s *StorageMock) DeleteChannelRule(ctx context.Context, userId, id int64) error {
+	return s.DeleteChannelRuleStub(ctx, userId, id)
+}
+func (s *StorageMock) CreateVideoRule(ctx context.Context, rule database.VideoRule) (int64, error) {
+	return s.CreateVideoRuleStub(ctx, rule)
+}
+func (s *StorageMock) GetVideoRules(ctx context.Context, userId int64) ([]database.VideoRule, error) {
+	return s.GetVideoRulesStub(ctx, userId)
+}
+func (s *StorageMock) DeleteVideoRule(ctx context.Context, userId, id int64) error {
+	return s.DeleteVideoRuleStub(ctx, userId, id)
+}
+func (s *StorageMock) CreateAlert(ctx context.Context, userId int64, query string) (int64, error) {
+	return s.CreateAlertStub(ctx, userId, query)
+}
+func (s *StorageMock) GetAlerts(ctx context.Context, userId int64) ([]database.Alert, error) {
+	return s.GetAlertsStub(ctx, userId)
+}
+func (s *StorageMock) MarkAlertsChecked(ctx context.Context, userId int64, checkedAt time.Time) error {
+	return s.MarkAlertsCheckedStub(ctx, userId, checkedAt)
+}
+func (s *StorageMock) DeleteAlert(ctx context.Context, userId, id int64) error {
+	return s.DeleteAlertStub(ctx, userId, id)
+}
+func (s *StorageMock) AddAlertMatches(ctx context.Context,
+	matches []database.AlertMatch) ([]database.AlertMatch, error) {
+	return s.AddAlertMatchesStub(ctx, matches)
+}
+func (s *StorageMock) GetAlertMatches(ctx context.Context, userId int64, limit int) ([]database.AlertMatch, error) {
+	return s.GetAlertMatchesStub(ctx, userId, limit)
+}
+func (s *StorageMock) CountUnreadAlertMatches(ctx context.Context, userId int64) (int, error) {
+	return s.CountUnreadAlertMatchesStub(ctx, userId)
+}
+func (s *StorageMock) MarkAlertMatchesRead(ctx context.Context, userId int64) error {
+	return s.MarkAlertMatchesReadStub(ctx, userId)
+}
+func (s *StorageMock) UpsertVideos(ctx context.Context, userId int64, videos []database.Video) error {
+	return s.UpsertVideosStub(ctx, userId, videos)
+}
+func (s *StorageMock) GetVideoChannels(ctx context.Context, userId int64) ([]database.VideoChannel, error) {
+	return s.GetVideoChannelsStub(ctx, userId)
+}
+func (s *StorageMock) SearchVideos(ctx context.Context,
+	search database.VideoSearch) ([]database.VideoSearchResult, error) {
+	return s.SearchVideosStub(ctx, search)
+}
+func (s *StorageMock) LockSubscribedChannels(ctx context.Context, userId int64, accountId string) error {
+	return s.LockSubscribedChannelsStub(ctx, userId, accountId)
+}
+func (s *StorageMock) GetSubscribedChannels(ctx context.Context, userId int64,
+	accountId string) ([]database.SubscribedChannel, error) {
+	return s.GetSubscribedChannelsStub(ctx, userId, accountId)
+}
+func (s *StorageMock) HasSubscriptionSnapshot(ctx context.Context, userId int64, accountId string) (bool, error) {
+	return s.HasSubscriptionSnapshotStub(ctx, userId, accountId)
+}
+func (s *StorageMock) ReplaceSubscribedChannels(ctx context.Context, userId int64, accountId string,
+	channels []database.SubscribedChannel) error {
+	return s.ReplaceSubscribedChannelsStub(ctx, userId, accountId, channels)
+}
+func (s *StorageMock) AddSubscriptionChanges(ctx context.Context, changes []database.SubscriptionChange) error {
+	return s.AddSubscriptionChangesStub(ctx, changes)
+}
+func (s *StorageMock) GetSubscriptionChanges(ctx context.Context, userId int64,
+	limit int) ([]database.SubscriptionChange, error) {
+	return s.GetSubscriptionChangesStub(ctx, userId, limit)
+}
+func (s *StorageMock) GetFollowedChannels(ctx context.Context, userId int64) ([]database.FollowedChannel, error) {
+	return s.GetFollowedChannelsStub(ctx, userId)
+}
+func (s *StorageMock) AddFollowedChannels(ctx context.Context, userId int64,
+	channels []database.FollowedChannel) (int, error) {
+	return s.AddFollowedChannelsStub(ctx, userId, channels)
+}
+func (s *StorageMock) DeleteFollowedChannel(ctx context.Context, userId int64, channelId string) error {
+	return s.DeleteFollowedChannelStub(ctx, userId, channelId)
+}
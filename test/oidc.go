@@ -0,0 +1,44 @@
+package test
+
+import (
+	"checkYoutube/test/mockoauth"
+	"net/http/httptest"
+	"testing"
+)
+
+// OIDCProviderMock is a local OpenID Connect provider stand-in, publishing its signing key on a JWKS endpoint
+type OIDCProviderMock struct {
+	*mockoauth.Server
+	HTTPServer *httptest.Server
+}
+
+// NewOIDCProviderMock starts a new OIDCProviderMock, that is closed when the test ends
+func NewOIDCProviderMock(t *testing.T) *OIDCProviderMock {
+	server, err := mockoauth.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	server.Issuer = httpServer.URL
+
+	return &OIDCProviderMock{
+		Server:     server,
+		HTTPServer: httpServer,
+	}
+}
+
+// JWKSURL returns the URL of the provider JWKS endpoint
+func (o *OIDCProviderMock) JWKSURL() string {
+	return o.Issuer + "/jwks"
+}
+
+// SignIDToken returns an RS256 ID token having the given claims, signed with the provider key
+func (o *OIDCProviderMock) SignIDToken(t *testing.T, claims map[string]any) string {
+	idToken, err := o.Server.SignIDToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idToken
+}
@@ -7,3 +7,27 @@ var StaticContent embed.FS
 
 //go:embed template/htmlTemplate.tmpl
 var HtmlTemplate []byte
+
+//go:embed template/settingsTemplate.tmpl
+var SettingsTemplate []byte
+
+//go:embed template/accountTemplate.tmpl
+var AccountTemplate []byte
+
+//go:embed template/rulesTemplate.tmpl
+var RulesTemplate []byte
+
+//go:embed template/alertsTemplate.tmpl
+var AlertsTemplate []byte
+
+//go:embed template/searchTemplate.tmpl
+var SearchTemplate []byte
+
+//go:embed template/subscriptionsTemplate.tmpl
+var SubscriptionsTemplate []byte
+
+//go:embed template/inactiveChannelsTemplate.tmpl
+var InactiveChannelsTemplate []byte
+
+//go:embed template/subscriptionHistoryTemplate.tmpl
+var SubscriptionHistoryTemplate []byte
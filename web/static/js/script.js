@@ -1,19 +1,17 @@
+// progressive enhancement of the page rendered by the server: filtering, sorting and paging work without JavaScript
 function jsScript() {
     const serverBasepath = document.querySelector('meta[name="server-basepath"]')
         .getAttribute('content');
 
-    // handle filters events
-    handleFilters()
-
     // convert timestamps to locale
     convertTimestampsToLocale()
 
+    // sort the rows without reloading the page when they all fit in it
+    sortByColumn()
+
     // hadle mark as viewed buttons event
     markAsViewed(serverBasepath)
     markAllAsViewed(serverBasepath)
-
-    // sort table by column on click
-    sortByColumn(serverBasepath)
 }
 
 // call the backend endpoint and remove table row when user clicks on "mark as viewed"
@@ -44,18 +42,17 @@ function markAsViewed(serverBasepath) {
     });
 }
 function markAllAsViewed(serverBasepath) {
-    // collect all channels IDs
-    const tableContentRows = document.querySelectorAll('table#videos-table tbody tr')
-    let channelsID = [];
-    tableContentRows.forEach((tr) => {
-        channelsID.push(tr.dataset.channelid);
-    });
+    // the button is shown only in the filtered view
+    const markAllAsViewedButton = document.querySelector('button#mark-all-as-viewed')
+    if (markAllAsViewedButton === null) {
+        return;
+    }
 
+    // collect the IDs of the channels in all the pages, of the selected group only when filtered by group
+    let channelsID = markAllAsViewedButton.dataset.channelids.split(' ').filter((channelID) => channelID !== '');
     if (channelsID.length === 0) {
         return;
     }
-
-    const markAllAsViewedButton = document.querySelector('button#mark-all-as-viewed')
     markAllAsViewedButton.addEventListener('click', async function() {
         try {
             // Frontend-based workaround
@@ -85,88 +82,75 @@ function visitChannel(channelID) {
     return new Promise(resolve => setTimeout(resolve, 1));
 }
 
-// handle results filters
-function handleFilters() {
-    // reload page when user clicks on "show all" or "show filtered"
-    document.getElementById("filters-div").addEventListener("click", function(e) {
-        if(e.target.id === "show-all-btn") {
-            window.open(window.location.href.split('?')[0],'_self');
-        } else {
-            window.open('?filtered=true','_self');
-        }
-    });
-
-    // highlight the proper filters button and update info text
-    let params = new URLSearchParams(document.location.search);
-    let filtered = params.get("filtered");
-    if (filtered === "true") {
-        document.getElementById("show-filtered-btn").classList.add("active");
-        document.getElementById("show-all-btn").classList.remove("active");
-        document.getElementById("channels-info-span").textContent="# of channels with new videos:";
-    } else {
-        document.getElementById("show-all-btn").classList.add("active");
-        document.getElementById("show-filtered-btn").classList.remove("active");
-        document.getElementById("channels-info-span").textContent="# of channels:";
-        document.querySelectorAll(".mark-as-viewed").forEach(el => el.remove());
-        document.getElementById("mark-all-as-viewed").remove();
+// re-sort the table rows in place as the server would when clicking a column header, the header links are followed
+// when the rows are split in pages, sorting them all being left to the server
+function sortByColumn() {
+    const table = document.getElementById('videos-table');
+    if (table === null || table.dataset.pages !== '1') {
+        return;
     }
+    const links = table.querySelectorAll('th.sortable a');
+    links.forEach((link) => {
+        link.addEventListener('click', function(event) {
+            event.preventDefault();
+            const url = new URL(link.href);
+            const column = url.searchParams.get('sort');
+            const descending = url.searchParams.get('dir') === 'desc';
+
+            // same order as the server: by column, then by channel title, the whole reversed when descending
+            const key = (row, name) => row.getAttribute('data-sort-' + name);
+            const tbody = table.querySelector('tbody');
+            const rows = Array.from(tbody.rows);
+            rows.sort((a, b) => {
+                let result = 0;
+                if (column === 'duration' || column === 'new_items') {
+                    result = Number(key(a, column)) - Number(key(b, column));
+                } else if (column === 'published') {
+                    result = key(a, column) < key(b, column) ? -1 : key(a, column) > key(b, column) ? 1 : 0;
+                }
+                if (result === 0) {
+                    result = key(a, 'channel').toLowerCase().localeCompare(key(b, 'channel').toLowerCase());
+                }
+                return descending ? -result : result;
+            });
+            rows.forEach((row) => tbody.appendChild(row));
+
+            // update the address, the sort fields of the forms, the arrows and the links as if reloaded
+            history.replaceState(null, '', url);
+            document.querySelectorAll('input[name="sort"]').forEach((input) => input.value = column);
+            const direction = descending ? 'desc' : 'asc';
+            document.querySelectorAll('input[name="dir"]').forEach((input) => input.value = direction);
+            document.querySelectorAll('input[name="return_to"]').forEach((input) => {
+                const returnTo = new URL(input.value, window.location.origin);
+                returnTo.searchParams.set('sort', column);
+                returnTo.searchParams.set('dir', direction);
+                input.value = returnTo.pathname + returnTo.search;
+            });
+            links.forEach((other) => {
+                const otherURL = new URL(other.href);
+                const otherColumn = otherURL.searchParams.get('sort');
+                const arrow = other.querySelector('span.sort-arrow');
+                if (otherColumn === column) {
+                    otherURL.searchParams.set('dir', descending ? 'asc' : 'desc');
+                    arrow.textContent = descending ? '↓' : '↑';
+                } else {
+                    otherURL.searchParams.set('dir', otherColumn === 'channel' ? 'asc' : 'desc');
+                    arrow.textContent = '⇅';
+                }
+                other.href = otherURL.toString();
+            });
+        });
+    });
 }
 
-// convert timestamps to locale
+// convert timestamps to locale, in the timezone of the user preferences if set
 function convertTimestampsToLocale() {
+    const timezone = document.querySelector('meta[name="timezone"]').getAttribute('content');
+    const options = timezone ? {timeZone: timezone} : undefined;
     const timestampElements = document.querySelectorAll('span[data-ts]');
     timestampElements.forEach((element) => {
-        element.innerText = new Date(element.dataset.ts).toLocaleString();
-    });
-}
-
-// sort results by column on click
-function sortByColumn(serverBasepath) {
-    const downArrow = "↓";
-    const upArrow = "↑";
-    const doubleArrow = "⇅";
-    const separator  = '-';
-    const table = document.getElementById("videos-table");
-
-    table.querySelectorAll('th.sortable').forEach((th) => {
-        th.addEventListener('click', function() {
-            let values = [];
-            let rowsMap = {}; // key = channel name, value = html of the row
-            let sortArrow = this.querySelector('span.sort-arrow');
-
-            // collect values to sort from each table row
-            table.querySelectorAll('tbody tr').forEach((row, index) => {
-                const cell = row.children[th.cellIndex]
-                const content = cell.querySelector('span[data-ts]') != null
-                    ? cell.querySelector('span[data-ts]').dataset.ts
-                    : cell.textContent.toLowerCase();
-                values.push(content + separator + index);
-                rowsMap[content + separator + index] = row.outerHTML;
-            });
-
-            // sort elements
-            if (sortArrow.innerHTML === upArrow) {
-                // descending order
-                values.sort((a, b) => b.localeCompare(a));
-                sortArrow.innerHTML = downArrow;
-            } else {
-                // ascending order
-                values.sort();
-                sortArrow.innerHTML = upArrow;
-            }
-
-            // change the sort arrow of other th elements to double arrow
-            table.querySelectorAll(`th.sortable:not(#${th.id})`).forEach((th) => {
-                th.querySelector('span.sort-arrow').innerHTML = doubleArrow;
-            });
-
-            // update table body
-            let tableBody = '';
-            values.forEach(key => tableBody += rowsMap[key]);
-            table.getElementsByTagName('tbody')[0].innerHTML = tableBody;
-
-            // reapply markAsViewed event listener to table elements
-            markAsViewed(serverBasepath);
-        });
+        if (element.dataset.ts) {
+            element.innerText = new Date(element.dataset.ts).toLocaleString(undefined, options);
+        }
     });
 }